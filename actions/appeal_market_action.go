@@ -0,0 +1,154 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/wrappers"
+	"github.com/ava-labs/hypersdk/chain"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/ava-labs/hypersdk/state"
+
+	"github.com/chokosabe/predictionvm/consts"
+	"github.com/chokosabe/predictionvm/storage"
+)
+
+const (
+	AppealMarketComputeUnits = 1000
+	MaxAppealMarketSize      = 64
+)
+
+var (
+	ErrUnmarshalEmptyAppealMarket              = errors.New("cannot unmarshal empty bytes as AppealMarket action")
+	ErrMarketNotAppealable                     = errors.New("market resolution cannot be appealed")
+	ErrInvalidProposedOutcome                  = errors.New("proposed outcome must differ from the challenged ruling")
+	_                             chain.Action = (*AppealMarket)(nil)
+)
+
+// AppealMarket escalates the stakers' ruling on a dispute, including an
+// Invalid one, to a second vote of the PRED stakers. It can be submitted
+// during the dispute window following that ruling; the actor escrows the
+// chain's appeal bond and proposes the outcome the market should resolve to
+// instead (YES, NO or Invalid).
+type AppealMarket struct {
+	MarketID        uint64 `serialize:"true" json:"marketId"`
+	ProposedOutcome uint8  `serialize:"true" json:"proposedOutcome"`
+}
+
+func (*AppealMarket) GetTypeID() uint8 {
+	return consts.AppealMarketID
+}
+
+// Bytes serializes the AppealMarket action.
+func (a *AppealMarket) Bytes() []byte {
+	p := &wrappers.Packer{
+		Bytes:   make([]byte, 0, MaxAppealMarketSize),
+		MaxSize: MaxAppealMarketSize,
+	}
+	p.PackByte(consts.AppealMarketID)
+	if err := codec.LinearCodec.MarshalInto(a, p); err != nil {
+		panic(fmt.Errorf("failed to marshal AppealMarket action: %w", err))
+	}
+	return p.Bytes
+}
+
+// UnmarshalAppealMarket deserializes bytes into an AppealMarket action.
+func UnmarshalAppealMarket(bytes []byte) (chain.Action, error) {
+	if len(bytes) == 0 {
+		return nil, ErrUnmarshalEmptyAppealMarket
+	}
+	if bytes[0] != consts.AppealMarketID {
+		return nil, fmt.Errorf("unexpected AppealMarket typeID: %d != %d", bytes[0], consts.AppealMarketID)
+	}
+	a := &AppealMarket{}
+	if err := codec.LinearCodec.UnmarshalFrom(
+		&wrappers.Packer{Bytes: bytes[1:]},
+		a,
+	); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal AppealMarket action: %w", err)
+	}
+	return a, nil
+}
+
+// StateKeys implements chain.Action
+func (a *AppealMarket) StateKeys(actor codec.Address, _ ids.ID) state.Keys {
+	return withStatusIndexKeys(state.Keys{
+		string(storage.MarketKey(a.MarketID)):      state.Read | state.Write,
+		string(storage.MarketStateKey(a.MarketID)): state.All,
+		string(storage.AppealKey(a.MarketID)):      state.All,
+		string(storage.BalanceKey(actor)):          state.Read | state.Write,
+	}, a.MarketID,
+		storage.MarketStatus_ResolvedYes, storage.MarketStatus_ResolvedNo, storage.MarketStatus_ResolvedInvalid,
+		storage.MarketStatus_Appealed,
+	)
+}
+
+// Execute escrows the bond and opens the vote on the appeal.
+func (a *AppealMarket) Execute(
+	ctx context.Context,
+	rules chain.Rules,
+	mu state.Mutable,
+	timestamp int64,
+	actor codec.Address,
+	_ ids.ID,
+) ([]byte, error) {
+	market, err := storage.GetMarket(ctx, mu, a.MarketID)
+	if err != nil {
+		return nil, err
+	}
+	if market.OracleType != consts.OracleTypeManual {
+		return nil, fmt.Errorf("%w: market %d is resolved by oracle type %d", ErrMarketNotAppealable, a.MarketID, market.OracleType)
+	}
+	if !market.Status.IsResolved() {
+		return nil, fmt.Errorf("%w: market %d (status: %s)", ErrMarketNotAppealable, a.MarketID, market.Status)
+	}
+	if market.Tier != storage.Tier_Dispute {
+		return nil, fmt.Errorf("%w: market %d is at tier %d, only rulings on disputes can be appealed", ErrMarketNotAppealable, a.MarketID, market.Tier)
+	}
+	if appealableUntil := market.ResolvedAt + DisputeWindow(rules); timestamp > appealableUntil {
+		return nil, fmt.Errorf("%w: market %d could be appealed until %d (current: %d)", ErrDisputeWindowClosed, a.MarketID, appealableUntil, timestamp)
+	}
+	proposed := storage.OutcomeType(a.ProposedOutcome)
+	switch proposed {
+	case storage.Outcome_Yes, storage.Outcome_No, storage.Outcome_Invalid:
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrInvalidOutcome, proposed)
+	}
+	if proposed == market.ResolvedOutcome {
+		return nil, fmt.Errorf("%w: market %d resolved %s", ErrInvalidProposedOutcome, a.MarketID, proposed)
+	}
+
+	bond := AppealBond(rules)
+	if err := storage.DeductBalance(ctx, mu, actor, bond); err != nil {
+		return nil, fmt.Errorf("failed to escrow appeal bond %d from %s: %w", bond, actor, err)
+	}
+	if err := storage.SetAppeal(ctx, mu, &storage.Appeal{
+		MarketID:        a.MarketID,
+		Appellant:       actor,
+		Bond:            bond,
+		AppealedOutcome: market.ResolvedOutcome,
+		ProposedOutcome: proposed,
+		VotingEndsAt:    timestamp + AppealVotingPeriod(rules),
+		Status:          storage.DisputeStatus_Pending,
+	}); err != nil {
+		return nil, err
+	}
+
+	market.Status = storage.MarketStatus_Appealed
+	if err := storage.SetMarket(ctx, mu, market); err != nil {
+		return nil, fmt.Errorf("failed to update appealed market %d: %w", a.MarketID, err)
+	}
+	return nil, nil
+}
+
+// ComputeUnits implements chain.Action
+func (*AppealMarket) ComputeUnits(chain.Rules) uint64 {
+	return AppealMarketComputeUnits
+}
+
+// ValidRange implements chain.Action
+func (*AppealMarket) ValidRange(chain.Rules) (int64, int64) {
+	return -1, -1 // Always valid
+}
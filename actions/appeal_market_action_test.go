@@ -0,0 +1,462 @@
+package actions
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/hypersdk/chain/chaintest"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chokosabe/predictionvm/consts"
+	"github.com/chokosabe/predictionvm/storage"
+)
+
+var (
+	testStakerA = codec.Address{0x0a}
+	testStakerB = codec.Address{0x0b}
+)
+
+// appealRulingTime is when the stakers rule on the dispute in
+// setDisputedRuling, and appealVotingEnd when voting on an appeal submitted at
+// that time ends.
+const (
+	appealRulingTime = disputeVotingEnd
+	appealVotingEnd  = appealRulingTime + consts.DefaultAppealVotingPeriod
+)
+
+// setDisputedRuling stores a manual market that testDisputer disputed and the
+// stakers ruled YES on again at appealRulingTime, testStakerB having voted to
+// uphold the ruling. testDisputer can afford the appeal bond.
+func setDisputedRuling(t *testing.T, mu *chaintest.InMemoryStore) {
+	require := require.New(t)
+	setResolvedManualMarket(t, mu)
+	disputeAndVote(t, mu, storage.Outcome_No, map[codec.Address]bool{testStakerB: false})
+	_, err := execute(t, &FinalizeAppeal{MarketID: 1, Oracle: testOracle}, &MockRules{}, mu, appealRulingTime, testDisputer, ids.Empty)
+	require.NoError(err)
+	require.NoError(storage.SetBalance(context.Background(), mu, testDisputer, consts.DefaultAppealBond))
+}
+
+// appealAndVote appeals the ruling of setDisputedRuling, proposing [proposed],
+// and casts the given votes.
+func appealAndVote(t *testing.T, mu *chaintest.InMemoryStore, proposed storage.OutcomeType, votes map[codec.Address]bool) {
+	require := require.New(t)
+	_, err := execute(t, &AppealMarket{MarketID: 1, ProposedOutcome: uint8(proposed)}, &MockRules{}, mu, appealRulingTime, testDisputer, ids.Empty)
+	require.NoError(err)
+	for voter, overturn := range votes {
+		_, err = execute(t, &VoteAppeal{MarketID: 1, Tier: storage.Tier_Appeal, Overturn: overturn}, &MockRules{}, mu, appealRulingTime+1, voter, ids.Empty)
+		require.NoError(err)
+	}
+}
+
+func TestStake_Execute(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	mu := chaintest.NewInMemoryStore()
+	require.NoError(storage.SetBalance(ctx, mu, testStakerA, 100))
+
+	_, err := execute(t, &Stake{}, &MockRules{}, mu, 0, testStakerA, ids.Empty)
+	require.ErrorIs(err, ErrZeroStakeAmount)
+	_, err = execute(t, &Stake{Amount: 101}, &MockRules{}, mu, 0, testStakerA, ids.Empty)
+	require.ErrorIs(err, storage.ErrInsufficientBalance)
+
+	_, err = execute(t, &Stake{Amount: 60}, &MockRules{}, mu, 0, testStakerA, ids.Empty)
+	require.NoError(err)
+	_, err = execute(t, &Stake{Amount: 40}, &MockRules{}, mu, 0, testStakerA, ids.Empty)
+	require.NoError(err)
+	requireBalance(t, mu, testStakerA, 0)
+	stake, err := storage.GetStake(ctx, mu, testStakerA)
+	require.NoError(err)
+	require.Equal(&storage.Stake{Amount: 100}, stake)
+
+	_, err = execute(t, &Unstake{Amount: 101}, &MockRules{}, mu, 0, testStakerA, ids.Empty)
+	require.ErrorIs(err, ErrInsufficientStake)
+	_, err = execute(t, &Unstake{Amount: 100}, &MockRules{}, mu, 0, testStakerA, ids.Empty)
+	require.NoError(err)
+	requireBalance(t, mu, testStakerA, 100)
+	_, err = mu.GetValue(ctx, storage.StakeKey(testStakerA))
+	require.Error(err, "Fully withdrawn stakes are removed")
+}
+
+func TestAppealMarket_Execute_Overturned(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	mu := chaintest.NewInMemoryStore()
+	setDisputedRuling(t, mu)
+	appealAndVote(t, mu, storage.Outcome_No, map[codec.Address]bool{testStakerA: true, testStakerB: false})
+	requireBalance(t, mu, testDisputer, 0)
+	requireOracleStats(t, mu, storage.OracleStats{Resolved: 1, Disputed: 1, TotalLatency: 2_000, SettledCollateral: 500})
+
+	market, err := storage.GetMarket(ctx, mu, 1)
+	require.NoError(err)
+	require.Equal(storage.MarketStatus_Appealed, market.Status)
+
+	// Appealed markets cannot be claimed, expired or resolved by the oracle.
+	_, err = execute(t, &Claim{MarketID: 1}, &MockRules{}, mu, appealVotingEnd, testStakerA, ids.Empty)
+	require.ErrorIs(err, ErrMarketNotResolved)
+	_, err = execute(t, &ExpireMarket{MarketID: 1}, &MockRules{}, mu, appealRulingTime+consts.DefaultResolutionGracePeriod+1, testStakerA, ids.Empty)
+	require.ErrorIs(err, ErrMarketAlreadyResolved)
+	_, err = execute(t, &ResolveMarket{MarketID: 1, Outcome: uint8(storage.Outcome_No)}, &MockRules{}, mu, appealRulingTime+1, testOracle, ids.Empty)
+	require.ErrorIs(err, ErrMarketAlreadyResolved)
+
+	// Voting stakes are locked until voting ends.
+	_, err = execute(t, &Unstake{Amount: 1}, &MockRules{}, mu, appealVotingEnd-1, testStakerA, ids.Empty)
+	require.ErrorIs(err, ErrStakeLocked)
+	_, err = execute(t, &FinalizeAppeal{MarketID: 1, Oracle: testOracle}, &MockRules{}, mu, appealVotingEnd-1, testStakerA, ids.Empty)
+	require.ErrorIs(err, ErrAppealVotingOpen)
+
+	_, err = execute(t, &FinalizeAppeal{MarketID: 1, Oracle: testOracle}, &MockRules{}, mu, appealVotingEnd, testStakerA, ids.Empty)
+	require.NoError(err)
+	requireOracleStats(t, mu, storage.OracleStats{Resolved: 1, Disputed: 1, DisputesLost: 1, TotalLatency: 2_000, SettledCollateral: 500, OverturnedCollateral: 500})
+
+	market, err = storage.GetMarket(ctx, mu, 1)
+	require.NoError(err)
+	require.Equal(storage.MarketStatus_ResolvedNo, market.Status)
+	require.Equal(storage.Outcome_No, market.ResolvedOutcome)
+	require.Equal(storage.Tier_Appeal, market.Tier)
+	require.Equal(appealVotingEnd, market.ResolvedAt)
+
+	appeal, err := storage.GetAppeal(ctx, mu, 1)
+	require.NoError(err)
+	require.Equal(storage.DisputeStatus_Upheld, appeal.Status)
+	require.Equal(uint64(100), appeal.OverturnVotes)
+	require.Equal(uint64(60), appeal.UpholdVotes)
+
+	// Both challenges of the YES ruling were right, so both bonds go back to
+	// testDisputer, immediately since the appeal's ruling is final.
+	dispute, err := storage.GetDispute(ctx, mu, 1)
+	require.NoError(err)
+	require.Equal(storage.DisputeStatus_Upheld, dispute.Status)
+	for _, tier := range []uint8{storage.Tier_Dispute, storage.Tier_Appeal} {
+		_, err = execute(t, &WithdrawBond{MarketID: 1, Tier: tier}, &MockRules{}, mu, appealVotingEnd, testOracle, ids.Empty)
+		require.ErrorIs(err, ErrNotBondBeneficiary)
+		_, err = execute(t, &WithdrawBond{MarketID: 1, Tier: tier}, &MockRules{}, mu, appealVotingEnd, testDisputer, ids.Empty)
+		require.NoError(err)
+	}
+	requireBalance(t, mu, testDisputer, consts.DefaultDisputeBond+consts.DefaultAppealBond)
+
+	_, err = execute(t, &Unstake{Amount: 100}, &MockRules{}, mu, appealVotingEnd, testStakerA, ids.Empty)
+	require.NoError(err)
+	requireBalance(t, mu, testStakerA, 100)
+}
+
+func TestAppealMarket_Execute_OverturnedToInvalid(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	mu := chaintest.NewInMemoryStore()
+	setDisputedRuling(t, mu)
+	appealAndVote(t, mu, storage.Outcome_Invalid, map[codec.Address]bool{testStakerB: true})
+
+	_, err := execute(t, &FinalizeAppeal{MarketID: 1, Oracle: testOracle}, &MockRules{}, mu, appealVotingEnd, testDisputer, ids.Empty)
+	require.NoError(err)
+
+	market, err := storage.GetMarket(ctx, mu, 1)
+	require.NoError(err)
+	require.Equal(storage.MarketStatus_ResolvedInvalid, market.Status)
+	require.Equal(storage.Outcome_Invalid, market.ResolvedOutcome)
+
+	dispute, err := storage.GetDispute(ctx, mu, 1)
+	require.NoError(err)
+	require.Equal(storage.DisputeStatus_Upheld, dispute.Status, "The disputed YES ruling was overturned")
+}
+
+func TestAppealMarket_Execute_InvalidRuling(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	mu := chaintest.NewInMemoryStore()
+	setResolvedManualMarket(t, mu)
+
+	// The stakers rule the market Invalid on dispute...
+	disputeAndVote(t, mu, storage.Outcome_Invalid, map[codec.Address]bool{testStakerA: true})
+	_, err := execute(t, &FinalizeAppeal{MarketID: 1, Oracle: testOracle}, &MockRules{}, mu, appealRulingTime, testDisputer, ids.Empty)
+	require.NoError(err)
+	market, err := storage.GetMarket(ctx, mu, 1)
+	require.NoError(err)
+	require.Equal(storage.MarketStatus_ResolvedInvalid, market.Status)
+	require.Equal(storage.Tier_Dispute, market.Tier)
+
+	// ...which is not final while it can still be appealed.
+	require.False(IsFinal(&MockRules{}, market, appealRulingTime+consts.DefaultDisputeWindow))
+	require.True(IsFinal(&MockRules{}, market, appealRulingTime+consts.DefaultDisputeWindow+1))
+	_, err = execute(t, &Claim{MarketID: 1}, &MockRules{}, mu, appealRulingTime, testStakerA, ids.Empty)
+	require.ErrorIs(err, ErrDisputeWindowOpen)
+
+	// The appeal restores the oracle's YES ruling.
+	require.NoError(storage.SetBalance(ctx, mu, testStakerB, consts.DefaultAppealBond))
+	_, err = execute(t, &AppealMarket{MarketID: 1, ProposedOutcome: uint8(storage.Outcome_Yes)}, &MockRules{}, mu, appealRulingTime, testStakerB, ids.Empty)
+	require.NoError(err)
+	market, err = storage.GetMarket(ctx, mu, 1)
+	require.NoError(err)
+	require.Equal(storage.MarketStatus_Appealed, market.Status)
+
+	_, err = execute(t, &VoteAppeal{MarketID: 1, Tier: storage.Tier_Appeal, Overturn: true}, &MockRules{}, mu, appealRulingTime+1, testStakerA, ids.Empty)
+	require.NoError(err)
+	_, err = execute(t, &FinalizeAppeal{MarketID: 1, Oracle: testOracle}, &MockRules{}, mu, appealVotingEnd, testDisputer, ids.Empty)
+	require.NoError(err)
+	market, err = storage.GetMarket(ctx, mu, 1)
+	require.NoError(err)
+	require.Equal(storage.MarketStatus_ResolvedYes, market.Status)
+	require.Equal(storage.Tier_Appeal, market.Tier)
+	require.True(IsFinal(&MockRules{}, market, appealVotingEnd))
+}
+
+func TestAppealMarket_Execute_RestoresInitialRuling(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	mu := chaintest.NewInMemoryStore()
+	setResolvedManualMarket(t, mu)
+
+	// The stakers first overturn the oracle's YES ruling...
+	disputeAndVote(t, mu, storage.Outcome_No, map[codec.Address]bool{testStakerA: true})
+	_, err := execute(t, &FinalizeAppeal{MarketID: 1, Oracle: testOracle}, &MockRules{}, mu, appealRulingTime, testDisputer, ids.Empty)
+	require.NoError(err)
+	requireOracleStats(t, mu, storage.OracleStats{Resolved: 1, Disputed: 1, DisputesLost: 1, TotalLatency: 2_000, SettledCollateral: 500, OverturnedCollateral: 500})
+
+	// ...then restore it on appeal, voting again at the appeal tier.
+	require.NoError(storage.SetBalance(ctx, mu, testStakerB, consts.DefaultAppealBond))
+	_, err = execute(t, &AppealMarket{MarketID: 1, ProposedOutcome: uint8(storage.Outcome_Yes)}, &MockRules{}, mu, appealRulingTime, testStakerB, ids.Empty)
+	require.NoError(err)
+	for voter, overturn := range map[codec.Address]bool{testStakerA: true, testStakerB: false} {
+		_, err = execute(t, &VoteAppeal{MarketID: 1, Tier: storage.Tier_Appeal, Overturn: overturn}, &MockRules{}, mu, appealRulingTime+1, voter, ids.Empty)
+		require.NoError(err)
+	}
+	_, err = execute(t, &FinalizeAppeal{MarketID: 1, Oracle: testOracle}, &MockRules{}, mu, appealVotingEnd, testDisputer, ids.Empty)
+	require.NoError(err)
+	requireOracleStats(t, mu, storage.OracleStats{Resolved: 1, Disputed: 1, TotalLatency: 2_000, SettledCollateral: 500})
+
+	market, err := storage.GetMarket(ctx, mu, 1)
+	require.NoError(err)
+	require.Equal(storage.MarketStatus_ResolvedYes, market.Status)
+	require.Equal(storage.Tier_Appeal, market.Tier)
+
+	// The dispute is re-settled against the final outcome, so the oracle
+	// collects the dispute bond and testStakerB gets the appeal bond back.
+	dispute, err := storage.GetDispute(ctx, mu, 1)
+	require.NoError(err)
+	require.Equal(storage.DisputeStatus_Rejected, dispute.Status)
+	_, err = execute(t, &WithdrawBond{MarketID: 1, Tier: storage.Tier_Dispute}, &MockRules{}, mu, appealVotingEnd, testOracle, ids.Empty)
+	require.NoError(err)
+	_, err = execute(t, &WithdrawBond{MarketID: 1, Tier: storage.Tier_Appeal}, &MockRules{}, mu, appealVotingEnd, testStakerB, ids.Empty)
+	require.NoError(err)
+	requireBalance(t, mu, testOracle, consts.DefaultDisputeBond)
+	requireBalance(t, mu, testStakerB, consts.DefaultAppealBond)
+}
+
+func TestAppealMarket_Execute_Rejected(t *testing.T) {
+	ctx := context.Background()
+
+	testCases := []struct {
+		name   string
+		extraB uint64 // Added to testStakerB's stake before the appeal
+		votes  map[codec.Address]bool
+	}{
+		{
+			name:  "NoVotes",
+			votes: map[codec.Address]bool{},
+		},
+		{
+			name:  "MajorityUpholds",
+			votes: map[codec.Address]bool{testStakerA: false, testStakerB: true},
+		},
+		{
+			name:   "Tie",
+			extraB: 40,
+			votes:  map[codec.Address]bool{testStakerA: true, testStakerB: false},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+			mu := chaintest.NewInMemoryStore()
+			setDisputedRuling(t, mu)
+			if tc.extraB > 0 {
+				require.NoError(storage.SetBalance(ctx, mu, testStakerB, tc.extraB))
+				_, err := execute(t, &Stake{Amount: tc.extraB}, &MockRules{}, mu, 0, testStakerB, ids.Empty)
+				require.NoError(err)
+			}
+			appealAndVote(t, mu, storage.Outcome_No, tc.votes)
+
+			_, err := execute(t, &FinalizeAppeal{MarketID: 1, Oracle: testOracle}, &MockRules{}, mu, appealVotingEnd, testDisputer, ids.Empty)
+			require.NoError(err)
+			requireOracleStats(t, mu, storage.OracleStats{Resolved: 1, Disputed: 1, TotalLatency: 2_000, SettledCollateral: 500})
+
+			market, err := storage.GetMarket(ctx, mu, 1)
+			require.NoError(err)
+			require.Equal(storage.MarketStatus_ResolvedYes, market.Status)
+			require.Equal(storage.Tier_Appeal, market.Tier)
+
+			appeal, err := storage.GetAppeal(ctx, mu, 1)
+			require.NoError(err)
+			require.Equal(storage.DisputeStatus_Rejected, appeal.Status)
+
+			// The YES ruling stood through both challenges; the oracle collects
+			// both bonds.
+			for _, tier := range []uint8{storage.Tier_Dispute, storage.Tier_Appeal} {
+				_, err = execute(t, &WithdrawBond{MarketID: 1, Tier: tier}, &MockRules{}, mu, appealVotingEnd, testDisputer, ids.Empty)
+				require.ErrorIs(err, ErrNotBondBeneficiary)
+				_, err = execute(t, &WithdrawBond{MarketID: 1, Tier: tier}, &MockRules{}, mu, appealVotingEnd, testOracle, ids.Empty)
+				require.NoError(err)
+			}
+			requireBalance(t, mu, testOracle, consts.DefaultDisputeBond+consts.DefaultAppealBond)
+
+			_, err = execute(t, &FinalizeAppeal{MarketID: 1, Oracle: testOracle}, &MockRules{}, mu, appealVotingEnd, testDisputer, ids.Empty)
+			require.ErrorIs(err, ErrMarketNotAppealable, "Appeals are finalized once")
+		})
+	}
+}
+
+func TestAppealMarket_Execute_Errors(t *testing.T) {
+	ctx := context.Background()
+
+	testCases := []struct {
+		name        string
+		setup       func(*testing.T, *chaintest.InMemoryStore)
+		action      *AppealMarket
+		timestamp   int64
+		expectedErr error
+	}{
+		{
+			name:        "MarketNotFound",
+			setup:       func(*testing.T, *chaintest.InMemoryStore) {},
+			action:      &AppealMarket{MarketID: 1, ProposedOutcome: uint8(storage.Outcome_No)},
+			timestamp:   appealRulingTime,
+			expectedErr: ErrMarketNotFound,
+		},
+		{
+			name:        "InitialRuling",
+			setup:       setResolvedManualMarket,
+			action:      &AppealMarket{MarketID: 1, ProposedOutcome: uint8(storage.Outcome_No)},
+			timestamp:   13_000,
+			expectedErr: ErrMarketNotAppealable,
+		},
+		{
+			name: "PendingDispute",
+			setup: func(t *testing.T, mu *chaintest.InMemoryStore) {
+				setResolvedManualMarket(t, mu)
+				disputeAndVote(t, mu, storage.Outcome_No, nil)
+			},
+			action:      &AppealMarket{MarketID: 1, ProposedOutcome: uint8(storage.Outcome_No)},
+			timestamp:   13_000,
+			expectedErr: ErrMarketNotAppealable,
+		},
+		{
+			name: "AlreadyAppealed",
+			setup: func(t *testing.T, mu *chaintest.InMemoryStore) {
+				setDisputedRuling(t, mu)
+				appealAndVote(t, mu, storage.Outcome_No, nil)
+			},
+			action:      &AppealMarket{MarketID: 1, ProposedOutcome: uint8(storage.Outcome_Invalid)},
+			timestamp:   appealRulingTime,
+			expectedErr: ErrMarketNotAppealable,
+		},
+		{
+			name:        "WindowClosed",
+			setup:       setDisputedRuling,
+			action:      &AppealMarket{MarketID: 1, ProposedOutcome: uint8(storage.Outcome_No)},
+			timestamp:   appealRulingTime + consts.DefaultDisputeWindow + 1,
+			expectedErr: ErrDisputeWindowClosed,
+		},
+		{
+			name:        "PendingOutcome",
+			setup:       setDisputedRuling,
+			action:      &AppealMarket{MarketID: 1, ProposedOutcome: uint8(storage.Outcome_Pending)},
+			timestamp:   appealRulingTime,
+			expectedErr: ErrInvalidOutcome,
+		},
+		{
+			name:        "SameOutcome",
+			setup:       setDisputedRuling,
+			action:      &AppealMarket{MarketID: 1, ProposedOutcome: uint8(storage.Outcome_Yes)},
+			timestamp:   appealRulingTime,
+			expectedErr: ErrInvalidProposedOutcome,
+		},
+		{
+			name: "InsufficientBalance",
+			setup: func(t *testing.T, mu *chaintest.InMemoryStore) {
+				setDisputedRuling(t, mu)
+				require.NoError(t, storage.SetBalance(ctx, mu, testDisputer, consts.DefaultAppealBond-1))
+			},
+			action:      &AppealMarket{MarketID: 1, ProposedOutcome: uint8(storage.Outcome_No)},
+			timestamp:   appealRulingTime,
+			expectedErr: storage.ErrInsufficientBalance,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+			mu := chaintest.NewInMemoryStore()
+			tc.setup(t, mu)
+
+			output, err := execute(t, tc.action, &MockRules{}, mu, tc.timestamp, testDisputer, ids.Empty)
+			require.ErrorIs(err, tc.expectedErr)
+			require.Nil(output)
+		})
+	}
+}
+
+func TestVoteAppeal_Execute_Errors(t *testing.T) {
+	testCases := []struct {
+		name        string
+		tier        uint8
+		voter       codec.Address
+		timestamp   int64
+		expectedErr error
+	}{
+		{
+			name:        "NoStake",
+			tier:        storage.Tier_Appeal,
+			voter:       testDisputer,
+			timestamp:   appealRulingTime + 1,
+			expectedErr: ErrNoStake,
+		},
+		{
+			name:        "AlreadyVoted",
+			tier:        storage.Tier_Appeal,
+			voter:       testStakerA,
+			timestamp:   appealRulingTime + 1,
+			expectedErr: ErrAlreadyVoted,
+		},
+		{
+			name:        "VotingClosed",
+			tier:        storage.Tier_Appeal,
+			voter:       testStakerB,
+			timestamp:   appealVotingEnd,
+			expectedErr: ErrAppealVotingClosed,
+		},
+		{
+			name:        "DisputeSettled",
+			tier:        storage.Tier_Dispute,
+			voter:       testStakerA,
+			timestamp:   appealRulingTime + 1,
+			expectedErr: ErrAppealVotingClosed,
+		},
+		{
+			name:        "InvalidTier",
+			tier:        storage.Tier_Oracle,
+			voter:       testStakerB,
+			timestamp:   appealRulingTime + 1,
+			expectedErr: ErrInvalidVoteTier,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+			mu := chaintest.NewInMemoryStore()
+			setDisputedRuling(t, mu)
+			appealAndVote(t, mu, storage.Outcome_No, map[codec.Address]bool{testStakerA: true})
+
+			output, err := execute(t, &VoteAppeal{MarketID: 1, Tier: tc.tier}, &MockRules{}, mu, tc.timestamp, tc.voter, ids.Empty)
+			require.ErrorIs(err, tc.expectedErr)
+			require.Nil(output)
+		})
+	}
+}
+
+func TestWithdrawBond_Execute_InvalidTier(t *testing.T) {
+	mu := chaintest.NewInMemoryStore()
+	setDisputedRuling(t, mu)
+
+	_, err := execute(t, &WithdrawBond{MarketID: 1, Tier: storage.Tier_Oracle}, &MockRules{}, mu, appealVotingEnd, testOracle, ids.Empty)
+	require.ErrorIs(t, err, ErrInvalidBondTier)
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/ava-labs/avalanchego/database"
 	"github.com/ava-labs/avalanchego/ids"
+	safemath "github.com/ava-labs/avalanchego/utils/math"
 	"github.com/ava-labs/hypersdk/chain"
 	"github.com/ava-labs/hypersdk/codec"
 	"github.com/ava-labs/hypersdk/state"
@@ -15,7 +16,10 @@ import (
 	"github.com/chokosabe/predictionvm/storage"
 )
 
-var _ chain.Action = (*BuyNo)(nil)
+var (
+	ErrUnmarshalEmptyBuyNo              = errors.New("cannot unmarshal empty bytes as BuyNo action")
+	_                      chain.Action = (*BuyNo)(nil)
+)
 
 // BuyNo represents an action where a user buys NO shares for a specific market.
 type BuyNo struct {
@@ -33,8 +37,13 @@ func (b *BuyNo) GetTypeID() uint8 {
 func (b *BuyNo) StateKeys(actor codec.Address, chainID ids.ID) state.Keys {
 	return state.Keys{
 		string(storage.BalanceKey(actor)): state.Read | state.Write,
+		// Trades only rewrite the MarketState, except on a market stored by the
+		// first release, whose record they split (see storage.SetMarketState).
 		string(storage.MarketKey(b.MarketID)): state.Read | state.Write,
-		string(storage.ShareBalanceKey(b.MarketID, actor, userConsts.NoShareType)): state.Read | state.Write,
+		string(storage.MarketStateKey(b.MarketID)): state.All,
+		string(storage.ShareBalanceKey(b.MarketID, actor, userConsts.NoShareType)): state.All, // Created on the first buy
+		string(storage.PositionIndexKey(actor, userConsts.NoShareType, b.MarketID)): state.All,
+		string(storage.PaidCollateralKey(b.MarketID, actor)): state.All,
 	}
 }
 
@@ -56,52 +65,42 @@ func (b *BuyNo) Execute(
 	}
 
 	// 1. Check if market exists and is active
-	market, err := storage.GetMarket(ctx, mu, b.MarketID)
+	market, err := storage.GetMarketState(ctx, mu, b.MarketID)
 	if err != nil {
-		if errors.Is(err, database.ErrNotFound) { // Corrected to database.ErrNotFound
-			return nil, fmt.Errorf("%w: market %d not found when fetching", ErrMarketNotFound, b.MarketID)
-		}
-		return nil, fmt.Errorf("failed to get market %d: %w", b.MarketID, err)
+		return nil, err
 	}
-	if market.Status == storage.MarketStatus_ResolvedYes || market.Status == storage.MarketStatus_ResolvedNo {
-		return nil, fmt.Errorf("%w: market %d is already resolved (status: %s)", ErrMarketInteraction, b.MarketID, market.Status.String())
+	if err := market.CheckTrading(b.MarketID, txTimestamp); err != nil {
+		return nil, err
 	}
-	if market.Status == storage.MarketStatus_TradingClosed {
-		return nil, fmt.Errorf("%w: market %d trading is closed (status: %s)", ErrMarketInteraction, b.MarketID, market.Status.String())
+
+	// 2. Deduct the cost from the actor's balance
+	cost, err := safemath.Mul(b.Amount, b.MaxPrice)
+	if err != nil {
+		return nil, fmt.Errorf("cost of %d shares at %d: %w", b.Amount, b.MaxPrice, err)
 	}
-	if txTimestamp > market.EndTime {
-		return nil, fmt.Errorf("%w: market %d has ended (current: %d, end: %d)", ErrMarketInteraction, b.MarketID, txTimestamp, market.EndTime)
+	totalShares, err := safemath.Add(market.TotalNoShares, b.Amount)
+	if err != nil {
+		return nil, fmt.Errorf("total NO shares of market %d: %w", b.MarketID, err)
 	}
-
-	// 2. Check actor's balance
-	balanceKey := storage.BalanceKey(actor)
-	currentBalanceBytes, err := mu.GetValue(ctx, balanceKey)
+	collateral, err := safemath.Add(market.Collateral, cost)
 	if err != nil {
-		if errors.Is(err, database.ErrNotFound) { // Corrected to database.ErrNotFound
-			cost := b.Amount * b.MaxPrice // Calculate cost for error message
-			return nil, fmt.Errorf("%w: actor %s has no balance record, cost is %d", ErrInsufficientFunds, actor.String(), cost)
-		}
-		return nil, fmt.Errorf("failed to get actor's balance for %s: %w", actor.String(), err)
+		return nil, fmt.Errorf("collateral of market %d: %w", b.MarketID, err)
 	}
-
-	currentBalance, err := database.ParseUInt64(currentBalanceBytes) // Corrected to database.ParseUInt64
+	// The trading fee is charged on top of the cost and burned; only the
+	// cost backs the shares.
+	fee := Fee(cost, TradingFeeRate(rules))
+	charged, err := safemath.Add(cost, fee)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse actor's balance for %s: %w", actor.String(), err)
+		return nil, fmt.Errorf("cost of %d shares with a fee of %d: %w", b.Amount, fee, err)
 	}
-
-	// 3. Calculate cost and ensure actor has enough funds
-	cost := b.Amount * b.MaxPrice
-	if currentBalance < cost {
-		return nil, fmt.Errorf("%w: actor balance %d, cost %d for %d shares at max price %d for market %d", ErrInsufficientFunds, currentBalance, cost, b.Amount, b.MaxPrice, b.MarketID)
+	if err := storage.DeductBalance(ctx, mu, actor, charged); err != nil {
+		return nil, fmt.Errorf("failed to pay %d (fee: %d) for %d shares of market %d: %w", charged, fee, b.Amount, b.MarketID, err)
 	}
-
-	// 4. Deduct funds
-	newBalance := currentBalance - cost
-	if err := mu.Insert(ctx, balanceKey, database.PackUInt64(newBalance)); err != nil { // Corrected to database.PackUInt64
-		return nil, fmt.Errorf("failed to set new balance %d for actor %s: %w", newBalance, actor.String(), err)
+	if err := storage.AddPaidCollateral(ctx, mu, b.MarketID, actor, cost); err != nil {
+		return nil, err
 	}
 
-	// 5. Credit NO shares to actor
+	// 3. Credit NO shares to actor
 	currentNoShares, err := storage.GetShareBalance(ctx, mu, b.MarketID, actor, userConsts.NoShareType)
 	if err != nil && !errors.Is(err, database.ErrNotFound) { // Corrected to database.ErrNotFound, treat as 0 shares
 		// Consider reverting native token balance change here or using a more transactional approach
@@ -114,9 +113,10 @@ func (b *BuyNo) Execute(
 		return nil, fmt.Errorf("failed to set new NO share balance %d for actor %s, market %d: %w", newShareBalance, actor.String(), b.MarketID, err)
 	}
 
-	// 6. Update market's total NO shares
-	market.TotalNoShares += b.Amount
-	if err := storage.SetMarket(ctx, mu, market); err != nil {
+	// 4. Update market's total NO shares
+	market.TotalNoShares = totalShares
+	market.Collateral = collateral // Held by the market until holders claim
+	if err := storage.SetMarketState(ctx, mu, b.MarketID, market); err != nil {
 		// Consider reverting previous state changes (actor balance, share balance)
 		return nil, fmt.Errorf("failed to update market %d with new total NO shares: %w", b.MarketID, err)
 	}
@@ -144,6 +144,7 @@ func (b *BuyNo) MaxGas(rules chain.Rules) uint64 {
 // Bytes implements chain.Action
 func (b *BuyNo) Bytes() []byte {
 	packer := codec.NewWriter(0, userConsts.MaxActionSize)
+	packer.PackByte(userConsts.BuyNoID)
 	if err := codec.LinearCodec.MarshalInto(b, packer.Packer); err != nil {
 		// This should ideally not happen with a well-defined struct
 		// and could panic or log fatally in a real scenario.
@@ -156,8 +157,25 @@ func (b *BuyNo) Bytes() []byte {
 }
 
 // Unmarshal is a helper function to deserialize bytes into a BuyNo action.
+// [d] excludes the type ID that Bytes prefixes.
 // The codecVersion parameter is currently ignored as LinearCodec.UnmarshalFrom does not use it.
 func (b *BuyNo) Unmarshal(d []byte, _ uint8) error {
 	packer := codec.NewReader(d, userConsts.MaxActionSize)
 	return codec.LinearCodec.UnmarshalFrom(packer.Packer, b)
 }
+
+// UnmarshalBuyNo is the unmarshaler function for BuyNo actions,
+// suitable for registration with codec.TypeParser.
+func UnmarshalBuyNo(b []byte) (chain.Action, error) {
+	if len(b) == 0 {
+		return nil, ErrUnmarshalEmptyBuyNo
+	}
+	if b[0] != userConsts.BuyNoID {
+		return nil, fmt.Errorf("unexpected BuyNo typeID: %d != %d", b[0], userConsts.BuyNoID)
+	}
+	action := &BuyNo{}
+	if err := action.Unmarshal(b[1:], 0); err != nil {
+		return nil, err
+	}
+	return action, nil
+}
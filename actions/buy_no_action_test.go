@@ -2,7 +2,6 @@ package actions
 
 import (
 	"context"
-	"fmt"
 	"testing"
 
 	"github.com/ava-labs/avalanchego/database"
@@ -65,7 +64,7 @@ func TestBuyNo_Execute_Success(t *testing.T) {
 	// 4. Execute the Action
 	txTimestamp := mr.GetTime()
 	var txID ids.ID
-	output, err := buyNoAction.Execute(ctx, mr, mu, txTimestamp, senderAddr, txID)
+	output, err := execute(t, buyNoAction, mr, mu, txTimestamp, senderAddr, txID)
 	units := buyNoAction.ComputeUnits(mr)
 
 	// 5. Assertions for successful execution
@@ -130,12 +129,14 @@ func TestBuyNo_Execute_Error_MarketNotFound(t *testing.T) {
 	// 3. Execute the Action
 	txTimestamp := mr.GetTime()
 	var txID ids.ID
-	output, err := buyNoAction.Execute(ctx, mr, mu, txTimestamp, senderAddr, txID)
+	output, err := execute(t, buyNoAction, mr, mu, txTimestamp, senderAddr, txID)
 
 	// 4. Assertions
 	require.Error(err, "Expected an error for market not found")
 	require.ErrorIs(err, ErrMarketNotFound, "Error should be ErrMarketNotFound")
-	require.Contains(err.Error(), fmt.Sprintf("market %d not found when fetching", nonExistentMarketID), "Error message mismatch")
+	var notFound *storage.MarketNotFoundError
+	require.ErrorAs(err, &notFound)
+	require.Equal(uint64(nonExistentMarketID), notFound.MarketID)
 	require.Nil(output, "Output should be nil on error")
 
 	// Check user's native token balance (should be unchanged)
@@ -212,12 +213,14 @@ func TestBuyNo_Execute_Error_MarketResolved(t *testing.T) {
 			// 3. Execute the Action
 			txTimestamp := mr.GetTime()
 			var txID ids.ID
-			output, err := buyNoAction.Execute(ctx, mr, mu, txTimestamp, senderAddr, txID)
+			output, err := execute(t, buyNoAction, mr, mu, txTimestamp, senderAddr, txID)
 
 			// 4. Assertions
 			require.Error(err, "Expected an error for resolved market")
 			require.ErrorIs(err, ErrMarketInteraction, "Error should be ErrMarketInteraction")
-			require.Contains(err.Error(), fmt.Sprintf("market %d is already resolved", marketID), "Error message mismatch")
+			var closed *storage.MarketClosedError
+			require.ErrorAs(err, &closed)
+			require.Equal(tc.marketStatus, closed.Status)
 			require.Nil(output, "Output should be nil on error")
 
 			// Check user's native token balance (should be unchanged)
@@ -290,13 +293,15 @@ func TestBuyNo_Execute_Error_InsufficientFunds(t *testing.T) {
 	// 4. Execute the Action
 	txTimestamp := mr.GetTime()
 	var txID ids.ID
-	output, err := buyNoAction.Execute(ctx, mr, mu, txTimestamp, senderAddr, txID)
+	output, err := execute(t, buyNoAction, mr, mu, txTimestamp, senderAddr, txID)
 
 	// 5. Assertions
 	require.Error(err, "Expected an error for insufficient funds")
 	require.ErrorIs(err, ErrInsufficientFunds, "Error should be ErrInsufficientFunds")
 	cost := amountToBuy * maxPrice
-	require.Contains(err.Error(), fmt.Sprintf("actor balance %d, cost %d", initialUserBalance, cost), "Error message mismatch")
+	var insufficient *storage.InsufficientBalanceError
+	require.ErrorAs(err, &insufficient)
+	require.Equal(storage.InsufficientBalanceError{Address: senderAddr, Balance: initialUserBalance, Required: cost}, *insufficient)
 	require.Nil(output, "Output should be nil on error")
 
 	// Check user's native token balance (should be unchanged)
@@ -322,7 +327,6 @@ func TestBuyNo_Execute_Error_InsufficientFunds(t *testing.T) {
 
 func TestBuyNo_Execute_Error_AmountZero(t *testing.T) {
 	require := require.New(t)
-	ctx := context.Background()
 	mu := chaintest.NewInMemoryStore() // Not strictly needed for this validation, but good practice
 
 	// Setup Rules (mocking GetTime)
@@ -347,7 +351,7 @@ func TestBuyNo_Execute_Error_AmountZero(t *testing.T) {
 	// Execute the Action
 	txTimestamp := mr.GetTime()
 	var txID ids.ID
-	output, err := buyNoAction.Execute(ctx, mr, mu, txTimestamp, senderAddr, txID)
+	output, err := execute(t, buyNoAction, mr, mu, txTimestamp, senderAddr, txID)
 
 	// Assertions
 	require.Error(err, "Expected an error for zero amount")
@@ -360,7 +364,6 @@ func TestBuyNo_Execute_Error_AmountZero(t *testing.T) {
 
 func TestBuyNo_Execute_Error_MaxPriceZero(t *testing.T) {
 	require := require.New(t)
-	ctx := context.Background()
 	mu := chaintest.NewInMemoryStore() // Not strictly needed, but good practice
 
 	// Setup Rules (mocking GetTime)
@@ -384,7 +387,7 @@ func TestBuyNo_Execute_Error_MaxPriceZero(t *testing.T) {
 	// Execute the Action
 	txTimestamp := mr.GetTime()
 	var txID ids.ID
-	output, err := buyNoAction.Execute(ctx, mr, mu, txTimestamp, senderAddr, txID)
+	output, err := execute(t, buyNoAction, mr, mu, txTimestamp, senderAddr, txID)
 
 	// Assertions
 	require.Error(err, "Expected an error for zero max price")
@@ -435,14 +438,16 @@ func TestBuyNo_Execute_Error_NoBalanceRecord_InsufficientFunds(t *testing.T) {
 	// 3. Execute the Action
 	txTimestamp := mr.GetTime()
 	var txID ids.ID
-	output, err := buyNoAction.Execute(ctx, mr, mu, txTimestamp, senderAddr, txID)
+	output, err := execute(t, buyNoAction, mr, mu, txTimestamp, senderAddr, txID)
 
 	// 4. Assertions
 	require.Error(err, "Expected an error for insufficient funds due to no balance record")
 	require.ErrorIs(err, ErrInsufficientFunds, "Error should be ErrInsufficientFunds")
 	cost := amountToBuy * maxPrice
-	// Actor balance will be treated as 0, and a specific error message is generated
-	require.Contains(err.Error(), fmt.Sprintf("actor %s has no balance record, cost is %d", senderAddr.String(), cost), "Error message mismatch")
+	// Actor balance will be treated as 0
+	var insufficient *storage.InsufficientBalanceError
+	require.ErrorAs(err, &insufficient)
+	require.Equal(storage.InsufficientBalanceError{Address: senderAddr, Balance: 0, Required: cost}, *insufficient)
 	require.Nil(output, "Output should be nil on error")
 
 	// Check user's native token balance (should still be effectively 0 or non-existent)
@@ -520,12 +525,14 @@ func TestBuyNo_Execute_Error_MarketTradingClosed(t *testing.T) {
 	// 4. Execute the Action
 	txTimestamp := mr.GetTime()
 	var txID ids.ID
-	output, err := buyNoAction.Execute(ctx, mr, mu, txTimestamp, senderAddr, txID)
+	output, err := execute(t, buyNoAction, mr, mu, txTimestamp, senderAddr, txID)
 
 	// 5. Assertions
 	require.Error(err, "Expected an error for market trading closed")
 	require.ErrorIs(err, ErrMarketInteraction, "Error should be ErrMarketInteraction")
-	require.Contains(err.Error(), fmt.Sprintf("market %d trading is closed", marketID), "Error message mismatch")
+	var closed *storage.MarketClosedError
+	require.ErrorAs(err, &closed)
+	require.Equal(storage.MarketStatus_TradingClosed, closed.Status)
 	require.Nil(output, "Output should be nil on error")
 
 	// Check user's native token balance (should be unchanged)
@@ -597,12 +604,14 @@ func TestBuyNo_Execute_Error_MarketEndTimePassed(t *testing.T) {
 	// 4. Execute the Action
 	txTimestamp := mr.GetTime()
 	var txID ids.ID
-	output, err := buyNoAction.Execute(ctx, mr, mu, txTimestamp, senderAddr, txID)
+	output, err := execute(t, buyNoAction, mr, mu, txTimestamp, senderAddr, txID)
 
 	// 5. Assertions
 	require.Error(err, "Expected an error for market EndTime passed")
 	require.ErrorIs(err, ErrMarketInteraction, "Error should be ErrMarketInteraction")
-	require.Contains(err.Error(), fmt.Sprintf("market %d has ended", marketID), "Error message mismatch")
+	var closed *storage.MarketClosedError
+	require.ErrorAs(err, &closed)
+	require.Equal(storage.MarketClosedError{MarketID: marketID, Status: storage.MarketStatus_Open, EndTime: market.EndTime, Time: txTimestamp}, *closed)
 	require.Nil(output, "Output should be nil on error")
 
 	// Check user's native token balance (should be unchanged)
@@ -7,6 +7,7 @@ import (
 
 	"github.com/ava-labs/avalanchego/database" // Added for database interactions
 	"github.com/ava-labs/avalanchego/ids"
+	safemath "github.com/ava-labs/avalanchego/utils/math"
 	"github.com/ava-labs/hypersdk/chain"
 	"github.com/ava-labs/hypersdk/codec"
 	"github.com/ava-labs/hypersdk/state" // Added for state.Keys, state.Permissions
@@ -15,7 +16,10 @@ import (
 	"github.com/chokosabe/predictionvm/storage"
 )
 
-var _ chain.Action = (*BuyYes)(nil)
+var (
+	ErrUnmarshalEmptyBuyYes              = errors.New("cannot unmarshal empty bytes as BuyYes action")
+	_                       chain.Action = (*BuyYes)(nil)
+)
 
 // BuyYes represents an action where a user buys YES shares for a specific market.
 type BuyYes struct {
@@ -30,9 +34,11 @@ type BuyYes struct {
 var (
 	ErrAmountCannotBeZero   = errors.New("amount cannot be zero")
 	ErrMaxPriceCannotBeZero = errors.New("max price cannot be zero")
-	ErrMarketNotFound       = errors.New("market not found")
-	ErrMarketInteraction    = errors.New("market interaction error") // Generic for resolved, cancelled, ended
-	ErrInsufficientFunds    = errors.New("insufficient funds")
+
+	// Aliases of the storage errors, which the typed storage errors match.
+	ErrMarketNotFound    = storage.ErrMarketNotFound
+	ErrMarketInteraction = storage.ErrMarketClosed
+	ErrInsufficientFunds = storage.ErrInsufficientBalance
 )
 
 // GetTypeID implements chain.Action
@@ -42,14 +48,15 @@ func (b *BuyYes) GetTypeID() uint8 {
 
 // StateKeys implements chain.Action
 func (b *BuyYes) StateKeys(actor codec.Address, _ ids.ID) state.Keys {
-	// TODO: storage.BalanceKey, storage.MarketKey, storage.ShareBalanceKey are currently undefined.
-	// These will need to be correctly defined and imported.
-	// For now, using placeholders that will cause compile errors for these specific lines,
-	// but the method signature and return type are correct for chain.Action.
 	return state.Keys{
-		string(storage.BalanceKey(actor)):                               state.Read | state.Write, // Placeholder
-		string(storage.MarketKey(b.MarketID)):                           state.Read | state.Write, // Placeholder
-		string(storage.ShareBalanceKey(b.MarketID, actor, userConsts.YesShareType)): state.Read | state.Write, // Placeholder
+		string(storage.BalanceKey(actor)): state.Read | state.Write,
+		// Trades only rewrite the MarketState, except on a market stored by the
+		// first release, whose record they split (see storage.SetMarketState).
+		string(storage.MarketKey(b.MarketID)):                                        state.Read | state.Write,
+		string(storage.MarketStateKey(b.MarketID)):                                   state.All,
+		string(storage.ShareBalanceKey(b.MarketID, actor, userConsts.YesShareType)):  state.All, // Created on the first buy
+		string(storage.PositionIndexKey(actor, userConsts.YesShareType, b.MarketID)): state.All,
+		string(storage.PaidCollateralKey(b.MarketID, actor)):                         state.All,
 	}
 }
 
@@ -71,67 +78,42 @@ func (b *BuyYes) Execute(
 	}
 
 	// 1. Check if market exists and is active
-	market, err := storage.GetMarket(ctx, mu, b.MarketID)
+	market, err := storage.GetMarketState(ctx, mu, b.MarketID)
 	if err != nil {
-		if errors.Is(err, database.ErrNotFound) {
-			return nil, fmt.Errorf("%w: market %d not found when fetching", ErrMarketNotFound, b.MarketID)
-		}
-		return nil, fmt.Errorf("failed to get market %d: %w", b.MarketID, err)
-	}
-	// Redundant if GetMarket returns error on not found, but good for clarity
-	// if market == nil {
-	// 	return nil, fmt.Errorf("%w: market %d not found", ErrMarketNotFound, b.MarketID)
-	// }
-	if market.Status == storage.MarketStatus_ResolvedYes || market.Status == storage.MarketStatus_ResolvedNo {
-		return nil, fmt.Errorf("%w: market %d is already resolved (status: %s)", ErrMarketInteraction, b.MarketID, market.Status.String())
-	}
-	// Removed IsCancelled check as MarketStatus does not have a direct 'Cancelled' state.
-	// Cancellation might be handled by a specific resolution outcome (e.g., Invalid) or other logic.
-	if txTimestamp > market.EndTime && market.Status == storage.MarketStatus_Open { // Can only trade if market is open and within time
-		// If trading is closed but not yet resolved, that's a different state (MarketStatus_TradingClosed)
-		// which might still allow other actions but not new trades like BuyYes.
-		// For BuyYes, if EndTime is passed and it's still 'Open', it's effectively ended for new trades.
-		// If it's TradingClosed, that also means new trades are not allowed.
-		return nil, fmt.Errorf("%w: market %d has ended or trading is closed (current: %d, end: %d, status: %s)", ErrMarketInteraction, b.MarketID, txTimestamp, market.EndTime, market.Status.String())
-	}
-	if market.Status == storage.MarketStatus_TradingClosed {
-		return nil, fmt.Errorf("%w: market %d trading is closed (status: %s)", ErrMarketInteraction, b.MarketID, market.Status.String())
-	}
-	if txTimestamp > market.EndTime {
-		return nil, fmt.Errorf("%w: market %d has ended (current: %d, end: %d)", ErrMarketInteraction, b.MarketID, txTimestamp, market.EndTime)
-	}
-
-	// 2. Check actor's balance
-	balanceKey := storage.BalanceKey(actor)
-	currentBalanceBytes, err := mu.GetValue(ctx, balanceKey)
-	if err != nil {
-		if errors.Is(err, database.ErrNotFound) {
-			// Actor has no balance record, treat as 0 balance for cost calculation
-			// This means they definitely don't have enough funds if cost > 0
-			cost := b.Amount * b.MaxPrice // Calculate cost to include in error message
-			return nil, fmt.Errorf("%w: actor %s has no balance record, cost is %d", ErrInsufficientFunds, actor.String(), cost)
-		}
-		return nil, fmt.Errorf("failed to get actor's balance for %s: %w", actor.String(), err)
+		return nil, err
+	}
+	if err := market.CheckTrading(b.MarketID, txTimestamp); err != nil {
+		return nil, err
 	}
 
-	currentBalance, err := database.ParseUInt64(currentBalanceBytes)
+	// 2. Deduct the cost from the actor's balance
+	cost, err := safemath.Mul(b.Amount, b.MaxPrice)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse actor's balance for %s: %w", actor.String(), err)
+		return nil, fmt.Errorf("cost of %d shares at %d: %w", b.Amount, b.MaxPrice, err)
 	}
-
-	// 3. Calculate cost and ensure actor has enough funds
-	cost := b.Amount * b.MaxPrice
-	if currentBalance < cost {
-		return nil, fmt.Errorf("%w: actor balance %d, cost %d for %d shares at max price %d for market %d", ErrInsufficientFunds, currentBalance, cost, b.Amount, b.MaxPrice, b.MarketID)
+	totalShares, err := safemath.Add(market.TotalYesShares, b.Amount)
+	if err != nil {
+		return nil, fmt.Errorf("total YES shares of market %d: %w", b.MarketID, err)
 	}
-
-	// 4. Deduct funds
-	newBalance := currentBalance - cost
-	if err := mu.Insert(ctx, balanceKey, database.PackUInt64(newBalance)); err != nil {
-		return nil, fmt.Errorf("failed to set new balance %d for actor %s: %w", newBalance, actor.String(), err)
+	collateral, err := safemath.Add(market.Collateral, cost)
+	if err != nil {
+		return nil, fmt.Errorf("collateral of market %d: %w", b.MarketID, err)
+	}
+	// The trading fee is charged on top of the cost and burned; only the
+	// cost backs the shares.
+	fee := Fee(cost, TradingFeeRate(rules))
+	charged, err := safemath.Add(cost, fee)
+	if err != nil {
+		return nil, fmt.Errorf("cost of %d shares with a fee of %d: %w", b.Amount, fee, err)
+	}
+	if err := storage.DeductBalance(ctx, mu, actor, charged); err != nil {
+		return nil, fmt.Errorf("failed to pay %d (fee: %d) for %d shares of market %d: %w", charged, fee, b.Amount, b.MarketID, err)
+	}
+	if err := storage.AddPaidCollateral(ctx, mu, b.MarketID, actor, cost); err != nil {
+		return nil, err
 	}
 
-	// 5. Credit YES shares to actor
+	// 3. Credit YES shares to actor
 	currentYesShares, err := storage.GetShareBalance(ctx, mu, b.MarketID, actor, userConsts.YesShareType)
 	// Allow ErrNotFound for initial share balance, in which case currentShareBalance defaults to 0 (uint64)
 	if err != nil && !errors.Is(err, database.ErrNotFound) {
@@ -145,11 +127,12 @@ func (b *BuyYes) Execute(
 		return nil, fmt.Errorf("failed to set new share balance %d for actor %s, market %d, type YES: %w", newShareBalance, actor.String(), b.MarketID, err)
 	}
 
-	// 6. Update market's total YES shares
-	// We use the 'market' variable fetched earlier in this Execute call.
-	// It's important that this 'market' instance is the one we want to modify and save.
-	market.TotalYesShares += b.Amount
-	if err := storage.SetMarket(ctx, mu, market); err != nil { // Pass the market object itself
+	// 4. Update market's total YES shares
+	// We use the 'market' state fetched earlier in this Execute call.
+	// It's important that this instance is the one we want to modify and save.
+	market.TotalYesShares = totalShares
+	market.Collateral = collateral // Held by the market until holders claim
+	if err := storage.SetMarketState(ctx, mu, b.MarketID, market); err != nil {
 		// Potentially revert user's share balance and native token balance changes here for atomicity
 		return nil, fmt.Errorf("failed to update market %d total YES shares: %w", b.MarketID, err)
 	}
@@ -171,6 +154,7 @@ func (b *BuyYes) ValidRange(rules chain.Rules) (start int64, end int64) {
 // Bytes implements chain.Action
 func (b *BuyYes) Bytes() []byte {
 	packer := codec.NewWriter(0, userConsts.MaxActionSize)
+	packer.PackByte(userConsts.BuyYesID)
 	if err := codec.LinearCodec.MarshalInto(b, packer.Packer); err != nil {
 		// This should ideally not happen with a well-defined struct
 		// and could panic or log fatally in a real scenario.
@@ -182,6 +166,7 @@ func (b *BuyYes) Bytes() []byte {
 }
 
 // Unmarshal is a helper function to deserialize bytes into a BuyYes action.
+// [d] excludes the type ID that Bytes prefixes.
 // The codecVersion parameter is currently ignored as LinearCodec.UnmarshalFrom does not use it.
 func (b *BuyYes) Unmarshal(d []byte, _ uint8) error {
 	packer := codec.NewReader(d, userConsts.MaxActionSize)
@@ -191,10 +176,16 @@ func (b *BuyYes) Unmarshal(d []byte, _ uint8) error {
 // UnmarshalBuyYes is the unmarshaler function for BuyYes actions,
 // suitable for registration with codec.TypeParser.
 func UnmarshalBuyYes(b []byte) (chain.Action, error) {
+	if len(b) == 0 {
+		return nil, ErrUnmarshalEmptyBuyYes
+	}
+	if b[0] != userConsts.BuyYesID {
+		return nil, fmt.Errorf("unexpected BuyYes typeID: %d != %d", b[0], userConsts.BuyYesID)
+	}
 	action := &BuyYes{}
 	// The codecVersion (cv) is not used by the Unmarshal method for LinearCodec,
 	// so we pass a zero value (or any uint8) for it.
-	err := action.Unmarshal(b, 0)
+	err := action.Unmarshal(b[1:], 0)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,239 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/wrappers"
+	"github.com/ava-labs/hypersdk/chain"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/ava-labs/hypersdk/state"
+
+	"github.com/chokosabe/predictionvm/consts"
+	"github.com/chokosabe/predictionvm/storage"
+)
+
+const (
+	ClaimComputeUnits = 1000
+	MaxClaimSize      = 16
+)
+
+var (
+	ErrUnmarshalEmptyClaim              = errors.New("cannot unmarshal empty bytes as Claim action")
+	ErrMarketNotResolved                = errors.New("market is not resolved")
+	ErrNothingToClaim                   = errors.New("actor has no shares to claim")
+	ErrDisputeWindowOpen                = errors.New("market resolution can still be disputed")
+	_                      chain.Action = (*Claim)(nil)
+)
+
+// Claim pays out the actor's share of a resolved market's collateral and
+// burns the shares it redeems (their balance keys are removed).
+//
+// Claims on manual markets open once the latest ruling can no longer be
+// challenged (see IsFinal).
+//
+// Collateral is split parimutuel-style: winning shares divide the remaining
+// collateral pro rata. When a market resolves Invalid, or nobody holds a
+// winning share, every YES and NO share is redeemed instead and each holder
+// is refunded the collateral they paid (see storage.PaidCollateralKey).
+type Claim struct {
+	MarketID uint64 `serialize:"true" json:"marketId"`
+}
+
+func (*Claim) GetTypeID() uint8 {
+	return consts.ClaimID
+}
+
+// Bytes serializes the Claim action.
+func (c *Claim) Bytes() []byte {
+	p := &wrappers.Packer{
+		Bytes:   make([]byte, 0, MaxClaimSize),
+		MaxSize: MaxClaimSize,
+	}
+	p.PackByte(consts.ClaimID)
+	if err := codec.LinearCodec.MarshalInto(c, p); err != nil {
+		panic(fmt.Errorf("failed to marshal Claim action: %w", err))
+	}
+	return p.Bytes
+}
+
+// UnmarshalClaim deserializes bytes into a Claim action.
+func UnmarshalClaim(bytes []byte) (chain.Action, error) {
+	if len(bytes) == 0 {
+		return nil, ErrUnmarshalEmptyClaim
+	}
+	if bytes[0] != consts.ClaimID {
+		return nil, fmt.Errorf("unexpected Claim typeID: %d != %d", bytes[0], consts.ClaimID)
+	}
+	c := &Claim{}
+	if err := codec.LinearCodec.UnmarshalFrom(
+		&wrappers.Packer{Bytes: bytes[1:]},
+		c,
+	); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal Claim action: %w", err)
+	}
+	return c, nil
+}
+
+// StateKeys implements chain.Action
+func (c *Claim) StateKeys(actor codec.Address, _ ids.ID) state.Keys {
+	return state.Keys{
+		string(storage.BalanceKey(actor)):                                        state.All, // Empty balances are removed, so the credit may create it
+		string(storage.MarketKey(c.MarketID)):                                    state.Read | state.Write,
+		string(storage.MarketStateKey(c.MarketID)):                               state.All,
+		string(storage.ShareBalanceKey(c.MarketID, actor, consts.YesShareType)):  state.Read | state.Write,
+		string(storage.ShareBalanceKey(c.MarketID, actor, consts.NoShareType)):   state.Read | state.Write,
+		string(storage.PositionIndexKey(actor, consts.YesShareType, c.MarketID)): state.Read | state.Write,
+		string(storage.PositionIndexKey(actor, consts.NoShareType, c.MarketID)):  state.Read | state.Write,
+		string(storage.PaidCollateralKey(c.MarketID, actor)):                     state.Read | state.Write,
+	}
+}
+
+// Execute redeems the actor's shares and credits the payout, less the claim
+// fee, to their balance.
+func (c *Claim) Execute(
+	ctx context.Context,
+	rules chain.Rules,
+	mu state.Mutable,
+	timestamp int64,
+	actor codec.Address,
+	_ ids.ID,
+) ([]byte, error) {
+	market, err := storage.GetMarket(ctx, mu, c.MarketID)
+	if err != nil {
+		return nil, err
+	}
+	if !market.Status.IsResolved() {
+		return nil, fmt.Errorf("%w: market %d (status: %s)", ErrMarketNotResolved, c.MarketID, market.Status)
+	}
+	if !IsFinal(rules, market, timestamp) {
+		return nil, fmt.Errorf("%w: market %d can be claimed after %d (current: %d)", ErrDisputeWindowOpen, c.MarketID, market.ResolvedAt+DisputeWindow(rules), timestamp)
+	}
+
+	yesShares, err := storage.GetShareBalance(ctx, mu, c.MarketID, actor, consts.YesShareType)
+	if err != nil {
+		return nil, err
+	}
+	noShares, err := storage.GetShareBalance(ctx, mu, c.MarketID, actor, consts.NoShareType)
+	if err != nil {
+		return nil, err
+	}
+
+	paid, err := storage.GetPaidCollateral(ctx, mu, c.MarketID, actor)
+	if err != nil {
+		return nil, err
+	}
+
+	redeemYes, redeemNo := redeemed(market)
+	if (!redeemYes || yesShares == 0) && (!redeemNo || noShares == 0) {
+		return nil, fmt.Errorf("%w: market %d", ErrNothingToClaim, c.MarketID)
+	}
+	payout := ClaimPayout(market, yesShares, noShares, paid)
+	if payout == 0 {
+		// Either the collateral has been paid out already or the shares are
+		// worth less than one unit.
+		return nil, fmt.Errorf("%w: market %d has no collateral left for the actor's shares", ErrNothingToClaim, c.MarketID)
+	}
+
+	if redeemYes && yesShares > 0 {
+		market.TotalYesShares -= yesShares
+		if err := storage.RemoveShareBalance(ctx, mu, c.MarketID, actor, consts.YesShareType); err != nil {
+			return nil, err
+		}
+	}
+	if redeemNo && noShares > 0 {
+		market.TotalNoShares -= noShares
+		if err := storage.RemoveShareBalance(ctx, mu, c.MarketID, actor, consts.NoShareType); err != nil {
+			return nil, err
+		}
+	}
+	// The record has served its purpose once the actor's shares are redeemed
+	// or, when only the winning side is, once their losing shares are
+	// worthless.
+	if err := storage.RemovePaidCollateral(ctx, mu, c.MarketID, actor); err != nil {
+		return nil, err
+	}
+	market.Collateral -= payout
+	if err := storage.SetMarket(ctx, mu, market); err != nil {
+		return nil, fmt.Errorf("failed to update market %d after claim: %w", c.MarketID, err)
+	}
+	// The claim fee is withheld from the payout and burned.
+	credit := payout - Fee(payout, ClaimFeeRate(rules))
+	if err := storage.AddBalance(ctx, mu, actor, credit); err != nil {
+		return nil, fmt.Errorf("failed to credit claim of %d to %s: %w", credit, actor, err)
+	}
+	return nil, nil
+}
+
+// redeemed reports which shares of resolved [market] Claim redeems: the
+// winning side's, or both sides' when the market is refunded because it was
+// ruled Invalid or nobody holds the winning side.
+func redeemed(market *storage.Market) (yes bool, no bool) {
+	switch {
+	case market.Status == storage.MarketStatus_ResolvedInvalid,
+		market.Status == storage.MarketStatus_ResolvedYes && market.TotalYesShares == 0,
+		market.Status == storage.MarketStatus_ResolvedNo && market.TotalNoShares == 0:
+		return true, true
+	case market.Status == storage.MarketStatus_ResolvedYes:
+		return true, false
+	default:
+		return false, true
+	}
+}
+
+// ClaimPayout returns what Claim pays a holder of [yesShares] and [noShares]
+// of resolved [market] who paid [paid] collateral into it: their winning
+// shares' part of the remaining collateral, or [paid] when the market is
+// refunded.
+func ClaimPayout(market *storage.Market, yesShares, noShares, paid uint64) uint64 {
+	redeemYes, redeemNo := redeemed(market)
+	if redeemYes && redeemNo {
+		return min(paid, market.Collateral)
+	}
+	var claimed, outstanding uint64
+	if redeemYes {
+		claimed += yesShares
+		outstanding += market.TotalYesShares
+	}
+	if redeemNo {
+		claimed += noShares
+		outstanding += market.TotalNoShares
+	}
+	if outstanding == 0 {
+		return 0
+	}
+	payout := new(big.Int).SetUint64(market.Collateral)
+	payout.Mul(payout, new(big.Int).SetUint64(claimed))
+	payout.Div(payout, new(big.Int).SetUint64(outstanding))
+	return payout.Uint64()
+}
+
+// IsFinal reports whether the outcome of a resolved market can no longer
+// change: it is not manually resolved, was decided by an appeal, expired
+// Invalid, or the window to challenge its latest ruling has passed. An Invalid
+// ruling on a dispute can still be appealed until that window closes.
+func IsFinal(rules chain.Rules, market *storage.Market, timestamp int64) bool {
+	switch {
+	case !market.Status.IsResolved():
+		return false
+	case market.OracleType != consts.OracleTypeManual,
+		market.Tier >= storage.Tier_Appeal,
+		market.Status == storage.MarketStatus_ResolvedInvalid && market.Tier != storage.Tier_Dispute:
+		return true
+	default:
+		return timestamp > market.ResolvedAt+DisputeWindow(rules)
+	}
+}
+
+// ComputeUnits implements chain.Action
+func (*Claim) ComputeUnits(chain.Rules) uint64 {
+	return ClaimComputeUnits
+}
+
+// ValidRange implements chain.Action
+func (*Claim) ValidRange(chain.Rules) (int64, int64) {
+	return -1, -1 // Always valid
+}
@@ -0,0 +1,228 @@
+package actions
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/hypersdk/chain/chaintest"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chokosabe/predictionvm/consts"
+	"github.com/chokosabe/predictionvm/storage"
+)
+
+// claimTime is the first time at which markets resolved at time zero can be claimed.
+const claimTime = consts.DefaultDisputeWindow + 1
+
+func TestClaim_Execute(t *testing.T) {
+	ctx := context.Background()
+	alice := codec.Address{0x01} // Holds 30 YES and 10 NO
+	bob := codec.Address{0x02}   // Holds 10 YES
+	carol := codec.Address{0x03} // Holds 50 NO
+
+	holdings := []struct {
+		actor     codec.Address
+		shareType uint8
+		amount    uint64
+	}{
+		{alice, consts.YesShareType, 30},
+		{alice, consts.NoShareType, 10},
+		{bob, consts.YesShareType, 10},
+		{carol, consts.NoShareType, 50},
+	}
+	// What each holder paid for their shares, at different prices.
+	paid := map[codec.Address]uint64{alice: 520, bob: 80, carol: 400}
+
+	testCases := []struct {
+		name     string
+		status   storage.MarketStatus
+		payouts  map[codec.Address]uint64
+		expected map[codec.Address]error
+	}{
+		{
+			name:     "ResolvedYes",
+			status:   storage.MarketStatus_ResolvedYes,
+			payouts:  map[codec.Address]uint64{alice: 750, bob: 250},
+			expected: map[codec.Address]error{carol: ErrNothingToClaim},
+		},
+		{
+			name:     "ResolvedNo",
+			status:   storage.MarketStatus_ResolvedNo,
+			payouts:  map[codec.Address]uint64{alice: 166, carol: 834},
+			expected: map[codec.Address]error{bob: ErrNothingToClaim},
+		},
+		{
+			// Holders get back what they paid, not a share-weighted part of
+			// the collateral.
+			name:    "ResolvedInvalidRefundsEveryone",
+			status:  storage.MarketStatus_ResolvedInvalid,
+			payouts: paid,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+			mu := chaintest.NewInMemoryStore()
+
+			require.NoError(storage.SetMarket(ctx, mu, &storage.Market{
+				ID:             1,
+				Status:         tc.status,
+				TotalYesShares: 40,
+				TotalNoShares:  60,
+				Collateral:     1_000,
+			}))
+			for _, h := range holdings {
+				require.NoError(storage.SetShareBalance(ctx, mu, 1, h.actor, h.shareType, h.amount))
+			}
+			for actor, amount := range paid {
+				require.NoError(storage.AddPaidCollateral(ctx, mu, 1, actor, amount))
+			}
+
+			// Claim in a fixed order so the last claimant receives any rounding dust.
+			for _, actor := range []codec.Address{alice, bob, carol} {
+				_, err := execute(t, &Claim{MarketID: 1}, &MockRules{}, mu, claimTime, actor, ids.Empty)
+				require.ErrorIs(err, tc.expected[actor])
+
+				balance, err := storage.GetBalance(ctx, mu, actor)
+				require.NoError(err)
+				require.Equal(tc.payouts[actor], balance)
+
+				remaining, err := storage.GetPaidCollateral(ctx, mu, 1, actor)
+				require.NoError(err)
+				if tc.expected[actor] == nil {
+					require.Zero(remaining, "Claiming removes the paid collateral record")
+				}
+			}
+
+			market, err := storage.GetMarket(ctx, mu, 1)
+			require.NoError(err)
+			require.Zero(market.Collateral, "All collateral should be paid out")
+
+			// Redeemed shares leave the holders' position index.
+			for _, h := range holdings {
+				redeemed := tc.status == storage.MarketStatus_ResolvedInvalid ||
+					(tc.status == storage.MarketStatus_ResolvedYes) == (h.shareType == consts.YesShareType)
+				indexed, err := storage.IsIndexed(ctx, mu, storage.PositionIndexKey(h.actor, h.shareType, 1))
+				require.NoError(err)
+				require.Equal(!redeemed, indexed)
+			}
+
+			_, err = execute(t, &Claim{MarketID: 1}, &MockRules{}, mu, claimTime, alice, ids.Empty)
+			require.ErrorIs(err, ErrNothingToClaim, "Shares cannot be claimed twice")
+		})
+	}
+}
+
+func TestClaim_Execute_ClaimFee(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	actor := codec.Address{0x01}
+	rules := &MockRules{
+		FetchCustomFunc: func(key string) (any, bool) {
+			if key == consts.ClaimFeeRateKey {
+				return uint64(100), true // 1%
+			}
+			return nil, false
+		},
+	}
+
+	mu := chaintest.NewInMemoryStore()
+	require.NoError(storage.SetMarket(ctx, mu, &storage.Market{
+		ID:             1,
+		Status:         storage.MarketStatus_ResolvedYes,
+		TotalYesShares: 40,
+		Collateral:     1_000,
+	}))
+	require.NoError(storage.SetShareBalance(ctx, mu, 1, actor, consts.YesShareType, 40))
+	_, err := execute(t, &Claim{MarketID: 1}, rules, mu, claimTime, actor, ids.Empty)
+	require.NoError(err)
+
+	// The fee is withheld from the payout and burned.
+	balance, err := storage.GetBalance(ctx, mu, actor)
+	require.NoError(err)
+	require.Equal(uint64(990), balance)
+	market, err := storage.GetMarket(ctx, mu, 1)
+	require.NoError(err)
+	require.Zero(market.Collateral)
+}
+
+func TestClaim_Execute_NoWinningSharesRefunds(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	mu := chaintest.NewInMemoryStore()
+	holder := codec.Address{0x01}
+
+	require.NoError(storage.SetMarket(ctx, mu, &storage.Market{
+		ID:            1,
+		Status:        storage.MarketStatus_ResolvedYes,
+		TotalNoShares: 10,
+		Collateral:    100,
+	}))
+	require.NoError(storage.SetShareBalance(ctx, mu, 1, holder, consts.NoShareType, 10))
+	require.NoError(storage.AddPaidCollateral(ctx, mu, 1, holder, 100))
+
+	_, err := execute(t, &Claim{MarketID: 1}, &MockRules{}, mu, claimTime, holder, ids.Empty)
+	require.NoError(err)
+	balance, err := storage.GetBalance(ctx, mu, holder)
+	require.NoError(err)
+	require.Equal(uint64(100), balance)
+}
+
+func TestClaim_Execute_Errors(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	mu := chaintest.NewInMemoryStore()
+	action := &Claim{MarketID: 1}
+
+	_, err := execute(t, action, &MockRules{}, mu, 0, codec.Address{0x01}, ids.Empty)
+	require.ErrorIs(err, ErrMarketNotFound)
+
+	require.NoError(storage.SetMarket(ctx, mu, &storage.Market{ID: 1, Status: storage.MarketStatus_TradingClosed}))
+	_, err = execute(t, action, &MockRules{}, mu, 0, codec.Address{0x01}, ids.Empty)
+	require.ErrorIs(err, ErrMarketNotResolved)
+
+	require.NoError(storage.SetMarket(ctx, mu, &storage.Market{ID: 1, Status: storage.MarketStatus_Disputed}))
+	_, err = execute(t, action, &MockRules{}, mu, claimTime, codec.Address{0x01}, ids.Empty)
+	require.ErrorIs(err, ErrMarketNotResolved)
+
+	require.NoError(storage.SetMarket(ctx, mu, &storage.Market{ID: 1, Status: storage.MarketStatus_ResolvedYes, ResolvedAt: 1_000}))
+	_, err = execute(t, action, &MockRules{}, mu, 1_000+consts.DefaultDisputeWindow, codec.Address{0x01}, ids.Empty)
+	require.ErrorIs(err, ErrDisputeWindowOpen)
+}
+
+func TestBuyThenExpireThenClaim_RefundsCollateral(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	mu := chaintest.NewInMemoryStore()
+	trader := codec.Address{0x01}
+
+	setPendingMarket(t, mu, &storage.Market{
+		ID:             1,
+		Description:    "Never resolved",
+		Status:         storage.MarketStatus_Open,
+		EndTime:        5_000,
+		ResolutionTime: 10_000,
+	})
+	require.NoError(storage.SetBalance(ctx, mu, trader, 1_000))
+
+	_, err := execute(t, &BuyYes{MarketID: 1, Amount: 5, MaxPrice: 20}, &MockRules{}, mu, 1_000, trader, ids.Empty)
+	require.NoError(err)
+	_, err = execute(t, &BuyNo{MarketID: 1, Amount: 10, MaxPrice: 30}, &MockRules{}, mu, 1_000, trader, ids.Empty)
+	require.NoError(err)
+
+	market, err := storage.GetMarket(ctx, mu, 1)
+	require.NoError(err)
+	require.Equal(uint64(400), market.Collateral)
+
+	expiry := 10_000 + consts.DefaultResolutionGracePeriod + 1
+	_, err = execute(t, &ExpireMarket{MarketID: 1}, &MockRules{}, mu, expiry, codec.Address{0x09}, ids.Empty)
+	require.NoError(err)
+	_, err = execute(t, &Claim{MarketID: 1}, &MockRules{}, mu, expiry, trader, ids.Empty)
+	require.NoError(err)
+
+	balance, err := storage.GetBalance(ctx, mu, trader)
+	require.NoError(err)
+	require.Equal(uint64(1_000), balance, "Expired markets should refund traders in full")
+}
@@ -2,17 +2,16 @@ package actions
 
 import (
 	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
 
+	"github.com/ava-labs/avalanchego/database"
 	"github.com/ava-labs/avalanchego/ids"
 	"github.com/ava-labs/avalanchego/utils/wrappers"
 	"github.com/ava-labs/hypersdk/chain"
 	"github.com/ava-labs/hypersdk/codec"
 	"github.com/ava-labs/hypersdk/state"
-	// "github.com/ava-labs/hypersdk/utils" // Removed as RandomID is not in this hypersdk version
-	"crypto/rand"
-	"encoding/binary"
 
 	"github.com/chokosabe/predictionvm/consts"
 	"github.com/chokosabe/predictionvm/storage"
@@ -20,18 +19,37 @@ import (
 
 const (
 	// CreateMarketComputeUnits reflects state reads (checking if market ID exists) and writes (new market, creator balance if fees apply)
+	// Genesis may override it with consts.CreateMarketComputeUnitsKey.
 	CreateMarketComputeUnits = 2000 // Placeholder
-	MaxCreateMarketSize      = 1024 // Placeholder, depends on description length and oracle parameters
+	// MaxCreateMarketSize is the size of a CreateMarket whose description,
+	// oracle source and oracle parameters are as long as allowed.
+	MaxCreateMarketSize = 1 + // Type ID
+		2 + consts.MaxDescriptionSize + // Description
+		8 + 8 + 1 + // EndTime, ResolutionTime, OracleType
+		2 + consts.MaxOracleSourceSize + // OracleSource
+		4 + storage.MaxOracleParametersSize + // OracleParameters
+		8 + 2 // ResolutionGracePeriod, MinOracleReputation
 )
 
 var (
-	ErrUnmarshalEmptyCreateMarket  = errors.New("cannot unmarshal empty bytes as CreateMarket action")
-	ErrDescriptionTooLong          = errors.New("market description is too long")
-	ErrOracleSourceTooLong         = errors.New("oracle source is too long")
-	ErrOracleParametersTooLong     = errors.New("oracle parameters are too long")
-	ErrEndTimeInPast               = errors.New("market end time is in the past")
-	ErrResolutionTimeBeforeEndTime = errors.New("market resolution time is before or at end time")
-	_                              chain.Action = (*CreateMarket)(nil)
+	ErrUnmarshalEmptyCreateMarket       = errors.New("cannot unmarshal empty bytes as CreateMarket action")
+	ErrDescriptionTooLong               = errors.New("market description is too long")
+	ErrOracleSourceTooLong              = errors.New("oracle source is too long")
+	ErrOracleParametersTooLong          = errors.New("oracle parameters are too long")
+	ErrMarketDataTooLarge               = errors.New("serialized market is too large")
+	ErrEndTimeInPast                    = errors.New("market end time is in the past")
+	ErrMarketDurationTooShort           = errors.New("market closes sooner than the minimum duration")
+	ErrMarketDurationTooLong            = errors.New("market closes later than the maximum duration")
+	ErrResolutionTimeBeforeEndTime      = errors.New("market resolution time is before or at end time")
+	ErrNegativeGracePeriod              = errors.New("market resolution grace period cannot be negative")
+	ErrGracePeriodTooLong               = errors.New("market resolution grace period exceeds the maximum")
+	ErrReputationRequiresManual         = errors.New("a minimum oracle reputation can only be required for manual markets")
+	ErrOracleReputationTooLow           = errors.New("oracle reputation is below the required minimum")
+	ErrMarketExists                     = errors.New("market already exists")
+	ErrUnmarshalEmptyCreateMarketResult = errors.New("cannot unmarshal empty bytes as CreateMarketResult")
+
+	_ chain.Action = (*CreateMarket)(nil)
+	_ codec.Typed  = (*CreateMarketResult)(nil)
 )
 
 // CreateMarket represents an action to create a new prediction market.
@@ -42,6 +60,25 @@ type CreateMarket struct {
 	OracleType       uint8  `serialize:"true" json:"oracleType"`
 	OracleSource     string `serialize:"true" json:"oracleSource"`
 	OracleParameters []byte `serialize:"true" json:"oracleParameters"`
+	// ResolutionGracePeriod overrides the chain's resolution grace period
+	// (in milliseconds) for this market. Zero uses the chain default.
+	ResolutionGracePeriod int64 `serialize:"true" json:"resolutionGracePeriod"`
+	// MinOracleReputation, when non-zero, requires the market's oracle to have
+	// at least this reputation (in basis points, see storage.OracleStats).
+	MinOracleReputation uint16 `serialize:"true" json:"minOracleReputation"`
+}
+
+// oracle returns the address that will resolve the market, or the empty
+// address for oracle types that are not resolved by an address.
+func (cm *CreateMarket) oracle(actor codec.Address) codec.Address {
+	if cm.OracleType != consts.OracleTypeManual {
+		return codec.EmptyAddress
+	}
+	params, err := storage.DecodeManualParameters(cm.OracleParameters)
+	if err != nil {
+		return actor // Rejected in Execute
+	}
+	return params.ResolverFor(&storage.Market{Creator: actor})
 }
 
 func (*CreateMarket) GetTypeID() uint8 {
@@ -79,15 +116,28 @@ func UnmarshalCreateMarket(bytes []byte) (chain.Action, error) {
 	return t, nil
 }
 
+// MarketIDFromActionID derives the ID of the market created by the action
+// with [actionID]. Action IDs are unique and the same on every validator, so
+// the ID can be computed before the action executes.
+func MarketIDFromActionID(actionID ids.ID) uint64 {
+	return binary.BigEndian.Uint64(actionID[:8])
+}
+
 // StateKeys defines which state keys are read/written by this action.
 func (cm *CreateMarket) StateKeys(actor codec.Address, actionID ids.ID) state.Keys {
-	// We will write to a new market key (ID generated in Execute) and potentially actor's balance for fees.
-	return state.Keys{
-		string(storage.StateKeysBalance(actor)): state.Write, // Assuming a potential fee
-		// A generic prefix indicating a write to the market space.
-		// The exact key isn't known until Execute.
-		string([]byte{storage.MarketPrefix}): state.Write,
+	marketID := MarketIDFromActionID(actionID)
+	keys := withStatusIndexKeys(state.Keys{
+		string(storage.MarketKey(marketID)):                                state.All,
+		string(storage.MarketStateKey(marketID)):                           state.All,
+		string(storage.IndexKey(storage.PendingResolutionIndex, marketID)): state.All,
+		string(storage.CreatorIndexKey(actor, marketID)):                   state.All,
+		string(storage.EndTimeIndexKey(cm.EndTime, marketID)):              state.All,
+		string(storage.BalanceKey(actor)):                                  state.Read | state.Write,
+	}, marketID, storage.MarketStatus_Open)
+	if cm.MinOracleReputation > 0 {
+		keys[string(storage.OracleStatsKey(cm.oracle(actor)))] = state.Read
 	}
+	return keys
 }
 
 // Execute performs the logic for the CreateMarket action.
@@ -103,69 +153,111 @@ func (cm *CreateMarket) Execute(
 	if len(cm.Description) == 0 {
 		return nil, errors.New("market description cannot be empty")
 	}
-	if len(cm.Description) > 256 { // Example max length
-		return nil, ErrDescriptionTooLong
+	if limit := MaxDescriptionSize(rules); uint64(len(cm.Description)) > limit {
+		return nil, fmt.Errorf("%w: %d bytes exceeds the maximum of %d", ErrDescriptionTooLong, len(cm.Description), limit)
 	}
-	if len(cm.OracleSource) > 256 { // Example max length
-		return nil, ErrOracleSourceTooLong
+	if limit := MaxOracleSourceSize(rules); uint64(len(cm.OracleSource)) > limit {
+		return nil, fmt.Errorf("%w: %d bytes exceeds the maximum of %d", ErrOracleSourceTooLong, len(cm.OracleSource), limit)
 	}
-	if len(cm.OracleParameters) > 512 { // Example max length
-		return nil, ErrOracleParametersTooLong
+	if limit := MaxOracleParametersSize(rules); uint64(len(cm.OracleParameters)) > limit {
+		return nil, fmt.Errorf("%w: %d bytes exceeds the maximum of %d", ErrOracleParametersTooLong, len(cm.OracleParameters), limit)
 	}
 	if cm.EndTime <= timestamp {
 		return nil, ErrEndTimeInPast
 	}
+	duration := cm.EndTime - timestamp
+	if minDuration := MinMarketDuration(rules); duration < minDuration {
+		return nil, fmt.Errorf("%w: %d < %d", ErrMarketDurationTooShort, duration, minDuration)
+	}
+	if maxDuration := MaxMarketDuration(rules); maxDuration > 0 && duration > maxDuration {
+		return nil, fmt.Errorf("%w: %d > %d", ErrMarketDurationTooLong, duration, maxDuration)
+	}
 	if cm.ResolutionTime <= cm.EndTime {
 		return nil, ErrResolutionTimeBeforeEndTime
 	}
-
-	// TODO: Deduct market creation fee from actor if applicable
-	// fee := rules.GetCreateMarketFee() // Assuming such a method exists on rules
-	// if err := storage.DeductBalance(ctx, mu, actor, fee); err != nil {
-	// 	return nil, fmt.Errorf("failed to deduct creation fee: %w", err)
-	// }
-
-	randomBytes := make([]byte, ids.IDLen)
-	_, err := rand.Read(randomBytes)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate random bytes for market ID: %w", err)
+	if cm.ResolutionGracePeriod < 0 {
+		return nil, ErrNegativeGracePeriod
+	}
+	if cm.ResolutionGracePeriod > consts.MaxResolutionGracePeriod {
+		return nil, fmt.Errorf("%w: %d > %d", ErrGracePeriodTooLong, cm.ResolutionGracePeriod, consts.MaxResolutionGracePeriod)
 	}
-	marketID, err := ids.ToID(randomBytes)
+	oracleParams, err := storage.DecodeOracleParameters(cm.OracleType, cm.OracleParameters)
 	if err != nil {
-		// This should ideally not happen if randomBytes is correct length and not all zeros
-		return nil, fmt.Errorf("failed to convert random bytes to market ID: %w", err)
+		return nil, err
+	}
+	if err := oracleParams.Verify(); err != nil {
+		return nil, err
+	}
+	oracle := cm.oracle(actor)
+	if cm.MinOracleReputation > 0 {
+		if cm.OracleType != consts.OracleTypeManual {
+			return nil, ErrReputationRequiresManual
+		}
+		stats, err := storage.GetOracleStats(ctx, mu, oracle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get stats of oracle %s: %w", oracle, err)
+		}
+		if reputation := stats.Reputation(); reputation < cm.MinOracleReputation {
+			return nil, fmt.Errorf("%w: oracle %s has %d, required %d", ErrOracleReputationTooLow, oracle, reputation, cm.MinOracleReputation)
+		}
+	}
+
+	marketID := MarketIDFromActionID(actionID)
+	if _, err := storage.GetMarket(ctx, mu, marketID); err == nil {
+		return nil, fmt.Errorf("%w: %d", ErrMarketExists, marketID)
+	} else if !errors.Is(err, database.ErrNotFound) {
+		return nil, fmt.Errorf("failed to check for existing market %d: %w", marketID, err)
 	}
-	
-	// Convert ids.ID to uint64 for storage.Market.ID
-	// Ensure this conversion is appropriate for your ID scheme.
-	// If storage.Market.ID should be ids.ID, then this conversion is not needed
-	// and storage.MarketKey, etc., would need to handle ids.ID.
-	// For now, assuming storage.Market.ID is uint64.
-	// We'll use the first 8 bytes of the ids.ID for the uint64 market ID.
-	marketIDUint64 := binary.BigEndian.Uint64(marketID[:8])
 
+	// The creator escrows the sweep deposit, which is paid to whoever sweeps
+	// the market once it is settled.
+	deposit := SweepDeposit(rules)
+	if err := storage.DeductBalance(ctx, mu, actor, deposit); err != nil {
+		return nil, fmt.Errorf("failed to escrow sweep deposit: %w", err)
+	}
 
 	market := &storage.Market{
-		ID:               marketIDUint64,
-		Description:      cm.Description,
-		Creator:          actor,
-		EndTime:          cm.EndTime,
-		ResolutionTime:   cm.ResolutionTime,
-		Status:           storage.MarketStatus_Open,
-		TotalYesShares:   0,
-		TotalNoShares:    0,
-		OracleType:       cm.OracleType,
-		OracleSource:     cm.OracleSource,
-		OracleParameters: cm.OracleParameters,
-		ResolvedOutcome:  storage.Outcome_Pending,
+		ID:                    marketID,
+		Description:           cm.Description,
+		Creator:               actor,
+		EndTime:               cm.EndTime,
+		ResolutionTime:        cm.ResolutionTime,
+		Status:                storage.MarketStatus_Open,
+		TotalYesShares:        0,
+		TotalNoShares:         0,
+		OracleType:            cm.OracleType,
+		OracleSource:          cm.OracleSource,
+		OracleParameters:      cm.OracleParameters,
+		ResolvedOutcome:       storage.Outcome_Pending,
+		ResolutionGracePeriod: cm.ResolutionGracePeriod,
+		Oracle:                oracle,
+		SweepDeposit:          deposit,
+	}
+	if err := CheckMarketDataSize(rules, market); err != nil {
+		return nil, err
 	}
 
 	if err := storage.SetMarket(ctx, mu, market); err != nil {
 		return nil, fmt.Errorf("failed to set new market %d: %w", market.ID, err)
 	}
+	if err := storage.AddToIndex(ctx, mu, storage.PendingResolutionIndex, market.ID); err != nil {
+		return nil, fmt.Errorf("failed to index new market %d: %w", market.ID, err)
+	}
 
-	resultMsg := fmt.Sprintf("Market %d created successfully by %s", market.ID, actor.String())
-	return []byte(resultMsg), nil
+	return (&CreateMarketResult{MarketID: market.ID}).Bytes(), nil
+}
+
+// CheckMarketDataSize returns ErrMarketDataTooLarge if the metadata of
+// [market] serializes to more than MaxMarketDataSize.
+func CheckMarketDataSize(rules chain.Rules, market *storage.Market) error {
+	data, err := storage.EncodeMarket(market)
+	if err != nil {
+		return err
+	}
+	if limit := MaxMarketDataSize(rules); uint64(len(data)) > limit {
+		return fmt.Errorf("%w: %d bytes exceeds the maximum of %d", ErrMarketDataTooLarge, len(data), limit)
+	}
+	return nil
 }
 
 // ComputeUnits estimates the computational cost of the CreateMarket action.
@@ -175,10 +267,50 @@ func (cm *CreateMarket) ComputeUnits(rules chain.Rules) uint64 {
 	// units += rules.GetCostPerByte(uint64(len(cm.Description)))
 	// units += rules.GetCostPerByte(uint64(len(cm.OracleSource)))
 	// units += rules.GetCostPerByte(uint64(len(cm.OracleParameters)))
-	return CreateMarketComputeUnits // Placeholder
+	return fetchPositive(rules, consts.CreateMarketComputeUnitsKey, uint64(CreateMarketComputeUnits))
 }
 
 // ValidRange defines the time range during which the action is valid.
 func (*CreateMarket) ValidRange(rules chain.Rules) (start int64, end int64) {
 	return -1, -1 // Always valid unless specific rules apply
 }
+
+// CreateMarketResult is the output of a CreateMarket action.
+type CreateMarketResult struct {
+	MarketID uint64 `serialize:"true" json:"marketId"`
+}
+
+func (*CreateMarketResult) GetTypeID() uint8 {
+	return consts.CreateMarketID
+}
+
+// Bytes serializes the CreateMarketResult, prefixed with its typeID.
+func (r *CreateMarketResult) Bytes() []byte {
+	p := &wrappers.Packer{
+		Bytes:   make([]byte, 0, 1+8),
+		MaxSize: 1 + 8,
+	}
+	p.PackByte(consts.CreateMarketID)
+	if err := codec.LinearCodec.MarshalInto(r, p); err != nil {
+		panic(fmt.Errorf("failed to marshal CreateMarketResult: %w", err))
+	}
+	return p.Bytes
+}
+
+// UnmarshalCreateMarketResult deserializes bytes into a CreateMarketResult.
+func UnmarshalCreateMarketResult(bytes []byte) (codec.Typed, error) {
+	if len(bytes) == 0 {
+		return nil, ErrUnmarshalEmptyCreateMarketResult
+	}
+	if bytes[0] != consts.CreateMarketID {
+		return nil, fmt.Errorf("unexpected CreateMarketResult typeID: %d != %d", bytes[0], consts.CreateMarketID)
+	}
+	r := &CreateMarketResult{}
+	if err := codec.LinearCodec.UnmarshalFrom(
+		&wrappers.Packer{Bytes: bytes[1:]},
+		r,
+	); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal CreateMarketResult: %w", err)
+	}
+	return r, nil
+}
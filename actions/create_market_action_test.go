@@ -0,0 +1,287 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/hypersdk/chain/chaintest"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chokosabe/predictionvm/consts"
+	"github.com/chokosabe/predictionvm/storage"
+)
+
+func TestCreateMarket_Execute_OracleParameters(t *testing.T) {
+	publisher := codec.Address{0x03}
+	validPriceFeed := (&storage.PriceFeedParameters{FeedID: 1, Window: 60_000, Threshold: 100, Publisher: publisher}).Bytes()
+
+	testCases := []struct {
+		name        string
+		oracleType  uint8
+		params      []byte
+		expectedErr error
+	}{
+		{
+			name:       "ManualWithoutParameters",
+			oracleType: consts.OracleTypeManual,
+		},
+		{
+			name:       "ManualWithResolver",
+			oracleType: consts.OracleTypeManual,
+			params:     (&storage.ManualParameters{Resolver: codec.Address{0x05}}).Bytes(),
+		},
+		{
+			name:       "PriceFeed",
+			oracleType: consts.OracleTypePriceFeed,
+			params:     validPriceFeed,
+		},
+		{
+			name:        "PriceFeedWithoutParameters",
+			oracleType:  consts.OracleTypePriceFeed,
+			expectedErr: storage.ErrMalformedOracleParameters,
+		},
+		{
+			name:        "UnknownVersion",
+			oracleType:  consts.OracleTypePriceFeed,
+			params:      append([]byte{storage.OracleParametersV1 + 1}, validPriceFeed[1:]...),
+			expectedErr: storage.ErrUnknownOracleParametersVersion,
+		},
+		{
+			name:        "Truncated",
+			oracleType:  consts.OracleTypePriceFeed,
+			params:      validPriceFeed[:len(validPriceFeed)-1],
+			expectedErr: storage.ErrMalformedOracleParameters,
+		},
+		{
+			name:        "TrailingBytes",
+			oracleType:  consts.OracleTypePriceFeed,
+			params:      append(validPriceFeed, 0x00),
+			expectedErr: storage.ErrMalformedOracleParameters,
+		},
+		{
+			name:        "ParametersOfAnotherOracleType",
+			oracleType:  consts.OracleTypePriceFeed,
+			params:      (&storage.ManualParameters{}).Bytes(),
+			expectedErr: storage.ErrMalformedOracleParameters,
+		},
+		{
+			name:        "ZeroWindow",
+			oracleType:  consts.OracleTypePriceFeed,
+			params:      (&storage.PriceFeedParameters{FeedID: 1, Threshold: 100, Publisher: publisher}).Bytes(),
+			expectedErr: storage.ErrInvalidOracleParameters,
+		},
+		{
+			name:        "ZeroThreshold",
+			oracleType:  consts.OracleTypePriceFeed,
+			params:      (&storage.PriceFeedParameters{FeedID: 1, Window: 60_000, Publisher: publisher}).Bytes(),
+			expectedErr: storage.ErrInvalidOracleParameters,
+		},
+		{
+			name:        "NoPublisher",
+			oracleType:  consts.OracleTypePriceFeed,
+			params:      (&storage.PriceFeedParameters{FeedID: 1, Window: 60_000, Threshold: 100}).Bytes(),
+			expectedErr: storage.ErrInvalidOracleParameters,
+		},
+		{
+			name:        "UnknownOracleType",
+			oracleType:  0xff,
+			params:      validPriceFeed,
+			expectedErr: storage.ErrUnknownOracleType,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+			mu := chaintest.NewInMemoryStore()
+			require.NoError(storage.SetBalance(context.Background(), mu, codec.Address{0x01}, consts.DefaultSweepDeposit))
+
+			action := &CreateMarket{
+				Description:      "Will the oracle parameters validate?",
+				EndTime:          5_000,
+				ResolutionTime:   10_000,
+				OracleType:       tc.oracleType,
+				OracleParameters: tc.params,
+			}
+			_, err := execute(t, action, &MockRules{}, mu, 1_000, codec.Address{0x01}, ids.Empty)
+			require.ErrorIs(err, tc.expectedErr)
+		})
+	}
+}
+
+func TestCreateMarket_Execute_DeterministicID(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	mu := chaintest.NewInMemoryStore()
+	actor := codec.Address{0x01}
+	actionID := ids.ID{0, 0, 0, 0, 0, 0, 0x12, 0x34, 0xff}
+	require.NoError(storage.SetBalance(ctx, mu, actor, consts.DefaultSweepDeposit))
+
+	action := &CreateMarket{
+		Description:    "Is the market ID derived from the action ID?",
+		EndTime:        5_000,
+		ResolutionTime: 10_000,
+		OracleType:     consts.OracleTypeManual,
+	}
+	output, err := execute(t, action, &MockRules{}, mu, 1_000, actor, actionID)
+	require.NoError(err)
+
+	result, err := UnmarshalCreateMarketResult(output)
+	require.NoError(err)
+	require.Equal(&CreateMarketResult{MarketID: 0x1234}, result)
+	require.Equal(uint64(0x1234), MarketIDFromActionID(actionID))
+	require.Contains(action.StateKeys(actor, actionID), string(storage.MarketKey(0x1234)))
+
+	market, err := storage.GetMarket(ctx, mu, 0x1234)
+	require.NoError(err)
+	require.Equal(action.Description, market.Description)
+	require.Equal(actor, market.Creator)
+
+	_, err = execute(t, action, &MockRules{}, mu, 1_000, actor, actionID)
+	require.ErrorIs(err, ErrMarketExists)
+}
+
+func TestCreateMarket_Execute_ProtocolParams(t *testing.T) {
+	params := &MockRules{
+		FetchCustomFunc: func(key string) (any, bool) {
+			switch key {
+			case consts.MinMarketDurationKey:
+				return int64(1_000), true
+			case consts.MaxMarketDurationKey:
+				return int64(10_000), true
+			case consts.MaxDescriptionSizeKey:
+				return uint64(8), true
+			case consts.CreateMarketComputeUnitsKey:
+				return uint64(5_000), true
+			}
+			return nil, false
+		},
+	}
+	require.Equal(t, uint64(5_000), (&CreateMarket{}).ComputeUnits(params))
+	require.Equal(t, uint64(CreateMarketComputeUnits), (&CreateMarket{}).ComputeUnits(&MockRules{}))
+
+	testCases := []struct {
+		name        string
+		description string
+		endTime     int64
+		expectedErr error
+	}{
+		{
+			name:        "WithinLimits",
+			description: "Will it?",
+			endTime:     5_000,
+		},
+		{
+			name:        "DescriptionTooLong",
+			description: "Will it rain?",
+			endTime:     5_000,
+			expectedErr: ErrDescriptionTooLong,
+		},
+		{
+			name:        "DurationTooShort",
+			description: "Will it?",
+			endTime:     1_999,
+			expectedErr: ErrMarketDurationTooShort,
+		},
+		{
+			name:        "DurationTooLong",
+			description: "Will it?",
+			endTime:     11_001,
+			expectedErr: ErrMarketDurationTooLong,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+			mu := chaintest.NewInMemoryStore()
+			require.NoError(storage.SetBalance(context.Background(), mu, codec.Address{0x01}, consts.DefaultSweepDeposit))
+
+			action := &CreateMarket{
+				Description:    tc.description,
+				EndTime:        tc.endTime,
+				ResolutionTime: tc.endTime + 1_000,
+				OracleType:     consts.OracleTypeManual,
+			}
+			_, err := execute(t, action, params, mu, 1_000, codec.Address{0x01}, ids.Empty)
+			require.ErrorIs(err, tc.expectedErr)
+		})
+	}
+}
+
+func TestCreateMarket_Execute_MarketDataSize(t *testing.T) {
+	require := require.New(t)
+	mu := chaintest.NewInMemoryStore()
+	require.NoError(storage.SetBalance(context.Background(), mu, codec.Address{0x01}, consts.DefaultSweepDeposit))
+	rules := &MockRules{
+		FetchCustomFunc: func(key string) (any, bool) {
+			if key == consts.MaxMarketDataSizeKey {
+				return uint64(64), true
+			}
+			return nil, false
+		},
+	}
+
+	action := &CreateMarket{
+		Description:    "Will the market fit?",
+		EndTime:        5_000,
+		ResolutionTime: 6_000,
+		OracleType:     consts.OracleTypeManual,
+	}
+	_, err := execute(t, action, rules, mu, 1_000, codec.Address{0x01}, ids.Empty)
+	require.ErrorIs(err, ErrMarketDataTooLarge)
+	_, err = execute(t, action, &MockRules{}, mu, 1_000, codec.Address{0x01}, ids.Empty)
+	require.NoError(err)
+}
+
+func TestCreateMarket_MaxSize(t *testing.T) {
+	require := require.New(t)
+	action := &CreateMarket{
+		Description:           strings.Repeat("d", consts.MaxDescriptionSize),
+		EndTime:               math.MaxInt64,
+		ResolutionTime:        math.MaxInt64,
+		OracleType:            math.MaxUint8,
+		OracleSource:          strings.Repeat("s", consts.MaxOracleSourceSize),
+		OracleParameters:      bytes.Repeat([]byte{0xff}, storage.MaxOracleParametersSize),
+		ResolutionGracePeriod: math.MaxInt64,
+		MinOracleReputation:   math.MaxUint16,
+	}
+	b := action.Bytes()
+	require.Len(b, MaxCreateMarketSize)
+
+	parsed, err := UnmarshalCreateMarket(b)
+	require.NoError(err)
+	require.Equal(action, parsed)
+}
+
+func TestCreateMarket_Execute_GracePeriod(t *testing.T) {
+	testCases := []struct {
+		name        string
+		gracePeriod int64
+		expectedErr error
+	}{
+		{"Maximum", consts.MaxResolutionGracePeriod, nil},
+		{"Negative", -1, ErrNegativeGracePeriod},
+		{"AboveMaximum", consts.MaxResolutionGracePeriod + 1, ErrGracePeriodTooLong},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+			mu := chaintest.NewInMemoryStore()
+			require.NoError(storage.SetBalance(context.Background(), mu, codec.Address{0x01}, consts.DefaultSweepDeposit))
+
+			action := &CreateMarket{
+				Description:           "Will the grace period validate?",
+				EndTime:               5_000,
+				ResolutionTime:        10_000,
+				OracleType:            consts.OracleTypeManual,
+				ResolutionGracePeriod: tc.gracePeriod,
+			}
+			_, err := execute(t, action, &MockRules{}, mu, 1_000, codec.Address{0x01}, ids.Empty)
+			require.ErrorIs(err, tc.expectedErr)
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/wrappers"
+	"github.com/ava-labs/hypersdk/chain"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/ava-labs/hypersdk/state"
+
+	"github.com/chokosabe/predictionvm/consts"
+	"github.com/chokosabe/predictionvm/storage"
+)
+
+const (
+	DisputeMarketComputeUnits = 1000
+	MaxDisputeMarketSize      = 64
+)
+
+var (
+	ErrUnmarshalEmptyDisputeMarket              = errors.New("cannot unmarshal empty bytes as DisputeMarket action")
+	ErrMarketNotDisputable                      = errors.New("market resolution cannot be disputed")
+	ErrDisputeWindowClosed                      = errors.New("market dispute window has closed")
+	ErrOracleMismatch                           = errors.New("oracle does not match the market's oracle")
+	_                              chain.Action = (*DisputeMarket)(nil)
+)
+
+// DisputeMarket challenges the initial ruling of a manual market during the
+// dispute window. The actor escrows the chain's dispute bond and proposes the
+// outcome the market should resolve to instead (YES, NO or Invalid). The
+// oracle does not rule on its own dispute: the PRED stakers vote on it through
+// VoteAppeal and FinalizeAppeal settles it.
+//
+// [Oracle] must name the market's resolver so that its statistics key can be
+// declared in StateKeys.
+type DisputeMarket struct {
+	MarketID        uint64        `serialize:"true" json:"marketId"`
+	ProposedOutcome uint8         `serialize:"true" json:"proposedOutcome"`
+	Oracle          codec.Address `serialize:"true" json:"oracle"`
+}
+
+func (*DisputeMarket) GetTypeID() uint8 {
+	return consts.DisputeMarketID
+}
+
+// Bytes serializes the DisputeMarket action.
+func (d *DisputeMarket) Bytes() []byte {
+	p := &wrappers.Packer{
+		Bytes:   make([]byte, 0, MaxDisputeMarketSize),
+		MaxSize: MaxDisputeMarketSize,
+	}
+	p.PackByte(consts.DisputeMarketID)
+	if err := codec.LinearCodec.MarshalInto(d, p); err != nil {
+		panic(fmt.Errorf("failed to marshal DisputeMarket action: %w", err))
+	}
+	return p.Bytes
+}
+
+// UnmarshalDisputeMarket deserializes bytes into a DisputeMarket action.
+func UnmarshalDisputeMarket(bytes []byte) (chain.Action, error) {
+	if len(bytes) == 0 {
+		return nil, ErrUnmarshalEmptyDisputeMarket
+	}
+	if bytes[0] != consts.DisputeMarketID {
+		return nil, fmt.Errorf("unexpected DisputeMarket typeID: %d != %d", bytes[0], consts.DisputeMarketID)
+	}
+	d := &DisputeMarket{}
+	if err := codec.LinearCodec.UnmarshalFrom(
+		&wrappers.Packer{Bytes: bytes[1:]},
+		d,
+	); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal DisputeMarket action: %w", err)
+	}
+	return d, nil
+}
+
+// StateKeys implements chain.Action
+func (d *DisputeMarket) StateKeys(actor codec.Address, _ ids.ID) state.Keys {
+	return withStatusIndexKeys(state.Keys{
+		string(storage.MarketKey(d.MarketID)):      state.Read | state.Write,
+		string(storage.MarketStateKey(d.MarketID)): state.All,
+		string(storage.DisputeKey(d.MarketID)):     state.All,
+		string(storage.BalanceKey(actor)):          state.Read | state.Write,
+		string(storage.OracleStatsKey(d.Oracle)):   state.All,
+	}, d.MarketID,
+		storage.MarketStatus_ResolvedYes, storage.MarketStatus_ResolvedNo, storage.MarketStatus_Disputed,
+	)
+}
+
+// Execute escrows the bond and opens the vote on the dispute.
+func (d *DisputeMarket) Execute(
+	ctx context.Context,
+	rules chain.Rules,
+	mu state.Mutable,
+	timestamp int64,
+	actor codec.Address,
+	_ ids.ID,
+) ([]byte, error) {
+	market, err := storage.GetMarket(ctx, mu, d.MarketID)
+	if err != nil {
+		return nil, err
+	}
+	if market.OracleType != consts.OracleTypeManual {
+		return nil, fmt.Errorf("%w: market %d is resolved by oracle type %d", ErrMarketNotDisputable, d.MarketID, market.OracleType)
+	}
+	if market.Status != storage.MarketStatus_ResolvedYes && market.Status != storage.MarketStatus_ResolvedNo {
+		return nil, fmt.Errorf("%w: market %d (status: %s)", ErrMarketNotDisputable, d.MarketID, market.Status)
+	}
+	if market.Tier != 0 {
+		return nil, fmt.Errorf("%w: market %d was already disputed", ErrMarketNotDisputable, d.MarketID)
+	}
+	if disputableUntil := market.ResolvedAt + DisputeWindow(rules); timestamp > disputableUntil {
+		return nil, fmt.Errorf("%w: market %d could be disputed until %d (current: %d)", ErrDisputeWindowClosed, d.MarketID, disputableUntil, timestamp)
+	}
+	proposed := storage.OutcomeType(d.ProposedOutcome)
+	switch proposed {
+	case storage.Outcome_Yes, storage.Outcome_No, storage.Outcome_Invalid:
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrInvalidOutcome, proposed)
+	}
+	if proposed == market.ResolvedOutcome {
+		return nil, fmt.Errorf("%w: market %d resolved %s", ErrInvalidProposedOutcome, d.MarketID, proposed)
+	}
+	oracle, err := manualResolver(market)
+	if err != nil {
+		return nil, err
+	}
+	if oracle != d.Oracle {
+		return nil, fmt.Errorf("%w: market %d is resolved by %s", ErrOracleMismatch, d.MarketID, oracle)
+	}
+
+	bond := DisputeBond(rules)
+	if err := storage.DeductBalance(ctx, mu, actor, bond); err != nil {
+		return nil, fmt.Errorf("failed to escrow dispute bond %d from %s: %w", bond, actor, err)
+	}
+	if err := storage.SetDispute(ctx, mu, &storage.Dispute{
+		MarketID:        d.MarketID,
+		Disputer:        actor,
+		Bond:            bond,
+		DisputedOutcome: market.ResolvedOutcome,
+		ProposedOutcome: proposed,
+		CreatedAt:       timestamp,
+		VotingEndsAt:    timestamp + AppealVotingPeriod(rules),
+		Status:          storage.DisputeStatus_Pending,
+	}); err != nil {
+		return nil, err
+	}
+
+	stats, err := storage.GetOracleStats(ctx, mu, oracle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stats of oracle %s: %w", oracle, err)
+	}
+	stats.Disputed++
+	if err := storage.SetOracleStats(ctx, mu, oracle, stats); err != nil {
+		return nil, err
+	}
+
+	market.Status = storage.MarketStatus_Disputed
+	if err := storage.SetMarket(ctx, mu, market); err != nil {
+		return nil, fmt.Errorf("failed to update disputed market %d: %w", d.MarketID, err)
+	}
+	return nil, nil
+}
+
+// ComputeUnits implements chain.Action
+func (*DisputeMarket) ComputeUnits(chain.Rules) uint64 {
+	return DisputeMarketComputeUnits
+}
+
+// ValidRange implements chain.Action
+func (*DisputeMarket) ValidRange(chain.Rules) (int64, int64) {
+	return -1, -1 // Always valid
+}
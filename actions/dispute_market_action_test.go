@@ -0,0 +1,316 @@
+package actions
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/hypersdk/chain/chaintest"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chokosabe/predictionvm/consts"
+	"github.com/chokosabe/predictionvm/storage"
+)
+
+var (
+	testOracle   = codec.Address{0x02}
+	testDisputer = codec.Address{0x07}
+)
+
+// disputeVotingEnd is when voting on a dispute submitted at 13_000 ends.
+const disputeVotingEnd = 13_000 + consts.DefaultAppealVotingPeriod
+
+// setResolvedManualMarket stores a manual market holding 500 of collateral,
+// created and resolved YES by testOracle at time 12_000, two seconds after its
+// resolution time. testDisputer can afford the dispute bond, and testStakerA
+// and testStakerB stake 100 and 60.
+func setResolvedManualMarket(t *testing.T, mu *chaintest.InMemoryStore) {
+	require := require.New(t)
+	ctx := context.Background()
+	setPendingMarket(t, mu, &storage.Market{
+		ID:             1,
+		Description:    "Disputable market",
+		Status:         storage.MarketStatus_TradingClosed,
+		Creator:        testOracle,
+		EndTime:        5_000,
+		ResolutionTime: 10_000,
+		OracleType:     consts.OracleTypeManual,
+		Oracle:         testOracle,
+		Collateral:     500,
+	})
+	_, err := execute(t, &ResolveMarket{MarketID: 1, Outcome: uint8(storage.Outcome_Yes)}, &MockRules{}, mu, 12_000, testOracle, ids.Empty)
+	require.NoError(err)
+	require.NoError(storage.SetBalance(ctx, mu, testDisputer, consts.DefaultDisputeBond))
+
+	for staker, amount := range map[codec.Address]uint64{testStakerA: 100, testStakerB: 60} {
+		require.NoError(storage.SetBalance(ctx, mu, staker, amount))
+		_, err = execute(t, &Stake{Amount: amount}, &MockRules{}, mu, 0, staker, ids.Empty)
+		require.NoError(err)
+	}
+}
+
+// disputeAndVote disputes the YES ruling of setResolvedManualMarket at 13_000,
+// proposing [proposed], and casts the given votes.
+func disputeAndVote(t *testing.T, mu *chaintest.InMemoryStore, proposed storage.OutcomeType, votes map[codec.Address]bool) {
+	require := require.New(t)
+	_, err := execute(t, &DisputeMarket{MarketID: 1, ProposedOutcome: uint8(proposed), Oracle: testOracle}, &MockRules{}, mu, 13_000, testDisputer, ids.Empty)
+	require.NoError(err)
+	for voter, overturn := range votes {
+		_, err = execute(t, &VoteAppeal{MarketID: 1, Tier: storage.Tier_Dispute, Overturn: overturn}, &MockRules{}, mu, 13_001, voter, ids.Empty)
+		require.NoError(err)
+	}
+}
+
+func requireOracleStats(t *testing.T, mu *chaintest.InMemoryStore, expected storage.OracleStats) {
+	stats, err := storage.GetOracleStats(context.Background(), mu, testOracle)
+	require.NoError(t, err)
+	require.Equal(t, expected, *stats)
+}
+
+func requireBalance(t *testing.T, mu *chaintest.InMemoryStore, addr codec.Address, expected uint64) {
+	balance, err := storage.GetBalance(context.Background(), mu, addr)
+	require.NoError(t, err)
+	require.Equal(t, expected, balance)
+}
+
+func TestDisputeMarket_Execute_Upheld(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	mu := chaintest.NewInMemoryStore()
+	setResolvedManualMarket(t, mu)
+	requireOracleStats(t, mu, storage.OracleStats{Resolved: 1, TotalLatency: 2_000, SettledCollateral: 500})
+
+	disputeAndVote(t, mu, storage.Outcome_No, map[codec.Address]bool{testStakerA: true, testStakerB: false})
+	requireBalance(t, mu, testDisputer, 0)
+	requireOracleStats(t, mu, storage.OracleStats{Resolved: 1, Disputed: 1, TotalLatency: 2_000, SettledCollateral: 500})
+
+	market, err := storage.GetMarket(ctx, mu, 1)
+	require.NoError(err)
+	require.Equal(storage.MarketStatus_Disputed, market.Status)
+
+	// Bonds cannot be withdrawn while the dispute is pending.
+	withdraw := &WithdrawBond{MarketID: 1, Tier: storage.Tier_Dispute}
+	_, err = execute(t, withdraw, &MockRules{}, mu, 14_000, testDisputer, ids.Empty)
+	require.ErrorIs(err, ErrNoBondToWithdraw)
+
+	_, err = execute(t, &FinalizeAppeal{MarketID: 1, Oracle: testOracle}, &MockRules{}, mu, disputeVotingEnd-1, testDisputer, ids.Empty)
+	require.ErrorIs(err, ErrAppealVotingOpen)
+
+	// The stakers overturn the ruling, upholding the dispute.
+	_, err = execute(t, &FinalizeAppeal{MarketID: 1, Oracle: testOracle}, &MockRules{}, mu, disputeVotingEnd, testDisputer, ids.Empty)
+	require.NoError(err)
+	requireOracleStats(t, mu, storage.OracleStats{Resolved: 1, Disputed: 1, DisputesLost: 1, TotalLatency: 2_000, SettledCollateral: 500, OverturnedCollateral: 500})
+
+	market, err = storage.GetMarket(ctx, mu, 1)
+	require.NoError(err)
+	require.Equal(storage.MarketStatus_ResolvedNo, market.Status)
+	require.Equal(disputeVotingEnd, market.ResolvedAt)
+	require.Equal(storage.Tier_Dispute, market.Tier)
+
+	dispute, err := storage.GetDispute(ctx, mu, 1)
+	require.NoError(err)
+	require.Equal(storage.DisputeStatus_Upheld, dispute.Status)
+	require.Equal(uint64(100), dispute.OverturnVotes)
+	require.Equal(uint64(60), dispute.UpholdVotes)
+
+	// The ruling on the dispute can still be appealed, so the bond stays in
+	// escrow until the dispute window after it has passed.
+	final := disputeVotingEnd + consts.DefaultDisputeWindow + 1
+	_, err = execute(t, withdraw, &MockRules{}, mu, final-1, testDisputer, ids.Empty)
+	require.ErrorIs(err, ErrNoBondToWithdraw)
+	_, err = execute(t, withdraw, &MockRules{}, mu, final, testOracle, ids.Empty)
+	require.ErrorIs(err, ErrNotBondBeneficiary)
+	_, err = execute(t, withdraw, &MockRules{}, mu, final, testDisputer, ids.Empty)
+	require.NoError(err)
+	requireBalance(t, mu, testDisputer, consts.DefaultDisputeBond)
+	_, err = execute(t, withdraw, &MockRules{}, mu, final, testDisputer, ids.Empty)
+	require.ErrorIs(err, ErrNoBondToWithdraw, "Bonds can only be withdrawn once")
+}
+
+func TestDisputeMarket_Execute_Rejected(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	mu := chaintest.NewInMemoryStore()
+	setResolvedManualMarket(t, mu)
+	disputeAndVote(t, mu, storage.Outcome_Invalid, map[codec.Address]bool{testStakerA: false, testStakerB: true})
+
+	// The stakers stand by the ruling and the oracle receives the bond once
+	// it is final.
+	_, err := execute(t, &FinalizeAppeal{MarketID: 1, Oracle: testOracle}, &MockRules{}, mu, disputeVotingEnd, testDisputer, ids.Empty)
+	require.NoError(err)
+	requireOracleStats(t, mu, storage.OracleStats{Resolved: 1, Disputed: 1, TotalLatency: 2_000, SettledCollateral: 500})
+
+	market, err := storage.GetMarket(ctx, mu, 1)
+	require.NoError(err)
+	require.Equal(storage.MarketStatus_ResolvedYes, market.Status)
+	require.Equal(storage.Tier_Dispute, market.Tier)
+
+	dispute, err := storage.GetDispute(ctx, mu, 1)
+	require.NoError(err)
+	require.Equal(storage.DisputeStatus_Rejected, dispute.Status)
+
+	withdraw := &WithdrawBond{MarketID: 1, Tier: storage.Tier_Dispute}
+	final := disputeVotingEnd + consts.DefaultDisputeWindow + 1
+	_, err = execute(t, withdraw, &MockRules{}, mu, final, testDisputer, ids.Empty)
+	require.ErrorIs(err, ErrNotBondBeneficiary)
+	_, err = execute(t, withdraw, &MockRules{}, mu, final, testOracle, ids.Empty)
+	require.NoError(err)
+	requireBalance(t, mu, testOracle, consts.DefaultDisputeBond)
+}
+
+func TestDisputeMarket_Execute_OracleCannotRule(t *testing.T) {
+	require := require.New(t)
+	mu := chaintest.NewInMemoryStore()
+	setResolvedManualMarket(t, mu)
+	disputeAndVote(t, mu, storage.Outcome_No, nil)
+
+	// Only the stakers settle the dispute: the oracle cannot rule on it and
+	// it does not expire while the vote is pending.
+	_, err := execute(t, &ResolveMarket{MarketID: 1, Outcome: uint8(storage.Outcome_No)}, &MockRules{}, mu, 14_000, testOracle, ids.Empty)
+	require.ErrorIs(err, ErrMarketAlreadyResolved)
+	_, err = execute(t, &ExpireMarket{MarketID: 1}, &MockRules{}, mu, 12_000+consts.DefaultResolutionGracePeriod+1, testDisputer, ids.Empty)
+	require.ErrorIs(err, ErrMarketAlreadyResolved)
+}
+
+func TestDisputeMarket_Execute_Errors(t *testing.T) {
+	ctx := context.Background()
+
+	testCases := []struct {
+		name        string
+		setup       func(*testing.T, *chaintest.InMemoryStore)
+		action      *DisputeMarket
+		timestamp   int64
+		expectedErr error
+	}{
+		{
+			name:        "MarketNotFound",
+			setup:       func(*testing.T, *chaintest.InMemoryStore) {},
+			action:      &DisputeMarket{MarketID: 1, ProposedOutcome: uint8(storage.Outcome_No), Oracle: testOracle},
+			timestamp:   13_000,
+			expectedErr: ErrMarketNotFound,
+		},
+		{
+			name: "PriceFeedMarket",
+			setup: func(t *testing.T, mu *chaintest.InMemoryStore) {
+				market := newPriceFeedMarket(1, &storage.PriceFeedParameters{FeedID: 9, Window: 1_000, Threshold: 1, Publisher: codec.Address{0x03}})
+				market.Status = storage.MarketStatus_ResolvedYes
+				require.NoError(t, storage.SetMarket(ctx, mu, market))
+			},
+			action:      &DisputeMarket{MarketID: 1, ProposedOutcome: uint8(storage.Outcome_No)},
+			timestamp:   13_000,
+			expectedErr: ErrMarketNotDisputable,
+		},
+		{
+			name: "Unresolved",
+			setup: func(t *testing.T, mu *chaintest.InMemoryStore) {
+				setPendingMarket(t, mu, &storage.Market{ID: 1, Creator: testOracle, Status: storage.MarketStatus_TradingClosed})
+			},
+			action:      &DisputeMarket{MarketID: 1, ProposedOutcome: uint8(storage.Outcome_No), Oracle: testOracle},
+			timestamp:   13_000,
+			expectedErr: ErrMarketNotDisputable,
+		},
+		{
+			name:        "WindowClosed",
+			setup:       setResolvedManualMarket,
+			action:      &DisputeMarket{MarketID: 1, ProposedOutcome: uint8(storage.Outcome_No), Oracle: testOracle},
+			timestamp:   12_000 + consts.DefaultDisputeWindow + 1,
+			expectedErr: ErrDisputeWindowClosed,
+		},
+		{
+			name:        "PendingOutcome",
+			setup:       setResolvedManualMarket,
+			action:      &DisputeMarket{MarketID: 1, ProposedOutcome: uint8(storage.Outcome_Pending), Oracle: testOracle},
+			timestamp:   13_000,
+			expectedErr: ErrInvalidOutcome,
+		},
+		{
+			name:        "SameOutcome",
+			setup:       setResolvedManualMarket,
+			action:      &DisputeMarket{MarketID: 1, ProposedOutcome: uint8(storage.Outcome_Yes), Oracle: testOracle},
+			timestamp:   13_000,
+			expectedErr: ErrInvalidProposedOutcome,
+		},
+		{
+			name:        "OracleMismatch",
+			setup:       setResolvedManualMarket,
+			action:      &DisputeMarket{MarketID: 1, ProposedOutcome: uint8(storage.Outcome_No), Oracle: testDisputer},
+			timestamp:   13_000,
+			expectedErr: ErrOracleMismatch,
+		},
+		{
+			name: "InsufficientBalance",
+			setup: func(t *testing.T, mu *chaintest.InMemoryStore) {
+				setResolvedManualMarket(t, mu)
+				require.NoError(t, storage.SetBalance(ctx, mu, testDisputer, consts.DefaultDisputeBond-1))
+			},
+			action:      &DisputeMarket{MarketID: 1, ProposedOutcome: uint8(storage.Outcome_No), Oracle: testOracle},
+			timestamp:   13_000,
+			expectedErr: storage.ErrInsufficientBalance,
+		},
+		{
+			name: "AlreadyDisputed",
+			setup: func(t *testing.T, mu *chaintest.InMemoryStore) {
+				setResolvedManualMarket(t, mu)
+				market, err := storage.GetMarket(ctx, mu, 1)
+				require.NoError(t, err)
+				market.Tier = storage.Tier_Dispute
+				require.NoError(t, storage.SetMarket(ctx, mu, market))
+			},
+			action:      &DisputeMarket{MarketID: 1, ProposedOutcome: uint8(storage.Outcome_No), Oracle: testOracle},
+			timestamp:   13_000,
+			expectedErr: ErrMarketNotDisputable,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+			mu := chaintest.NewInMemoryStore()
+			tc.setup(t, mu)
+
+			output, err := execute(t, tc.action, &MockRules{}, mu, tc.timestamp, testDisputer, ids.Empty)
+			require.ErrorIs(err, tc.expectedErr)
+			require.Nil(output)
+		})
+	}
+}
+
+func TestCreateMarket_Execute_MinOracleReputation(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	mu := chaintest.NewInMemoryStore()
+
+	action := &CreateMarket{
+		Description:         "Only for oracles with a track record",
+		EndTime:             5_000,
+		ResolutionTime:      10_000,
+		OracleType:          consts.OracleTypeManual,
+		OracleParameters:    (&storage.ManualParameters{Resolver: testOracle}).Bytes(),
+		MinOracleReputation: 9_000,
+	}
+	require.NoError(storage.SetBalance(ctx, mu, testDisputer, consts.DefaultSweepDeposit))
+	_, err := execute(t, action, &MockRules{}, mu, 1_000, testDisputer, ids.Empty)
+	require.ErrorIs(err, ErrOracleReputationTooLow, "Oracles without resolutions have no reputation")
+
+	// Reputation is weighted by collateral: overturning 1_000 of the 10_000
+	// settled gives 9_000.
+	stats := &storage.OracleStats{Resolved: storage.MinReputationSample, Disputed: 2, DisputesLost: 2, SettledCollateral: 10_000, OverturnedCollateral: 1_001}
+	require.NoError(storage.SetOracleStats(ctx, mu, testOracle, stats))
+	_, err = execute(t, action, &MockRules{}, mu, 1_000, testDisputer, ids.Empty)
+	require.ErrorIs(err, ErrOracleReputationTooLow)
+	stats.OverturnedCollateral = 1_000
+	require.NoError(storage.SetOracleStats(ctx, mu, testOracle, stats))
+	_, err = execute(t, action, &MockRules{}, mu, 1_000, testDisputer, ids.Empty)
+	require.NoError(err)
+
+	// A spotless record on too few markets earns no reputation.
+	stats = &storage.OracleStats{Resolved: storage.MinReputationSample - 1, SettledCollateral: 10_000}
+	require.NoError(storage.SetOracleStats(ctx, mu, testOracle, stats))
+	_, err = execute(t, action, &MockRules{}, mu, 1_000, testDisputer, ids.Empty)
+	require.ErrorIs(err, ErrOracleReputationTooLow)
+
+	action.OracleType = consts.OracleTypePriceFeed
+	action.OracleParameters = (&storage.PriceFeedParameters{FeedID: 1, Window: 1_000, Threshold: 1, Publisher: codec.Address{0x03}}).Bytes()
+	_, err = execute(t, action, &MockRules{}, mu, 1_000, testDisputer, ids.Empty)
+	require.ErrorIs(err, ErrReputationRequiresManual)
+}
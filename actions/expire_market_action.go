@@ -0,0 +1,122 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/wrappers"
+	"github.com/ava-labs/hypersdk/chain"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/ava-labs/hypersdk/state"
+
+	"github.com/chokosabe/predictionvm/consts"
+	"github.com/chokosabe/predictionvm/storage"
+)
+
+const (
+	ExpireMarketComputeUnits = 500
+	MaxExpireMarketSize      = 16
+)
+
+var (
+	ErrUnmarshalEmptyExpireMarket                = errors.New("cannot unmarshal empty bytes as ExpireMarket action")
+	ErrResolutionDeadlineNotReached              = errors.New("market resolution deadline has not passed")
+	_                               chain.Action = (*ExpireMarket)(nil)
+)
+
+// ExpireMarket marks a market that was not resolved before its resolution
+// deadline as Invalid, letting every holder reclaim their collateral through
+// Claim. Anyone may expire a market.
+type ExpireMarket struct {
+	MarketID uint64 `serialize:"true" json:"marketId"`
+}
+
+func (*ExpireMarket) GetTypeID() uint8 {
+	return consts.ExpireMarketID
+}
+
+// Bytes serializes the ExpireMarket action.
+func (e *ExpireMarket) Bytes() []byte {
+	p := &wrappers.Packer{
+		Bytes:   make([]byte, 0, MaxExpireMarketSize),
+		MaxSize: MaxExpireMarketSize,
+	}
+	p.PackByte(consts.ExpireMarketID)
+	if err := codec.LinearCodec.MarshalInto(e, p); err != nil {
+		panic(fmt.Errorf("failed to marshal ExpireMarket action: %w", err))
+	}
+	return p.Bytes
+}
+
+// UnmarshalExpireMarket deserializes bytes into an ExpireMarket action.
+func UnmarshalExpireMarket(bytes []byte) (chain.Action, error) {
+	if len(bytes) == 0 {
+		return nil, ErrUnmarshalEmptyExpireMarket
+	}
+	if bytes[0] != consts.ExpireMarketID {
+		return nil, fmt.Errorf("unexpected ExpireMarket typeID: %d != %d", bytes[0], consts.ExpireMarketID)
+	}
+	e := &ExpireMarket{}
+	if err := codec.LinearCodec.UnmarshalFrom(
+		&wrappers.Packer{Bytes: bytes[1:]},
+		e,
+	); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal ExpireMarket action: %w", err)
+	}
+	return e, nil
+}
+
+// StateKeys implements chain.Action
+func (e *ExpireMarket) StateKeys(codec.Address, ids.ID) state.Keys {
+	return withStatusIndexKeys(state.Keys{
+		string(storage.MarketKey(e.MarketID)):                                state.Read | state.Write,
+		string(storage.MarketStateKey(e.MarketID)):                           state.All,
+		string(storage.IndexKey(storage.PendingResolutionIndex, e.MarketID)): state.Read | state.Write,
+	}, e.MarketID,
+		storage.MarketStatus_Open, storage.MarketStatus_TradingClosed,
+		storage.MarketStatus_ResolvedInvalid,
+	)
+}
+
+// Execute resolves the market as Invalid.
+func (e *ExpireMarket) Execute(
+	ctx context.Context,
+	rules chain.Rules,
+	mu state.Mutable,
+	timestamp int64,
+	_ codec.Address,
+	_ ids.ID,
+) ([]byte, error) {
+	market, err := storage.GetMarket(ctx, mu, e.MarketID)
+	if err != nil {
+		return nil, err
+	}
+	if market.Status.IsResolved() || market.Status == storage.MarketStatus_Disputed || market.Status == storage.MarketStatus_Appealed {
+		return nil, fmt.Errorf("%w: market %d (status: %s)", ErrMarketAlreadyResolved, e.MarketID, market.Status)
+	}
+	if deadline := market.ResolutionDeadline(ResolutionGracePeriod(rules)); timestamp <= deadline {
+		return nil, fmt.Errorf("%w: market %d can be expired after %d (current: %d)", ErrResolutionDeadlineNotReached, e.MarketID, deadline, timestamp)
+	}
+
+	market.Status = storage.MarketStatus_ResolvedInvalid
+	market.ResolvedOutcome = storage.Outcome_Invalid
+	if err := storage.SetMarket(ctx, mu, market); err != nil {
+		return nil, fmt.Errorf("failed to update expired market %d: %w", e.MarketID, err)
+	}
+	if err := storage.RemoveFromIndex(ctx, mu, storage.PendingResolutionIndex, e.MarketID); err != nil {
+		return nil, fmt.Errorf("failed to unindex expired market %d: %w", e.MarketID, err)
+	}
+	return nil, nil
+}
+
+// ComputeUnits implements chain.Action
+func (*ExpireMarket) ComputeUnits(chain.Rules) uint64 {
+	return ExpireMarketComputeUnits
+}
+
+// ValidRange implements chain.Action
+func (*ExpireMarket) ValidRange(chain.Rules) (int64, int64) {
+	return -1, -1 // Always valid
+}
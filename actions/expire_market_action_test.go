@@ -0,0 +1,161 @@
+package actions
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/hypersdk/chain/chaintest"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chokosabe/predictionvm/consts"
+	"github.com/chokosabe/predictionvm/storage"
+)
+
+// setPendingMarket stores an unresolved manual market and indexes it as
+// pending resolution.
+func setPendingMarket(t *testing.T, mu *chaintest.InMemoryStore, market *storage.Market) {
+	ctx := context.Background()
+	require.NoError(t, storage.SetMarket(ctx, mu, market))
+	require.NoError(t, storage.AddToIndex(ctx, mu, storage.PendingResolutionIndex, market.ID))
+}
+
+func TestExpireMarket_Execute(t *testing.T) {
+	ctx := context.Background()
+	caller := codec.Address{0x09} // Anyone may expire a market
+	genesisGracePeriod := &MockRules{
+		FetchCustomFunc: func(key string) (any, bool) {
+			if key == consts.ResolutionGracePeriodKey {
+				return int64(2_000), true
+			}
+			return nil, false
+		},
+	}
+
+	testCases := []struct {
+		name        string
+		gracePeriod int64 // Per-market override
+		rules       *MockRules
+		timestamp   int64
+		expectedErr error
+	}{
+		{
+			name:        "DefaultGracePeriodNotPassed",
+			rules:       &MockRules{},
+			timestamp:   10_000 + consts.DefaultResolutionGracePeriod,
+			expectedErr: ErrResolutionDeadlineNotReached,
+		},
+		{
+			name:      "DefaultGracePeriodPassed",
+			rules:     &MockRules{},
+			timestamp: 10_000 + consts.DefaultResolutionGracePeriod + 1,
+		},
+		{
+			name:        "GenesisGracePeriodNotPassed",
+			rules:       genesisGracePeriod,
+			timestamp:   12_000,
+			expectedErr: ErrResolutionDeadlineNotReached,
+		},
+		{
+			name:      "GenesisGracePeriodPassed",
+			rules:     genesisGracePeriod,
+			timestamp: 12_001,
+		},
+		{
+			name:        "MarketOverridesGenesis",
+			gracePeriod: 500,
+			rules:       genesisGracePeriod,
+			timestamp:   10_501,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+			mu := chaintest.NewInMemoryStore()
+			setPendingMarket(t, mu, &storage.Market{
+				ID:                    1,
+				Description:           "Unresolved market",
+				Status:                storage.MarketStatus_TradingClosed,
+				Creator:               codec.Address{0x02},
+				EndTime:               5_000,
+				ResolutionTime:        10_000,
+				ResolutionGracePeriod: tc.gracePeriod,
+			})
+
+			output, err := execute(t, &ExpireMarket{MarketID: 1}, tc.rules, mu, tc.timestamp, caller, ids.Empty)
+			require.ErrorIs(err, tc.expectedErr)
+			require.Nil(output)
+
+			market, err := storage.GetMarket(ctx, mu, 1)
+			require.NoError(err)
+			pending, err := storage.IsIndexed(ctx, mu, storage.IndexKey(storage.PendingResolutionIndex, 1))
+			require.NoError(err)
+			if tc.expectedErr != nil {
+				require.Equal(storage.MarketStatus_TradingClosed, market.Status)
+				require.True(pending)
+				return
+			}
+			require.Equal(storage.MarketStatus_ResolvedInvalid, market.Status)
+			require.Equal(storage.Outcome_Invalid, market.ResolvedOutcome)
+			require.Empty(pending, "Expired markets should leave the pending-resolution index")
+		})
+	}
+}
+
+func TestExpireMarket_Execute_Errors(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	mu := chaintest.NewInMemoryStore()
+	action := &ExpireMarket{MarketID: 1}
+
+	_, err := execute(t, action, &MockRules{}, mu, 1, codec.Address{}, ids.Empty)
+	require.ErrorIs(err, ErrMarketNotFound)
+
+	require.NoError(storage.SetMarket(ctx, mu, &storage.Market{
+		ID:             1,
+		Status:         storage.MarketStatus_ResolvedYes,
+		ResolutionTime: 10_000,
+	}))
+	_, err = execute(t, action, &MockRules{}, mu, 10_000+consts.DefaultResolutionGracePeriod+1, codec.Address{}, ids.Empty)
+	require.ErrorIs(err, ErrMarketAlreadyResolved)
+
+	// A deadline beyond the largest timestamp does not wrap around into the
+	// past.
+	setPendingMarket(t, mu, &storage.Market{
+		ID:                    2,
+		Status:                storage.MarketStatus_TradingClosed,
+		ResolutionTime:        math.MaxInt64 - 1,
+		ResolutionGracePeriod: consts.MaxResolutionGracePeriod,
+	})
+	_, err = execute(t, &ExpireMarket{MarketID: 2}, &MockRules{}, mu, 10_000, codec.Address{}, ids.Empty)
+	require.ErrorIs(err, ErrResolutionDeadlineNotReached)
+}
+
+func TestResolveMarket_Execute_DeadlinePassed(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	mu := chaintest.NewInMemoryStore()
+
+	creator := codec.Address{0x02}
+	setPendingMarket(t, mu, &storage.Market{
+		ID:                    1,
+		Description:           "Manual market",
+		Status:                storage.MarketStatus_TradingClosed,
+		Creator:               creator,
+		EndTime:               5_000,
+		ResolutionTime:        10_000,
+		ResolutionGracePeriod: 1_000,
+	})
+
+	action := &ResolveMarket{MarketID: 1, Outcome: uint8(storage.Outcome_Yes)}
+	_, err := execute(t, action, &MockRules{}, mu, 11_001, creator, ids.Empty)
+	require.ErrorIs(err, ErrResolutionDeadlinePassed)
+
+	_, err = execute(t, action, &MockRules{}, mu, 11_000, creator, ids.Empty)
+	require.NoError(err)
+	pending, err := storage.IsIndexed(ctx, mu, storage.IndexKey(storage.PendingResolutionIndex, 1))
+	require.NoError(err)
+	require.False(pending, "Resolved markets should leave the pending-resolution index")
+}
@@ -0,0 +1,210 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/wrappers"
+	"github.com/ava-labs/hypersdk/chain"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/ava-labs/hypersdk/state"
+
+	"github.com/chokosabe/predictionvm/consts"
+	"github.com/chokosabe/predictionvm/storage"
+)
+
+const (
+	FinalizeAppealComputeUnits = 1000
+	MaxFinalizeAppealSize      = 64
+)
+
+var (
+	ErrUnmarshalEmptyFinalizeAppeal              = errors.New("cannot unmarshal empty bytes as FinalizeAppeal action")
+	ErrAppealVotingOpen                          = errors.New("appeal voting is still open")
+	_                               chain.Action = (*FinalizeAppeal)(nil)
+)
+
+// FinalizeAppeal tallies the stakers' vote on a market's dispute or appeal
+// once voting has ended and resolves the market accordingly. Anyone may
+// submit it.
+//
+// The challenged ruling is overturned only if strictly more stake voted to
+// overturn it than to uphold it. The dispute is settled against the resulting
+// outcome, and re-settled after an appeal, so that WithdrawBond pays each bond
+// to the right party and the oracle is charged with a lost dispute exactly
+// when its initial ruling does not stand.
+//
+// [Oracle] must name the market's resolver so that its statistics key can be
+// declared in StateKeys.
+type FinalizeAppeal struct {
+	MarketID uint64        `serialize:"true" json:"marketId"`
+	Oracle   codec.Address `serialize:"true" json:"oracle"`
+}
+
+func (*FinalizeAppeal) GetTypeID() uint8 {
+	return consts.FinalizeAppealID
+}
+
+// Bytes serializes the FinalizeAppeal action.
+func (f *FinalizeAppeal) Bytes() []byte {
+	p := &wrappers.Packer{
+		Bytes:   make([]byte, 0, MaxFinalizeAppealSize),
+		MaxSize: MaxFinalizeAppealSize,
+	}
+	p.PackByte(consts.FinalizeAppealID)
+	if err := codec.LinearCodec.MarshalInto(f, p); err != nil {
+		panic(fmt.Errorf("failed to marshal FinalizeAppeal action: %w", err))
+	}
+	return p.Bytes
+}
+
+// UnmarshalFinalizeAppeal deserializes bytes into a FinalizeAppeal action.
+func UnmarshalFinalizeAppeal(bytes []byte) (chain.Action, error) {
+	if len(bytes) == 0 {
+		return nil, ErrUnmarshalEmptyFinalizeAppeal
+	}
+	if bytes[0] != consts.FinalizeAppealID {
+		return nil, fmt.Errorf("unexpected FinalizeAppeal typeID: %d != %d", bytes[0], consts.FinalizeAppealID)
+	}
+	f := &FinalizeAppeal{}
+	if err := codec.LinearCodec.UnmarshalFrom(
+		&wrappers.Packer{Bytes: bytes[1:]},
+		f,
+	); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal FinalizeAppeal action: %w", err)
+	}
+	return f, nil
+}
+
+// StateKeys implements chain.Action
+func (f *FinalizeAppeal) StateKeys(codec.Address, ids.ID) state.Keys {
+	return withStatusIndexKeys(state.Keys{
+		string(storage.MarketKey(f.MarketID)):      state.Read | state.Write,
+		string(storage.MarketStateKey(f.MarketID)): state.All,
+		string(storage.AppealKey(f.MarketID)):      state.Read | state.Write,
+		string(storage.DisputeKey(f.MarketID)):     state.Read | state.Write,
+		string(storage.OracleStatsKey(f.Oracle)):   state.All,
+	}, f.MarketID,
+		storage.MarketStatus_Disputed, storage.MarketStatus_Appealed,
+		storage.MarketStatus_ResolvedYes, storage.MarketStatus_ResolvedNo, storage.MarketStatus_ResolvedInvalid,
+	)
+}
+
+// Execute resolves the challenged market according to the stakers' vote.
+func (f *FinalizeAppeal) Execute(
+	ctx context.Context,
+	_ chain.Rules,
+	mu state.Mutable,
+	timestamp int64,
+	_ codec.Address,
+	_ ids.ID,
+) ([]byte, error) {
+	market, err := storage.GetMarket(ctx, mu, f.MarketID)
+	if err != nil {
+		return nil, err
+	}
+	var tier uint8
+	switch market.Status {
+	case storage.MarketStatus_Disputed:
+		tier = storage.Tier_Dispute
+	case storage.MarketStatus_Appealed:
+		tier = storage.Tier_Appeal
+	default:
+		return nil, fmt.Errorf("%w: market %d (status: %s)", ErrMarketNotAppealable, f.MarketID, market.Status)
+	}
+	oracle, err := manualResolver(market)
+	if err != nil {
+		return nil, err
+	}
+	if oracle != f.Oracle {
+		return nil, fmt.Errorf("%w: market %d is resolved by %s", ErrOracleMismatch, f.MarketID, oracle)
+	}
+	dispute, err := storage.GetDispute(ctx, mu, f.MarketID)
+	if err != nil {
+		return nil, err
+	}
+
+	var outcome storage.OutcomeType
+	if tier == storage.Tier_Dispute {
+		if timestamp < dispute.VotingEndsAt {
+			return nil, fmt.Errorf("%w: dispute of market %d can be finalized at %d (current: %d)", ErrAppealVotingOpen, f.MarketID, dispute.VotingEndsAt, timestamp)
+		}
+		outcome = dispute.DisputedOutcome
+		if dispute.OverturnVotes > dispute.UpholdVotes {
+			outcome = dispute.ProposedOutcome
+		}
+	} else {
+		appeal, err := storage.GetAppeal(ctx, mu, f.MarketID)
+		if err != nil {
+			return nil, err
+		}
+		if timestamp < appeal.VotingEndsAt {
+			return nil, fmt.Errorf("%w: appeal of market %d can be finalized at %d (current: %d)", ErrAppealVotingOpen, f.MarketID, appeal.VotingEndsAt, timestamp)
+		}
+		outcome = appeal.AppealedOutcome
+		if appeal.OverturnVotes > appeal.UpholdVotes {
+			outcome = appeal.ProposedOutcome
+			appeal.Status = storage.DisputeStatus_Upheld
+		} else {
+			appeal.Status = storage.DisputeStatus_Rejected
+		}
+		if err := storage.SetAppeal(ctx, mu, appeal); err != nil {
+			return nil, err
+		}
+	}
+
+	wasLost := dispute.Status == storage.DisputeStatus_Upheld
+	lost := outcome != dispute.DisputedOutcome
+	if lost {
+		dispute.Status = storage.DisputeStatus_Upheld
+	} else {
+		dispute.Status = storage.DisputeStatus_Rejected
+	}
+	if err := storage.SetDispute(ctx, mu, dispute); err != nil {
+		return nil, err
+	}
+	if lost != wasLost {
+		stats, err := storage.GetOracleStats(ctx, mu, oracle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get stats of oracle %s: %w", oracle, err)
+		}
+		if lost {
+			stats.DisputesLost++
+			stats.OverturnedCollateral += market.Collateral
+		} else {
+			stats.DisputesLost--
+			stats.OverturnedCollateral -= min(stats.OverturnedCollateral, market.Collateral)
+		}
+		if err := storage.SetOracleStats(ctx, mu, oracle, stats); err != nil {
+			return nil, err
+		}
+	}
+
+	market.ResolvedOutcome = outcome
+	market.ResolvedAt = timestamp
+	market.Tier = tier
+	switch outcome {
+	case storage.Outcome_Yes:
+		market.Status = storage.MarketStatus_ResolvedYes
+	case storage.Outcome_No:
+		market.Status = storage.MarketStatus_ResolvedNo
+	default:
+		market.Status = storage.MarketStatus_ResolvedInvalid
+	}
+	if err := storage.SetMarket(ctx, mu, market); err != nil {
+		return nil, fmt.Errorf("failed to update finalized market %d: %w", f.MarketID, err)
+	}
+	return nil, nil
+}
+
+// ComputeUnits implements chain.Action
+func (*FinalizeAppeal) ComputeUnits(chain.Rules) uint64 {
+	return FinalizeAppealComputeUnits
+}
+
+// ValidRange implements chain.Action
+func (*FinalizeAppeal) ValidRange(chain.Rules) (int64, int64) {
+	return -1, -1 // Always valid
+}
@@ -2,12 +2,22 @@ package actions
 
 import (
 	"context"
+	"encoding/hex"
+	"maps"
+	"math"
+	"os"
+	"strings"
 	"testing"
 
 	"github.com/ava-labs/avalanchego/ids" // Added for txID
+	safemath "github.com/ava-labs/avalanchego/utils/math"
 	// "github.com/ava-labs/avalanchego/database" // Removed, chaintest handles in-memory store
+	"github.com/ava-labs/hypersdk/chain"
 	"github.com/ava-labs/hypersdk/chain/chaintest" // Added for NewInMemoryStore
 	"github.com/ava-labs/hypersdk/codec"
+	"github.com/ava-labs/hypersdk/genesis"
+	hkeys "github.com/ava-labs/hypersdk/keys"
+	"github.com/ava-labs/hypersdk/state"
 	// "github.com/ava-labs/hypersdk/consts" // Removed, consts.Dimensions not used
 	// "github.com/ava-labs/hypersdk/state" // Removed, state.Mutable is not used
 	// "github.com/ava-labs/hypersdk/utils" // Removed, utils.Address not used
@@ -75,7 +85,7 @@ func TestBuyYes_Execute_Success(t *testing.T) {
 	// Placeholder for txTimestamp and txID
 	txTimestamp := mr.GetTime() // Can use mocked time
 	var txID ids.ID             // Dummy txID
-	output, err := buyYesAction.Execute(ctx, mr, mu, txTimestamp, senderAddr, txID)
+	output, err := execute(t, buyYesAction, mr, mu, txTimestamp, senderAddr, txID)
 	units := buyYesAction.ComputeUnits(mr) // Assuming ComputeUnits is what was intended for the second variable
 
 	// 6. Assertions
@@ -89,6 +99,11 @@ func TestBuyYes_Execute_Success(t *testing.T) {
 	expectedFinalBalance := initialUserBalance - (amountToBuy * maxPriceOrCollateral)
 	require.Equal(expectedFinalBalance, finalUserBalance) // Check if balance is correctly debited
 
+	// Check the collateral the user paid is recorded for refunds
+	paid, err := storage.GetPaidCollateral(ctx, mu, marketID, senderAddr)
+	require.NoError(err)
+	require.Equal(amountToBuy*maxPriceOrCollateral, paid)
+
 	// Check user's YES share balance
 	userYesShares, err := storage.GetShareBalance(ctx, mu, marketID, senderAddr, userConsts.YesShareType) // Changed pvmConsts to userConsts
 	require.NoError(err)
@@ -106,3 +121,189 @@ func TestBuyYes_Execute_Success(t *testing.T) {
 	require.NoError(err)
 	require.Equal(uint64(0), userNoShares)
 }
+
+// storageUnits returns the read, allocate and write units charged for
+// [keys], the way chain.Transaction.Units does.
+func storageUnits(r *genesis.Rules, keys state.Keys) (reads, allocates, writes uint64) {
+	for k := range keys {
+		chunks, _ := hkeys.MaxChunks([]byte(k))
+		reads += r.StorageKeyReadUnits + uint64(chunks)*r.StorageValueReadUnits
+		allocates += r.StorageKeyAllocateUnits + uint64(chunks)*r.StorageValueAllocateUnits
+		writes += r.StorageKeyWriteUnits + uint64(chunks)*r.StorageValueWriteUnits
+	}
+	return reads, allocates, writes
+}
+
+// BenchmarkBuyYes executes trades against one market and reports the storage
+// units each trade is charged, next to what it was charged when trades
+// rewrote the whole market record.
+func BenchmarkBuyYes(b *testing.B) {
+	ctx := context.Background()
+	mu := chaintest.NewInMemoryStore()
+	actor := codec.Address{0x01}
+	require.NoError(b, storage.SetMarket(ctx, mu, &storage.Market{
+		ID:               1,
+		Description:      strings.Repeat("d", 256),
+		Status:           storage.MarketStatus_Open,
+		EndTime:          200,
+		ResolutionTime:   300,
+		OracleType:       userConsts.OracleTypePriceFeed,
+		OracleParameters: (&storage.PriceFeedParameters{FeedID: 1, Window: 60_000, Threshold: 100, Publisher: codec.Address{0x03}}).Bytes(),
+	}))
+	require.NoError(b, storage.SetBalance(ctx, mu, actor, uint64(b.N)+1))
+
+	action := &BuyYes{MarketID: 1, Amount: 1, MaxPrice: 1}
+	keys := action.StateKeys(actor, ids.Empty)
+	wholeRecordKeys := maps.Clone(keys)
+	delete(wholeRecordKeys, string(storage.MarketStateKey(1)))
+	wholeRecordKeys[string(storage.MarketKey(1))] = state.Read | state.Write
+
+	rules := genesis.NewDefaultRules()
+	reads, allocates, writes := storageUnits(rules, keys)
+	wholeReads, wholeAllocates, wholeWrites := storageUnits(rules, wholeRecordKeys)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := action.Execute(ctx, &MockRules{}, mu, 100, actor, ids.Empty); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(reads), "read-units/op")
+	b.ReportMetric(float64(allocates), "allocate-units/op")
+	b.ReportMetric(float64(writes), "write-units/op")
+	b.ReportMetric(float64(wholeReads-reads+wholeAllocates-allocates+wholeWrites-writes), "saved-units/op")
+}
+
+func TestBuy_Execute_Overflow(t *testing.T) {
+	ctx := context.Background()
+	actor := codec.Address{0x01}
+
+	testCases := []struct {
+		name   string
+		market *storage.Market
+		action chain.Action
+	}{
+		{
+			name:   "BuyYesCost",
+			market: &storage.Market{ID: 1, Status: storage.MarketStatus_Open, EndTime: 200},
+			action: &BuyYes{MarketID: 1, Amount: math.MaxUint64/2 + 1, MaxPrice: 2},
+		},
+		{
+			name:   "BuyNoCost",
+			market: &storage.Market{ID: 1, Status: storage.MarketStatus_Open, EndTime: 200},
+			action: &BuyNo{MarketID: 1, Amount: 2, MaxPrice: math.MaxUint64/2 + 1},
+		},
+		{
+			name:   "BuyYesTotalShares",
+			market: &storage.Market{ID: 1, Status: storage.MarketStatus_Open, EndTime: 200, TotalYesShares: math.MaxUint64},
+			action: &BuyYes{MarketID: 1, Amount: 1, MaxPrice: 1},
+		},
+		{
+			name:   "BuyNoCollateral",
+			market: &storage.Market{ID: 1, Status: storage.MarketStatus_Open, EndTime: 200, Collateral: math.MaxUint64},
+			action: &BuyNo{MarketID: 1, Amount: 1, MaxPrice: 1},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+			mu := chaintest.NewInMemoryStore()
+			require.NoError(storage.SetBalance(ctx, mu, actor, math.MaxUint64))
+			require.NoError(storage.SetMarket(ctx, mu, tc.market))
+
+			_, err := execute(t, tc.action, &MockRules{}, mu, 100, actor, ids.Empty)
+			require.ErrorIs(err, safemath.ErrOverflow)
+
+			balance, err := storage.GetBalance(ctx, mu, actor)
+			require.NoError(err)
+			require.Equal(uint64(math.MaxUint64), balance, "A failed purchase should not charge the buyer")
+		})
+	}
+}
+
+func TestBuy_Execute_TradingFee(t *testing.T) {
+	ctx := context.Background()
+	actor := codec.Address{0x01}
+	rules := &MockRules{
+		FetchCustomFunc: func(key string) (any, bool) {
+			if key == userConsts.TradingFeeRateKey {
+				return uint64(250), true // 2.5%
+			}
+			return nil, false
+		},
+	}
+
+	for _, action := range []chain.Action{
+		&BuyYes{MarketID: 1, Amount: 100, MaxPrice: 4},
+		&BuyNo{MarketID: 1, Amount: 100, MaxPrice: 4},
+	} {
+		require := require.New(t)
+		mu := chaintest.NewInMemoryStore()
+		require.NoError(storage.SetBalance(ctx, mu, actor, 1_000))
+		require.NoError(storage.SetMarket(ctx, mu, &storage.Market{ID: 1, Status: storage.MarketStatus_Open, EndTime: 200}))
+
+		_, err := execute(t, action, rules, mu, 100, actor, ids.Empty)
+		require.NoError(err)
+
+		// The fee is charged on top of the cost and burned.
+		balance, err := storage.GetBalance(ctx, mu, actor)
+		require.NoError(err)
+		require.Equal(uint64(1_000-400-10), balance)
+		market, err := storage.GetMarket(ctx, mu, 1)
+		require.NoError(err)
+		require.Equal(uint64(400), market.Collateral)
+		paid, err := storage.GetPaidCollateral(ctx, mu, 1, actor)
+		require.NoError(err)
+		require.Equal(uint64(400), paid)
+
+		// A buyer who cannot cover the fee cannot buy.
+		require.NoError(storage.SetBalance(ctx, mu, actor, 400))
+		_, err = execute(t, action, rules, mu, 100, actor, ids.Empty)
+		require.ErrorIs(err, storage.ErrInsufficientBalance)
+	}
+}
+
+func TestBuy_Execute_MarketV1(t *testing.T) {
+	ctx := context.Background()
+	actor := codec.Address{0x01}
+	// An Open market written by the SetMarket of the first release, which
+	// has no MarketState record.
+	data, err := os.ReadFile("../tests/integration/testdata/market_v1_open.hex")
+	require.NoError(t, err)
+	v1, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	require.NoError(t, err)
+	const marketID = 0x1235
+
+	for _, action := range []chain.Action{
+		&BuyYes{MarketID: marketID, Amount: 10, MaxPrice: 4},
+		&BuyNo{MarketID: marketID, Amount: 10, MaxPrice: 4},
+	} {
+		require := require.New(t)
+		mu := chaintest.NewInMemoryStore()
+		require.NoError(storage.SetBalance(ctx, mu, actor, 1_000))
+		require.NoError(mu.Insert(ctx, storage.MarketKey(marketID), v1))
+		before, err := storage.GetMarket(ctx, mu, marketID)
+		require.NoError(err)
+
+		_, err = execute(t, action, &MockRules{}, mu, 100, actor, ids.Empty)
+		require.NoError(err)
+
+		// The trade split the record into the current layout.
+		_, version, err := storage.DecodeMarket(marketID, mu.Storage[string(storage.MarketKey(marketID))])
+		require.NoError(err)
+		require.Equal(storage.MarketVersion, version)
+		market, err := storage.GetMarket(ctx, mu, marketID)
+		require.NoError(err)
+		require.Equal(before.Description, market.Description)
+		require.Equal(before.Oracle, market.Oracle)
+		require.Equal(before.TotalYesShares+before.TotalNoShares+10, market.TotalYesShares+market.TotalNoShares)
+		require.Equal(uint64(40), market.Collateral)
+
+		// Later writes of the whole market see the split records.
+		market.Status = storage.MarketStatus_TradingClosed
+		require.NoError(storage.SetMarket(ctx, mu, market))
+		stored, err := storage.GetMarket(ctx, mu, marketID)
+		require.NoError(err)
+		require.Equal(market, stored)
+	}
+}
@@ -0,0 +1,72 @@
+package actions
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/hypersdk/chain/chaintest"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chokosabe/predictionvm/consts"
+	"github.com/chokosabe/predictionvm/storage"
+)
+
+// requireIndexed checks which status index holds [marketID].
+func requireIndexed(t *testing.T, mu *chaintest.InMemoryStore, marketID uint64, expected storage.MarketStatus) {
+	t.Helper()
+	for _, status := range storage.MarketStatuses {
+		indexed, err := storage.IsIndexed(context.Background(), mu, storage.IndexKey(storage.StatusIndex(status), marketID))
+		require.NoError(t, err)
+		require.Equal(t, status == expected, indexed, "market %d indexed as %s", marketID, status)
+	}
+}
+
+func TestMarketIndexes_FollowLifecycle(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	mu := chaintest.NewInMemoryStore()
+	actionID := ids.ID{7: 0x01}
+	marketID := MarketIDFromActionID(actionID)
+	require.NoError(storage.SetBalance(ctx, mu, testOracle, consts.DefaultSweepDeposit))
+
+	create := &CreateMarket{
+		Description:    "Does the market move between status indexes?",
+		EndTime:        storage.EndTimeBucket + 5_000,
+		ResolutionTime: storage.EndTimeBucket + 10_000,
+		OracleType:     consts.OracleTypeManual,
+	}
+	_, err := execute(t, create, &MockRules{}, mu, 1_000, testOracle, actionID)
+	require.NoError(err)
+	requireIndexed(t, mu, marketID, storage.MarketStatus_Open)
+
+	byCreator, err := storage.IsIndexed(ctx, mu, storage.CreatorIndexKey(testOracle, marketID))
+	require.NoError(err)
+	require.True(byCreator)
+	byEndTime, err := storage.IsIndexed(ctx, mu, storage.IndexEntryKey(storage.EndTimeIndexPrefix(1), marketID))
+	require.NoError(err)
+	require.True(byEndTime)
+
+	// Trading does not change the status, so the indexes are left alone.
+	require.NoError(storage.SetBalance(ctx, mu, testDisputer, 10*consts.DefaultDisputeBond))
+	_, err = execute(t, &BuyYes{MarketID: marketID, Amount: 10, MaxPrice: 1}, &MockRules{}, mu, 2_000, testDisputer, ids.Empty)
+	require.NoError(err)
+	requireIndexed(t, mu, marketID, storage.MarketStatus_Open)
+
+	_, err = execute(t, &ResolveMarket{MarketID: marketID, Outcome: uint8(storage.Outcome_Yes)}, &MockRules{}, mu, storage.EndTimeBucket+12_000, testOracle, ids.Empty)
+	require.NoError(err)
+	requireIndexed(t, mu, marketID, storage.MarketStatus_ResolvedYes)
+
+	_, err = execute(t, &DisputeMarket{MarketID: marketID, ProposedOutcome: uint8(storage.Outcome_No), Oracle: testOracle}, &MockRules{}, mu, storage.EndTimeBucket+13_000, testDisputer, ids.Empty)
+	require.NoError(err)
+	requireIndexed(t, mu, marketID, storage.MarketStatus_Disputed)
+
+	_, err = execute(t, &FinalizeAppeal{MarketID: marketID, Oracle: testOracle}, &MockRules{}, mu, storage.EndTimeBucket+13_000+consts.DefaultAppealVotingPeriod, testDisputer, ids.Empty)
+	require.NoError(err)
+	requireIndexed(t, mu, marketID, storage.MarketStatus_ResolvedYes)
+
+	// The entries the market left are removed.
+	for _, status := range []storage.MarketStatus{storage.MarketStatus_Open, storage.MarketStatus_Disputed} {
+		require.NotContains(mu.Storage, string(storage.IndexKey(storage.StatusIndex(status), marketID)))
+	}
+}
@@ -0,0 +1,142 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ava-labs/avalanchego/database"
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/wrappers"
+	"github.com/ava-labs/hypersdk/chain"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/ava-labs/hypersdk/state"
+
+	"github.com/chokosabe/predictionvm/consts"
+	"github.com/chokosabe/predictionvm/storage"
+)
+
+const (
+	// PublishPriceComputeUnits covers rewriting the feed and storing the
+	// observation.
+	PublishPriceComputeUnits = 500
+	MaxPublishPriceSize      = 64
+)
+
+var (
+	ErrUnmarshalEmptyPublishPrice = errors.New("cannot unmarshal empty bytes as PublishPrice action")
+	ErrPriceCannotBeZero          = errors.New("price cannot be zero")
+	ErrSequenceMismatch           = errors.New("sequence does not match the number of feed observations")
+	ErrStaleObservation           = errors.New("observation is not newer than the latest feed observation")
+	ErrFeedCapacityMismatch       = errors.New("capacity does not match the chain's feed capacity")
+	ErrUnauthorizedPublisher      = errors.New("actor is not allowed to publish prices")
+	_                             chain.Action = (*PublishPrice)(nil)
+)
+
+// PublishPrice pushes a new price observation to feed [FeedID] of the actor,
+// timestamped with the block time. Feeds are namespaced by publisher, so an
+// actor can only ever publish to its own feeds, and only if the chain allows
+// it to publish (see IsFeedPublisher).
+//
+// [Sequence] must be the number of observations the feed already has (see
+// Feed.Count) and [Capacity] the chain's feed capacity (see FeedCapacity);
+// together they name the ring buffer slot the new observation is stored in,
+// which StateKeys cannot otherwise know.
+type PublishPrice struct {
+	FeedID   uint64 `serialize:"true" json:"feedId"`
+	Price    uint64 `serialize:"true" json:"price"`
+	Sequence uint64 `serialize:"true" json:"sequence"`
+	Capacity uint64 `serialize:"true" json:"capacity"`
+}
+
+func (*PublishPrice) GetTypeID() uint8 {
+	return consts.PublishPriceID
+}
+
+// Bytes serializes the PublishPrice action.
+func (p *PublishPrice) Bytes() []byte {
+	packer := &wrappers.Packer{
+		Bytes:   make([]byte, 0, MaxPublishPriceSize),
+		MaxSize: MaxPublishPriceSize,
+	}
+	packer.PackByte(consts.PublishPriceID)
+	if err := codec.LinearCodec.MarshalInto(p, packer); err != nil {
+		panic(fmt.Errorf("failed to marshal PublishPrice action: %w", err))
+	}
+	return packer.Bytes
+}
+
+// UnmarshalPublishPrice deserializes bytes into a PublishPrice action.
+func UnmarshalPublishPrice(bytes []byte) (chain.Action, error) {
+	if len(bytes) == 0 {
+		return nil, ErrUnmarshalEmptyPublishPrice
+	}
+	if bytes[0] != consts.PublishPriceID {
+		return nil, fmt.Errorf("unexpected PublishPrice typeID: %d != %d", bytes[0], consts.PublishPriceID)
+	}
+	p := &PublishPrice{}
+	if err := codec.LinearCodec.UnmarshalFrom(
+		&wrappers.Packer{Bytes: bytes[1:]},
+		p,
+	); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal PublishPrice action: %w", err)
+	}
+	return p, nil
+}
+
+// StateKeys implements chain.Action
+func (p *PublishPrice) StateKeys(actor codec.Address, _ ids.ID) state.Keys {
+	return state.Keys{
+		string(storage.FeedKey(actor, p.FeedID)):                       state.All, // Created on the first publish
+		string(storage.FeedObservationKey(actor, p.FeedID, storage.FeedSlot(p.Sequence, p.Capacity))): state.Allocate | state.Write,
+	}
+}
+
+// Execute stores the observation and updates the feed.
+func (p *PublishPrice) Execute(
+	ctx context.Context,
+	rules chain.Rules,
+	mu state.Mutable,
+	timestamp int64,
+	actor codec.Address,
+	_ ids.ID,
+) ([]byte, error) {
+	if p.Price == 0 {
+		return nil, ErrPriceCannotBeZero
+	}
+	if !IsFeedPublisher(rules, actor) {
+		return nil, fmt.Errorf("%w: %s", ErrUnauthorizedPublisher, actor)
+	}
+	if capacity := FeedCapacity(rules); p.Capacity != capacity {
+		return nil, fmt.Errorf("%w: %d != %d", ErrFeedCapacityMismatch, p.Capacity, capacity)
+	}
+
+	feed, err := storage.GetFeed(ctx, mu, actor, p.FeedID)
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+		feed = &storage.Feed{ID: p.FeedID, Publisher: actor}
+	case err != nil:
+		return nil, fmt.Errorf("failed to get feed %d: %w", p.FeedID, err)
+	}
+	if p.Sequence != feed.Count {
+		return nil, fmt.Errorf("%w: feed %d has %d observations, got %d", ErrSequenceMismatch, p.FeedID, feed.Count, p.Sequence)
+	}
+
+	if latest, ok := feed.LatestObservation(); ok && timestamp <= latest.Timestamp {
+		return nil, fmt.Errorf("%w: feed %d latest %d, current %d", ErrStaleObservation, p.FeedID, latest.Timestamp, timestamp)
+	}
+	if err := storage.AppendObservation(ctx, mu, feed, timestamp, p.Price, p.Capacity); err != nil {
+		return nil, fmt.Errorf("failed to update feed %d: %w", p.FeedID, err)
+	}
+	return nil, nil
+}
+
+// ComputeUnits implements chain.Action
+func (*PublishPrice) ComputeUnits(chain.Rules) uint64 {
+	return PublishPriceComputeUnits
+}
+
+// ValidRange implements chain.Action
+func (*PublishPrice) ValidRange(chain.Rules) (int64, int64) {
+	return -1, -1 // Always valid
+}
@@ -0,0 +1,183 @@
+package actions
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/hypersdk/chain/chaintest"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/ava-labs/hypersdk/state"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chokosabe/predictionvm/consts"
+	"github.com/chokosabe/predictionvm/storage"
+)
+
+const feedCapacity = consts.DefaultFeedCapacity
+
+func TestPublishPrice_Execute_CreatesFeed(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	mu := chaintest.NewInMemoryStore()
+	mr := &MockRules{}
+
+	publisher := codec.Address{0x01}
+	action := &PublishPrice{FeedID: 7, Price: 100, Capacity: feedCapacity}
+
+	output, err := execute(t, action, mr, mu, 1_000, publisher, ids.Empty)
+	require.NoError(err)
+	require.Nil(output)
+
+	feed, err := storage.GetFeed(ctx, mu, publisher, 7)
+	require.NoError(err)
+	require.Equal(publisher, feed.Publisher)
+	require.Equal(uint64(1), feed.Count)
+	obs, err := storage.GetFeedObservation(ctx, mu, publisher, 7, 0, feedCapacity)
+	require.NoError(err)
+	require.Equal(&storage.PriceObservation{Timestamp: 1_000, Price: 100, Cumulative: []byte{}}, obs)
+
+	// Another address publishing to the same feed ID creates its own feed.
+	other := codec.Address{0x02}
+	_, err = execute(t, &PublishPrice{FeedID: 7, Price: 5, Capacity: feedCapacity}, mr, mu, 2_000, other, ids.Empty)
+	require.NoError(err)
+	feed, err = storage.GetFeed(ctx, mu, publisher, 7)
+	require.NoError(err)
+	require.Equal(uint64(1), feed.Count, "Feeds are namespaced by publisher")
+}
+
+func TestPublishPrice_Execute_Errors(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	mu := chaintest.NewInMemoryStore()
+	mr := &MockRules{}
+
+	publisher := codec.Address{0x01}
+	_, err := execute(t, &PublishPrice{FeedID: 7, Price: 100, Capacity: feedCapacity}, mr, mu, 1_000, publisher, ids.Empty)
+	require.NoError(err)
+
+	testCases := []struct {
+		name        string
+		action      *PublishPrice
+		timestamp   int64
+		expectedErr error
+	}{
+		{"ZeroPrice", &PublishPrice{FeedID: 7, Price: 0, Sequence: 1, Capacity: feedCapacity}, 2_000, ErrPriceCannotBeZero},
+		{"CapacityMismatch", &PublishPrice{FeedID: 7, Price: 101, Sequence: 1, Capacity: 8}, 2_000, ErrFeedCapacityMismatch},
+		{"SequenceTaken", &PublishPrice{FeedID: 7, Price: 101, Sequence: 0, Capacity: feedCapacity}, 2_000, ErrSequenceMismatch},
+		{"SequenceAhead", &PublishPrice{FeedID: 7, Price: 101, Sequence: 2, Capacity: feedCapacity}, 2_000, ErrSequenceMismatch},
+		{"SameTimestamp", &PublishPrice{FeedID: 7, Price: 101, Sequence: 1, Capacity: feedCapacity}, 1_000, ErrStaleObservation},
+		{"OlderTimestamp", &PublishPrice{FeedID: 7, Price: 101, Sequence: 1, Capacity: feedCapacity}, 500, ErrStaleObservation},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			output, err := execute(t, tc.action, mr, mu, tc.timestamp, publisher, ids.Empty)
+			require.ErrorIs(err, tc.expectedErr)
+			require.Nil(output)
+		})
+	}
+
+	feed, err := storage.GetFeed(ctx, mu, publisher, 7)
+	require.NoError(err)
+	require.Equal(uint64(1), feed.Count, "Failed publishes should not modify the feed")
+}
+
+func TestPublishPrice_Execute_Publishers(t *testing.T) {
+	require := require.New(t)
+	mu := chaintest.NewInMemoryStore()
+	allowed := codec.Address{0x01}
+	mr := &MockRules{
+		FetchCustomFunc: func(key string) (any, bool) {
+			if key == consts.FeedPublishersKey {
+				return []codec.Address{allowed}, true
+			}
+			return nil, false
+		},
+	}
+
+	action := &PublishPrice{FeedID: 7, Price: 100, Capacity: feedCapacity}
+	_, err := execute(t, action, mr, mu, 1_000, codec.Address{0x02}, ids.Empty)
+	require.ErrorIs(err, ErrUnauthorizedPublisher)
+	_, err = execute(t, action, mr, mu, 1_000, allowed, ids.Empty)
+	require.NoError(err)
+}
+
+func TestPublishPrice_Execute_RingBuffer(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	mu := chaintest.NewInMemoryStore()
+	const capacity = 8
+	mr := &MockRules{
+		FetchCustomFunc: func(key string) (any, bool) {
+			if key == consts.FeedCapacityKey {
+				return uint64(capacity), true
+			}
+			return nil, false
+		},
+	}
+
+	publisher := codec.Address{0x01}
+	const total = 20
+	for i := uint64(0); i < total; i++ {
+		action := &PublishPrice{FeedID: 1, Price: i + 1, Sequence: i, Capacity: capacity}
+		_, err := execute(t, action, mr, mu, int64(i+1)*1_000, publisher, ids.Empty)
+		require.NoError(err)
+	}
+
+	feed, err := storage.GetFeed(ctx, mu, publisher, 1)
+	require.NoError(err)
+	require.Equal(uint64(total), feed.Count)
+	latest, ok := feed.LatestObservation()
+	require.True(ok)
+	require.Equal(uint64(total), latest.Price)
+
+	// Only the last [capacity] observations are kept, each in its slot.
+	for seq := uint64(0); seq < total; seq++ {
+		obs, err := storage.GetFeedObservation(ctx, mu, publisher, 1, seq, capacity)
+		if seq < total-capacity {
+			require.ErrorIs(err, storage.ErrObservationOverwritten, "observation %d", seq)
+			continue
+		}
+		require.NoError(err)
+		require.Equal(seq, obs.Sequence)
+	}
+	found := 0
+	for key := range mu.Storage {
+		if key[0] == storage.FeedObservationPrefix {
+			found++
+		}
+	}
+	require.Equal(capacity, found, "The feed should not grow past its capacity")
+
+	// Windows within the ring buffer are covered: prices 13..17 each held
+	// for 1s average to 15.
+	atStart, err := storage.GetObservationAt(ctx, mu, publisher, 1, 12, capacity, 13_000)
+	require.NoError(err)
+	atEnd, err := storage.GetObservationAt(ctx, mu, publisher, 1, 17, capacity, 18_000)
+	require.NoError(err)
+	twap, err := storage.TWAP(13_000, 18_000, atStart, atEnd)
+	require.NoError(err)
+	require.Equal(uint64(15), twap)
+
+	// Windows that fell out of it fail cleanly.
+	_, err = storage.GetObservationAt(ctx, mu, publisher, 1, 0, capacity, 1_000)
+	require.ErrorIs(err, storage.ErrObservationOverwritten)
+	_, err = storage.FindObservationAt(ctx, readState(mu), publisher, 1, capacity, 5_000)
+	require.ErrorIs(err, storage.ErrObservationOverwritten)
+	seq, err := storage.FindObservationAt(ctx, readState(mu), publisher, 1, capacity, 13_500)
+	require.NoError(err)
+	require.Equal(uint64(12), seq)
+}
+
+// readState serves batched reads from an in-memory store, like the VM's
+// ReadState does from the accepted state.
+func readState(im state.Immutable) storage.ReadState {
+	return func(ctx context.Context, keys [][]byte) ([][]byte, []error) {
+		values := make([][]byte, len(keys))
+		errs := make([]error, len(keys))
+		for i, key := range keys {
+			values[i], errs[i] = im.GetValue(ctx, key)
+		}
+		return values, errs
+	}
+}
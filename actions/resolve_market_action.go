@@ -0,0 +1,251 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/wrappers"
+	"github.com/ava-labs/hypersdk/chain"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/ava-labs/hypersdk/state"
+
+	"github.com/chokosabe/predictionvm/consts"
+	"github.com/chokosabe/predictionvm/storage"
+)
+
+const (
+	// ResolveMarketComputeUnits covers reading the market and, for price-feed
+	// markets, the feed observations at both ends of the TWAP window.
+	ResolveMarketComputeUnits = 1000
+	MaxResolveMarketSize      = 128
+)
+
+var (
+	ErrUnmarshalEmptyResolveMarket = errors.New("cannot unmarshal empty bytes as ResolveMarket action")
+	ErrMarketAlreadyResolved       = errors.New("market is already resolved")
+	ErrResolutionTooEarly          = errors.New("market resolution time has not been reached")
+	ErrResolutionDeadlinePassed    = errors.New("market resolution deadline has passed")
+	ErrUnauthorizedResolver        = errors.New("actor is not allowed to resolve this market")
+	ErrInvalidOutcome              = errors.New("invalid market outcome")
+	ErrFeedMismatch                = errors.New("feed does not match the market's oracle parameters")
+	ErrUnsupportedOracleType       = errors.New("unsupported oracle type")
+	_                              chain.Action = (*ResolveMarket)(nil)
+)
+
+// ResolveMarket settles the outcome of a market once its resolution time has
+// passed.
+//
+// Markets must be resolved before their resolution deadline, after which they
+// can only be expired as Invalid (see ExpireMarket). Disputes of the ruling
+// are settled by the stakers, not the oracle (see DisputeMarket).
+//
+// Manual markets are resolved by their resolver (the creator unless the
+// oracle parameters name another address), who supplies [Outcome].
+// Price-feed markets can be resolved by anyone: the outcome is derived from
+// the feed's TWAP and [Outcome] is ignored. [FeedPublisher] and [FeedID] must
+// name the market's feed, and [StartObservation] and [EndObservation] the
+// sequence numbers of its observations in effect at the start and end of the
+// TWAP window (see storage.FindObservationAt), and [FeedCapacity] the chain's
+// feed capacity (see FeedCapacity), so that their keys can be declared in
+// StateKeys.
+type ResolveMarket struct {
+	MarketID         uint64        `serialize:"true" json:"marketId"`
+	Outcome          uint8         `serialize:"true" json:"outcome"`
+	FeedID           uint64        `serialize:"true" json:"feedId"`
+	FeedPublisher    codec.Address `serialize:"true" json:"feedPublisher"`
+	StartObservation uint64        `serialize:"true" json:"startObservation"`
+	EndObservation   uint64        `serialize:"true" json:"endObservation"`
+	FeedCapacity     uint64        `serialize:"true" json:"feedCapacity"`
+}
+
+func (*ResolveMarket) GetTypeID() uint8 {
+	return consts.ResolveMarketID
+}
+
+// Bytes serializes the ResolveMarket action.
+func (r *ResolveMarket) Bytes() []byte {
+	p := &wrappers.Packer{
+		Bytes:   make([]byte, 0, MaxResolveMarketSize),
+		MaxSize: MaxResolveMarketSize,
+	}
+	p.PackByte(consts.ResolveMarketID)
+	if err := codec.LinearCodec.MarshalInto(r, p); err != nil {
+		panic(fmt.Errorf("failed to marshal ResolveMarket action: %w", err))
+	}
+	return p.Bytes
+}
+
+// UnmarshalResolveMarket deserializes bytes into a ResolveMarket action.
+func UnmarshalResolveMarket(bytes []byte) (chain.Action, error) {
+	if len(bytes) == 0 {
+		return nil, ErrUnmarshalEmptyResolveMarket
+	}
+	if bytes[0] != consts.ResolveMarketID {
+		return nil, fmt.Errorf("unexpected ResolveMarket typeID: %d != %d", bytes[0], consts.ResolveMarketID)
+	}
+	r := &ResolveMarket{}
+	if err := codec.LinearCodec.UnmarshalFrom(
+		&wrappers.Packer{Bytes: bytes[1:]},
+		r,
+	); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal ResolveMarket action: %w", err)
+	}
+	return r, nil
+}
+
+// StateKeys implements chain.Action
+func (r *ResolveMarket) StateKeys(actor codec.Address, _ ids.ID) state.Keys {
+	return withStatusIndexKeys(state.Keys{
+		string(storage.MarketKey(r.MarketID)): state.Read | state.Write,
+		string(storage.MarketStateKey(r.MarketID)): state.All,
+		// The observations in effect at either end of the TWAP window, and
+		// the ones following them to show they are still in effect.
+		string(storage.FeedObservationKey(r.FeedPublisher, r.FeedID, storage.FeedSlot(r.StartObservation, r.FeedCapacity))):   state.Read,
+		string(storage.FeedObservationKey(r.FeedPublisher, r.FeedID, storage.FeedSlot(r.StartObservation+1, r.FeedCapacity))): state.Read,
+		string(storage.FeedObservationKey(r.FeedPublisher, r.FeedID, storage.FeedSlot(r.EndObservation, r.FeedCapacity))):     state.Read,
+		string(storage.FeedObservationKey(r.FeedPublisher, r.FeedID, storage.FeedSlot(r.EndObservation+1, r.FeedCapacity))):   state.Read,
+		string(storage.IndexKey(storage.PendingResolutionIndex, r.MarketID)): state.Read | state.Write,
+		// Manual markets are resolved by their oracle, so the actor's stats
+		// are updated.
+		string(storage.OracleStatsKey(actor)): state.All,
+	}, r.MarketID,
+		storage.MarketStatus_Open, storage.MarketStatus_TradingClosed,
+		storage.MarketStatus_ResolvedYes, storage.MarketStatus_ResolvedNo,
+	)
+}
+
+// Execute records the market's outcome.
+func (r *ResolveMarket) Execute(
+	ctx context.Context,
+	rules chain.Rules,
+	mu state.Mutable,
+	timestamp int64,
+	actor codec.Address,
+	_ ids.ID,
+) ([]byte, error) {
+	market, err := storage.GetMarket(ctx, mu, r.MarketID)
+	if err != nil {
+		return nil, err
+	}
+	if market.Status != storage.MarketStatus_Open && market.Status != storage.MarketStatus_TradingClosed {
+		return nil, fmt.Errorf("%w: market %d (status: %s)", ErrMarketAlreadyResolved, r.MarketID, market.Status)
+	}
+	if timestamp < market.ResolutionTime {
+		return nil, fmt.Errorf("%w: market %d resolves at %d (current: %d)", ErrResolutionTooEarly, r.MarketID, market.ResolutionTime, timestamp)
+	}
+	if deadline := market.ResolutionDeadline(ResolutionGracePeriod(rules)); timestamp > deadline {
+		return nil, fmt.Errorf("%w: market %d had to be resolved by %d (current: %d)", ErrResolutionDeadlinePassed, r.MarketID, deadline, timestamp)
+	}
+
+	var outcome storage.OutcomeType
+	switch market.OracleType {
+	case consts.OracleTypeManual:
+		resolver, err := manualResolver(market)
+		if err != nil {
+			return nil, err
+		}
+		if actor != resolver {
+			return nil, fmt.Errorf("%w: market %d is resolved by %s", ErrUnauthorizedResolver, r.MarketID, resolver)
+		}
+		outcome = storage.OutcomeType(r.Outcome)
+		if outcome != storage.Outcome_Yes && outcome != storage.Outcome_No {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidOutcome, outcome)
+		}
+	case consts.OracleTypePriceFeed:
+		outcome, err = r.outcomeFromFeed(ctx, rules, mu, market)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedOracleType, market.OracleType)
+	}
+
+	if market.OracleType == consts.OracleTypeManual {
+		if err := recordResolution(ctx, mu, actor, timestamp-market.ResolutionTime, market.Collateral); err != nil {
+			return nil, err
+		}
+	}
+
+	market.ResolvedOutcome = outcome
+	market.ResolvedAt = timestamp
+	if outcome == storage.Outcome_Yes {
+		market.Status = storage.MarketStatus_ResolvedYes
+	} else {
+		market.Status = storage.MarketStatus_ResolvedNo
+	}
+	if err := storage.SetMarket(ctx, mu, market); err != nil {
+		return nil, fmt.Errorf("failed to update resolved market %d: %w", r.MarketID, err)
+	}
+	if err := storage.RemoveFromIndex(ctx, mu, storage.PendingResolutionIndex, r.MarketID); err != nil {
+		return nil, fmt.Errorf("failed to unindex resolved market %d: %w", r.MarketID, err)
+	}
+	return nil, nil
+}
+
+// manualResolver returns the address allowed to rule on a manual market.
+func manualResolver(market *storage.Market) (codec.Address, error) {
+	params, err := storage.DecodeManualParameters(market.OracleParameters)
+	if err != nil {
+		return codec.EmptyAddress, fmt.Errorf("failed to decode oracle parameters of market %d: %w", market.ID, err)
+	}
+	return params.ResolverFor(market), nil
+}
+
+// recordResolution adds a resolution of a market holding [collateral], made
+// [latency] milliseconds after the market's resolution time, to the oracle's
+// statistics.
+func recordResolution(ctx context.Context, mu state.Mutable, oracle codec.Address, latency int64, collateral uint64) error {
+	stats, err := storage.GetOracleStats(ctx, mu, oracle)
+	if err != nil {
+		return fmt.Errorf("failed to get stats of oracle %s: %w", oracle, err)
+	}
+	stats.Resolved++
+	stats.TotalLatency += uint64(max(latency, 0))
+	stats.SettledCollateral += collateral
+	return storage.SetOracleStats(ctx, mu, oracle, stats)
+}
+
+// outcomeFromFeed compares the feed's TWAP over the configured window ending
+// at the market's resolution time against the market's threshold. The feed
+// must cover the whole window.
+func (r *ResolveMarket) outcomeFromFeed(ctx context.Context, rules chain.Rules, im state.Immutable, market *storage.Market) (storage.OutcomeType, error) {
+	params, err := storage.DecodePriceFeedParameters(market.OracleParameters)
+	if err != nil {
+		return storage.Outcome_Pending, fmt.Errorf("failed to decode oracle parameters of market %d: %w", r.MarketID, err)
+	}
+	if params.FeedID != r.FeedID || params.Publisher != r.FeedPublisher {
+		return storage.Outcome_Pending, fmt.Errorf("%w: market %d uses feed %d of %s, got feed %d of %s", ErrFeedMismatch, r.MarketID, params.FeedID, params.Publisher, r.FeedID, r.FeedPublisher)
+	}
+	if capacity := FeedCapacity(rules); r.FeedCapacity != capacity {
+		return storage.Outcome_Pending, fmt.Errorf("%w: %d != %d", ErrFeedCapacityMismatch, r.FeedCapacity, capacity)
+	}
+	start, end := market.ResolutionTime-params.Window, market.ResolutionTime
+	atStart, err := storage.GetObservationAt(ctx, im, params.Publisher, params.FeedID, r.StartObservation, r.FeedCapacity, start)
+	if err != nil {
+		return storage.Outcome_Pending, fmt.Errorf("failed to read feed %d at %d for market %d: %w", params.FeedID, start, r.MarketID, err)
+	}
+	atEnd, err := storage.GetObservationAt(ctx, im, params.Publisher, params.FeedID, r.EndObservation, r.FeedCapacity, end)
+	if err != nil {
+		return storage.Outcome_Pending, fmt.Errorf("failed to read feed %d at %d for market %d: %w", params.FeedID, end, r.MarketID, err)
+	}
+	twap, err := storage.TWAP(start, end, atStart, atEnd)
+	if err != nil {
+		return storage.Outcome_Pending, fmt.Errorf("failed to compute TWAP of feed %d for market %d: %w", params.FeedID, r.MarketID, err)
+	}
+	if twap >= params.Threshold {
+		return storage.Outcome_Yes, nil
+	}
+	return storage.Outcome_No, nil
+}
+
+// ComputeUnits implements chain.Action
+func (*ResolveMarket) ComputeUnits(chain.Rules) uint64 {
+	return ResolveMarketComputeUnits
+}
+
+// ValidRange implements chain.Action
+func (*ResolveMarket) ValidRange(chain.Rules) (int64, int64) {
+	return -1, -1 // Always valid
+}
@@ -0,0 +1,288 @@
+package actions
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/hypersdk/chain/chaintest"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chokosabe/predictionvm/consts"
+	"github.com/chokosabe/predictionvm/storage"
+)
+
+// setFeed stores a feed published by [publisher] with the given observations.
+func setFeed(t *testing.T, mu *chaintest.InMemoryStore, feedID uint64, publisher codec.Address, observations ...storage.PriceObservation) {
+	feed := &storage.Feed{ID: feedID, Publisher: publisher}
+	for _, obs := range observations {
+		require.NoError(t, storage.AppendObservation(context.Background(), mu, feed, obs.Timestamp, obs.Price, feedCapacity))
+	}
+}
+
+func newPriceFeedMarket(marketID uint64, params *storage.PriceFeedParameters) *storage.Market {
+	return &storage.Market{
+		ID:               marketID,
+		Description:      "Will the price be at least the threshold?",
+		Status:           storage.MarketStatus_Open,
+		Creator:          codec.Address{0x02},
+		EndTime:          5_000,
+		ResolutionTime:   10_000,
+		OracleType:       consts.OracleTypePriceFeed,
+		OracleParameters: params.Bytes(),
+	}
+}
+
+func TestResolveMarket_Execute_PriceFeed(t *testing.T) {
+	ctx := context.Background()
+	publisher := codec.Address{0x03}
+	resolver := codec.Address{0x04} // Anyone may resolve a price-feed market
+
+	// The price is 100 for 6s and 200 for the last 4s of a 10s window,
+	// giving a TWAP of (100*6000 + 200*4000) / 10000 = 140.
+	observations := []storage.PriceObservation{
+		{Timestamp: -5_000, Price: 50}, // Before the window, superseded at its start
+		{Timestamp: 0, Price: 100},
+		{Timestamp: 6_000, Price: 200},
+		{Timestamp: 12_000, Price: 1_000}, // After the resolution time, ignored
+	}
+
+	testCases := []struct {
+		name            string
+		threshold       uint64
+		expectedStatus  storage.MarketStatus
+		expectedOutcome storage.OutcomeType
+	}{
+		{"TWAPAtThreshold", 140, storage.MarketStatus_ResolvedYes, storage.Outcome_Yes},
+		{"TWAPAboveThreshold", 120, storage.MarketStatus_ResolvedYes, storage.Outcome_Yes},
+		{"TWAPBelowThreshold", 141, storage.MarketStatus_ResolvedNo, storage.Outcome_No},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+			mu := chaintest.NewInMemoryStore()
+			setFeed(t, mu, 9, publisher, observations...)
+
+			market := newPriceFeedMarket(1, &storage.PriceFeedParameters{FeedID: 9, Window: 10_000, Threshold: tc.threshold, Publisher: publisher})
+			require.NoError(storage.SetMarket(ctx, mu, market))
+
+			action := &ResolveMarket{MarketID: 1, FeedID: 9, FeedPublisher: publisher, FeedCapacity: feedCapacity, StartObservation: 1, EndObservation: 2}
+			output, err := execute(t, action, &MockRules{}, mu, 15_000, resolver, ids.Empty)
+			require.NoError(err)
+			require.Nil(output)
+
+			updatedMarket, err := storage.GetMarket(ctx, mu, 1)
+			require.NoError(err)
+			require.Equal(tc.expectedStatus, updatedMarket.Status)
+			require.Equal(tc.expectedOutcome, updatedMarket.ResolvedOutcome)
+		})
+	}
+}
+
+func TestResolveMarket_Execute_Manual(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	mu := chaintest.NewInMemoryStore()
+
+	creator := codec.Address{0x02}
+	market := &storage.Market{
+		ID:             1,
+		Description:    "Manual market",
+		Status:         storage.MarketStatus_TradingClosed,
+		Creator:        creator,
+		EndTime:        5_000,
+		ResolutionTime: 10_000,
+		OracleType:     consts.OracleTypeManual,
+	}
+	require.NoError(storage.SetMarket(ctx, mu, market))
+
+	action := &ResolveMarket{MarketID: 1, Outcome: uint8(storage.Outcome_No)}
+	_, err := execute(t, action, &MockRules{}, mu, 10_000, creator, ids.Empty)
+	require.NoError(err)
+
+	updatedMarket, err := storage.GetMarket(ctx, mu, 1)
+	require.NoError(err)
+	require.Equal(storage.MarketStatus_ResolvedNo, updatedMarket.Status)
+	require.Equal(storage.Outcome_No, updatedMarket.ResolvedOutcome)
+}
+
+func TestResolveMarket_Execute_ManualResolver(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	mu := chaintest.NewInMemoryStore()
+
+	creator := codec.Address{0x02}
+	resolver := codec.Address{0x05}
+	market := &storage.Market{
+		ID:               1,
+		Description:      "Manual market with a designated resolver",
+		Status:           storage.MarketStatus_TradingClosed,
+		Creator:          creator,
+		EndTime:          5_000,
+		ResolutionTime:   10_000,
+		OracleType:       consts.OracleTypeManual,
+		OracleParameters: (&storage.ManualParameters{Resolver: resolver}).Bytes(),
+	}
+	require.NoError(storage.SetMarket(ctx, mu, market))
+
+	action := &ResolveMarket{MarketID: 1, Outcome: uint8(storage.Outcome_Yes)}
+	_, err := execute(t, action, &MockRules{}, mu, 10_000, creator, ids.Empty)
+	require.ErrorIs(err, ErrUnauthorizedResolver, "The creator cannot resolve once a resolver is named")
+
+	_, err = execute(t, action, &MockRules{}, mu, 10_000, resolver, ids.Empty)
+	require.NoError(err)
+}
+
+func TestResolveMarket_Execute_Errors(t *testing.T) {
+	ctx := context.Background()
+	creator := codec.Address{0x02}
+	publisher := codec.Address{0x03}
+
+	manual := func() *storage.Market {
+		return &storage.Market{
+			ID:             1,
+			Description:    "Manual market",
+			Status:         storage.MarketStatus_Open,
+			Creator:        creator,
+			EndTime:        5_000,
+			ResolutionTime: 10_000,
+		}
+	}
+
+	testCases := []struct {
+		name        string
+		market      *storage.Market
+		feed        []storage.PriceObservation
+		action      *ResolveMarket
+		actor       codec.Address
+		timestamp   int64
+		expectedErr error
+	}{
+		{
+			name:        "MarketNotFound",
+			action:      &ResolveMarket{MarketID: 1, Outcome: uint8(storage.Outcome_Yes)},
+			actor:       creator,
+			timestamp:   10_000,
+			expectedErr: ErrMarketNotFound,
+		},
+		{
+			name:        "TooEarly",
+			market:      manual(),
+			action:      &ResolveMarket{MarketID: 1, Outcome: uint8(storage.Outcome_Yes)},
+			actor:       creator,
+			timestamp:   9_999,
+			expectedErr: ErrResolutionTooEarly,
+		},
+		{
+			name: "AlreadyResolved",
+			market: func() *storage.Market {
+				m := manual()
+				m.Status = storage.MarketStatus_ResolvedYes
+				return m
+			}(),
+			action:      &ResolveMarket{MarketID: 1, Outcome: uint8(storage.Outcome_No)},
+			actor:       creator,
+			timestamp:   10_000,
+			expectedErr: ErrMarketAlreadyResolved,
+		},
+		{
+			name:        "ManualNotCreator",
+			market:      manual(),
+			action:      &ResolveMarket{MarketID: 1, Outcome: uint8(storage.Outcome_Yes)},
+			actor:       codec.Address{0x09},
+			timestamp:   10_000,
+			expectedErr: ErrUnauthorizedResolver,
+		},
+		{
+			name:        "ManualPendingOutcome",
+			market:      manual(),
+			action:      &ResolveMarket{MarketID: 1, Outcome: uint8(storage.Outcome_Pending)},
+			actor:       creator,
+			timestamp:   10_000,
+			expectedErr: ErrInvalidOutcome,
+		},
+		{
+			name:        "FeedMismatch",
+			market:      newPriceFeedMarket(1, &storage.PriceFeedParameters{FeedID: 9, Window: 1_000, Threshold: 1, Publisher: publisher}),
+			feed:        []storage.PriceObservation{{Timestamp: 9_000, Price: 10}},
+			action:      &ResolveMarket{MarketID: 1, FeedID: 8, FeedPublisher: publisher, FeedCapacity: feedCapacity},
+			actor:       creator,
+			timestamp:   10_000,
+			expectedErr: ErrFeedMismatch,
+		},
+		{
+			name:        "FeedOfAnotherPublisher",
+			market:      newPriceFeedMarket(1, &storage.PriceFeedParameters{FeedID: 9, Window: 1_000, Threshold: 1, Publisher: codec.Address{0x07}}),
+			feed:        []storage.PriceObservation{{Timestamp: 9_000, Price: 10}},
+			action:      &ResolveMarket{MarketID: 1, FeedID: 9, FeedPublisher: publisher, FeedCapacity: feedCapacity},
+			actor:       creator,
+			timestamp:   10_000,
+			expectedErr: ErrFeedMismatch,
+		},
+		{
+			name:        "FeedCapacityMismatch",
+			market:      newPriceFeedMarket(1, &storage.PriceFeedParameters{FeedID: 9, Window: 1_000, Threshold: 1, Publisher: publisher}),
+			feed:        []storage.PriceObservation{{Timestamp: 9_000, Price: 10}},
+			action:      &ResolveMarket{MarketID: 1, FeedID: 9, FeedPublisher: publisher, FeedCapacity: 8},
+			actor:       creator,
+			timestamp:   10_000,
+			expectedErr: ErrFeedCapacityMismatch,
+		},
+		{
+			name:        "NoObservationBeforeResolution",
+			market:      newPriceFeedMarket(1, &storage.PriceFeedParameters{FeedID: 9, Window: 1_000, Threshold: 1, Publisher: publisher}),
+			feed:        []storage.PriceObservation{{Timestamp: 11_000, Price: 10}},
+			action:      &ResolveMarket{MarketID: 1, FeedID: 9, FeedPublisher: publisher, FeedCapacity: feedCapacity},
+			actor:       creator,
+			timestamp:   12_000,
+			expectedErr: storage.ErrWindowNotCovered,
+		},
+		{
+			name:        "WindowNotCovered",
+			market:      newPriceFeedMarket(1, &storage.PriceFeedParameters{FeedID: 9, Window: 1_000, Threshold: 1, Publisher: publisher}),
+			feed:        []storage.PriceObservation{{Timestamp: 9_500, Price: 10}},
+			action:      &ResolveMarket{MarketID: 1, FeedID: 9, FeedPublisher: publisher, FeedCapacity: feedCapacity},
+			actor:       creator,
+			timestamp:   10_000,
+			expectedErr: storage.ErrWindowNotCovered,
+		},
+		{
+			name:   "SupersededObservation",
+			market: newPriceFeedMarket(1, &storage.PriceFeedParameters{FeedID: 9, Window: 1_000, Threshold: 1, Publisher: publisher}),
+			feed: []storage.PriceObservation{
+				{Timestamp: 8_000, Price: 10},
+				{Timestamp: 8_500, Price: 10_000},
+			},
+			action:      &ResolveMarket{MarketID: 1, FeedID: 9, FeedPublisher: publisher, FeedCapacity: feedCapacity, StartObservation: 0, EndObservation: 1},
+			actor:       creator,
+			timestamp:   10_000,
+			expectedErr: storage.ErrObservationNotInEffect,
+		},
+		{
+			name:        "ObservationAfterTime",
+			market:      newPriceFeedMarket(1, &storage.PriceFeedParameters{FeedID: 9, Window: 1_000, Threshold: 1, Publisher: publisher}),
+			feed:        []storage.PriceObservation{{Timestamp: 8_000, Price: 10}, {Timestamp: 9_500, Price: 10}},
+			action:      &ResolveMarket{MarketID: 1, FeedID: 9, FeedPublisher: publisher, FeedCapacity: feedCapacity, StartObservation: 1, EndObservation: 1},
+			actor:       creator,
+			timestamp:   10_000,
+			expectedErr: storage.ErrObservationNotInEffect,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+			mu := chaintest.NewInMemoryStore()
+			if tc.market != nil {
+				require.NoError(storage.SetMarket(ctx, mu, tc.market))
+			}
+			if tc.feed != nil {
+				setFeed(t, mu, 9, publisher, tc.feed...)
+			}
+
+			output, err := execute(t, tc.action, &MockRules{}, mu, tc.timestamp, tc.actor, ids.Empty)
+			require.ErrorIs(err, tc.expectedErr)
+			require.Nil(output)
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package actions
+
+import (
+	"slices"
+
+	"github.com/ava-labs/hypersdk/chain"
+	"github.com/ava-labs/hypersdk/codec"
+
+	"github.com/chokosabe/predictionvm/consts"
+	"github.com/chokosabe/predictionvm/storage"
+)
+
+// The getters below read the protocol parameters genesis sets as custom
+// rules (see genesis.ProtocolParams). A parameter genesis leaves unset takes
+// its default from consts.
+
+// FeedCapacity is the number of observations each price feed keeps.
+func FeedCapacity(r chain.Rules) uint64 {
+	return fetchPositive(r, consts.FeedCapacityKey, consts.DefaultFeedCapacity)
+}
+
+// IsFeedPublisher reports whether [addr] may publish prices. A chain that
+// lists no publishers lets every address publish to its own feeds.
+func IsFeedPublisher(r chain.Rules, addr codec.Address) bool {
+	v, ok := r.FetchCustom(consts.FeedPublishersKey)
+	if !ok {
+		return true
+	}
+	publishers, ok := v.([]codec.Address)
+	return !ok || len(publishers) == 0 || slices.Contains(publishers, addr)
+}
+
+// ResolutionGracePeriod is how long past its resolution time a market waits
+// for a ruling before it can be expired as Invalid.
+func ResolutionGracePeriod(r chain.Rules) int64 {
+	return fetchPositive(r, consts.ResolutionGracePeriodKey, consts.DefaultResolutionGracePeriod)
+}
+
+// TradingFeeRate is the fee, in basis points of the cost, BuyYes and BuyNo
+// charge on top of the shares' cost.
+func TradingFeeRate(r chain.Rules) uint64 {
+	return fetchPositive(r, consts.TradingFeeRateKey, consts.DefaultTradingFeeRate)
+}
+
+// ClaimFeeRate is the fee, in basis points of the payout, Claim withholds.
+func ClaimFeeRate(r chain.Rules) uint64 {
+	return fetchPositive(r, consts.ClaimFeeRateKey, consts.DefaultClaimFeeRate)
+}
+
+// Fee returns [rate] basis points of [amount], rounded down. Rates above
+// consts.MaxFeeRate charge the whole amount.
+func Fee(amount, rate uint64) uint64 {
+	rate = min(rate, consts.MaxFeeRate)
+	// Split [amount] so that the product cannot overflow.
+	return amount/consts.MaxFeeRate*rate + amount%consts.MaxFeeRate*rate/consts.MaxFeeRate
+}
+
+// DisputeWindow is how long a manual market's ruling stays open to disputes.
+func DisputeWindow(r chain.Rules) int64 {
+	return fetchPositive(r, consts.DisputeWindowKey, consts.DefaultDisputeWindow)
+}
+
+// DisputeBond is what DisputeMarket escrows.
+func DisputeBond(r chain.Rules) uint64 {
+	return fetchPositive(r, consts.DisputeBondKey, consts.DefaultDisputeBond)
+}
+
+// AppealBond is what AppealMarket escrows.
+func AppealBond(r chain.Rules) uint64 {
+	return fetchPositive(r, consts.AppealBondKey, consts.DefaultAppealBond)
+}
+
+// AppealVotingPeriod is how long stakers vote on a dispute or an appeal.
+func AppealVotingPeriod(r chain.Rules) int64 {
+	return fetchPositive(r, consts.AppealVotingPeriodKey, consts.DefaultAppealVotingPeriod)
+}
+
+// SweepDeposit is what CreateMarket escrows for whoever sweeps the market.
+func SweepDeposit(r chain.Rules) uint64 {
+	return fetchPositive(r, consts.SweepDepositKey, consts.DefaultSweepDeposit)
+}
+
+// MinMarketDuration is the shortest time a market may stay open. Zero means
+// no minimum.
+func MinMarketDuration(r chain.Rules) int64 {
+	return fetchPositive(r, consts.MinMarketDurationKey, consts.DefaultMinMarketDuration)
+}
+
+// MaxMarketDuration is the longest time a market may stay open. Zero means no
+// maximum.
+func MaxMarketDuration(r chain.Rules) int64 {
+	return fetchPositive(r, consts.MaxMarketDurationKey, consts.DefaultMaxMarketDuration)
+}
+
+// MaxDescriptionSize caps the length of a market's description.
+func MaxDescriptionSize(r chain.Rules) uint64 {
+	return fetchPositive(r, consts.MaxDescriptionSizeKey, uint64(consts.MaxDescriptionSize))
+}
+
+// MaxOracleSourceSize caps the length of a market's oracle source.
+func MaxOracleSourceSize(r chain.Rules) uint64 {
+	return fetchPositive(r, consts.MaxOracleSourceSizeKey, uint64(consts.MaxOracleSourceSize))
+}
+
+// MaxOracleParametersSize caps the length of a market's oracle parameters.
+func MaxOracleParametersSize(r chain.Rules) uint64 {
+	return fetchPositive(r, consts.MaxOracleParametersSizeKey, uint64(storage.MaxOracleParametersSize))
+}
+
+// MaxMarketDataSize caps the size of a market's serialized metadata.
+func MaxMarketDataSize(r chain.Rules) uint64 {
+	return fetchPositive(r, consts.MaxMarketDataSizeKey, uint64(consts.MaxMarketDataSize))
+}
+
+// fetchPositive returns the custom rule stored under [key], or [def] if it is
+// unset, of another type or not positive.
+func fetchPositive[T int64 | uint64](r chain.Rules, key string, def T) T {
+	if v, ok := r.FetchCustom(key); ok {
+		if value, ok := v.(T); ok && value > 0 {
+			return value
+		}
+	}
+	return def
+}
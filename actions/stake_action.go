@@ -0,0 +1,112 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/wrappers"
+	"github.com/ava-labs/hypersdk/chain"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/ava-labs/hypersdk/state"
+
+	"github.com/chokosabe/predictionvm/consts"
+	"github.com/chokosabe/predictionvm/storage"
+)
+
+const (
+	StakeComputeUnits = 500
+	MaxStakeSize      = 16
+)
+
+var (
+	ErrUnmarshalEmptyStake              = errors.New("cannot unmarshal empty bytes as Stake action")
+	ErrZeroStakeAmount                  = errors.New("stake amount must be positive")
+	_                      chain.Action = (*Stake)(nil)
+)
+
+// Stake locks [Amount] of the actor's balance so that it can be used to vote
+// on appeals. Each staked unit is one vote.
+type Stake struct {
+	Amount uint64 `serialize:"true" json:"amount"`
+}
+
+func (*Stake) GetTypeID() uint8 {
+	return consts.StakeID
+}
+
+// Bytes serializes the Stake action.
+func (s *Stake) Bytes() []byte {
+	p := &wrappers.Packer{
+		Bytes:   make([]byte, 0, MaxStakeSize),
+		MaxSize: MaxStakeSize,
+	}
+	p.PackByte(consts.StakeID)
+	if err := codec.LinearCodec.MarshalInto(s, p); err != nil {
+		panic(fmt.Errorf("failed to marshal Stake action: %w", err))
+	}
+	return p.Bytes
+}
+
+// UnmarshalStake deserializes bytes into a Stake action.
+func UnmarshalStake(bytes []byte) (chain.Action, error) {
+	if len(bytes) == 0 {
+		return nil, ErrUnmarshalEmptyStake
+	}
+	if bytes[0] != consts.StakeID {
+		return nil, fmt.Errorf("unexpected Stake typeID: %d != %d", bytes[0], consts.StakeID)
+	}
+	s := &Stake{}
+	if err := codec.LinearCodec.UnmarshalFrom(
+		&wrappers.Packer{Bytes: bytes[1:]},
+		s,
+	); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal Stake action: %w", err)
+	}
+	return s, nil
+}
+
+// StateKeys implements chain.Action
+func (*Stake) StateKeys(actor codec.Address, _ ids.ID) state.Keys {
+	return state.Keys{
+		string(storage.BalanceKey(actor)): state.Read | state.Write,
+		string(storage.StakeKey(actor)):   state.All,
+	}
+}
+
+// Execute moves [Amount] from the actor's balance to its stake.
+func (s *Stake) Execute(
+	ctx context.Context,
+	_ chain.Rules,
+	mu state.Mutable,
+	_ int64,
+	actor codec.Address,
+	_ ids.ID,
+) ([]byte, error) {
+	if s.Amount == 0 {
+		return nil, ErrZeroStakeAmount
+	}
+	if err := storage.DeductBalance(ctx, mu, actor, s.Amount); err != nil {
+		return nil, fmt.Errorf("failed to stake %d from %s: %w", s.Amount, actor, err)
+	}
+	stake, err := storage.GetStake(ctx, mu, actor)
+	if err != nil {
+		return nil, err
+	}
+	stake.Amount += s.Amount
+	if err := storage.SetStake(ctx, mu, actor, stake); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// ComputeUnits implements chain.Action
+func (*Stake) ComputeUnits(chain.Rules) uint64 {
+	return StakeComputeUnits
+}
+
+// ValidRange implements chain.Action
+func (*Stake) ValidRange(chain.Rules) (int64, int64) {
+	return -1, -1 // Always valid
+}
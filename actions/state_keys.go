@@ -0,0 +1,19 @@
+package actions
+
+import (
+	"github.com/ava-labs/hypersdk/state"
+
+	"github.com/chokosabe/predictionvm/storage"
+)
+
+// withStatusIndexKeys adds the status index keys of [marketID] for each of
+// [statuses] to [keys]. storage.SetMarket moves a market between these
+// indexes when its status changes, so an action declares every status the
+// market may leave or enter. Index entries are created and removed as the
+// market moves, hence state.All.
+func withStatusIndexKeys(keys state.Keys, marketID uint64, statuses ...storage.MarketStatus) state.Keys {
+	for _, key := range storage.StatusIndexKeys(marketID, statuses...) {
+		keys[key] = state.All
+	}
+	return keys
+}
@@ -0,0 +1,83 @@
+package actions
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/hypersdk/chain"
+	"github.com/ava-labs/hypersdk/chain/chaintest"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/ava-labs/hypersdk/keys"
+	"github.com/ava-labs/hypersdk/state"
+	"github.com/ava-labs/hypersdk/state/tstate"
+	"github.com/stretchr/testify/require"
+)
+
+// strictScope is the scope of a single action's execution. Unlike the chain,
+// which only reverts the action, it fails the test on any access to a key the
+// action did not declare with the required permission, even if the action
+// swallows the resulting error.
+type strictScope struct {
+	t      *testing.T
+	action chain.Action
+	keys   state.Keys
+}
+
+func (s *strictScope) Has(key []byte, perm state.Permissions) bool {
+	if s.keys.Has(key, perm) {
+		return true
+	}
+	s.t.Errorf("%T accessed key %x with undeclared permission %s (declared: %s)", s.action, key, perm, s.keys[string(key)])
+	return false
+}
+
+// execute runs [action] the way the chain does: in a view of [mu] scoped to
+// the action's StateKeys. Changes are written back to [mu] only if the action
+// succeeds. The test fails if a declared key is malformed or if the action
+// touches a key it did not declare.
+func execute(
+	t *testing.T,
+	action chain.Action,
+	rules chain.Rules,
+	mu *chaintest.InMemoryStore,
+	timestamp int64,
+	actor codec.Address,
+	actionID ids.ID,
+) ([]byte, error) {
+	t.Helper()
+	ctx := context.Background()
+	stateKeys := action.StateKeys(actor, actionID)
+	for k := range stateKeys {
+		_, ok := keys.DecodeChunks([]byte(k))
+		require.True(t, ok && keys.Valid(k), "%T declared malformed key %x", action, k)
+	}
+
+	ts := tstate.New(len(stateKeys))
+	view := ts.NewView(&strictScope{t: t, action: action, keys: stateKeys}, mu, len(stateKeys))
+	output, err := action.Execute(ctx, rules, view, timestamp, actor, actionID)
+	if err != nil {
+		return output, err
+	}
+	view.Commit()
+	for k, v := range ts.ChangedKeys() {
+		if v.IsNothing() {
+			require.NoError(t, mu.Remove(ctx, []byte(k)))
+		} else {
+			require.NoError(t, mu.Insert(ctx, []byte(k), v.Value()))
+		}
+	}
+	return output, nil
+}
+
+func TestExecute_UndeclaredKey(t *testing.T) {
+	// A stand-in for the test running an action that under-declares its keys.
+	inner := &testing.T{}
+	mu := chaintest.NewInMemoryStore()
+
+	action := &Stake{Amount: 1}
+	view := tstate.New(0).NewView(&strictScope{t: inner, action: action, keys: state.Keys{}}, mu, 0)
+	_, err := action.Execute(context.Background(), &MockRules{}, view, 0, codec.Address{0x01}, ids.Empty)
+	require.ErrorIs(t, err, tstate.ErrInvalidKeyOrPermission)
+	require.True(t, inner.Failed())
+}
@@ -0,0 +1,229 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ava-labs/avalanchego/database"
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/wrappers"
+	"github.com/ava-labs/hypersdk/chain"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/ava-labs/hypersdk/state"
+
+	"github.com/chokosabe/predictionvm/consts"
+	"github.com/chokosabe/predictionvm/storage"
+)
+
+const (
+	SweepMarketComputeUnits = 2000
+	// MaxSweepHolders caps the number of holders whose share keys one
+	// SweepMarket removes.
+	MaxSweepHolders = 16
+	// MaxSweepVoters caps the number of voters whose votes one SweepMarket
+	// removes.
+	MaxSweepVoters     = 16
+	MaxSweepMarketSize = 1 + 8 + codec.AddressLen + 8 + 4 + MaxSweepHolders*codec.AddressLen + 4 + MaxSweepVoters*codec.AddressLen
+)
+
+var (
+	ErrUnmarshalEmptySweepMarket              = errors.New("cannot unmarshal empty bytes as SweepMarket action")
+	ErrMarketNotSettled                       = errors.New("market is not settled")
+	ErrSweepMarketMismatch                    = errors.New("creator or end time does not match the market")
+	ErrTooManySweepHolders                    = errors.New("too many holders to sweep")
+	ErrTooManySweepVoters                     = errors.New("too many voters to sweep")
+	_                            chain.Action = (*SweepMarket)(nil)
+)
+
+// SweepMarket removes a settled market from state: its records, its index
+// entries, the share and paid collateral keys of [Holders], whose remaining
+// shares are worthless, and the dispute and appeal votes of [Voters]. A
+// MarketSummary is kept in its place and the actor is paid the market's sweep
+// deposit.
+//
+// [Creator] and [EndTime] must match the market; they locate the index
+// entries to remove.
+type SweepMarket struct {
+	MarketID uint64          `serialize:"true" json:"marketId"`
+	Creator  codec.Address   `serialize:"true" json:"creator"`
+	EndTime  int64           `serialize:"true" json:"endTime"`
+	Holders  []codec.Address `serialize:"true" json:"holders"`
+	Voters   []codec.Address `serialize:"true" json:"voters"`
+}
+
+func (*SweepMarket) GetTypeID() uint8 {
+	return consts.SweepMarketID
+}
+
+// Bytes serializes the SweepMarket action.
+func (s *SweepMarket) Bytes() []byte {
+	p := &wrappers.Packer{
+		Bytes:   make([]byte, 0, MaxSweepMarketSize),
+		MaxSize: MaxSweepMarketSize,
+	}
+	p.PackByte(consts.SweepMarketID)
+	if err := codec.LinearCodec.MarshalInto(s, p); err != nil {
+		panic(fmt.Errorf("failed to marshal SweepMarket action: %w", err))
+	}
+	return p.Bytes
+}
+
+// UnmarshalSweepMarket deserializes bytes into a SweepMarket action.
+func UnmarshalSweepMarket(bytes []byte) (chain.Action, error) {
+	if len(bytes) == 0 {
+		return nil, ErrUnmarshalEmptySweepMarket
+	}
+	if bytes[0] != consts.SweepMarketID {
+		return nil, fmt.Errorf("unexpected SweepMarket typeID: %d != %d", bytes[0], consts.SweepMarketID)
+	}
+	s := &SweepMarket{}
+	if err := codec.LinearCodec.UnmarshalFrom(
+		&wrappers.Packer{Bytes: bytes[1:]},
+		s,
+	); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal SweepMarket action: %w", err)
+	}
+	return s, nil
+}
+
+// StateKeys implements chain.Action
+func (s *SweepMarket) StateKeys(actor codec.Address, _ ids.ID) state.Keys {
+	keys := withStatusIndexKeys(state.Keys{
+		string(storage.MarketKey(s.MarketID)):                  state.Read | state.Write,
+		string(storage.MarketStateKey(s.MarketID)):             state.Read | state.Write,
+		string(storage.CreatorIndexKey(s.Creator, s.MarketID)): state.All,
+		string(storage.EndTimeIndexKey(s.EndTime, s.MarketID)): state.All,
+		string(storage.DisputeKey(s.MarketID)):                 state.Read | state.Write,
+		string(storage.AppealKey(s.MarketID)):                  state.Read | state.Write,
+		string(storage.MarketSummaryKey(s.MarketID)):           state.All,
+		string(storage.BalanceKey(actor)):                      state.All, // Empty balances are removed, so the credit may create it
+	}, s.MarketID, storage.MarketStatus_ResolvedYes, storage.MarketStatus_ResolvedNo, storage.MarketStatus_ResolvedInvalid)
+	for _, holder := range s.Holders {
+		for _, shareType := range []uint8{consts.YesShareType, consts.NoShareType} {
+			keys[string(storage.ShareBalanceKey(s.MarketID, holder, shareType))] = state.Read | state.Write
+			keys[string(storage.PositionIndexKey(holder, shareType, s.MarketID))] = state.Read | state.Write
+		}
+		keys[string(storage.PaidCollateralKey(s.MarketID, holder))] = state.Read | state.Write
+	}
+	for _, voter := range s.Voters {
+		for _, tier := range []uint8{storage.Tier_Dispute, storage.Tier_Appeal} {
+			keys[string(storage.AppealVoteKey(s.MarketID, tier, voter))] = state.Read | state.Write
+		}
+	}
+	return keys
+}
+
+// Execute removes the market and credits the sweep deposit to the actor.
+func (s *SweepMarket) Execute(
+	ctx context.Context,
+	rules chain.Rules,
+	mu state.Mutable,
+	timestamp int64,
+	actor codec.Address,
+	_ ids.ID,
+) ([]byte, error) {
+	if len(s.Holders) > MaxSweepHolders {
+		return nil, fmt.Errorf("%w: %d > %d", ErrTooManySweepHolders, len(s.Holders), MaxSweepHolders)
+	}
+	if len(s.Voters) > MaxSweepVoters {
+		return nil, fmt.Errorf("%w: %d > %d", ErrTooManySweepVoters, len(s.Voters), MaxSweepVoters)
+	}
+	market, err := storage.GetMarket(ctx, mu, s.MarketID)
+	if err != nil {
+		return nil, err
+	}
+	if market.Creator != s.Creator || market.EndTime != s.EndTime {
+		return nil, fmt.Errorf("%w: market %d was created by %s and ends at %d", ErrSweepMarketMismatch, s.MarketID, market.Creator, market.EndTime)
+	}
+	if err := checkSettled(ctx, rules, mu, market, timestamp); err != nil {
+		return nil, err
+	}
+
+	for _, holder := range s.Holders {
+		for _, shareType := range []uint8{consts.YesShareType, consts.NoShareType} {
+			if err := storage.RemoveShareBalance(ctx, mu, s.MarketID, holder, shareType); err != nil {
+				return nil, err
+			}
+		}
+		if err := storage.RemovePaidCollateral(ctx, mu, s.MarketID, holder); err != nil {
+			return nil, err
+		}
+	}
+	for _, voter := range s.Voters {
+		for _, tier := range []uint8{storage.Tier_Dispute, storage.Tier_Appeal} {
+			if err := storage.RemoveVote(ctx, mu, s.MarketID, tier, voter); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := storage.DeleteMarket(ctx, mu, market); err != nil {
+		return nil, err
+	}
+	if err := storage.SetMarketSummary(ctx, mu, &storage.MarketSummary{
+		ID:              market.ID,
+		Creator:         market.Creator,
+		Oracle:          market.Oracle,
+		EndTime:         market.EndTime,
+		Status:          market.Status,
+		ResolvedOutcome: market.ResolvedOutcome,
+		ResolvedAt:      market.ResolvedAt,
+		Tier:            market.Tier,
+		SweptAt:         timestamp,
+	}); err != nil {
+		return nil, err
+	}
+	// Collateral left over from rounding down payouts goes with the deposit.
+	rebate := market.SweepDeposit + market.Collateral
+	if err := storage.AddBalance(ctx, mu, actor, rebate); err != nil {
+		return nil, fmt.Errorf("failed to pay sweep rebate %d of market %d to %s: %w", rebate, s.MarketID, actor, err)
+	}
+	return nil, nil
+}
+
+// checkSettled returns ErrMarketNotSettled unless [market] is final, no claim
+// can pay out any more and every bond posted against it has been withdrawn.
+func checkSettled(ctx context.Context, rules chain.Rules, im state.Immutable, market *storage.Market, timestamp int64) error {
+	if !IsFinal(rules, market, timestamp) {
+		return fmt.Errorf("%w: market %d is not final (status: %s)", ErrMarketNotSettled, market.ID, market.Status)
+	}
+	var unclaimed uint64
+	redeemYes, redeemNo := redeemed(market)
+	if redeemYes {
+		unclaimed += market.TotalYesShares
+	}
+	if redeemNo {
+		unclaimed += market.TotalNoShares
+	}
+	// Once every winner has claimed, the losing shares look refundable but
+	// there is no collateral left to pay them.
+	if unclaimed > 0 && market.Collateral > 0 {
+		return fmt.Errorf("%w: market %d has %d unclaimed shares", ErrMarketNotSettled, market.ID, unclaimed)
+	}
+
+	dispute, err := storage.GetDispute(ctx, im, market.ID)
+	switch {
+	case err == nil && dispute.Bond > 0:
+		return fmt.Errorf("%w: dispute bond of market %d was not withdrawn", ErrMarketNotSettled, market.ID)
+	case err != nil && !errors.Is(err, database.ErrNotFound):
+		return err
+	}
+	appeal, err := storage.GetAppeal(ctx, im, market.ID)
+	switch {
+	case err == nil && appeal.Bond > 0:
+		return fmt.Errorf("%w: appeal bond of market %d was not withdrawn", ErrMarketNotSettled, market.ID)
+	case err != nil && !errors.Is(err, database.ErrNotFound):
+		return err
+	}
+	return nil
+}
+
+// ComputeUnits implements chain.Action
+func (*SweepMarket) ComputeUnits(chain.Rules) uint64 {
+	return SweepMarketComputeUnits
+}
+
+// ValidRange implements chain.Action
+func (*SweepMarket) ValidRange(chain.Rules) (int64, int64) {
+	return -1, -1 // Always valid
+}
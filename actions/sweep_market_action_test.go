@@ -0,0 +1,173 @@
+package actions
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/hypersdk/chain/chaintest"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chokosabe/predictionvm/consts"
+	"github.com/chokosabe/predictionvm/storage"
+)
+
+var testSweeper = codec.Address{0x09}
+
+// setSweepableMarket creates a manual market through CreateMarket and
+// resolves it to Yes at time zero, as ResolveMarket would, with [alice]
+// holding 30 YES and 10 NO, [bob] holding 20 NO and [testDisputer] having
+// voted on its dispute and appeal.
+func setSweepableMarket(t *testing.T, mu *chaintest.InMemoryStore, alice, bob codec.Address) *storage.Market {
+	t.Helper()
+	require := require.New(t)
+	ctx := context.Background()
+
+	require.NoError(storage.SetBalance(ctx, mu, testOracle, consts.DefaultSweepDeposit))
+	create := &CreateMarket{
+		Description:    "Is the market swept once settled?",
+		EndTime:        5_000,
+		ResolutionTime: 10_000,
+		OracleType:     consts.OracleTypeManual,
+	}
+	output, err := execute(t, create, &MockRules{}, mu, 1_000, testOracle, ids.ID{7: 0x01})
+	require.NoError(err)
+	result, err := UnmarshalCreateMarketResult(output)
+	require.NoError(err)
+
+	market, err := storage.GetMarket(ctx, mu, result.(*CreateMarketResult).MarketID)
+	require.NoError(err)
+	require.Equal(consts.DefaultSweepDeposit, market.SweepDeposit)
+	market.Status = storage.MarketStatus_ResolvedYes
+	market.ResolvedOutcome = storage.Outcome_Yes
+	market.TotalYesShares = 30
+	market.TotalNoShares = 30
+	market.Collateral = 1_001
+	require.NoError(storage.SetMarket(ctx, mu, market))
+	require.NoError(storage.RemoveFromIndex(ctx, mu, storage.PendingResolutionIndex, market.ID))
+	require.NoError(storage.SetShareBalance(ctx, mu, market.ID, alice, consts.YesShareType, 30))
+	require.NoError(storage.SetShareBalance(ctx, mu, market.ID, alice, consts.NoShareType, 10))
+	require.NoError(storage.SetShareBalance(ctx, mu, market.ID, bob, consts.NoShareType, 20))
+	require.NoError(storage.AddPaidCollateral(ctx, mu, market.ID, alice, 501))
+	require.NoError(storage.AddPaidCollateral(ctx, mu, market.ID, bob, 500))
+	require.NoError(storage.SetVote(ctx, mu, market.ID, storage.Tier_Dispute, testDisputer, true))
+	require.NoError(storage.SetVote(ctx, mu, market.ID, storage.Tier_Appeal, testDisputer, false))
+	return market
+}
+
+func TestSweepMarket_Execute(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	mu := chaintest.NewInMemoryStore()
+	alice, bob := codec.Address{0x01}, codec.Address{0x03}
+	market := setSweepableMarket(t, mu, alice, bob)
+	requireBalance(t, mu, testOracle, 0)
+
+	sweep := &SweepMarket{
+		MarketID: market.ID,
+		Creator:  market.Creator,
+		EndTime:  market.EndTime,
+		Holders:  []codec.Address{alice, bob},
+		Voters:   []codec.Address{testDisputer},
+	}
+	_, err := execute(t, sweep, &MockRules{}, mu, claimTime, testSweeper, ids.Empty)
+	require.ErrorIs(err, ErrMarketNotSettled, "Alice has not claimed her YES shares")
+
+	_, err = execute(t, &Claim{MarketID: market.ID}, &MockRules{}, mu, claimTime, alice, ids.Empty)
+	require.NoError(err)
+	requireBalance(t, mu, alice, 1_001)
+
+	_, err = execute(t, sweep, &MockRules{}, mu, claimTime, testSweeper, ids.Empty)
+	require.NoError(err)
+	requireBalance(t, mu, testSweeper, consts.DefaultSweepDeposit)
+
+	// Only the summary and the participants' balances are left.
+	for key := range mu.Storage {
+		prefix := key[0]
+		require.Contains([]byte{storage.BalancePrefix, storage.MarketSummaryPrefix}, prefix, "key %x was not swept", key)
+	}
+	summary, err := storage.GetMarketSummary(ctx, mu, market.ID)
+	require.NoError(err)
+	require.Equal(&storage.MarketSummary{
+		ID:              market.ID,
+		Creator:         testOracle,
+		Oracle:          testOracle,
+		EndTime:         5_000,
+		Status:          storage.MarketStatus_ResolvedYes,
+		ResolvedOutcome: storage.Outcome_Yes,
+		SweptAt:         claimTime,
+	}, summary)
+
+	_, err = execute(t, sweep, &MockRules{}, mu, claimTime, testSweeper, ids.Empty)
+	require.ErrorIs(err, ErrMarketNotFound)
+}
+
+func TestSweepMarket_Execute_Errors(t *testing.T) {
+	alice, bob := codec.Address{0x01}, codec.Address{0x03}
+
+	testCases := []struct {
+		name        string
+		setup       func(*testing.T, *chaintest.InMemoryStore, *storage.Market)
+		sweep       func(*SweepMarket)
+		timestamp   int64
+		expectedErr error
+	}{
+		{
+			name:        "DisputeWindowOpen",
+			timestamp:   consts.DefaultDisputeWindow,
+			expectedErr: ErrMarketNotSettled,
+		},
+		{
+			name: "BondNotWithdrawn",
+			setup: func(t *testing.T, mu *chaintest.InMemoryStore, market *storage.Market) {
+				require.NoError(t, storage.SetDispute(context.Background(), mu, &storage.Dispute{MarketID: market.ID, Disputer: testDisputer, Bond: 1}))
+			},
+			expectedErr: ErrMarketNotSettled,
+		},
+		{
+			name:        "CreatorMismatch",
+			sweep:       func(s *SweepMarket) { s.Creator = testDisputer },
+			expectedErr: ErrSweepMarketMismatch,
+		},
+		{
+			name:        "EndTimeMismatch",
+			sweep:       func(s *SweepMarket) { s.EndTime++ },
+			expectedErr: ErrSweepMarketMismatch,
+		},
+		{
+			name:        "TooManyHolders",
+			sweep:       func(s *SweepMarket) { s.Holders = make([]codec.Address, MaxSweepHolders+1) },
+			expectedErr: ErrTooManySweepHolders,
+		},
+		{
+			name:        "TooManyVoters",
+			sweep:       func(s *SweepMarket) { s.Voters = make([]codec.Address, MaxSweepVoters+1) },
+			expectedErr: ErrTooManySweepVoters,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+			mu := chaintest.NewInMemoryStore()
+			market := setSweepableMarket(t, mu, alice, bob)
+			_, err := execute(t, &Claim{MarketID: market.ID}, &MockRules{}, mu, claimTime, alice, ids.Empty)
+			require.NoError(err)
+			if tc.setup != nil {
+				tc.setup(t, mu, market)
+			}
+
+			sweep := &SweepMarket{MarketID: market.ID, Creator: market.Creator, EndTime: market.EndTime}
+			if tc.sweep != nil {
+				tc.sweep(sweep)
+			}
+			timestamp := tc.timestamp
+			if timestamp == 0 {
+				timestamp = claimTime
+			}
+			_, err = execute(t, sweep, &MockRules{}, mu, timestamp, testSweeper, ids.Empty)
+			require.ErrorIs(err, tc.expectedErr)
+			requireBalance(t, mu, testSweeper, 0)
+		})
+	}
+}
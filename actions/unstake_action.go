@@ -0,0 +1,119 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/wrappers"
+	"github.com/ava-labs/hypersdk/chain"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/ava-labs/hypersdk/state"
+
+	"github.com/chokosabe/predictionvm/consts"
+	"github.com/chokosabe/predictionvm/storage"
+)
+
+const (
+	UnstakeComputeUnits = 500
+	MaxUnstakeSize      = 16
+)
+
+var (
+	ErrUnmarshalEmptyUnstake              = errors.New("cannot unmarshal empty bytes as Unstake action")
+	ErrInsufficientStake                  = errors.New("insufficient stake")
+	ErrStakeLocked                        = errors.New("stake is locked by an appeal vote")
+	_                        chain.Action = (*Unstake)(nil)
+)
+
+// Unstake returns [Amount] of the actor's stake to its balance. A stake that
+// voted on an appeal stays locked until that appeal's voting ends.
+type Unstake struct {
+	Amount uint64 `serialize:"true" json:"amount"`
+}
+
+func (*Unstake) GetTypeID() uint8 {
+	return consts.UnstakeID
+}
+
+// Bytes serializes the Unstake action.
+func (u *Unstake) Bytes() []byte {
+	p := &wrappers.Packer{
+		Bytes:   make([]byte, 0, MaxUnstakeSize),
+		MaxSize: MaxUnstakeSize,
+	}
+	p.PackByte(consts.UnstakeID)
+	if err := codec.LinearCodec.MarshalInto(u, p); err != nil {
+		panic(fmt.Errorf("failed to marshal Unstake action: %w", err))
+	}
+	return p.Bytes
+}
+
+// UnmarshalUnstake deserializes bytes into an Unstake action.
+func UnmarshalUnstake(bytes []byte) (chain.Action, error) {
+	if len(bytes) == 0 {
+		return nil, ErrUnmarshalEmptyUnstake
+	}
+	if bytes[0] != consts.UnstakeID {
+		return nil, fmt.Errorf("unexpected Unstake typeID: %d != %d", bytes[0], consts.UnstakeID)
+	}
+	u := &Unstake{}
+	if err := codec.LinearCodec.UnmarshalFrom(
+		&wrappers.Packer{Bytes: bytes[1:]},
+		u,
+	); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal Unstake action: %w", err)
+	}
+	return u, nil
+}
+
+// StateKeys implements chain.Action
+func (*Unstake) StateKeys(actor codec.Address, _ ids.ID) state.Keys {
+	return state.Keys{
+		string(storage.StakeKey(actor)):   state.Read | state.Write,
+		string(storage.BalanceKey(actor)): state.All, // Empty balances are removed, so the credit may create it
+	}
+}
+
+// Execute moves [Amount] from the actor's stake back to its balance.
+func (u *Unstake) Execute(
+	ctx context.Context,
+	_ chain.Rules,
+	mu state.Mutable,
+	timestamp int64,
+	actor codec.Address,
+	_ ids.ID,
+) ([]byte, error) {
+	if u.Amount == 0 {
+		return nil, ErrZeroStakeAmount
+	}
+	stake, err := storage.GetStake(ctx, mu, actor)
+	if err != nil {
+		return nil, err
+	}
+	if stake.Amount < u.Amount {
+		return nil, fmt.Errorf("%w: %s has %d staked, requested %d", ErrInsufficientStake, actor, stake.Amount, u.Amount)
+	}
+	if timestamp < stake.LockedUntil {
+		return nil, fmt.Errorf("%w: stake of %s is locked until %d (current: %d)", ErrStakeLocked, actor, stake.LockedUntil, timestamp)
+	}
+	stake.Amount -= u.Amount
+	if err := storage.SetStake(ctx, mu, actor, stake); err != nil {
+		return nil, err
+	}
+	if err := storage.AddBalance(ctx, mu, actor, u.Amount); err != nil {
+		return nil, fmt.Errorf("failed to return stake %d to %s: %w", u.Amount, actor, err)
+	}
+	return nil, nil
+}
+
+// ComputeUnits implements chain.Action
+func (*Unstake) ComputeUnits(chain.Rules) uint64 {
+	return UnstakeComputeUnits
+}
+
+// ValidRange implements chain.Action
+func (*Unstake) ValidRange(chain.Rules) (int64, int64) {
+	return -1, -1 // Always valid
+}
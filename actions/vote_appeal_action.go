@@ -0,0 +1,172 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/wrappers"
+	"github.com/ava-labs/hypersdk/chain"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/ava-labs/hypersdk/state"
+
+	"github.com/chokosabe/predictionvm/consts"
+	"github.com/chokosabe/predictionvm/storage"
+)
+
+const (
+	VoteAppealComputeUnits = 500
+	MaxVoteAppealSize      = 16
+)
+
+var (
+	ErrUnmarshalEmptyVoteAppeal              = errors.New("cannot unmarshal empty bytes as VoteAppeal action")
+	ErrNoStake                               = errors.New("only stakers can vote on appeals")
+	ErrAppealVotingClosed                    = errors.New("appeal voting has closed")
+	ErrAlreadyVoted                          = errors.New("actor already voted on the appeal")
+	ErrInvalidVoteTier                       = errors.New("stakers only vote at the dispute and appeal tiers")
+	_                           chain.Action = (*VoteAppeal)(nil)
+)
+
+// VoteAppeal casts the actor's whole stake for ([Overturn]) or against the
+// proposed outcome of a market's pending dispute ([Tier] storage.Tier_Dispute)
+// or appeal (storage.Tier_Appeal). The stake is locked until voting ends so
+// that it cannot be moved to vote again.
+type VoteAppeal struct {
+	MarketID uint64 `serialize:"true" json:"marketId"`
+	Tier     uint8  `serialize:"true" json:"tier"`
+	Overturn bool   `serialize:"true" json:"overturn"`
+}
+
+func (*VoteAppeal) GetTypeID() uint8 {
+	return consts.VoteAppealID
+}
+
+// Bytes serializes the VoteAppeal action.
+func (v *VoteAppeal) Bytes() []byte {
+	p := &wrappers.Packer{
+		Bytes:   make([]byte, 0, MaxVoteAppealSize),
+		MaxSize: MaxVoteAppealSize,
+	}
+	p.PackByte(consts.VoteAppealID)
+	if err := codec.LinearCodec.MarshalInto(v, p); err != nil {
+		panic(fmt.Errorf("failed to marshal VoteAppeal action: %w", err))
+	}
+	return p.Bytes
+}
+
+// UnmarshalVoteAppeal deserializes bytes into a VoteAppeal action.
+func UnmarshalVoteAppeal(bytes []byte) (chain.Action, error) {
+	if len(bytes) == 0 {
+		return nil, ErrUnmarshalEmptyVoteAppeal
+	}
+	if bytes[0] != consts.VoteAppealID {
+		return nil, fmt.Errorf("unexpected VoteAppeal typeID: %d != %d", bytes[0], consts.VoteAppealID)
+	}
+	v := &VoteAppeal{}
+	if err := codec.LinearCodec.UnmarshalFrom(
+		&wrappers.Packer{Bytes: bytes[1:]},
+		v,
+	); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal VoteAppeal action: %w", err)
+	}
+	return v, nil
+}
+
+// StateKeys implements chain.Action
+func (v *VoteAppeal) StateKeys(actor codec.Address, _ ids.ID) state.Keys {
+	return state.Keys{
+		string(storage.DisputeKey(v.MarketID)):                   state.Read | state.Write,
+		string(storage.AppealKey(v.MarketID)):                    state.Read | state.Write,
+		string(storage.AppealVoteKey(v.MarketID, v.Tier, actor)): state.All,
+		string(storage.StakeKey(actor)):                          state.Read | state.Write,
+	}
+}
+
+// Execute tallies the actor's stake on the dispute or appeal.
+func (v *VoteAppeal) Execute(
+	ctx context.Context,
+	_ chain.Rules,
+	mu state.Mutable,
+	timestamp int64,
+	actor codec.Address,
+	_ ids.ID,
+) ([]byte, error) {
+	var (
+		status       storage.DisputeStatus
+		votingEndsAt int64
+		tally        func(overturn bool, stake uint64) error
+	)
+	switch v.Tier {
+	case storage.Tier_Dispute:
+		dispute, err := storage.GetDispute(ctx, mu, v.MarketID)
+		if err != nil {
+			return nil, err
+		}
+		status, votingEndsAt = dispute.Status, dispute.VotingEndsAt
+		tally = func(overturn bool, stake uint64) error {
+			if overturn {
+				dispute.OverturnVotes += stake
+			} else {
+				dispute.UpholdVotes += stake
+			}
+			return storage.SetDispute(ctx, mu, dispute)
+		}
+	case storage.Tier_Appeal:
+		appeal, err := storage.GetAppeal(ctx, mu, v.MarketID)
+		if err != nil {
+			return nil, err
+		}
+		status, votingEndsAt = appeal.Status, appeal.VotingEndsAt
+		tally = func(overturn bool, stake uint64) error {
+			if overturn {
+				appeal.OverturnVotes += stake
+			} else {
+				appeal.UpholdVotes += stake
+			}
+			return storage.SetAppeal(ctx, mu, appeal)
+		}
+	default:
+		return nil, fmt.Errorf("%w: %d", ErrInvalidVoteTier, v.Tier)
+	}
+	if status != storage.DisputeStatus_Pending || timestamp >= votingEndsAt {
+		return nil, fmt.Errorf("%w: tier %d vote on market %d (status: %s, voting ends at: %d, current: %d)", ErrAppealVotingClosed, v.Tier, v.MarketID, status, votingEndsAt, timestamp)
+	}
+	voted, err := storage.HasVoted(ctx, mu, v.MarketID, v.Tier, actor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get vote of %s on market %d: %w", actor, v.MarketID, err)
+	}
+	if voted {
+		return nil, fmt.Errorf("%w: %s on market %d at tier %d", ErrAlreadyVoted, actor, v.MarketID, v.Tier)
+	}
+	stake, err := storage.GetStake(ctx, mu, actor)
+	if err != nil {
+		return nil, err
+	}
+	if stake.Amount == 0 {
+		return nil, fmt.Errorf("%w: %s has no stake", ErrNoStake, actor)
+	}
+
+	if err := tally(v.Overturn, stake.Amount); err != nil {
+		return nil, err
+	}
+	if err := storage.SetVote(ctx, mu, v.MarketID, v.Tier, actor, v.Overturn); err != nil {
+		return nil, err
+	}
+	stake.LockedUntil = max(stake.LockedUntil, votingEndsAt)
+	if err := storage.SetStake(ctx, mu, actor, stake); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// ComputeUnits implements chain.Action
+func (*VoteAppeal) ComputeUnits(chain.Rules) uint64 {
+	return VoteAppealComputeUnits
+}
+
+// ValidRange implements chain.Action
+func (*VoteAppeal) ValidRange(chain.Rules) (int64, int64) {
+	return -1, -1 // Always valid
+}
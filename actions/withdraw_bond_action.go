@@ -0,0 +1,165 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/wrappers"
+	"github.com/ava-labs/hypersdk/chain"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/ava-labs/hypersdk/state"
+
+	"github.com/chokosabe/predictionvm/consts"
+	"github.com/chokosabe/predictionvm/storage"
+)
+
+const (
+	WithdrawBondComputeUnits = 500
+	MaxWithdrawBondSize      = 16
+)
+
+var (
+	ErrUnmarshalEmptyWithdrawBond = errors.New("cannot unmarshal empty bytes as WithdrawBond action")
+	ErrNotBondBeneficiary         = errors.New("actor is not entitled to the bond")
+	ErrNoBondToWithdraw           = errors.New("no bond to withdraw")
+	ErrInvalidBondTier            = errors.New("bonds are only posted at the dispute and appeal tiers")
+	_                             chain.Action = (*WithdrawBond)(nil)
+)
+
+// WithdrawBond pays out the escrowed bond posted to challenge a market's
+// ruling at [Tier] (storage.Tier_Dispute or storage.Tier_Appeal) once the
+// market is final. The bond goes back to the challenger if the challenge was
+// upheld and to the market's oracle otherwise.
+type WithdrawBond struct {
+	MarketID uint64 `serialize:"true" json:"marketId"`
+	Tier     uint8  `serialize:"true" json:"tier"`
+}
+
+func (*WithdrawBond) GetTypeID() uint8 {
+	return consts.WithdrawBondID
+}
+
+// Bytes serializes the WithdrawBond action.
+func (w *WithdrawBond) Bytes() []byte {
+	p := &wrappers.Packer{
+		Bytes:   make([]byte, 0, MaxWithdrawBondSize),
+		MaxSize: MaxWithdrawBondSize,
+	}
+	p.PackByte(consts.WithdrawBondID)
+	if err := codec.LinearCodec.MarshalInto(w, p); err != nil {
+		panic(fmt.Errorf("failed to marshal WithdrawBond action: %w", err))
+	}
+	return p.Bytes
+}
+
+// UnmarshalWithdrawBond deserializes bytes into a WithdrawBond action.
+func UnmarshalWithdrawBond(bytes []byte) (chain.Action, error) {
+	if len(bytes) == 0 {
+		return nil, ErrUnmarshalEmptyWithdrawBond
+	}
+	if bytes[0] != consts.WithdrawBondID {
+		return nil, fmt.Errorf("unexpected WithdrawBond typeID: %d != %d", bytes[0], consts.WithdrawBondID)
+	}
+	w := &WithdrawBond{}
+	if err := codec.LinearCodec.UnmarshalFrom(
+		&wrappers.Packer{Bytes: bytes[1:]},
+		w,
+	); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal WithdrawBond action: %w", err)
+	}
+	return w, nil
+}
+
+// StateKeys implements chain.Action
+func (w *WithdrawBond) StateKeys(actor codec.Address, _ ids.ID) state.Keys {
+	bondKey := storage.DisputeKey(w.MarketID)
+	if w.Tier == storage.Tier_Appeal {
+		bondKey = storage.AppealKey(w.MarketID)
+	}
+	return state.Keys{
+		string(storage.MarketKey(w.MarketID)):      state.Read,
+		string(storage.MarketStateKey(w.MarketID)): state.Read,
+		string(bondKey):                   state.Read | state.Write,
+		string(storage.BalanceKey(actor)): state.All, // Empty balances are removed, so the credit may create it
+	}
+}
+
+// Execute credits the bond to its beneficiary.
+func (w *WithdrawBond) Execute(
+	ctx context.Context,
+	rules chain.Rules,
+	mu state.Mutable,
+	timestamp int64,
+	actor codec.Address,
+	_ ids.ID,
+) ([]byte, error) {
+	if w.Tier != storage.Tier_Dispute && w.Tier != storage.Tier_Appeal {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidBondTier, w.Tier)
+	}
+	market, err := storage.GetMarket(ctx, mu, w.MarketID)
+	if err != nil {
+		return nil, err
+	}
+	if !IsFinal(rules, market, timestamp) {
+		return nil, fmt.Errorf("%w: market %d is not final (status: %s)", ErrNoBondToWithdraw, w.MarketID, market.Status)
+	}
+	oracle, err := manualResolver(market)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		challenger codec.Address
+		status     storage.DisputeStatus
+		bond       uint64
+		save       func() error
+	)
+	if w.Tier == storage.Tier_Dispute {
+		dispute, err := storage.GetDispute(ctx, mu, w.MarketID)
+		if err != nil {
+			return nil, err
+		}
+		challenger, status, bond = dispute.Disputer, dispute.Status, dispute.Bond
+		dispute.Bond = 0
+		save = func() error { return storage.SetDispute(ctx, mu, dispute) }
+	} else {
+		appeal, err := storage.GetAppeal(ctx, mu, w.MarketID)
+		if err != nil {
+			return nil, err
+		}
+		challenger, status, bond = appeal.Appellant, appeal.Status, appeal.Bond
+		appeal.Bond = 0
+		save = func() error { return storage.SetAppeal(ctx, mu, appeal) }
+	}
+
+	beneficiary := oracle
+	if status == storage.DisputeStatus_Upheld {
+		beneficiary = challenger
+	}
+	if actor != beneficiary {
+		return nil, fmt.Errorf("%w: tier %d bond of market %d (status: %s) goes to %s", ErrNotBondBeneficiary, w.Tier, w.MarketID, status, beneficiary)
+	}
+	if bond == 0 {
+		return nil, fmt.Errorf("%w: tier %d bond of market %d was already withdrawn", ErrNoBondToWithdraw, w.Tier, w.MarketID)
+	}
+
+	if err := save(); err != nil {
+		return nil, err
+	}
+	if err := storage.AddBalance(ctx, mu, actor, bond); err != nil {
+		return nil, fmt.Errorf("failed to pay tier %d bond %d of market %d to %s: %w", w.Tier, bond, w.MarketID, actor, err)
+	}
+	return nil, nil
+}
+
+// ComputeUnits implements chain.Action
+func (*WithdrawBond) ComputeUnits(chain.Rules) uint64 {
+	return WithdrawBondComputeUnits
+}
+
+// ValidRange implements chain.Action
+func (*WithdrawBond) ValidRange(chain.Rules) (int64, int64) {
+	return -1, -1 // Always valid
+}
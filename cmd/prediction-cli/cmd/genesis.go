@@ -6,12 +6,17 @@ package cmd
 import (
 	"encoding/json"
 	"os"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 
 	"github.com/ava-labs/hypersdk/fees"
-	"github.com/ava-labs/hypersdk/genesis"
+	"github.com/ava-labs/hypersdk/utils"
+
+	"github.com/chokosabe/predictionvm/consts"
+
+	"github.com/chokosabe/predictionvm/genesis"
 )
 
 var genesisCmd = &cobra.Command{
@@ -35,11 +40,16 @@ var genGenesisCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
-		var allocs []*genesis.CustomAllocation
+		var allocs []genesis.Allocation
 		if err := json.Unmarshal(a, &allocs); err != nil {
 			return err
 		}
-		genesis := genesis.NewDefaultGenesis(allocs)
+		for _, alloc := range allocs {
+			if _, err := genesis.ParseAddress(alloc.Address); err != nil {
+				return err
+			}
+		}
+		genesis := genesis.NewGenesis(allocs)
 		if len(minUnitPrice) > 0 {
 			d, err := fees.ParseDimensions(minUnitPrice)
 			if err != nil {
@@ -80,3 +90,56 @@ var genGenesisCmd = &cobra.Command{
 		return nil
 	},
 }
+
+var validateGenesisCmd = &cobra.Command{
+	Use:   "validate [genesis file]",
+	Short: "Checks a genesis file and prints a summary of it",
+	PreRunE: func(_ *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return ErrInvalidArgs
+		}
+		return nil
+	},
+	RunE: func(_ *cobra.Command, args []string) error {
+		b, err := os.ReadFile(args[0])
+		if err != nil {
+			return err
+		}
+		g := &genesis.Genesis{}
+		if err := g.Load(b); err != nil {
+			return err
+		}
+		summary, err := g.Verify()
+		if err != nil {
+			return err
+		}
+
+		utils.Outf("{{yellow}}accounts:{{/}} %d\n", summary.Accounts)
+		utils.Outf("{{yellow}}supply:{{/}} %s %s\n", utils.FormatBalance(summary.Supply), consts.Symbol)
+		utils.Outf("{{yellow}}markets:{{/}} %d\n", len(summary.Markets))
+		for _, market := range summary.Markets {
+			utils.Outf(
+				"  {{cyan}}%d:{{/}} %q {{yellow}}oracle:{{/}} %s {{yellow}}closes:{{/}} %s {{yellow}}liquidity:{{/}} %s %s\n",
+				market.ID,
+				market.Description,
+				market.Oracle,
+				time.UnixMilli(market.EndTime).UTC().Format(time.RFC3339),
+				utils.FormatBalance(market.Collateral),
+				consts.Symbol,
+			)
+		}
+		params, err := json.MarshalIndent(summary.Params, "", "  ")
+		if err != nil {
+			return err
+		}
+		utils.Outf("{{yellow}}params:{{/}} %s\n", params)
+		rules, err := json.MarshalIndent(summary.Rules, "", "  ")
+		if err != nil {
+			return err
+		}
+		utils.Outf("{{yellow}}rules:{{/}} %s\n", rules)
+
+		color.Green("%s is a valid genesis", args[0])
+		return nil
+	},
+}
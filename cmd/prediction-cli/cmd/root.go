@@ -112,6 +112,7 @@ func init() {
 	)
 	genesisCmd.AddCommand(
 		genGenesisCmd,
+		validateGenesisCmd,
 	)
 
 	// key
@@ -11,6 +11,7 @@ import (
 const (
 	Name   = "predictionvm" // Changed from "morpheusvm"
 	Symbol = "PRED"       // Changed from "RED"
+	HRP    = "pred"       // Human-readable part of bech32 genesis addresses
 
 	// MaxMarketDataSize defines the maximum expected size for marshaled market data.
 	MaxMarketDataSize = 1024
@@ -20,22 +21,151 @@ const (
 	CreateMarketID uint8 = iota
 	BuyYesID       // Automatically 1
 	BuyNoID        // Automatically 2
-	// TODO: Add ResolveMarketID, BuyNoID etc.
+	ResolveMarketID
+	PublishPriceID
+	ExpireMarketID
+	ClaimID
+	DisputeMarketID
+	WithdrawBondID
+	StakeID
+	UnstakeID
+	AppealMarketID
+	VoteAppealID
+	FinalizeAppealID
+	SweepMarketID
 )
 
 const (
 	// CodecVersionDefault is the default version for marshalling/unmarshalling.
 	CodecVersionDefault uint16 = 0
 
-	// Storage Prefixes
-	BalancePrefix byte = 0x3 // Assuming 0x0-height, 0x1-timestamp, 0x2-fee
-
-	// Storage Chunk Sizes/Info
-	BalanceChunks uint16 = 1
-	Uint16Len     int    = 2
+	// Storage prefixes and chunk sizes are defined in the storage package.
 
 	// Limits
 	MaxActionSize = 1024 // 1KB limit for action byte size
+	// MaxDescriptionSize and MaxOracleSourceSize are the longest market
+	// description and oracle source, in bytes, that fit a stored market.
+	// Genesis may lower them.
+	MaxDescriptionSize  = 256
+	MaxOracleSourceSize = 256
+)
+
+// Price feeds
+const (
+	// DefaultFeedCapacity is the number of observations each feed keeps.
+	// Publishing more overwrites the oldest.
+	DefaultFeedCapacity uint64 = 1024
+
+	// FeedCapacityKey is the chain.Rules custom key holding the chain-wide
+	// feed capacity. FeedPublishersKey holds the addresses allowed to
+	// publish, as a []codec.Address.
+	FeedCapacityKey   = "feedCapacity"
+	FeedPublishersKey = "feedPublishers"
+)
+
+// Resolution deadline
+const (
+	// DefaultResolutionGracePeriod is how long (in milliseconds) after its
+	// ResolutionTime a market may remain unresolved before anyone can expire
+	// it as Invalid. Chains may override it in genesis and markets may
+	// override it at creation.
+	DefaultResolutionGracePeriod int64 = 7 * 24 * 60 * 60 * 1000
+
+	// MaxResolutionGracePeriod bounds the grace period of a chain and of any
+	// market, so that resolution deadlines stay far from overflowing.
+	MaxResolutionGracePeriod int64 = 10 * 365 * 24 * 60 * 60 * 1000
+
+	// ResolutionGracePeriodKey is the chain.Rules custom key holding the
+	// chain-wide resolution grace period.
+	ResolutionGracePeriodKey = "resolutionGracePeriod"
+)
+
+// Disputes
+const (
+	// DefaultDisputeWindow is how long (in milliseconds) after a manual market
+	// is resolved its outcome may be disputed. Claims open once it has passed.
+	DefaultDisputeWindow int64 = 24 * 60 * 60 * 1000
+
+	// DefaultDisputeBond is the amount a disputer escrows. It is returned if
+	// stakers overturn the disputed ruling and paid to the oracle otherwise.
+	DefaultDisputeBond uint64 = 1_000_000_000
+
+	// DisputeWindowKey and DisputeBondKey are the chain.Rules custom keys
+	// holding the chain-wide dispute window and bond.
+	DisputeWindowKey = "disputeWindow"
+	DisputeBondKey   = "disputeBond"
+)
+
+// Appeals
+const (
+	// DefaultAppealBond is the amount an appellant escrows. It is returned if
+	// stakers overturn the appealed ruling and paid to the oracle otherwise.
+	DefaultAppealBond uint64 = 2 * DefaultDisputeBond
+
+	// DefaultAppealVotingPeriod is how long (in milliseconds) stakers may vote
+	// on a dispute or an appeal.
+	DefaultAppealVotingPeriod int64 = 3 * 24 * 60 * 60 * 1000
+
+	// AppealBondKey and AppealVotingPeriodKey are the chain.Rules custom keys
+	// holding the chain-wide appeal bond and voting period.
+	AppealBondKey         = "appealBond"
+	AppealVotingPeriodKey = "appealVotingPeriod"
+)
+
+// Sweeping
+const (
+	// DefaultSweepDeposit is the amount a market's creator escrows. It is paid
+	// to whoever sweeps the market from state once it is settled.
+	DefaultSweepDeposit uint64 = 10_000_000
+
+	// SweepDepositKey is the chain.Rules custom key holding the chain-wide
+	// sweep deposit.
+	SweepDepositKey = "sweepDeposit"
+)
+
+// Market limits
+const (
+	// DefaultMinMarketDuration and DefaultMaxMarketDuration bound how long (in
+	// milliseconds) after its creation a market may close. Zero leaves the
+	// bound unset.
+	DefaultMinMarketDuration int64 = 0
+	DefaultMaxMarketDuration int64 = 0
+
+	// MinMarketDurationKey, MaxMarketDurationKey, MaxDescriptionSizeKey,
+	// MaxOracleSourceSizeKey and MaxOracleParametersSizeKey are the
+	// chain.Rules custom keys holding the chain-wide market limits.
+	MinMarketDurationKey       = "minMarketDuration"
+	MaxMarketDurationKey       = "maxMarketDuration"
+	MaxDescriptionSizeKey      = "maxDescriptionSize"
+	MaxOracleSourceSizeKey     = "maxOracleSourceSize"
+	MaxOracleParametersSizeKey = "maxOracleParametersSize"
+
+	// CreateMarketComputeUnitsKey is the chain.Rules custom key holding the
+	// compute units, and so the fee, charged for CreateMarket.
+	CreateMarketComputeUnitsKey = "createMarketComputeUnits"
+
+	// MaxMarketDataSizeKey is the chain.Rules custom key holding the largest
+	// serialized market CreateMarket accepts. It may only lower
+	// MaxMarketDataSize.
+	MaxMarketDataSizeKey = "maxMarketDataSize"
+)
+
+// Fees
+const (
+	// MaxFeeRate is the highest fee rate, in basis points: a fee of the whole
+	// amount.
+	MaxFeeRate uint64 = 10_000
+
+	// DefaultTradingFeeRate is the fee, in basis points of the cost, charged
+	// on top of a purchase of shares. DefaultClaimFeeRate is the fee, in basis
+	// points, Claim withholds from a payout. Fees are burned.
+	DefaultTradingFeeRate uint64 = 0
+	DefaultClaimFeeRate   uint64 = 0
+
+	// TradingFeeRateKey and ClaimFeeRateKey are the chain.Rules custom keys
+	// holding the chain-wide fee rates.
+	TradingFeeRateKey = "tradingFeeRate"
+	ClaimFeeRateKey   = "claimFeeRate"
 )
 
 // Share Types
@@ -44,6 +174,15 @@ const (
 	NoShareType  uint8 = 1
 )
 
+// Oracle Types
+const (
+	// OracleTypeManual markets are resolved by a human resolver.
+	OracleTypeManual uint8 = 0
+	// OracleTypePriceFeed markets are resolved against the time-weighted
+	// average of an on-chain price feed.
+	OracleTypePriceFeed uint8 = 1
+)
+
 // ShareTypeToString converts a share type to its string representation.
 func ShareTypeToString(shareType uint8) string {
 	switch shareType {
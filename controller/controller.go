@@ -23,19 +23,12 @@ func New() *Controller {
 }
 
 func (c *Controller) SponsorStateKeys(addr codec.Address) state.Keys {
-	key := storage.StateKeysBalance(addr)
-	return state.Keys{string(key): state.Read}
+	// Fees are deducted from the sponsor's balance.
+	return state.Keys{string(storage.BalanceKey(addr)): state.Read | state.Write}
 }
 
 func (c *Controller) CanDeduct(ctx context.Context, addr codec.Address, im state.Immutable, amount uint64) error {
-	bal, err := storage.GetBalance(ctx, im, addr)
-	if err != nil {
-		return err
-	}
-	if bal < amount {
-		return storage.ErrInsufficientBalance
-	}
-	return nil
+	return storage.EnsureActorHasBalance(ctx, im, addr, amount)
 }
 
 func (c *Controller) Deduct(ctx context.Context, addr codec.Address, mu state.Mutable, amount uint64) error {
@@ -0,0 +1,34 @@
+package genesis
+
+import (
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/x/merkledb"
+	"github.com/ava-labs/hypersdk/chain"
+	hgenesis "github.com/ava-labs/hypersdk/genesis"
+)
+
+var _ hgenesis.GenesisAndRuleFactory = (*Factory)(nil)
+
+// Factory loads a predictionvm Genesis and the rules it carries: the hypersdk
+// rules and its protocol parameters.
+type Factory struct{}
+
+func (Factory) Load(genesisBytes []byte, _ []byte, networkID uint32, chainID ids.ID) (hgenesis.Genesis, chain.RuleFactory, error) {
+	g := &Genesis{}
+	if err := g.Load(genesisBytes); err != nil {
+		return nil, nil, err
+	}
+	if g.StateBranchFactor == 0 {
+		g.StateBranchFactor = merkledb.BranchFactor16
+	}
+	if g.Rules == nil {
+		g.Rules = hgenesis.NewDefaultRules()
+	}
+	g.Rules.NetworkID = networkID
+	g.Rules.ChainID = chainID
+	if _, err := g.Verify(); err != nil {
+		return nil, nil, err
+	}
+
+	return g, g.RuleFactory(), nil
+}
@@ -7,35 +7,58 @@ import (
 	"time"
 
 	"github.com/ava-labs/avalanchego/trace"
+	"github.com/ava-labs/avalanchego/x/merkledb"
 	"github.com/ava-labs/hypersdk/chain"
 	"github.com/ava-labs/hypersdk/codec"
+	hgenesis "github.com/ava-labs/hypersdk/genesis"
 	"github.com/ava-labs/hypersdk/state"
 	"github.com/btcsuite/btcd/btcutil/bech32"
+
+	"github.com/chokosabe/predictionvm/consts"
 )
 
-var _ chain.Genesis = (*Genesis)(nil)
+var (
+	_ chain.Genesis    = (*Genesis)(nil)
+	_ hgenesis.Genesis = (*Genesis)(nil)
+)
 
 // CustomGenesisState defines the structure for custom genesis data specific to PredictionVM.
 type CustomGenesisState struct {
-	Markets []struct {
-		ID              uint64 `json:"id"`
-		Question        string `json:"question"`
-		ClosingTime     int64  `json:"closingTime"` // Unix timestamp
-		CollateralAssetID string `json:"collateralAssetId"`
-	} `json:"markets"`
+	Markets []GenesisMarket `json:"markets"`
 }
 
 // Genesis is the genesis data for the PredictionVM.
 type Genesis struct {
 	Magic     uint64 `json:"magic"`
-	Timestamp int64  `json:"timestamp"` // Unix timestamp for the genesis block
+	Timestamp int64  `json:"timestamp"` // Unix timestamp for the genesis block, in seconds
 
 	Custom CustomGenesisState `json:"custom"`
 
-	Allocations []struct {
-		Address string `json:"address"` // Bech32 address
-		Balance uint64 `json:"balance"`
-	} `json:"allocations"`
+	// Params are the chain-wide market parameters.
+	Params ProtocolParams `json:"params"`
+
+	Allocations []Allocation `json:"allocations"`
+
+	StateBranchFactor merkledb.BranchFactor `json:"stateBranchFactor"`
+	// Rules are the hypersdk chain rules. The factory fills in the network
+	// and chain IDs when the VM is instantiated.
+	Rules *hgenesis.Rules `json:"initialRules"`
+}
+
+// Allocation credits [Balance] to a bech32 [Address] at genesis.
+type Allocation struct {
+	Address string `json:"address"`
+	Balance uint64 `json:"balance"`
+}
+
+// NewGenesis returns a genesis with [allocations], no markets and the default
+// hypersdk rules.
+func NewGenesis(allocations []Allocation) *Genesis {
+	return &Genesis{
+		Allocations:       allocations,
+		StateBranchFactor: merkledb.BranchFactor16,
+		Rules:             hgenesis.NewDefaultRules(),
+	}
 }
 
 func (g *Genesis) Load(raw []byte) error {
@@ -50,51 +73,80 @@ func (g *Genesis) GetTimestamp() int64 {
 	return g.Timestamp
 }
 
+func (g *Genesis) GetStateBranchFactor() merkledb.BranchFactor {
+	return g.StateBranchFactor
+}
+
+// RuleFactory returns the rule factory of the chain [g] starts.
+func (g *Genesis) RuleFactory() chain.RuleFactory {
+	return &RuleFactory{Rules: &Rules{Rules: g.Rules, Params: &g.Params}}
+}
+
 func (g *Genesis) InitializeState(ctx context.Context, tracer trace.Tracer, mu state.Mutable, bh chain.BalanceHandler) error {
 	for _, alloc := range g.Allocations {
-		hrp, data5bit, err := bech32.Decode(alloc.Address)
-		if err != nil {
-			return fmt.Errorf("failed to decode bech32 address %s: %w", alloc.Address, err)
-		}
-		_ = hrp // predictionvm allows any HRP for now, or check specific like "morpheus", "prediction"
-
-		data8bit, err := bech32.ConvertBits(data5bit, 5, 8, false)
+		addr, err := ParseAddress(alloc.Address)
 		if err != nil {
-			return fmt.Errorf("failed to convert bech32 data bits for address %s: %w", alloc.Address, err)
-		}
-
-		var addr codec.Address
-		if len(data8bit) > codec.AddressLen {
-			return fmt.Errorf("decoded address %s is too long: got %d bytes, expected max %d", alloc.Address, len(data8bit), codec.AddressLen)
+			return err
 		}
-		copy(addr[:], data8bit)
 		if err := bh.AddBalance(ctx, addr, mu, alloc.Balance); err != nil { // Using AddBalance from BalanceHandler, removed createAccount argument
 			return err
 		}
 	}
-	// TODO: Initialize markets from g.Custom.Markets
-	return nil
+	// Markets are seeded last so that their creators' allocations can fund
+	// their initial liquidity.
+	return g.seedMarkets(ctx, mu)
 }
 
-func GetDefault() *Genesis {
-	return &Genesis{
-		Magic:     12345,
-		Timestamp: time.Now().Unix(),
-		Custom: CustomGenesisState{
-			Markets: []struct {
-				ID              uint64 `json:"id"`
-				Question        string `json:"question"`
-				ClosingTime     int64  `json:"closingTime"`
-				CollateralAssetID string `json:"collateralAssetId"`
-			}{
-				{ID: 1, Question: "Will X happen by Y date?", ClosingTime: time.Now().Add(24 * time.Hour).Unix(), CollateralAssetID: "AVAX"},
-			},
-		},
-		Allocations: []struct {
-			Address string `json:"address"`
-			Balance uint64 `json:"balance"`
-		}{
-			// Add default allocations if needed
+// ParseAddress decodes a bech32 genesis address.
+func ParseAddress(s string) (codec.Address, error) {
+	hrp, data5bit, err := bech32.Decode(s)
+	if err != nil {
+		return codec.EmptyAddress, fmt.Errorf("failed to decode bech32 address %s: %w", s, err)
+	}
+	_ = hrp // predictionvm allows any HRP for now, or check specific like "morpheus", "prediction"
+
+	data8bit, err := bech32.ConvertBits(data5bit, 5, 8, false)
+	if err != nil {
+		return codec.EmptyAddress, fmt.Errorf("failed to convert bech32 data bits for address %s: %w", s, err)
+	}
+
+	var addr codec.Address
+	if len(data8bit) > codec.AddressLen {
+		return codec.EmptyAddress, fmt.Errorf("decoded address %s is too long: got %d bytes, expected max %d", s, len(data8bit), codec.AddressLen)
+	}
+	copy(addr[:], data8bit)
+	return addr, nil
+}
+
+// FormatAddress encodes [addr] as a bech32 genesis address.
+func FormatAddress(addr codec.Address) string {
+	data5bit, err := bech32.ConvertBits(addr[:], 8, 5, true)
+	if err != nil {
+		panic(fmt.Errorf("failed to convert address bits: %w", err))
+	}
+	s, err := bech32.Encode(consts.HRP, data5bit)
+	if err != nil {
+		panic(fmt.Errorf("failed to encode bech32 address: %w", err))
+	}
+	return s
+}
+
+// GetDefault returns a genesis seeding one manual market, which [creator]
+// creates and resolves.
+func GetDefault(creator codec.Address) *Genesis {
+	g := NewGenesis(nil)
+	g.Magic = 12345
+	g.Timestamp = time.Now().Unix()
+	g.Custom.Markets = []GenesisMarket{
+		{
+			ID:                1,
+			Question:          "Will X happen by Y date?",
+			ClosingTime:       time.Now().Add(24 * time.Hour).UnixMilli(),
+			ResolutionTime:    time.Now().Add(48 * time.Hour).UnixMilli(),
+			CollateralAssetID: consts.Symbol,
+			Creator:           FormatAddress(creator),
+			OracleType:        consts.OracleTypeManual,
 		},
 	}
+	return g
 }
@@ -0,0 +1,222 @@
+package genesis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ava-labs/hypersdk/chain"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/ava-labs/hypersdk/state"
+
+	"github.com/chokosabe/predictionvm/actions"
+	"github.com/chokosabe/predictionvm/consts"
+	"github.com/chokosabe/predictionvm/storage"
+)
+
+var (
+	ErrInvalidGenesisMarket   = errors.New("invalid genesis market")
+	ErrDuplicateGenesisMarket = errors.New("duplicate genesis market ID")
+)
+
+// GenesisMarket is a market that exists from the first block. Times are Unix
+// milliseconds, like every other market time.
+type GenesisMarket struct {
+	ID                uint64 `json:"id"`
+	Question          string `json:"question"`
+	ClosingTime       int64  `json:"closingTime"`       // End of trading
+	ResolutionTime    int64  `json:"resolutionTime"`    // Earliest time the market can be resolved
+	CollateralAssetID string `json:"collateralAssetId"` // Must be empty or consts.Symbol; markets only hold the native asset
+	// Creator is the bech32 address that funds InitialLiquidity and, unless
+	// Resolver is set, resolves a manual market.
+	Creator    string `json:"creator"`
+	OracleType uint8  `json:"oracleType"`
+	// Resolver is the bech32 address that resolves a manual market in place
+	// of Creator.
+	Resolver string `json:"resolver,omitempty"`
+	// PriceFeed configures the oracle of a price feed market.
+	PriceFeed *storage.PriceFeedParameters `json:"priceFeed,omitempty"`
+	// InitialLiquidity is moved from Creator's allocation into the market's
+	// collateral. Creator receives as many YES and NO shares.
+	InitialLiquidity uint64 `json:"initialLiquidity"`
+}
+
+// Market validates [gm] as CreateMarket would under [rules] at [createdAt],
+// and returns the market it seeds, without its initial liquidity.
+func (gm *GenesisMarket) Market(rules chain.Rules, createdAt int64) (*storage.Market, error) {
+	var (
+		maxQuestion = actions.MaxDescriptionSize(rules)
+		minDuration = actions.MinMarketDuration(rules)
+		maxDuration = actions.MaxMarketDuration(rules)
+		duration    = gm.ClosingTime - createdAt
+	)
+	switch {
+	case len(gm.Question) == 0:
+		return nil, fmt.Errorf("%w %d: question is empty", ErrInvalidGenesisMarket, gm.ID)
+	case uint64(len(gm.Question)) > maxQuestion:
+		return nil, fmt.Errorf("%w %d: question is longer than %d bytes", ErrInvalidGenesisMarket, gm.ID, maxQuestion)
+	case gm.CollateralAssetID != "" && gm.CollateralAssetID != consts.Symbol:
+		return nil, fmt.Errorf("%w %d: unsupported collateral asset %q", ErrInvalidGenesisMarket, gm.ID, gm.CollateralAssetID)
+	case gm.ClosingTime <= createdAt:
+		return nil, fmt.Errorf("%w %d: closing time %d is not after genesis time %d", ErrInvalidGenesisMarket, gm.ID, gm.ClosingTime, createdAt)
+	case duration < minDuration:
+		return nil, fmt.Errorf("%w %d: %w: %d < %d", ErrInvalidGenesisMarket, gm.ID, actions.ErrMarketDurationTooShort, duration, minDuration)
+	case maxDuration > 0 && duration > maxDuration:
+		return nil, fmt.Errorf("%w %d: %w: %d > %d", ErrInvalidGenesisMarket, gm.ID, actions.ErrMarketDurationTooLong, duration, maxDuration)
+	case gm.ResolutionTime <= gm.ClosingTime:
+		return nil, fmt.Errorf("%w %d: resolution time %d is not after closing time %d", ErrInvalidGenesisMarket, gm.ID, gm.ResolutionTime, gm.ClosingTime)
+	}
+
+	var creator codec.Address
+	if gm.Creator != "" {
+		addr, err := ParseAddress(gm.Creator)
+		if err != nil {
+			return nil, fmt.Errorf("%w %d: creator: %w", ErrInvalidGenesisMarket, gm.ID, err)
+		}
+		creator = addr
+	} else if gm.InitialLiquidity > 0 {
+		return nil, fmt.Errorf("%w %d: initial liquidity requires a creator", ErrInvalidGenesisMarket, gm.ID)
+	}
+
+	market := &storage.Market{
+		ID:              gm.ID,
+		Description:     gm.Question,
+		Creator:         creator,
+		EndTime:         gm.ClosingTime,
+		ResolutionTime:  gm.ResolutionTime,
+		Status:          storage.MarketStatus_Open,
+		OracleType:      gm.OracleType,
+		ResolvedOutcome: storage.Outcome_Pending,
+	}
+	switch gm.OracleType {
+	case consts.OracleTypeManual:
+		if gm.PriceFeed != nil {
+			return nil, fmt.Errorf("%w %d: manual markets take no price feed", ErrInvalidGenesisMarket, gm.ID)
+		}
+		manual := &storage.ManualParameters{}
+		if gm.Resolver != "" {
+			addr, err := ParseAddress(gm.Resolver)
+			if err != nil {
+				return nil, fmt.Errorf("%w %d: resolver: %w", ErrInvalidGenesisMarket, gm.ID, err)
+			}
+			manual.Resolver = addr
+		}
+		market.Oracle = manual.ResolverFor(market)
+		if market.Oracle == codec.EmptyAddress {
+			return nil, fmt.Errorf("%w %d: manual markets need a creator or resolver", ErrInvalidGenesisMarket, gm.ID)
+		}
+		market.OracleParameters = manual.Bytes()
+	case consts.OracleTypePriceFeed:
+		if gm.PriceFeed == nil {
+			return nil, fmt.Errorf("%w %d: price feed markets need a price feed", ErrInvalidGenesisMarket, gm.ID)
+		}
+		if gm.Resolver != "" {
+			return nil, fmt.Errorf("%w %d: price feed markets take no resolver", ErrInvalidGenesisMarket, gm.ID)
+		}
+		if err := gm.PriceFeed.Verify(); err != nil {
+			return nil, fmt.Errorf("%w %d: %w", ErrInvalidGenesisMarket, gm.ID, err)
+		}
+		market.OracleParameters = gm.PriceFeed.Bytes()
+	default:
+		return nil, fmt.Errorf("%w %d: %w: %d", ErrInvalidGenesisMarket, gm.ID, storage.ErrUnknownOracleType, gm.OracleType)
+	}
+	if limit := actions.MaxOracleParametersSize(rules); uint64(len(market.OracleParameters)) > limit {
+		return nil, fmt.Errorf("%w %d: %w: %d bytes exceeds the maximum of %d", ErrInvalidGenesisMarket, gm.ID, actions.ErrOracleParametersTooLong, len(market.OracleParameters), limit)
+	}
+	if err := actions.CheckMarketDataSize(rules, market); err != nil {
+		return nil, fmt.Errorf("%w %d: %w", ErrInvalidGenesisMarket, gm.ID, err)
+	}
+	return market, nil
+}
+
+// marketsCreatedAt returns the time, in milliseconds, the genesis markets are
+// created at. The genesis timestamp is in seconds.
+func (g *Genesis) marketsCreatedAt() int64 {
+	return time.Unix(g.Timestamp, 0).UnixMilli()
+}
+
+// markets validates every genesis market against the protocol parameters,
+// including that their IDs are unique, and returns the markets they seed.
+func (g *Genesis) markets() ([]*storage.Market, error) {
+	var (
+		c         = &g.Custom
+		rules     = g.RuleFactory().GetRules(g.Timestamp)
+		createdAt = g.marketsCreatedAt()
+		markets   = make([]*storage.Market, 0, len(c.Markets))
+		seen      = make(map[uint64]bool, len(c.Markets))
+	)
+	for i := range c.Markets {
+		gm := &c.Markets[i]
+		if seen[gm.ID] {
+			return nil, fmt.Errorf("%w: %d", ErrDuplicateGenesisMarket, gm.ID)
+		}
+		seen[gm.ID] = true
+		market, err := gm.Market(rules, createdAt)
+		if err != nil {
+			return nil, err
+		}
+		markets = append(markets, market)
+	}
+	return markets, nil
+}
+
+// seedMarkets stores the genesis markets and moves their initial liquidity
+// from their creators' allocations, which must already be credited.
+func (g *Genesis) seedMarkets(ctx context.Context, mu state.Mutable) error {
+	markets, err := g.markets()
+	if err != nil {
+		return err
+	}
+	for i, market := range markets {
+		if liquidity := g.Custom.Markets[i].InitialLiquidity; liquidity > 0 {
+			if err := storage.DeductBalance(ctx, mu, market.Creator, liquidity); err != nil {
+				return fmt.Errorf("failed to fund genesis market %d: %w", market.ID, err)
+			}
+			for _, shareType := range []uint8{consts.YesShareType, consts.NoShareType} {
+				if err := storage.SetShareBalance(ctx, mu, market.ID, market.Creator, shareType, liquidity); err != nil {
+					return fmt.Errorf("failed to credit liquidity shares of genesis market %d: %w", market.ID, err)
+				}
+			}
+			if err := storage.AddPaidCollateral(ctx, mu, market.ID, market.Creator, liquidity); err != nil {
+				return fmt.Errorf("failed to record liquidity of genesis market %d: %w", market.ID, err)
+			}
+			fund(market, liquidity)
+		}
+		if err := storage.SetMarket(ctx, mu, market); err != nil {
+			return fmt.Errorf("failed to store genesis market %d: %w", market.ID, err)
+		}
+		if err := storage.AddToIndex(ctx, mu, storage.PendingResolutionIndex, market.ID); err != nil {
+			return fmt.Errorf("failed to index genesis market %d: %w", market.ID, err)
+		}
+	}
+	return nil
+}
+
+// IndexKeys returns the index entries seedMarkets stores, so that nodes can
+// list the genesis markets without iterating the state.
+func (g *Genesis) IndexKeys() ([][]byte, error) {
+	markets, err := g.markets()
+	if err != nil {
+		return nil, err
+	}
+	var keys [][]byte
+	for i, market := range markets {
+		keys = append(keys, storage.MarketIndexKeys(market)...)
+		keys = append(keys, storage.IndexKey(storage.PendingResolutionIndex, market.ID))
+		if g.Custom.Markets[i].InitialLiquidity > 0 {
+			for _, shareType := range []uint8{consts.YesShareType, consts.NoShareType} {
+				keys = append(keys, storage.PositionIndexKey(market.Creator, shareType, market.ID))
+			}
+		}
+	}
+	return keys, nil
+}
+
+// fund records [liquidity] as the collateral of [market], backing as many YES
+// and NO shares.
+func fund(market *storage.Market, liquidity uint64) {
+	market.Collateral = liquidity
+	market.TotalYesShares = liquidity
+	market.TotalNoShares = liquidity
+}
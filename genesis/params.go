@@ -0,0 +1,189 @@
+package genesis
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ava-labs/hypersdk/chain"
+	"github.com/ava-labs/hypersdk/codec"
+	hgenesis "github.com/ava-labs/hypersdk/genesis"
+
+	"github.com/chokosabe/predictionvm/consts"
+	"github.com/chokosabe/predictionvm/storage"
+)
+
+var (
+	ErrInvalidProtocolParams = errors.New("invalid protocol parameters")
+
+	_ chain.Rules       = (*Rules)(nil)
+	_ chain.RuleFactory = (*RuleFactory)(nil)
+)
+
+// ProtocolParams are the chain-wide market parameters. Actions read them
+// through chain.Rules.FetchCustom under the keys in consts. Zero fields use
+// the defaults in consts. Times are in milliseconds.
+type ProtocolParams struct {
+	// CreateMarketComputeUnits sets the fee of CreateMarket.
+	CreateMarketComputeUnits uint64 `json:"createMarketComputeUnits"`
+
+	// FeedCapacity is the number of observations each price feed keeps.
+	FeedCapacity uint64 `json:"feedCapacity"`
+	// FeedPublishers are the bech32 addresses allowed to publish prices. When
+	// empty, every address may publish to its own feeds.
+	FeedPublishers []string `json:"feedPublishers,omitempty"`
+
+	// TradingFeeRate and ClaimFeeRate are in basis points, at most
+	// consts.MaxFeeRate.
+	TradingFeeRate uint64 `json:"tradingFeeRate"`
+	ClaimFeeRate   uint64 `json:"claimFeeRate"`
+
+	DisputeBond  uint64 `json:"disputeBond"`
+	AppealBond   uint64 `json:"appealBond"`
+	SweepDeposit uint64 `json:"sweepDeposit"`
+
+	MinMarketDuration     int64 `json:"minMarketDuration"`
+	MaxMarketDuration     int64 `json:"maxMarketDuration"`
+	ResolutionGracePeriod int64 `json:"resolutionGracePeriod"`
+	DisputeWindow         int64 `json:"disputeWindow"`
+	AppealVotingPeriod    int64 `json:"appealVotingPeriod"`
+
+	// The size limits may only be lowered: markets are stored with room for
+	// the defaults and no more.
+	MaxDescriptionSize      uint64 `json:"maxDescriptionSize"`
+	MaxOracleSourceSize     uint64 `json:"maxOracleSourceSize"`
+	MaxOracleParametersSize uint64 `json:"maxOracleParametersSize"`
+	MaxMarketDataSize       uint64 `json:"maxMarketDataSize"`
+}
+
+// Verify returns ErrInvalidProtocolParams if [p] lists a feed publisher that
+// is not a valid address, sets a fee rate above
+// consts.MaxFeeRate, a negative time, a minimum market duration above its
+// maximum, a resolution grace period above consts.MaxResolutionGracePeriod or
+// a size limit above what a stored market has room for.
+func (p *ProtocolParams) Verify() error {
+	for _, publisher := range p.FeedPublishers {
+		if _, err := ParseAddress(publisher); err != nil {
+			return fmt.Errorf("%w: feed publisher: %w", ErrInvalidProtocolParams, err)
+		}
+	}
+	for _, rate := range []struct {
+		name  string
+		value uint64
+	}{
+		{"tradingFeeRate", p.TradingFeeRate},
+		{"claimFeeRate", p.ClaimFeeRate},
+	} {
+		if rate.value > consts.MaxFeeRate {
+			return fmt.Errorf("%w: %s %d exceeds %d", ErrInvalidProtocolParams, rate.name, rate.value, consts.MaxFeeRate)
+		}
+	}
+	for _, period := range []struct {
+		name  string
+		value int64
+	}{
+		{"minMarketDuration", p.MinMarketDuration},
+		{"maxMarketDuration", p.MaxMarketDuration},
+		{"resolutionGracePeriod", p.ResolutionGracePeriod},
+		{"disputeWindow", p.DisputeWindow},
+		{"appealVotingPeriod", p.AppealVotingPeriod},
+	} {
+		if period.value < 0 {
+			return fmt.Errorf("%w: %s %d is negative", ErrInvalidProtocolParams, period.name, period.value)
+		}
+	}
+	if p.MaxMarketDuration > 0 && p.MinMarketDuration > p.MaxMarketDuration {
+		return fmt.Errorf("%w: minMarketDuration %d exceeds maxMarketDuration %d", ErrInvalidProtocolParams, p.MinMarketDuration, p.MaxMarketDuration)
+	}
+	if p.ResolutionGracePeriod > consts.MaxResolutionGracePeriod {
+		return fmt.Errorf("%w: resolutionGracePeriod %d exceeds %d", ErrInvalidProtocolParams, p.ResolutionGracePeriod, consts.MaxResolutionGracePeriod)
+	}
+	for _, limit := range []struct {
+		name  string
+		value uint64
+		max   uint64
+	}{
+		{"maxDescriptionSize", p.MaxDescriptionSize, consts.MaxDescriptionSize},
+		{"maxOracleSourceSize", p.MaxOracleSourceSize, consts.MaxOracleSourceSize},
+		{"maxOracleParametersSize", p.MaxOracleParametersSize, storage.MaxOracleParametersSize},
+		{"maxMarketDataSize", p.MaxMarketDataSize, consts.MaxMarketDataSize},
+	} {
+		if limit.value > limit.max {
+			return fmt.Errorf("%w: %s %d exceeds %d", ErrInvalidProtocolParams, limit.name, limit.value, limit.max)
+		}
+	}
+	return nil
+}
+
+// Fetch returns the parameter stored under custom rule [key].
+func (p *ProtocolParams) Fetch(key string) (any, bool) {
+	switch key {
+	case consts.FeedCapacityKey:
+		return p.FeedCapacity, true
+	case consts.FeedPublishersKey:
+		return p.feedPublishers(), true
+	case consts.CreateMarketComputeUnitsKey:
+		return p.CreateMarketComputeUnits, true
+	case consts.TradingFeeRateKey:
+		return p.TradingFeeRate, true
+	case consts.ClaimFeeRateKey:
+		return p.ClaimFeeRate, true
+	case consts.DisputeBondKey:
+		return p.DisputeBond, true
+	case consts.AppealBondKey:
+		return p.AppealBond, true
+	case consts.SweepDepositKey:
+		return p.SweepDeposit, true
+	case consts.MinMarketDurationKey:
+		return p.MinMarketDuration, true
+	case consts.MaxMarketDurationKey:
+		return p.MaxMarketDuration, true
+	case consts.ResolutionGracePeriodKey:
+		return p.ResolutionGracePeriod, true
+	case consts.DisputeWindowKey:
+		return p.DisputeWindow, true
+	case consts.AppealVotingPeriodKey:
+		return p.AppealVotingPeriod, true
+	case consts.MaxDescriptionSizeKey:
+		return p.MaxDescriptionSize, true
+	case consts.MaxOracleSourceSizeKey:
+		return p.MaxOracleSourceSize, true
+	case consts.MaxOracleParametersSizeKey:
+		return p.MaxOracleParametersSize, true
+	case consts.MaxMarketDataSizeKey:
+		return p.MaxMarketDataSize, true
+	default:
+		return nil, false
+	}
+}
+
+// feedPublishers parses FeedPublishers, skipping the invalid addresses Verify
+// rejects.
+func (p *ProtocolParams) feedPublishers() []codec.Address {
+	publishers := make([]codec.Address, 0, len(p.FeedPublishers))
+	for _, publisher := range p.FeedPublishers {
+		if addr, err := ParseAddress(publisher); err == nil {
+			publishers = append(publishers, addr)
+		}
+	}
+	return publishers
+}
+
+// Rules are the hypersdk rules of a chain with its protocol parameters
+// exposed through FetchCustom.
+type Rules struct {
+	*hgenesis.Rules
+	Params *ProtocolParams
+}
+
+func (r *Rules) FetchCustom(key string) (any, bool) {
+	return r.Params.Fetch(key)
+}
+
+// RuleFactory returns the same Rules at every timestamp.
+type RuleFactory struct {
+	Rules *Rules
+}
+
+func (f *RuleFactory) GetRules(int64) chain.Rules {
+	return f.Rules
+}
@@ -0,0 +1,79 @@
+package genesis
+
+import (
+	"errors"
+	"fmt"
+
+	safemath "github.com/ava-labs/avalanchego/utils/math"
+	"github.com/ava-labs/hypersdk/codec"
+	hgenesis "github.com/ava-labs/hypersdk/genesis"
+
+	"github.com/chokosabe/predictionvm/storage"
+)
+
+var (
+	ErrInvalidAllocation = errors.New("invalid genesis allocation")
+	ErrSupplyOverflow    = errors.New("genesis supply overflows")
+)
+
+// Summary describes a genesis that passed Verify.
+type Summary struct {
+	Accounts int    // Distinct allocated addresses
+	Supply   uint64 // Sum of the allocations
+	// Markets are the seeded markets, with their initial liquidity.
+	Markets []*storage.Market
+	Params  ProtocolParams
+	Rules   *hgenesis.Rules
+}
+
+// Verify checks everything InitializeState and the factory would reject, so
+// that a bad genesis is caught before a node starts with it. Allocations are
+// decoded as InitializeState decodes them and must not overflow the supply,
+// markets must be valid under the protocol parameters and funded by their
+// creators' allocations, and the protocol parameters must be valid.
+func (g *Genesis) Verify() (*Summary, error) {
+	var (
+		balances = make(map[codec.Address]uint64, len(g.Allocations))
+		supply   uint64
+	)
+	for i, alloc := range g.Allocations {
+		addr, err := ParseAddress(alloc.Address)
+		if err != nil {
+			return nil, fmt.Errorf("%w %d: %w", ErrInvalidAllocation, i, err)
+		}
+		supply, err = safemath.Add(supply, alloc.Balance)
+		if err != nil {
+			return nil, fmt.Errorf("%w: allocation %d of %d to %s", ErrSupplyOverflow, i, alloc.Balance, alloc.Address)
+		}
+		// Cannot overflow as the supply did not.
+		balances[addr] += alloc.Balance
+	}
+
+	markets, err := g.markets()
+	if err != nil {
+		return nil, err
+	}
+	for i, market := range markets {
+		liquidity := g.Custom.Markets[i].InitialLiquidity
+		if balance := balances[market.Creator]; liquidity > balance {
+			return nil, fmt.Errorf("%w %d: initial liquidity %d exceeds the %d left to creator %s", ErrInvalidGenesisMarket, market.ID, liquidity, balance, g.Custom.Markets[i].Creator)
+		}
+		balances[market.Creator] -= liquidity
+		fund(market, liquidity)
+	}
+
+	if err := g.Params.Verify(); err != nil {
+		return nil, err
+	}
+	rules := g.Rules
+	if rules == nil {
+		rules = hgenesis.NewDefaultRules()
+	}
+	return &Summary{
+		Accounts: len(balances),
+		Supply:   supply,
+		Markets:  markets,
+		Params:   g.Params,
+		Rules:    rules,
+	}, nil
+}
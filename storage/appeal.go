@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/ava-labs/avalanchego/database"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/ava-labs/hypersdk/state"
+)
+
+const (
+	// MaxAppealDataSize defines the maximum size of a marshaled appeal.
+	MaxAppealDataSize = 128
+
+	// AppealChunks is the number of 64-byte chunks reserved for an appeal.
+	AppealChunks uint16 = MaxAppealDataSize/64 + 1
+
+	// AppealVoteChunks is the number of 64-byte chunks reserved for a vote.
+	AppealVoteChunks uint16 = 1
+)
+
+// Appeal escalates the stakers' ruling on a dispute to a second vote of the
+// PRED stakers. If more stake votes to overturn the ruling than to uphold it,
+// the market resolves to [ProposedOutcome]. [Bond] is held in escrow until the
+// appeal is finalized, then paid out according to [Status].
+type Appeal struct {
+	MarketID        uint64        `serialize:"true" json:"marketId"`
+	Appellant       codec.Address `serialize:"true" json:"appellant"`
+	Bond            uint64        `serialize:"true" json:"bond"`
+	AppealedOutcome OutcomeType   `serialize:"true" json:"appealedOutcome"` // The ruling being challenged
+	ProposedOutcome OutcomeType   `serialize:"true" json:"proposedOutcome"`
+	VotingEndsAt    int64         `serialize:"true" json:"votingEndsAt"`
+	OverturnVotes   uint64        `serialize:"true" json:"overturnVotes"` // Stake voting for [ProposedOutcome]
+	UpholdVotes     uint64        `serialize:"true" json:"upholdVotes"`   // Stake voting for [AppealedOutcome]
+	Status          DisputeStatus `serialize:"true" json:"status"`
+}
+
+// AppealKey generates the state key for the appeal of a market.
+// Format: AppealPrefix | MarketID (uint64) | Chunks (uint16)
+func AppealKey(marketID uint64) []byte {
+	key := make([]byte, 1+8+2)
+	key[0] = AppealPrefix
+	binary.BigEndian.PutUint64(key[1:], marketID)
+	binary.BigEndian.PutUint16(key[1+8:], AppealChunks)
+	return key
+}
+
+// GetAppeal retrieves the appeal of a market.
+func GetAppeal(ctx context.Context, im state.Immutable, marketID uint64) (*Appeal, error) {
+	valBytes, err := im.GetValue(ctx, AppealKey(marketID))
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return nil, fmt.Errorf("appeal of market %d not found: %w", marketID, err)
+		}
+		return nil, err
+	}
+	reader := codec.NewReader(valBytes, MaxAppealDataSize)
+	appeal := &Appeal{}
+	if err := codec.LinearCodec.UnmarshalFrom(reader.Packer, appeal); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal appeal of market %d: %w", marketID, err)
+	}
+	return appeal, nil
+}
+
+// SetAppeal stores the appeal of a market.
+func SetAppeal(ctx context.Context, mu state.Mutable, appeal *Appeal) error {
+	writer := codec.NewWriter(0, MaxAppealDataSize)
+	if err := codec.LinearCodec.MarshalInto(appeal, writer.Packer); err != nil {
+		return fmt.Errorf("failed to marshal appeal of market %d: %w", appeal.MarketID, err)
+	}
+	if err := writer.Err(); err != nil {
+		return fmt.Errorf("writer error after marshaling appeal of market %d: %w", appeal.MarketID, err)
+	}
+	return mu.Insert(ctx, AppealKey(appeal.MarketID), writer.Bytes())
+}
+
+// AppealVoteKey generates the state key recording a voter's vote on the
+// dispute (Tier_Dispute) or appeal (Tier_Appeal) of a market.
+// Format: AppealVotePrefix | MarketID (uint64) | Tier (uint8) | Voter (codec.Address) | Chunks (uint16)
+func AppealVoteKey(marketID uint64, tier uint8, voter codec.Address) []byte {
+	key := make([]byte, 1+8+1+codec.AddressLen+2)
+	key[0] = AppealVotePrefix
+	binary.BigEndian.PutUint64(key[1:], marketID)
+	key[1+8] = tier
+	copy(key[1+8+1:], voter[:])
+	binary.BigEndian.PutUint16(key[1+8+1+codec.AddressLen:], AppealVoteChunks)
+	return key
+}
+
+// HasVoted reports whether [voter] already voted at [tier] on a market.
+func HasVoted(ctx context.Context, im state.Immutable, marketID uint64, tier uint8, voter codec.Address) (bool, error) {
+	_, err := im.GetValue(ctx, AppealVoteKey(marketID, tier, voter))
+	if errors.Is(err, database.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// SetVote records a voter's vote at [tier] on a market.
+func SetVote(ctx context.Context, mu state.Mutable, marketID uint64, tier uint8, voter codec.Address, overturn bool) error {
+	value := []byte{0}
+	if overturn {
+		value[0] = 1
+	}
+	return mu.Insert(ctx, AppealVoteKey(marketID, tier, voter), value)
+}
+
+// RemoveVote deletes the record of a voter's vote at [tier] on a market.
+func RemoveVote(ctx context.Context, mu state.Mutable, marketID uint64, tier uint8, voter codec.Address) error {
+	return mu.Remove(ctx, AppealVoteKey(marketID, tier, voter))
+}
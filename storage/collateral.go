@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/ava-labs/avalanchego/database"
+	safemath "github.com/ava-labs/avalanchego/utils/math"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/ava-labs/hypersdk/state"
+)
+
+// PaidCollateralChunks is the number of 64-byte chunks reserved for a paid
+// collateral record.
+const PaidCollateralChunks uint16 = 1
+
+// PaidCollateralKey generates the state key for the collateral [user] paid
+// into market [marketID]. A market refunds each holder from this record, and
+// it is the cost basis of the holder's shares.
+// Format: PaidCollateralPrefix | MarketID (uint64) | UserAddress (codec.Address) | Chunks (uint16)
+func PaidCollateralKey(marketID uint64, user codec.Address) []byte {
+	key := make([]byte, 1+8+codec.AddressLen+2)
+	key[0] = PaidCollateralPrefix
+	binary.BigEndian.PutUint64(key[1:], marketID)
+	copy(key[1+8:], user[:])
+	binary.BigEndian.PutUint16(key[1+8+codec.AddressLen:], PaidCollateralChunks)
+	return key
+}
+
+// GetPaidCollateral retrieves the collateral [user] paid into market
+// [marketID], which is zero if there is no record.
+func GetPaidCollateral(ctx context.Context, im state.Immutable, marketID uint64, user codec.Address) (uint64, error) {
+	valBytes, err := im.GetValue(ctx, PaidCollateralKey(marketID, user))
+	return innerGetPaidCollateral(valBytes, err, marketID, user)
+}
+
+// GetPaidCollateralFromState retrieves a paid collateral record through [f].
+// Used to serve RPC queries.
+func GetPaidCollateralFromState(ctx context.Context, f ReadState, marketID uint64, user codec.Address) (uint64, error) {
+	values, errs := f(ctx, [][]byte{PaidCollateralKey(marketID, user)})
+	return innerGetPaidCollateral(values[0], errs[0], marketID, user)
+}
+
+func innerGetPaidCollateral(valBytes []byte, err error, marketID uint64, user codec.Address) (uint64, error) {
+	if errors.Is(err, database.ErrNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	reader := codec.NewReader(valBytes, len(valBytes))
+	amount := reader.UnpackUint64(true)
+	if err := reader.Err(); err != nil {
+		return 0, fmt.Errorf("failed to unpack collateral paid by %s into market %d: %w", user, marketID, err)
+	}
+	return amount, nil
+}
+
+// AddPaidCollateral records that [user] paid [amount] more collateral into
+// market [marketID].
+func AddPaidCollateral(ctx context.Context, mu state.Mutable, marketID uint64, user codec.Address, amount uint64) error {
+	paid, err := GetPaidCollateral(ctx, mu, marketID, user)
+	if err != nil {
+		return err
+	}
+	paid, err = safemath.Add(paid, amount)
+	if err != nil {
+		return fmt.Errorf("collateral paid by %s into market %d: %w", user, marketID, err)
+	}
+	writer := codec.NewWriter(8, 8)
+	writer.PackUint64(paid)
+	if err := writer.Err(); err != nil {
+		return fmt.Errorf("failed to pack collateral paid by %s into market %d: %w", user, marketID, err)
+	}
+	return mu.Insert(ctx, PaidCollateralKey(marketID, user), writer.Bytes())
+}
+
+// RemovePaidCollateral deletes the record of the collateral [user] paid into
+// market [marketID].
+func RemovePaidCollateral(ctx context.Context, mu state.Mutable, marketID uint64, user codec.Address) error {
+	return mu.Remove(ctx, PaidCollateralKey(marketID, user))
+}
@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/ava-labs/avalanchego/database"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/ava-labs/hypersdk/state"
+)
+
+const (
+	// MaxDisputeDataSize defines the maximum size of a marshaled dispute.
+	MaxDisputeDataSize = 128
+
+	// DisputeChunks is the number of 64-byte chunks reserved for a dispute.
+	DisputeChunks uint16 = MaxDisputeDataSize/64 + 1
+)
+
+// Escalation tiers of a market's ruling, recorded in Market.Tier.
+const (
+	Tier_Oracle  uint8 = 0 // Initial ruling of the oracle
+	Tier_Dispute uint8 = 1 // Stakers' vote on a dispute
+	Tier_Appeal  uint8 = 2 // Stakers' vote on an appeal; final
+)
+
+// DisputeStatus defines the possible states of a dispute or appeal.
+type DisputeStatus uint8
+
+const (
+	DisputeStatus_Pending  DisputeStatus = 0 // Awaiting a ruling
+	DisputeStatus_Upheld   DisputeStatus = 1 // The challenged ruling was overturned; the bond goes back to the challenger
+	DisputeStatus_Rejected DisputeStatus = 2 // The challenged ruling stood; the bond is forfeited to the oracle
+)
+
+func (ds DisputeStatus) String() string {
+	switch ds {
+	case DisputeStatus_Pending:
+		return "Pending"
+	case DisputeStatus_Upheld:
+		return "Upheld"
+	case DisputeStatus_Rejected:
+		return "Rejected"
+	default:
+		return fmt.Sprintf("UnknownDisputeStatus:%d", ds)
+	}
+}
+
+// Dispute is a challenge of a market's initial ruling, settled by a vote of
+// the PRED stakers. If more stake votes to overturn the ruling than to uphold
+// it, the market resolves to [ProposedOutcome]. [Bond] is held in escrow until
+// the market is final, then paid out according to [Status].
+type Dispute struct {
+	MarketID        uint64        `serialize:"true" json:"marketId"`
+	Disputer        codec.Address `serialize:"true" json:"disputer"`
+	Bond            uint64        `serialize:"true" json:"bond"`
+	DisputedOutcome OutcomeType   `serialize:"true" json:"disputedOutcome"` // The ruling being challenged
+	ProposedOutcome OutcomeType   `serialize:"true" json:"proposedOutcome"`
+	CreatedAt       int64         `serialize:"true" json:"createdAt"`
+	VotingEndsAt    int64         `serialize:"true" json:"votingEndsAt"`
+	OverturnVotes   uint64        `serialize:"true" json:"overturnVotes"` // Stake voting for [ProposedOutcome]
+	UpholdVotes     uint64        `serialize:"true" json:"upholdVotes"`   // Stake voting for [DisputedOutcome]
+	Status          DisputeStatus `serialize:"true" json:"status"`
+}
+
+// DisputeKey generates the state key for the dispute of a market.
+// Format: DisputePrefix | MarketID (uint64) | Chunks (uint16)
+func DisputeKey(marketID uint64) []byte {
+	key := make([]byte, 1+8+2)
+	key[0] = DisputePrefix
+	binary.BigEndian.PutUint64(key[1:], marketID)
+	binary.BigEndian.PutUint16(key[1+8:], DisputeChunks)
+	return key
+}
+
+// GetDispute retrieves the dispute of a market.
+func GetDispute(ctx context.Context, im state.Immutable, marketID uint64) (*Dispute, error) {
+	valBytes, err := im.GetValue(ctx, DisputeKey(marketID))
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return nil, fmt.Errorf("dispute of market %d not found: %w", marketID, err)
+		}
+		return nil, err
+	}
+	reader := codec.NewReader(valBytes, MaxDisputeDataSize)
+	dispute := &Dispute{}
+	if err := codec.LinearCodec.UnmarshalFrom(reader.Packer, dispute); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal dispute of market %d: %w", marketID, err)
+	}
+	return dispute, nil
+}
+
+// SetDispute stores the dispute of a market.
+func SetDispute(ctx context.Context, mu state.Mutable, dispute *Dispute) error {
+	writer := codec.NewWriter(0, MaxDisputeDataSize)
+	if err := codec.LinearCodec.MarshalInto(dispute, writer.Packer); err != nil {
+		return fmt.Errorf("failed to marshal dispute of market %d: %w", dispute.MarketID, err)
+	}
+	if err := writer.Err(); err != nil {
+		return fmt.Errorf("writer error after marshaling dispute of market %d: %w", dispute.MarketID, err)
+	}
+	return mu.Insert(ctx, DisputeKey(dispute.MarketID), writer.Bytes())
+}
@@ -3,9 +3,108 @@
 
 package storage
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ava-labs/avalanchego/database"
+	"github.com/ava-labs/hypersdk/codec"
+
+	pvmConsts "github.com/chokosabe/predictionvm/consts"
+)
 
 var (
 	ErrInvalidAddress = errors.New("invalid address")
 	ErrInvalidBalance = errors.New("invalid balance")
+
+	// The errors below are matched with errors.Is by the typed errors that
+	// carry their details.
+	ErrMarketNotFound      = errors.New("market not found")
+	ErrMarketClosed        = errors.New("market is closed for trading")
+	ErrInsufficientBalance = errors.New("insufficient balance")
+	ErrInsufficientShares  = errors.New("insufficient shares")
+)
+
+// Stable codes of the typed errors, for APIs that report errors to clients.
+const (
+	CodeMarketNotFound      = "market_not_found"
+	CodeMarketClosed        = "market_closed"
+	CodeInsufficientBalance = "insufficient_balance"
+	CodeInsufficientShares  = "insufficient_shares"
 )
+
+// ErrorCode returns the stable code of the typed error in [err]'s chain, or
+// the empty string if there is none.
+func ErrorCode(err error) string {
+	switch {
+	case errors.Is(err, ErrMarketNotFound):
+		return CodeMarketNotFound
+	case errors.Is(err, ErrMarketClosed):
+		return CodeMarketClosed
+	case errors.Is(err, ErrInsufficientBalance):
+		return CodeInsufficientBalance
+	case errors.Is(err, ErrInsufficientShares):
+		return CodeInsufficientShares
+	default:
+		return ""
+	}
+}
+
+// MarketNotFoundError reports that market [MarketID] is not in state. It
+// matches both ErrMarketNotFound and database.ErrNotFound.
+type MarketNotFoundError struct {
+	MarketID uint64
+}
+
+func (e *MarketNotFoundError) Error() string {
+	return fmt.Sprintf("market %d not found", e.MarketID)
+}
+
+func (*MarketNotFoundError) Is(target error) bool { return target == ErrMarketNotFound }
+
+func (*MarketNotFoundError) Unwrap() error { return database.ErrNotFound }
+
+// MarketClosedError reports that market [MarketID] no longer accepts trades
+// at [Time], either because of its [Status] or because [EndTime] has passed.
+type MarketClosedError struct {
+	MarketID uint64
+	Status   MarketStatus
+	EndTime  int64
+	Time     int64
+}
+
+func (e *MarketClosedError) Error() string {
+	return fmt.Sprintf("market %d is closed for trading (status: %s, end: %d, current: %d)", e.MarketID, e.Status, e.EndTime, e.Time)
+}
+
+func (*MarketClosedError) Is(target error) bool { return target == ErrMarketClosed }
+
+// InsufficientBalanceError reports that [Address] holds [Balance] of the
+// [Required] amount.
+type InsufficientBalanceError struct {
+	Address  codec.Address
+	Balance  uint64
+	Required uint64
+}
+
+func (e *InsufficientBalanceError) Error() string {
+	return fmt.Sprintf("insufficient balance: %s has %d, needs %d", e.Address, e.Balance, e.Required)
+}
+
+func (*InsufficientBalanceError) Is(target error) bool { return target == ErrInsufficientBalance }
+
+// InsufficientSharesError reports that [Holder] holds [Balance] of the
+// [Required] shares of [ShareType] in market [MarketID].
+type InsufficientSharesError struct {
+	MarketID  uint64
+	Holder    codec.Address
+	ShareType uint8
+	Balance   uint64
+	Required  uint64
+}
+
+func (e *InsufficientSharesError) Error() string {
+	return fmt.Sprintf("insufficient %s shares: %s has %d in market %d, needs %d", pvmConsts.ShareTypeToString(e.ShareType), e.Holder, e.Balance, e.MarketID, e.Required)
+}
+
+func (*InsufficientSharesError) Is(target error) bool { return target == ErrInsufficientShares }
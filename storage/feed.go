@@ -0,0 +1,295 @@
+package storage
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ava-labs/avalanchego/database"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/ava-labs/hypersdk/state"
+)
+
+const (
+	// MaxFeedDataSize defines the maximum expected size for a marshaled feed
+	// or observation.
+	MaxFeedDataSize = 128
+
+	// FeedChunks is the number of 64-byte chunks reserved for a feed value.
+	FeedChunks uint16 = MaxFeedDataSize/64 + 1
+
+	// FeedObservationChunks is the number of 64-byte chunks reserved for an
+	// observation.
+	FeedObservationChunks uint16 = MaxFeedDataSize/64 + 1
+)
+
+var (
+	ErrFeedEmpty              = errors.New("feed has no observations")
+	ErrWindowNotCovered       = errors.New("feed has no observation at or before the start of the window")
+	ErrObservationNotInEffect = errors.New("observation is not the one in effect at the requested time")
+	ErrInvalidTWAPWindow      = errors.New("twap window end must be after its start")
+	ErrObservationOverwritten = errors.New("observation has been overwritten in the feed's ring buffer")
+)
+
+// PriceObservation is a single price published to a feed. [Sequence] tells it
+// apart from the observations that share its ring buffer slot. [Cumulative] is
+// the feed's price integrated over time (price × milliseconds) from its first
+// observation up to this one, as a big-endian unsigned integer, so that the
+// TWAP over any window follows from the two observations in effect at its
+// ends.
+type PriceObservation struct {
+	Sequence   uint64 `serialize:"true" json:"sequence"`
+	Timestamp  int64  `serialize:"true" json:"timestamp"`
+	Price      uint64 `serialize:"true" json:"price"`
+	Cumulative []byte `serialize:"true" json:"cumulative"`
+}
+
+// CumulativeAt returns the feed's cumulative price at [t], which must not be
+// before the observation or after the next one.
+func (o *PriceObservation) CumulativeAt(t int64) *big.Int {
+	cumulative := new(big.Int).SetBytes(o.Cumulative)
+	elapsed := new(big.Int).Mul(new(big.Int).SetUint64(o.Price), big.NewInt(t-o.Timestamp))
+	return cumulative.Add(cumulative, elapsed)
+}
+
+// Feed is an on-chain price feed. Feeds are namespaced by their publisher, so
+// only [Publisher] can ever write to feed [ID] under its address. Observations
+// are kept in a ring buffer of the chain's feed capacity: observation [seq] is
+// stored in slot seq mod capacity (see FeedSlot), overwriting the one
+// published capacity observations before it. The feed records how many
+// observations it has and the latest one.
+type Feed struct {
+	ID        uint64           `serialize:"true" json:"id"`
+	Publisher codec.Address    `serialize:"true" json:"publisher"`
+	Count     uint64           `serialize:"true" json:"count"`
+	Latest    PriceObservation `serialize:"true" json:"latest"`
+}
+
+// LatestObservation returns the most recent observation of the feed.
+func (f *Feed) LatestObservation() (*PriceObservation, bool) {
+	if f.Count == 0 {
+		return nil, false
+	}
+	return &f.Latest, true
+}
+
+// Observe records a [price] published at [timestamp] as the feed's latest
+// observation and returns it with its sequence number. Callers check that
+// [timestamp] is after the latest observation.
+func (f *Feed) Observe(timestamp int64, price uint64) (uint64, *PriceObservation) {
+	seq := f.Count
+	obs := PriceObservation{Sequence: seq, Timestamp: timestamp, Price: price}
+	if latest, ok := f.LatestObservation(); ok {
+		obs.Cumulative = latest.CumulativeAt(timestamp).Bytes()
+	}
+	f.Count++
+	f.Latest = obs
+	return seq, &obs
+}
+
+// FeedKey generates the state key for feed [feedID] of [publisher].
+// Format: FeedPrefix | Publisher (Address) | FeedID (uint64) | Chunks (uint16)
+func FeedKey(publisher codec.Address, feedID uint64) []byte {
+	key := make([]byte, 1+codec.AddressLen+8+2)
+	key[0] = FeedPrefix
+	copy(key[1:], publisher[:])
+	binary.BigEndian.PutUint64(key[1+codec.AddressLen:], feedID)
+	binary.BigEndian.PutUint16(key[1+codec.AddressLen+8:], FeedChunks)
+	return key
+}
+
+// FeedSlot returns the ring buffer slot of observation [seq] in a feed of
+// [capacity] observations. A zero capacity keeps every observation.
+func FeedSlot(seq uint64, capacity uint64) uint64 {
+	if capacity == 0 {
+		return seq
+	}
+	return seq % capacity
+}
+
+// FeedObservationKey generates the state key of slot [slot] of the ring buffer
+// of feed [feedID] of [publisher].
+// Format: FeedObservationPrefix | Publisher (Address) | FeedID (uint64) | Slot (uint64) | Chunks (uint16)
+func FeedObservationKey(publisher codec.Address, feedID uint64, slot uint64) []byte {
+	key := make([]byte, 1+codec.AddressLen+8+8+2)
+	key[0] = FeedObservationPrefix
+	copy(key[1:], publisher[:])
+	binary.BigEndian.PutUint64(key[1+codec.AddressLen:], feedID)
+	binary.BigEndian.PutUint64(key[1+codec.AddressLen+8:], slot)
+	binary.BigEndian.PutUint16(key[1+codec.AddressLen+8+8:], FeedObservationChunks)
+	return key
+}
+
+// GetFeed retrieves feed [feedID] of [publisher] from the state.
+func GetFeed(ctx context.Context, im state.Immutable, publisher codec.Address, feedID uint64) (*Feed, error) {
+	valBytes, err := im.GetValue(ctx, FeedKey(publisher, feedID))
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return nil, fmt.Errorf("feed %d not found: %w", feedID, err)
+		}
+		return nil, err
+	}
+
+	reader := codec.NewReader(valBytes, MaxFeedDataSize)
+	feed := &Feed{}
+	if err := codec.LinearCodec.UnmarshalFrom(reader.Packer, feed); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal feed %d: %w", feedID, err)
+	}
+	return feed, nil
+}
+
+// SetFeed stores a feed into the state.
+func SetFeed(ctx context.Context, mu state.Mutable, feed *Feed) error {
+	return insertFeedRecord(ctx, mu, FeedKey(feed.Publisher, feed.ID), feed, feed.ID)
+}
+
+// GetFeedObservation retrieves the observation of feed [feedID] of
+// [publisher] with sequence number [seq] from a ring buffer of [capacity]. It
+// returns ErrObservationOverwritten if a later observation took its slot, and
+// database.ErrNotFound if it has not been published.
+func GetFeedObservation(ctx context.Context, im state.Immutable, publisher codec.Address, feedID uint64, seq uint64, capacity uint64) (*PriceObservation, error) {
+	valBytes, err := im.GetValue(ctx, FeedObservationKey(publisher, feedID, FeedSlot(seq, capacity)))
+	return parseFeedObservation(valBytes, err, feedID, seq)
+}
+
+// GetFeedObservationFromState retrieves an observation through [f], as
+// GetFeedObservation does from an immutable state.
+func GetFeedObservationFromState(ctx context.Context, f ReadState, publisher codec.Address, feedID uint64, seq uint64, capacity uint64) (*PriceObservation, error) {
+	values, errs := f(ctx, [][]byte{FeedObservationKey(publisher, feedID, FeedSlot(seq, capacity))})
+	return parseFeedObservation(values[0], errs[0], feedID, seq)
+}
+
+func parseFeedObservation(valBytes []byte, err error, feedID uint64, seq uint64) (*PriceObservation, error) {
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return nil, fmt.Errorf("observation %d of feed %d not found: %w", seq, feedID, err)
+		}
+		return nil, err
+	}
+	reader := codec.NewReader(valBytes, MaxFeedDataSize)
+	obs := &PriceObservation{}
+	if err := codec.LinearCodec.UnmarshalFrom(reader.Packer, obs); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal observation %d of feed %d: %w", seq, feedID, err)
+	}
+	switch {
+	case obs.Sequence > seq:
+		return nil, fmt.Errorf("%w: observation %d of feed %d was replaced by observation %d", ErrObservationOverwritten, seq, feedID, obs.Sequence)
+	case obs.Sequence < seq:
+		return nil, fmt.Errorf("observation %d of feed %d not found: %w", seq, feedID, database.ErrNotFound)
+	}
+	return obs, nil
+}
+
+// AppendObservation records a [price] published to [feed] at [timestamp],
+// storing the new observation in the feed's ring buffer of [capacity] and the
+// updated feed.
+func AppendObservation(ctx context.Context, mu state.Mutable, feed *Feed, timestamp int64, price uint64, capacity uint64) error {
+	seq, obs := feed.Observe(timestamp, price)
+	if err := insertFeedRecord(ctx, mu, FeedObservationKey(feed.Publisher, feed.ID, FeedSlot(seq, capacity)), obs, feed.ID); err != nil {
+		return err
+	}
+	return SetFeed(ctx, mu, feed)
+}
+
+func insertFeedRecord(ctx context.Context, mu state.Mutable, key []byte, record any, feedID uint64) error {
+	writer := codec.NewWriter(0, MaxFeedDataSize)
+	if err := codec.LinearCodec.MarshalInto(record, writer.Packer); err != nil {
+		return fmt.Errorf("failed to marshal feed %d: %w", feedID, err)
+	}
+	if err := writer.Err(); err != nil {
+		return fmt.Errorf("writer error after marshaling feed %d: %w", feedID, err)
+	}
+	return mu.Insert(ctx, key, writer.Bytes())
+}
+
+// GetObservationAt returns observation [seq] of feed [feedID] of [publisher],
+// read from a ring buffer of [capacity], checking that it is the one in effect
+// at [t]: published at or before [t], and not followed by another observation
+// published at or before [t]. It returns ErrWindowNotCovered if the feed's
+// first observation is after [t], and ErrObservationOverwritten if [t] fell
+// out of the ring buffer.
+func GetObservationAt(ctx context.Context, im state.Immutable, publisher codec.Address, feedID uint64, seq uint64, capacity uint64, t int64) (*PriceObservation, error) {
+	obs, err := GetFeedObservation(ctx, im, publisher, feedID, seq, capacity)
+	if err != nil {
+		return nil, err
+	}
+	if obs.Timestamp > t {
+		if seq == 0 {
+			return nil, fmt.Errorf("%w: feed %d starts at %d, after %d", ErrWindowNotCovered, feedID, obs.Timestamp, t)
+		}
+		return nil, fmt.Errorf("%w: observation %d of feed %d was published at %d, after %d", ErrObservationNotInEffect, seq, feedID, obs.Timestamp, t)
+	}
+	next, err := GetFeedObservation(ctx, im, publisher, feedID, seq+1, capacity)
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+		return obs, nil
+	case err != nil:
+		return nil, err
+	case next.Timestamp <= t:
+		return nil, fmt.Errorf("%w: observation %d of feed %d was superseded at %d, before %d", ErrObservationNotInEffect, seq, feedID, next.Timestamp, t)
+	}
+	return obs, nil
+}
+
+// FindObservationAt returns the sequence number of the observation of feed
+// [feedID] of [publisher] in effect at [t], reading through [f] from a ring
+// buffer of [capacity]. Clients use it to name the observations a
+// ResolveMarket reads. It returns ErrObservationOverwritten if [t] is before
+// the oldest observation still in the ring buffer.
+func FindObservationAt(ctx context.Context, f ReadState, publisher codec.Address, feedID uint64, capacity uint64, t int64) (uint64, error) {
+	values, errs := f(ctx, [][]byte{FeedKey(publisher, feedID)})
+	if errs[0] != nil {
+		return 0, fmt.Errorf("failed to read feed %d: %w", feedID, errs[0])
+	}
+	feed := &Feed{}
+	if err := codec.LinearCodec.UnmarshalFrom(codec.NewReader(values[0], MaxFeedDataSize).Packer, feed); err != nil {
+		return 0, fmt.Errorf("failed to unmarshal feed %d: %w", feedID, err)
+	}
+	if feed.Count == 0 {
+		return 0, fmt.Errorf("%w: feed %d", ErrFeedEmpty, feedID)
+	}
+
+	// Find the first observation published after [t] among those still in the
+	// ring buffer; the one before it is in effect at [t].
+	var oldest uint64
+	if capacity > 0 && feed.Count > capacity {
+		oldest = feed.Count - capacity
+	}
+	lo, hi := oldest, feed.Count
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		obs, err := GetFeedObservationFromState(ctx, f, publisher, feedID, mid, capacity)
+		if err != nil {
+			return 0, err
+		}
+		if obs.Timestamp > t {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	switch {
+	case lo == 0:
+		return 0, fmt.Errorf("%w: feed %d, time %d", ErrWindowNotCovered, feedID, t)
+	case lo == oldest:
+		return 0, fmt.Errorf("%w: feed %d, time %d is before its oldest kept observation %d", ErrObservationOverwritten, feedID, t, oldest)
+	}
+	return lo - 1, nil
+}
+
+// TWAP returns the time-weighted average price over [start, end] given the
+// observations in effect at [start] and at [end] (see GetObservationAt).
+// Each observation's price holds from its timestamp until the next one.
+func TWAP(start, end int64, atStart, atEnd *PriceObservation) (uint64, error) {
+	if end <= start {
+		return 0, ErrInvalidTWAPWindow
+	}
+	if atStart.Timestamp > start {
+		return 0, fmt.Errorf("%w: first observation at %d, window starts at %d", ErrWindowNotCovered, atStart.Timestamp, start)
+	}
+	weighted := atEnd.CumulativeAt(end)
+	weighted.Sub(weighted, atStart.CumulativeAt(start))
+	return weighted.Div(weighted, big.NewInt(end-start)).Uint64(), nil
+}
@@ -0,0 +1,203 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+
+	"github.com/ava-labs/avalanchego/database"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/ava-labs/hypersdk/state"
+)
+
+const (
+	// PendingResolutionIndex holds the IDs of markets that have not been
+	// resolved yet.
+	PendingResolutionIndex byte = 0x0
+
+	// CreatorIndex holds the IDs of the markets created by an address.
+	CreatorIndex byte = 0x1
+
+	// EndTimeIndex holds the IDs of the markets whose trading ends within an
+	// EndTimeBucket.
+	EndTimeIndex byte = 0x2
+
+	// PositionIndex holds the IDs of the markets in which an address holds
+	// shares of a given type.
+	PositionIndex byte = 0x3
+
+	// statusIndexBase is added to a MarketStatus to get the index holding the
+	// IDs of the markets with that status.
+	statusIndexBase byte = 0x10
+
+	// EndTimeBucket is the width (in milliseconds) of the end time ranges
+	// grouped under one EndTimeIndex scope.
+	EndTimeBucket int64 = 24 * 60 * 60 * 1000
+
+	// IndexChunks is the number of 64-byte chunks reserved for an index entry.
+	IndexChunks uint16 = 1
+)
+
+// indexEntry is the value stored under every index entry key.
+var indexEntry = []byte{1}
+
+// StatusIndex returns the index holding the IDs of markets with [status].
+func StatusIndex(status MarketStatus) byte {
+	return statusIndexBase + byte(status)
+}
+
+// EndTimeBucketOf returns the EndTimeIndex bucket of a market ending at
+// [endTime].
+func EndTimeBucketOf(endTime int64) uint64 {
+	return uint64(max(endTime, 0) / EndTimeBucket)
+}
+
+// indexPrefix returns the prefix shared by the entry keys of the index
+// identified by [index] and [scope].
+// Format: IndexPrefix | Index (uint8) | Scope
+func indexPrefix(index byte, scope []byte) []byte {
+	prefix := make([]byte, 0, 1+1+len(scope))
+	prefix = append(prefix, IndexPrefix, index)
+	return append(prefix, scope...)
+}
+
+// IndexEntryKey generates the state key of [id]'s entry in the index whose
+// entry keys start with [prefix]. Every entry is a key of its own, so an
+// index never fills up and actions declare the exact entries they touch.
+// IDs are big endian, so the entries of an index sort by ID.
+// Format: IndexPrefix | Index (uint8) | Scope | ID (uint64) | Chunks (uint16)
+func IndexEntryKey(prefix []byte, id uint64) []byte {
+	key := make([]byte, 0, len(prefix)+8+2)
+	key = append(key, prefix...)
+	key = binary.BigEndian.AppendUint64(key, id)
+	return binary.BigEndian.AppendUint16(key, IndexChunks)
+}
+
+func indexEntryKey(index byte, scope []byte, id uint64) []byte {
+	return IndexEntryKey(indexPrefix(index, scope), id)
+}
+
+// IndexKeyPrefix returns the prefix of the entry keys of an index.
+func IndexKeyPrefix(index byte) []byte {
+	return indexPrefix(index, nil)
+}
+
+// IndexKey generates the state key of [id]'s entry in an index.
+// Format: IndexPrefix | Index (uint8) | ID (uint64) | Chunks (uint16)
+func IndexKey(index byte, id uint64) []byte {
+	return indexEntryKey(index, nil, id)
+}
+
+// CreatorIndexPrefix returns the prefix of the entry keys of the markets
+// created by [creator].
+func CreatorIndexPrefix(creator codec.Address) []byte {
+	return indexPrefix(CreatorIndex, creator[:])
+}
+
+// CreatorIndexKey generates the state key of [marketID]'s entry among the
+// markets created by [creator].
+// Format: IndexPrefix | CreatorIndex | Creator (codec.Address) | MarketID (uint64) | Chunks (uint16)
+func CreatorIndexKey(creator codec.Address, marketID uint64) []byte {
+	return indexEntryKey(CreatorIndex, creator[:], marketID)
+}
+
+// EndTimeIndexPrefix returns the prefix of the entry keys of the markets
+// ending in [bucket].
+func EndTimeIndexPrefix(bucket uint64) []byte {
+	return indexPrefix(EndTimeIndex, binary.BigEndian.AppendUint64(nil, bucket))
+}
+
+// EndTimeIndexKey generates the state key of the entry of [marketID], which
+// ends at [endTime], in the end time index.
+// Format: IndexPrefix | EndTimeIndex | Bucket (uint64) | MarketID (uint64) | Chunks (uint16)
+func EndTimeIndexKey(endTime int64, marketID uint64) []byte {
+	return indexEntryKey(EndTimeIndex, binary.BigEndian.AppendUint64(nil, EndTimeBucketOf(endTime)), marketID)
+}
+
+// PositionIndexPrefix returns the prefix of the entry keys of the markets in
+// which [user] holds shares of [shareType].
+func PositionIndexPrefix(user codec.Address, shareType uint8) []byte {
+	return indexPrefix(PositionIndex, append(user[:], shareType))
+}
+
+// PositionIndexKey generates the state key of [marketID]'s entry among the
+// markets in which [user] holds shares of [shareType].
+// Format: IndexPrefix | PositionIndex | User (codec.Address) | ShareType (uint8) | MarketID (uint64) | Chunks (uint16)
+func PositionIndexKey(user codec.Address, shareType uint8, marketID uint64) []byte {
+	return indexEntryKey(PositionIndex, append(user[:], shareType), marketID)
+}
+
+// ParseIndexKey returns the ID of the index entry [key] if it has [prefix].
+func ParseIndexKey(prefix []byte, key []byte) (uint64, bool) {
+	if len(key) != len(prefix)+8+2 || !bytes.HasPrefix(key, prefix) {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(key[len(prefix):]), true
+}
+
+// StatusIndexKeys returns the status index keys [marketID] is stored under
+// for each of [statuses]. Actions that move a market between these statuses
+// declare them.
+func StatusIndexKeys(marketID uint64, statuses ...MarketStatus) []string {
+	keys := make([]string, len(statuses))
+	for i, status := range statuses {
+		keys[i] = string(IndexKey(StatusIndex(status), marketID))
+	}
+	return keys
+}
+
+// IsIndexed returns whether the index entry [key] exists.
+func IsIndexed(ctx context.Context, im state.Immutable, key []byte) (bool, error) {
+	_, err := im.GetValue(ctx, key)
+	if errors.Is(err, database.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// AddToIndex adds [id] to an index. Adding an ID that is already indexed is a
+// no-op.
+func AddToIndex(ctx context.Context, mu state.Mutable, index byte, id uint64) error {
+	return mu.Insert(ctx, IndexKey(index, id), indexEntry)
+}
+
+// RemoveFromIndex removes [id] from an index. Removing an ID that is not
+// indexed is a no-op.
+func RemoveFromIndex(ctx context.Context, mu state.Mutable, index byte, id uint64) error {
+	return mu.Remove(ctx, IndexKey(index, id))
+}
+
+// MarketIndexKeys returns the status, creator and end time index entries of
+// [market].
+func MarketIndexKeys(market *Market) [][]byte {
+	return [][]byte{
+		IndexKey(StatusIndex(market.Status), market.ID),
+		CreatorIndexKey(market.Creator, market.ID),
+		EndTimeIndexKey(market.EndTime, market.ID),
+	}
+}
+
+// updateMarketIndexes moves [market] between the status, creator and end time
+// indexes after it changed from [prev] (nil for a new market).
+func updateMarketIndexes(ctx context.Context, mu state.Mutable, prev *Market, market *Market) error {
+	after := MarketIndexKeys(market)
+	var before [][]byte
+	if prev != nil {
+		before = MarketIndexKeys(prev)
+	}
+	for i, key := range after {
+		if before != nil {
+			if bytes.Equal(before[i], key) {
+				continue
+			}
+			if err := mu.Remove(ctx, before[i]); err != nil {
+				return err
+			}
+		}
+		if err := mu.Insert(ctx, key, indexEntry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
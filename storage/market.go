@@ -1,10 +1,12 @@
 package storage
 
 import (
+	"bytes"
 	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"math"
 
 	"github.com/ava-labs/avalanchego/database"
 	"github.com/ava-labs/hypersdk/codec"
@@ -21,8 +23,22 @@ const (
 	MarketStatus_TradingClosed MarketStatus = 1 // Trading is closed, awaiting resolution
 	MarketStatus_ResolvedYes   MarketStatus = 2 // Market resolved as YES
 	MarketStatus_ResolvedNo    MarketStatus = 3 // Market resolved as NO
+	MarketStatus_ResolvedInvalid MarketStatus = 4 // Market expired unresolved or was ruled Invalid; holders are refunded
+	MarketStatus_Disputed        MarketStatus = 5 // Initial ruling is disputed, awaiting the stakers' vote
+	MarketStatus_Appealed        MarketStatus = 6 // Stakers' ruling on a dispute is appealed, awaiting their second vote
 )
 
+// MarketStatuses lists every MarketStatus.
+var MarketStatuses = []MarketStatus{
+	MarketStatus_Open,
+	MarketStatus_TradingClosed,
+	MarketStatus_ResolvedYes,
+	MarketStatus_ResolvedNo,
+	MarketStatus_ResolvedInvalid,
+	MarketStatus_Disputed,
+	MarketStatus_Appealed,
+}
+
 func (ms MarketStatus) String() string {
 	switch ms {
 	case MarketStatus_Open:
@@ -33,11 +49,22 @@ func (ms MarketStatus) String() string {
 		return "ResolvedYes"
 	case MarketStatus_ResolvedNo:
 		return "ResolvedNo"
+	case MarketStatus_ResolvedInvalid:
+		return "ResolvedInvalid"
+	case MarketStatus_Disputed:
+		return "Disputed"
+	case MarketStatus_Appealed:
+		return "Appealed"
 	default:
 		return fmt.Sprintf("UnknownMarketStatus:%d", ms)
 	}
 }
 
+// IsResolved reports whether the market has a final outcome.
+func (ms MarketStatus) IsResolved() bool {
+	return ms == MarketStatus_ResolvedYes || ms == MarketStatus_ResolvedNo || ms == MarketStatus_ResolvedInvalid
+}
+
 // OutcomeType defines the possible resolved outcomes of a prediction market.
 type OutcomeType uint8
 
@@ -63,75 +90,296 @@ func (ot OutcomeType) String() string {
 	}
 }
 
-// Market defines the structure for a prediction market.
+// Market defines the structure for a prediction market. It is stored as two
+// records: the metadata fixed at creation under MarketKey, and the MarketState
+// that trading and resolution change under MarketStateKey.
 type Market struct {
-	ID               uint64        `serialize:"true" json:"id"`
-	Description      string        `serialize:"true" json:"description"`
-	Status           MarketStatus  `serialize:"true" json:"status"`
-	Creator          codec.Address `serialize:"true" json:"creator"`
-	EndTime          int64         `serialize:"true" json:"endTime"`
-	ResolutionTime   int64         `serialize:"true" json:"resolutionTime"`
-	TotalYesShares   uint64        `serialize:"true" json:"totalYesShares"`
-	TotalNoShares    uint64        `serialize:"true" json:"totalNoShares"`
-	OracleType       uint8         `serialize:"true" json:"oracleType"`        // Type of oracle (e.g., 0 for Manual, 1 for Chainlink)
-	OracleSource     string        `serialize:"true" json:"oracleSource"`      // Oracle identifier (URL, address, etc.)
-	OracleParameters []byte        `serialize:"true" json:"oracleParameters"`  // Specific parameters for the oracle job
-	ResolvedOutcome  OutcomeType   `serialize:"true" json:"resolvedOutcome"` // The final outcome of the market
+	ID                    uint64        `json:"id"`
+	Description           string        `json:"description"`
+	Status                MarketStatus  `json:"status"`
+	Creator               codec.Address `json:"creator"`
+	EndTime               int64         `json:"endTime"`
+	ResolutionTime        int64         `json:"resolutionTime"`
+	TotalYesShares        uint64        `json:"totalYesShares"`
+	TotalNoShares         uint64        `json:"totalNoShares"`
+	OracleType            uint8         `json:"oracleType"`            // Type of oracle (consts.OracleTypeManual or consts.OracleTypePriceFeed)
+	OracleSource          string        `json:"oracleSource"`          // Oracle identifier (URL, address, etc.)
+	OracleParameters      []byte        `json:"oracleParameters"`      // Specific parameters for the oracle job
+	ResolvedOutcome       OutcomeType   `json:"resolvedOutcome"`       // The final outcome of the market
+	ResolutionGracePeriod int64         `json:"resolutionGracePeriod"` // Overrides the chain's grace period when non-zero
+	Collateral            uint64        `json:"collateral"`            // Native tokens paid in by traders and not yet claimed
+	Oracle                codec.Address `json:"oracle"`                // Address whose rulings resolve the market (empty for price-feed markets)
+	ResolvedAt            int64         `json:"resolvedAt"`            // Time of the latest ruling
+	Tier                  uint8         `json:"tier"`                  // Dispute tier of the latest ruling (0 = initial resolution)
+	SweepDeposit          uint64        `json:"sweepDeposit"`          // Escrowed by the creator and paid to whoever sweeps the settled market
+}
+
+// MarketState is the part of a market that trades and rulings change. It is
+// kept apart from the market's metadata so that a trade only rewrites a
+// single chunk. EndTime never changes but is kept here so that trades do not
+// need to read the metadata.
+type MarketState struct {
+	Status          MarketStatus `serialize:"true" json:"status"`
+	EndTime         int64        `serialize:"true" json:"endTime"`
+	TotalYesShares  uint64       `serialize:"true" json:"totalYesShares"`
+	TotalNoShares   uint64       `serialize:"true" json:"totalNoShares"`
+	Collateral      uint64       `serialize:"true" json:"collateral"`
+	ResolvedOutcome OutcomeType  `serialize:"true" json:"resolvedOutcome"`
+	ResolvedAt      int64        `serialize:"true" json:"resolvedAt"`
+	Tier            uint8        `serialize:"true" json:"tier"`
+}
+
+// State returns a copy of the market's MarketState.
+func (m *Market) State() *MarketState {
+	return &MarketState{
+		Status:          m.Status,
+		EndTime:         m.EndTime,
+		TotalYesShares:  m.TotalYesShares,
+		TotalNoShares:   m.TotalNoShares,
+		Collateral:      m.Collateral,
+		ResolvedOutcome: m.ResolvedOutcome,
+		ResolvedAt:      m.ResolvedAt,
+		Tier:            m.Tier,
+	}
+}
+
+// SetState overwrites the market's MarketState fields with [s].
+func (m *Market) SetState(s *MarketState) {
+	m.Status = s.Status
+	m.EndTime = s.EndTime
+	m.TotalYesShares = s.TotalYesShares
+	m.TotalNoShares = s.TotalNoShares
+	m.Collateral = s.Collateral
+	m.ResolvedOutcome = s.ResolvedOutcome
+	m.ResolvedAt = s.ResolvedAt
+	m.Tier = s.Tier
+}
+
+// CheckTrading returns a MarketClosedError unless market [marketID], whose
+// state is [s], accepts trades at [timestamp].
+func (s *MarketState) CheckTrading(marketID uint64, timestamp int64) error {
+	if s.Status != MarketStatus_Open || timestamp > s.EndTime {
+		return &MarketClosedError{MarketID: marketID, Status: s.Status, EndTime: s.EndTime, Time: timestamp}
+	}
+	return nil
+}
+
+// StatusAt returns the status of the market at [timestamp]. No action records
+// the end of trading, so an Open market whose trading period has ended is
+// reported TradingClosed.
+func (m *Market) StatusAt(timestamp int64) MarketStatus {
+	if m.Status == MarketStatus_Open && timestamp > m.EndTime {
+		return MarketStatus_TradingClosed
+	}
+	return m.Status
+}
+
+// ResolutionDeadline returns the time after which the unresolved market may be
+// expired as Invalid. [defaultGracePeriod] applies when the market does not set
+// its own grace period. A deadline past the largest timestamp is capped
+// there, so the market never expires.
+func (m *Market) ResolutionDeadline(defaultGracePeriod int64) int64 {
+	gracePeriod := m.ResolutionGracePeriod
+	if gracePeriod == 0 {
+		gracePeriod = defaultGracePeriod
+	}
+	if gracePeriod > 0 && m.ResolutionTime > math.MaxInt64-gracePeriod {
+		return math.MaxInt64
+	}
+	return m.ResolutionTime + gracePeriod
 }
 
+const (
+	// MarketChunks is the number of 64-byte chunks reserved for a market's
+	// metadata.
+	MarketChunks uint16 = pvmConsts.MaxMarketDataSize/64 + 1
+
+	// MaxMarketStateSize defines the maximum size of an encoded MarketState,
+	// which fits a single chunk.
+	MaxMarketStateSize = 63
+
+	// MarketStateChunks is the number of 64-byte chunks reserved for a
+	// market's state.
+	MarketStateChunks uint16 = MaxMarketStateSize/64 + 1
+
+	// ShareBalanceChunks is the number of 64-byte chunks reserved for a share
+	// balance.
+	ShareBalanceChunks uint16 = 1
+)
+
+var ErrMarketStateImmutable = errors.New("market status and end time can only change through SetMarket")
+
 // MarketKey generates the state key for a given market ID.
-// Format: MarketPrefix | MarketID (uint64)
+// Format: MarketPrefix | MarketID (uint64) | Chunks (uint16)
 func MarketKey(marketID uint64) []byte {
-	key := make([]byte, 1+8) // Use literal 8 for Uint64Len
+	key := make([]byte, 1+8+2) // Use literal 8 for Uint64Len
 	key[0] = MarketPrefix
 	binary.BigEndian.PutUint64(key[1:], marketID)
+	binary.BigEndian.PutUint16(key[1+8:], MarketChunks)
+	return key
+}
+
+// MarketStateKey generates the state key for the MarketState of a market.
+// MarketV1 markets have no MarketState record until SetMarket or
+// SetMarketState splits their old record, so actions that write a market
+// declare this key with state.All.
+// Format: MarketStatePrefix | MarketID (uint64) | Chunks (uint16)
+func MarketStateKey(marketID uint64) []byte {
+	key := make([]byte, 1+8+2)
+	key[0] = MarketStatePrefix
+	binary.BigEndian.PutUint64(key[1:], marketID)
+	binary.BigEndian.PutUint16(key[1+8:], MarketStateChunks)
 	return key
 }
 
-// GetMarket retrieves a market by its ID from the state.
+// GetMarket retrieves a market by its ID from the state. It returns a
+// MarketNotFoundError if the market does not exist.
 func GetMarket(ctx context.Context, im state.Immutable, marketID uint64) (*Market, error) {
-	key := MarketKey(marketID)
-	valBytes, err := im.GetValue(ctx, key)
+	market, _, err := getMarket(ctx, im, marketID)
+	return market, err
+}
+
+// GetMarketFromState retrieves a market through [f], reading its metadata
+// and MarketState in one call. Used to serve RPC queries.
+func GetMarketFromState(ctx context.Context, f ReadState, marketID uint64) (*Market, error) {
+	values, errs := f(ctx, [][]byte{MarketKey(marketID), MarketStateKey(marketID)})
+	market, _, err := decodeMarket(marketID, values[0], errs[0], values[1], errs[1])
+	return market, err
+}
+
+// getMarket retrieves a market along with the bytes of its metadata record.
+func getMarket(ctx context.Context, im state.Immutable, marketID uint64) (*Market, []byte, error) {
+	valBytes, err := im.GetValue(ctx, MarketKey(marketID))
 	if err != nil {
-		if errors.Is(err, database.ErrNotFound) {
-			return nil, fmt.Errorf("market %d not found: %w", marketID, err)
-		}
-		return nil, err
+		return decodeMarket(marketID, valBytes, err, nil, nil)
 	}
-	if len(valBytes) == 0 {
-		// Should not happen if ErrNotFound is handled, but good practice
-		return nil, fmt.Errorf("market %d found but value is empty", marketID)
+	stateBytes, stateErr := im.GetValue(ctx, MarketStateKey(marketID))
+	return decodeMarket(marketID, valBytes, nil, stateBytes, stateErr)
+}
+
+// decodeMarket decodes the metadata and MarketState records of market
+// [marketID], as read with errors [err] and [stateErr]. A market without a
+// MarketState record is decoded as MarketV1.
+func decodeMarket(marketID uint64, valBytes []byte, err error, stateBytes []byte, stateErr error) (*Market, []byte, error) {
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, nil, &MarketNotFoundError{MarketID: marketID}
 	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if errors.Is(stateErr, database.ErrNotFound) {
+		market, err := DecodeMarketV1(marketID, valBytes)
+		return market, valBytes, err
+	}
+	if stateErr != nil {
+		return nil, nil, stateErr
+	}
+	market, _, err := DecodeMarket(marketID, valBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	marketState, err := DecodeMarketState(marketID, stateBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	market.SetState(marketState)
+	return market, valBytes, nil
+}
 
-	reader := codec.NewReader(valBytes, pvmConsts.MaxMarketDataSize)
-	market := &Market{}
-	if err := codec.LinearCodec.UnmarshalFrom(reader.Packer, market); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal market %d using LinearCodec: %w", marketID, err)
+// SetMarket stores a market into the state and keeps the status, creator and
+// end time indexes in sync with it. Callers must declare the index keys of
+// any status the market moves between (see StatusIndexKeys); a new market
+// also needs its creator and end time index keys.
+//
+// The metadata record is only rewritten when it changed, which includes
+// records stored in an older version. That also splits the MarketState out of
+// MarketV1 records.
+func SetMarket(ctx context.Context, mu state.Mutable, market *Market) error {
+	prev, prevBytes, err := getMarket(ctx, mu, market.ID)
+	if errors.Is(err, database.ErrNotFound) {
+		prev = nil
+	} else if err != nil {
+		return fmt.Errorf("failed to get stored market %d: %w", market.ID, err)
 	}
-	if err := reader.Err(); err != nil {
-		return nil, fmt.Errorf("reader error after unmarshaling market %d: %w", marketID, err)
+	if err := updateMarketIndexes(ctx, mu, prev, market); err != nil {
+		return fmt.Errorf("failed to index market %d: %w", market.ID, err)
 	}
+	valBytes, err := EncodeMarket(market)
+	if err != nil {
+		return err
+	}
+	if prev == nil || !bytes.Equal(prevBytes, valBytes) {
+		if err := mu.Insert(ctx, MarketKey(market.ID), valBytes); err != nil {
+			return err
+		}
+	}
+	return setMarketState(ctx, mu, market.ID, market.State())
+}
 
-	return market, nil
+// GetMarketState retrieves the MarketState of a market without its metadata.
+// MarketV1 markets have no MarketState record, so theirs is read from their
+// MarketV1 record under MarketKey, which callers must also declare.
+func GetMarketState(ctx context.Context, im state.Immutable, marketID uint64) (*MarketState, error) {
+	marketState, _, err := getMarketState(ctx, im, marketID)
+	return marketState, err
 }
 
-// SetMarket stores a market into the state.
-func SetMarket(ctx context.Context, mu state.Mutable, market *Market) error {
-	key := MarketKey(market.ID)
-	writer := codec.NewWriter(0, pvmConsts.MaxMarketDataSize)
-	if err := codec.LinearCodec.MarshalInto(market, writer.Packer); err != nil {
-		return fmt.Errorf("failed to marshal market %d using LinearCodec: %w", market.ID, err)
+// getMarketState retrieves the MarketState of a market along with, for a
+// MarketV1 market, the market its record holds.
+func getMarketState(ctx context.Context, im state.Immutable, marketID uint64) (*MarketState, *Market, error) {
+	valBytes, err := im.GetValue(ctx, MarketStateKey(marketID))
+	if err == nil {
+		marketState, err := DecodeMarketState(marketID, valBytes)
+		return marketState, nil, err
+	}
+	if !errors.Is(err, database.ErrNotFound) {
+		return nil, nil, err
 	}
-	if err := writer.Err(); err != nil {
-		return fmt.Errorf("writer error after marshaling market %d: %w", market.ID, err)
+	market, _, err := getMarket(ctx, im, marketID)
+	if err != nil {
+		return nil, nil, err
 	}
-	return mu.Insert(ctx, key, writer.Bytes())
+	return market.State(), market, nil
+}
+
+// SetMarketState stores the MarketState of a market without touching its
+// metadata, as trades do. The status and end time are indexed, so changing
+// them returns ErrMarketStateImmutable; use SetMarket instead.
+//
+// The record of a MarketV1 market is split instead, so callers must declare
+// MarketKey with state.Write and MarketStateKey with state.All.
+func SetMarketState(ctx context.Context, mu state.Mutable, marketID uint64, marketState *MarketState) error {
+	prev, v1, err := getMarketState(ctx, mu, marketID)
+	if err != nil {
+		return err
+	}
+	if prev.Status != marketState.Status || prev.EndTime != marketState.EndTime {
+		return fmt.Errorf("%w: market %d", ErrMarketStateImmutable, marketID)
+	}
+	if v1 != nil {
+		valBytes, err := EncodeMarket(v1)
+		if err != nil {
+			return err
+		}
+		if err := mu.Insert(ctx, MarketKey(marketID), valBytes); err != nil {
+			return err
+		}
+	}
+	return setMarketState(ctx, mu, marketID, marketState)
+}
+
+func setMarketState(ctx context.Context, mu state.Mutable, marketID uint64, marketState *MarketState) error {
+	valBytes, err := EncodeMarketState(marketID, marketState)
+	if err != nil {
+		return err
+	}
+	return mu.Insert(ctx, MarketStateKey(marketID), valBytes)
 }
 
 // ShareBalanceKey generates the state key for a user's share balance in a market.
-// Format: ShareBalancePrefix | MarketID (uint64) | UserAddress (codec.Address) | ShareType (uint8)
+// Format: ShareBalancePrefix | MarketID (uint64) | UserAddress (codec.Address) | ShareType (uint8) | Chunks (uint16)
 func ShareBalanceKey(marketID uint64, user codec.Address, shareType uint8) []byte {
-	key := make([]byte, 1+8+codec.AddressLen+1) // Use literal 8 for Uint64Len and 1 for Uint8Len
+	key := make([]byte, 1+8+codec.AddressLen+1+2) // Use literal 8 for Uint64Len and 1 for Uint8Len
 	key[0] = ShareBalancePrefix
 	offset := 1
 	binary.BigEndian.PutUint64(key[offset:], marketID)
@@ -139,13 +387,25 @@ func ShareBalanceKey(marketID uint64, user codec.Address, shareType uint8) []byt
 	copy(key[offset:], user[:])
 	offset += codec.AddressLen
 	key[offset] = shareType
+	offset++
+	binary.BigEndian.PutUint16(key[offset:], ShareBalanceChunks)
 	return key
 }
 
 // GetShareBalance retrieves a user's share balance for a specific market and share type.
 func GetShareBalance(ctx context.Context, im state.Immutable, marketID uint64, user codec.Address, shareType uint8) (uint64, error) {
-	key := ShareBalanceKey(marketID, user, shareType)
-	valBytes, err := im.GetValue(ctx, key) // Use GetValue and pass ctx
+	valBytes, err := im.GetValue(ctx, ShareBalanceKey(marketID, user, shareType)) // Use GetValue and pass ctx
+	return innerGetShareBalance(valBytes, err, marketID, user, shareType)
+}
+
+// GetShareBalanceFromState retrieves a share balance through [f]. Used to
+// serve RPC queries.
+func GetShareBalanceFromState(ctx context.Context, f ReadState, marketID uint64, user codec.Address, shareType uint8) (uint64, error) {
+	values, errs := f(ctx, [][]byte{ShareBalanceKey(marketID, user, shareType)})
+	return innerGetShareBalance(values[0], errs[0], marketID, user, shareType)
+}
+
+func innerGetShareBalance(valBytes []byte, err error, marketID uint64, user codec.Address, shareType uint8) (uint64, error) {
 	if errors.Is(err, database.ErrNotFound) {
 		return 0, nil // No shares of this type for this user/market, treat as 0
 	}
@@ -156,16 +416,24 @@ func GetShareBalance(ctx context.Context, im state.Immutable, marketID uint64, u
 		return 0, nil // Key exists but empty value, treat as 0
 	}
 	reader := codec.NewReader(valBytes, len(valBytes))
-	balance := reader.UnpackUint64(true) // true for required
+	balance := reader.UnpackUint64(false) // Zeroes are no longer stored, but older records may hold one
 	if errs := reader.Err(); errs != nil {
 		return 0, fmt.Errorf("failed to unpack share balance for market %d, user %s, type %d: %w", marketID, user, shareType, errs)
 	}
 	return balance, nil
 }
 
-// SetShareBalance sets a user's share balance for a specific market and share type.
+// SetShareBalance sets a user's share balance for a specific market and share
+// type, removing it when [amount] is zero, and keeps the user's position index
+// in sync with it, so callers must also declare PositionIndexKey.
 func SetShareBalance(ctx context.Context, mu state.Mutable, marketID uint64, user codec.Address, shareType uint8, amount uint64) error {
+	if amount == 0 {
+		return RemoveShareBalance(ctx, mu, marketID, user, shareType)
+	}
 	key := ShareBalanceKey(marketID, user, shareType)
+	if err := mu.Insert(ctx, PositionIndexKey(user, shareType, marketID), indexEntry); err != nil {
+		return fmt.Errorf("failed to index position of user %s in market %d, type %d: %w", user, marketID, shareType, err)
+	}
 	writer := codec.NewWriter(8, 8) // Use literal 8, 8 for Uint64Len
 	writer.PackUint64(amount)
 	if errs := writer.Err(); errs != nil {
@@ -174,6 +442,15 @@ func SetShareBalance(ctx context.Context, mu state.Mutable, marketID uint64, use
 	return mu.Insert(ctx, key, writer.Bytes()) // Use Insert and pass ctx
 }
 
+// RemoveShareBalance deletes a user's share balance for a specific market and
+// share type along with its position index entry.
+func RemoveShareBalance(ctx context.Context, mu state.Mutable, marketID uint64, user codec.Address, shareType uint8) error {
+	if err := mu.Remove(ctx, PositionIndexKey(user, shareType, marketID)); err != nil {
+		return fmt.Errorf("failed to unindex position of user %s in market %d, type %d: %w", user, marketID, shareType, err)
+	}
+	return mu.Remove(ctx, ShareBalanceKey(marketID, user, shareType))
+}
+
 // AddShares adds a specified amount of shares to a user's balance for a market and share type.
 func AddShares(ctx context.Context, mu state.Mutable, marketID uint64, user codec.Address, shareType uint8, amountToAdd uint64) error {
 	currentShares, err := GetShareBalance(ctx, mu, marketID, user, shareType) // Pass ctx
@@ -186,14 +463,14 @@ func AddShares(ctx context.Context, mu state.Mutable, marketID uint64, user code
 }
 
 // DeductShares subtracts a specified amount of shares from a user's balance.
-// Returns an error if the user does not have enough shares.
+// Returns an InsufficientSharesError if the user does not have enough shares.
 func DeductShares(ctx context.Context, mu state.Mutable, marketID uint64, user codec.Address, shareType uint8, amountToDeduct uint64) error {
 	currentShares, err := GetShareBalance(ctx, mu, marketID, user, shareType) // Pass ctx
 	if err != nil {
 		return fmt.Errorf("failed to get current share balance for user %s, market %d, type %d: %w", user, marketID, shareType, err)
 	}
 	if currentShares < amountToDeduct {
-		return fmt.Errorf("insufficient %s shares for user %s in market %d (has %d, needs %d)", pvmConsts.ShareTypeToString(shareType), user, marketID, currentShares, amountToDeduct)
+		return &InsufficientSharesError{MarketID: marketID, Holder: user, ShareType: shareType, Balance: currentShares, Required: amountToDeduct}
 	}
 	newShares := currentShares - amountToDeduct
 	return SetShareBalance(ctx, mu, marketID, user, shareType, newShares) // Pass ctx
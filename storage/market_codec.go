@@ -0,0 +1,230 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ava-labs/hypersdk/codec"
+
+	pvmConsts "github.com/chokosabe/predictionvm/consts"
+)
+
+// Market records are versioned so that fields can be added without breaking
+// the markets already in state. A market is stored as two records: its
+// metadata under MarketKey, as a version byte followed by the market ID and
+// the rest of the metadata, and its MarketState under MarketStateKey, which
+// carries its own version byte.
+//
+// The first release stored the whole market under MarketKey without a
+// version byte and no MarketState record (MarketV1). The MarketState record
+// tells the layouts apart: a market without one was stored by the first
+// release.
+//
+// GetMarket decodes every known version into the current Market and SetMarket
+// always writes MarketVersion, so old records are rewritten lazily the next
+// time their market changes.
+//
+// To change Market: freeze the current layout as marketVN with its own
+// decoder, then bump MarketVersion. Never edit a frozen layout. MarketState
+// records follow the same rules.
+const (
+	// MarketV1 is the unprefixed encoding of the whole market written by the
+	// first release.
+	MarketV1 byte = 1
+	// MarketV2 only holds the market's metadata, including the
+	// ResolutionGracePeriod, Oracle and SweepDeposit added since MarketV1;
+	// its MarketState is stored under MarketStateKey.
+	MarketV2 byte = 2
+
+	// MarketVersion is the version SetMarket writes.
+	MarketVersion = MarketV2
+
+	// MarketStateV1 is the current encoding version of MarketState records.
+	MarketStateV1 byte = 1
+)
+
+var (
+	ErrUnknownMarketVersion = errors.New("unknown market record version")
+	ErrMalformedMarket      = errors.New("malformed market record")
+)
+
+// marketDecoders decodes the body of a versioned record into the current
+// Market.
+var marketDecoders = map[byte]func([]byte) (*Market, error){
+	MarketV2: decodeMarketV2,
+}
+
+// EncodeMarket serializes the metadata of [market] as a MarketVersion record.
+func EncodeMarket(market *Market) ([]byte, error) {
+	return encodeRecord(MarketVersion, &marketV2{
+		ID:                    market.ID,
+		Description:           market.Description,
+		Creator:               market.Creator,
+		ResolutionTime:        market.ResolutionTime,
+		OracleType:            market.OracleType,
+		OracleSource:          market.OracleSource,
+		OracleParameters:      market.OracleParameters,
+		ResolutionGracePeriod: market.ResolutionGracePeriod,
+		Oracle:                market.Oracle,
+		SweepDeposit:          market.SweepDeposit,
+	}, pvmConsts.MaxMarketDataSize, market.ID)
+}
+
+// EncodeMarketState serializes the MarketState of market [marketID] as a
+// MarketStateV1 record.
+func EncodeMarketState(marketID uint64, marketState *MarketState) ([]byte, error) {
+	return encodeRecord(MarketStateV1, marketState, MaxMarketStateSize, marketID)
+}
+
+func encodeRecord(version byte, record any, maxSize int, marketID uint64) ([]byte, error) {
+	writer := codec.NewWriter(1, maxSize)
+	writer.Packer.PackByte(version)
+	if err := codec.LinearCodec.MarshalInto(record, writer.Packer); err != nil {
+		return nil, fmt.Errorf("failed to marshal market %d: %w", marketID, err)
+	}
+	if err := writer.Err(); err != nil {
+		return nil, fmt.Errorf("failed to marshal market %d: %w", marketID, err)
+	}
+	return writer.Bytes(), nil
+}
+
+// DecodeMarketState decodes the MarketState record of market [marketID].
+func DecodeMarketState(marketID uint64, b []byte) (*MarketState, error) {
+	if len(b) == 0 {
+		return nil, fmt.Errorf("%w: market %d state is empty", ErrMalformedMarket, marketID)
+	}
+	if b[0] != MarketStateV1 {
+		return nil, fmt.Errorf("%w: market %d state has version %d", ErrUnknownMarketVersion, marketID, b[0])
+	}
+	marketState := &MarketState{}
+	if err := unmarshalMarketRecord(b[1:], marketState); err != nil {
+		return nil, fmt.Errorf("%w: market %d state: %w", ErrMalformedMarket, marketID, err)
+	}
+	return marketState, nil
+}
+
+// DecodeMarket decodes the metadata record of market [marketID] and returns
+// it with the version it was stored in. Its MarketState is decoded separately
+// (see DecodeMarketState).
+func DecodeMarket(marketID uint64, b []byte) (*Market, byte, error) {
+	if len(b) == 0 {
+		return nil, 0, fmt.Errorf("%w: market %d record is empty", ErrMalformedMarket, marketID)
+	}
+	decode, ok := marketDecoders[b[0]]
+	if !ok {
+		return nil, 0, fmt.Errorf("%w: market %d has version %d", ErrUnknownMarketVersion, marketID, b[0])
+	}
+	market, err := decode(b[1:])
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: market %d (version %d): %w", ErrMalformedMarket, marketID, b[0], err)
+	}
+	if market.ID != marketID {
+		return nil, 0, fmt.Errorf("%w: market %d record holds market %d", ErrMalformedMarket, marketID, market.ID)
+	}
+	return market, b[0], nil
+}
+
+// DecodeMarketV1 decodes the MarketV1 record of market [marketID], which
+// holds the whole market.
+func DecodeMarketV1(marketID uint64, b []byte) (*Market, error) {
+	market, err := decodeMarketV1(b)
+	if err != nil {
+		return nil, fmt.Errorf("%w: market %d (version %d): %w", ErrMalformedMarket, marketID, MarketV1, err)
+	}
+	if market.ID != marketID {
+		return nil, fmt.Errorf("%w: market %d record holds market %d", ErrMalformedMarket, marketID, market.ID)
+	}
+	return market, nil
+}
+
+func unmarshalMarketRecord(b []byte, record any) error {
+	reader := codec.NewReader(b, pvmConsts.MaxMarketDataSize)
+	if err := codec.LinearCodec.UnmarshalFrom(reader.Packer, record); err != nil {
+		return err
+	}
+	if err := reader.Err(); err != nil {
+		return err
+	}
+	if !reader.Empty() {
+		return fmt.Errorf("%d trailing bytes", len(b)-reader.Offset())
+	}
+	return nil
+}
+
+// marketV2 is the frozen layout of MarketV2 records.
+type marketV2 struct {
+	ID                    uint64        `serialize:"true"`
+	Description           string        `serialize:"true"`
+	Creator               codec.Address `serialize:"true"`
+	ResolutionTime        int64         `serialize:"true"`
+	OracleType            uint8         `serialize:"true"`
+	OracleSource          string        `serialize:"true"`
+	OracleParameters      []byte        `serialize:"true"`
+	ResolutionGracePeriod int64         `serialize:"true"`
+	Oracle                codec.Address `serialize:"true"`
+	SweepDeposit          uint64        `serialize:"true"`
+}
+
+func decodeMarketV2(b []byte) (*Market, error) {
+	v2 := &marketV2{}
+	if err := unmarshalMarketRecord(b, v2); err != nil {
+		return nil, err
+	}
+	return &Market{
+		ID:                    v2.ID,
+		Description:           v2.Description,
+		Creator:               v2.Creator,
+		ResolutionTime:        v2.ResolutionTime,
+		OracleType:            v2.OracleType,
+		OracleSource:          v2.OracleSource,
+		OracleParameters:      v2.OracleParameters,
+		ResolutionGracePeriod: v2.ResolutionGracePeriod,
+		Oracle:                v2.Oracle,
+		SweepDeposit:          v2.SweepDeposit,
+	}, nil
+}
+
+// marketV1 is the frozen layout of MarketV1 records.
+type marketV1 struct {
+	ID               uint64        `serialize:"true"`
+	Description      string        `serialize:"true"`
+	Status           MarketStatus  `serialize:"true"`
+	Creator          codec.Address `serialize:"true"`
+	EndTime          int64         `serialize:"true"`
+	ResolutionTime   int64         `serialize:"true"`
+	TotalYesShares   uint64        `serialize:"true"`
+	TotalNoShares    uint64        `serialize:"true"`
+	OracleType       uint8         `serialize:"true"`
+	OracleSource     string        `serialize:"true"`
+	OracleParameters []byte        `serialize:"true"`
+	ResolvedOutcome  OutcomeType   `serialize:"true"`
+}
+
+// decodeMarketV1 upgrades a MarketV1 record. The fields added since take
+// their defaults: the chain's grace period, no collateral (traders' payments
+// were not escrowed yet), the creator as the resolver of a manual market, a
+// ruling old enough to be final and no sweep deposit.
+func decodeMarketV1(b []byte) (*Market, error) {
+	v1 := &marketV1{}
+	if err := unmarshalMarketRecord(b, v1); err != nil {
+		return nil, err
+	}
+	market := &Market{
+		ID:               v1.ID,
+		Description:      v1.Description,
+		Status:           v1.Status,
+		Creator:          v1.Creator,
+		EndTime:          v1.EndTime,
+		ResolutionTime:   v1.ResolutionTime,
+		TotalYesShares:   v1.TotalYesShares,
+		TotalNoShares:    v1.TotalNoShares,
+		OracleType:       v1.OracleType,
+		OracleSource:     v1.OracleSource,
+		OracleParameters: v1.OracleParameters,
+		ResolvedOutcome:  v1.ResolvedOutcome,
+	}
+	if market.OracleType == pvmConsts.OracleTypeManual {
+		market.Oracle = market.Creator
+	}
+	return market, nil
+}
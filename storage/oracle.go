@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ava-labs/hypersdk/codec"
+
+	pvmConsts "github.com/chokosabe/predictionvm/consts"
+)
+
+const (
+	// OracleParametersV1 is the current encoding of oracle parameters.
+	OracleParametersV1 byte = 1
+
+	// MaxOracleParametersSize defines the maximum size of encoded oracle parameters.
+	MaxOracleParametersSize = 512
+)
+
+var (
+	ErrUnknownOracleType              = errors.New("unknown oracle type")
+	ErrUnknownOracleParametersVersion = errors.New("unknown oracle parameters version")
+	ErrMalformedOracleParameters      = errors.New("malformed oracle parameters")
+	ErrInvalidOracleParameters        = errors.New("invalid oracle parameters")
+)
+
+// OracleParameters are the typed parameters of a market's oracle. They are
+// stored in Market.OracleParameters as a version byte followed by the
+// codec-serialized struct for the market's OracleType.
+type OracleParameters interface {
+	// OracleType returns the consts.OracleType* the parameters belong to.
+	OracleType() uint8
+	// Verify checks that the parameters are usable to resolve a market.
+	Verify() error
+}
+
+var (
+	_ OracleParameters = (*ManualParameters)(nil)
+	_ OracleParameters = (*PriceFeedParameters)(nil)
+)
+
+// ManualParameters are the oracle parameters of a manually resolved market.
+// The market is resolved by [Resolver], or by its creator when [Resolver] is
+// the empty address. Manual markets may also omit their parameters entirely.
+type ManualParameters struct {
+	Resolver codec.Address `serialize:"true" json:"resolver"`
+}
+
+func (*ManualParameters) OracleType() uint8 { return pvmConsts.OracleTypeManual }
+
+func (*ManualParameters) Verify() error { return nil }
+
+// Bytes serializes the parameters for use as a market's OracleParameters.
+func (p *ManualParameters) Bytes() []byte { return encodeOracleParameters(OracleParametersV1, p) }
+
+// ResolverFor returns the address allowed to resolve [market].
+func (p *ManualParameters) ResolverFor(market *Market) codec.Address {
+	if p.Resolver == codec.EmptyAddress {
+		return market.Creator
+	}
+	return p.Resolver
+}
+
+// PriceFeedParameters are the oracle parameters of a market resolved against
+// a price feed. The market resolves YES if the TWAP of [FeedID] over the
+// [Window] milliseconds before the market's resolution time is at least
+// [Threshold], and NO otherwise. Only a feed published by [Publisher] can
+// resolve the market.
+type PriceFeedParameters struct {
+	FeedID    uint64        `serialize:"true" json:"feedId"`
+	Window    int64         `serialize:"true" json:"window"`
+	Threshold uint64        `serialize:"true" json:"threshold"`
+	Publisher codec.Address `serialize:"true" json:"publisher"`
+}
+
+func (*PriceFeedParameters) OracleType() uint8 { return pvmConsts.OracleTypePriceFeed }
+
+func (p *PriceFeedParameters) Verify() error {
+	if p.Window <= 0 {
+		return fmt.Errorf("%w: price feed window must be positive, got %d", ErrInvalidOracleParameters, p.Window)
+	}
+	if p.Threshold == 0 {
+		return fmt.Errorf("%w: price feed threshold cannot be zero", ErrInvalidOracleParameters)
+	}
+	if p.Publisher == codec.EmptyAddress {
+		return fmt.Errorf("%w: price feed publisher must be set", ErrInvalidOracleParameters)
+	}
+	return nil
+}
+
+// Bytes serializes the parameters for use as a market's OracleParameters.
+func (p *PriceFeedParameters) Bytes() []byte { return encodeOracleParameters(OracleParametersV1, p) }
+
+func encodeOracleParameters(version byte, p OracleParameters) []byte {
+	writer := codec.NewWriter(0, MaxOracleParametersSize)
+	writer.Packer.PackByte(version)
+	if err := codec.LinearCodec.MarshalInto(p, writer.Packer); err != nil {
+		panic(fmt.Errorf("failed to marshal oracle parameters: %w", err))
+	}
+	return writer.Bytes()
+}
+
+// DecodeOracleParameters decodes the OracleParameters of a market with the
+// given oracle type. It does not Verify the decoded parameters.
+func DecodeOracleParameters(oracleType uint8, b []byte) (OracleParameters, error) {
+	var p OracleParameters
+	switch oracleType {
+	case pvmConsts.OracleTypeManual:
+		if len(b) == 0 {
+			return &ManualParameters{}, nil
+		}
+		p = &ManualParameters{}
+	case pvmConsts.OracleTypePriceFeed:
+		p = &PriceFeedParameters{}
+	default:
+		return nil, fmt.Errorf("%w: %d", ErrUnknownOracleType, oracleType)
+	}
+
+	if len(b) == 0 {
+		return nil, fmt.Errorf("%w: missing parameters for oracle type %d", ErrMalformedOracleParameters, oracleType)
+	}
+	if len(b) > MaxOracleParametersSize {
+		return nil, fmt.Errorf("%w: %d bytes exceeds the maximum of %d", ErrMalformedOracleParameters, len(b), MaxOracleParametersSize)
+	}
+	if b[0] != OracleParametersV1 {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownOracleParametersVersion, b[0])
+	}
+	reader := codec.NewReader(b[1:], MaxOracleParametersSize)
+	if err := codec.LinearCodec.UnmarshalFrom(reader.Packer, p); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformedOracleParameters, err)
+	}
+	if reader.Offset() != len(b)-1 {
+		return nil, fmt.Errorf("%w: %d trailing bytes", ErrMalformedOracleParameters, len(b)-1-reader.Offset())
+	}
+	return p, nil
+}
+
+// DecodePriceFeedParameters decodes the OracleParameters of a price-feed market.
+func DecodePriceFeedParameters(b []byte) (*PriceFeedParameters, error) {
+	p, err := DecodeOracleParameters(pvmConsts.OracleTypePriceFeed, b)
+	if err != nil {
+		return nil, err
+	}
+	return p.(*PriceFeedParameters), nil
+}
+
+// DecodeManualParameters decodes the OracleParameters of a manual market.
+func DecodeManualParameters(b []byte) (*ManualParameters, error) {
+	p, err := DecodeOracleParameters(pvmConsts.OracleTypeManual, b)
+	if err != nil {
+		return nil, err
+	}
+	return p.(*ManualParameters), nil
+}
@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+
+	"github.com/ava-labs/avalanchego/database"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/ava-labs/hypersdk/state"
+)
+
+const (
+	// MaxOracleStatsSize defines the maximum size of marshaled oracle statistics.
+	MaxOracleStatsSize = 64
+
+	// OracleStatsChunks is the number of 64-byte chunks reserved for oracle statistics.
+	OracleStatsChunks uint16 = 1
+
+	// MaxReputation is the reputation of an oracle that never lost a dispute,
+	// in basis points.
+	MaxReputation uint16 = 10_000
+
+	// MinReputationSample is the number of resolutions an oracle needs before
+	// it earns a reputation.
+	MinReputationSample uint64 = 10
+)
+
+// OracleStats is the track record of an oracle address.
+type OracleStats struct {
+	Resolved     uint64 `serialize:"true" json:"resolved"`     // Markets resolved by the oracle
+	Disputed     uint64 `serialize:"true" json:"disputed"`     // Rulings of the oracle that were disputed
+	DisputesLost uint64 `serialize:"true" json:"disputesLost"` // Disputes that overturned the oracle's ruling
+	TotalLatency uint64 `serialize:"true" json:"totalLatency"` // Sum of resolution delays after ResolutionTime, in milliseconds
+
+	SettledCollateral    uint64 `serialize:"true" json:"settledCollateral"`    // Collateral of the markets resolved by the oracle
+	OverturnedCollateral uint64 `serialize:"true" json:"overturnedCollateral"` // Collateral of the markets whose ruling was overturned
+}
+
+// AverageLatency returns how long after ResolutionTime the oracle resolves
+// markets on average, in milliseconds.
+func (s *OracleStats) AverageLatency() uint64 {
+	if s.Resolved == 0 {
+		return 0
+	}
+	return s.TotalLatency / s.Resolved
+}
+
+// Reputation returns the share of the collateral settled by the oracle whose
+// ruling was not overturned, in basis points. Weighting by collateral keeps
+// an oracle from building a reputation on empty markets. An oracle with fewer
+// than MinReputationSample resolutions has no reputation.
+func (s *OracleStats) Reputation() uint16 {
+	if s.Resolved < MinReputationSample || s.SettledCollateral == 0 || s.OverturnedCollateral >= s.SettledCollateral {
+		return 0
+	}
+	hi, lo := bits.Mul64(s.SettledCollateral-s.OverturnedCollateral, uint64(MaxReputation))
+	reputation, _ := bits.Div64(hi, lo, s.SettledCollateral)
+	return uint16(reputation)
+}
+
+// OracleStatsKey generates the state key for an oracle's statistics.
+// Format: OracleStatsPrefix | Oracle (codec.Address) | Chunks (uint16)
+func OracleStatsKey(oracle codec.Address) []byte {
+	key := make([]byte, 1+codec.AddressLen+2)
+	key[0] = OracleStatsPrefix
+	copy(key[1:], oracle[:])
+	binary.BigEndian.PutUint16(key[1+codec.AddressLen:], OracleStatsChunks)
+	return key
+}
+
+// GetOracleStats retrieves an oracle's statistics. Oracles without a track
+// record have zero statistics.
+func GetOracleStats(ctx context.Context, im state.Immutable, oracle codec.Address) (*OracleStats, error) {
+	valBytes, err := im.GetValue(ctx, OracleStatsKey(oracle))
+	return innerGetOracleStats(valBytes, err, oracle)
+}
+
+// GetOracleStatsFromState retrieves an oracle's statistics through [f]. Used
+// to serve RPC queries.
+func GetOracleStatsFromState(ctx context.Context, f ReadState, oracle codec.Address) (*OracleStats, error) {
+	values, errs := f(ctx, [][]byte{OracleStatsKey(oracle)})
+	return innerGetOracleStats(values[0], errs[0], oracle)
+}
+
+func innerGetOracleStats(valBytes []byte, err error, oracle codec.Address) (*OracleStats, error) {
+	if errors.Is(err, database.ErrNotFound) {
+		return &OracleStats{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	reader := codec.NewReader(valBytes, MaxOracleStatsSize)
+	stats := &OracleStats{}
+	if err := codec.LinearCodec.UnmarshalFrom(reader.Packer, stats); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal stats of oracle %s: %w", oracle, err)
+	}
+	return stats, nil
+}
+
+// SetOracleStats stores an oracle's statistics.
+func SetOracleStats(ctx context.Context, mu state.Mutable, oracle codec.Address, stats *OracleStats) error {
+	writer := codec.NewWriter(0, MaxOracleStatsSize)
+	if err := codec.LinearCodec.MarshalInto(stats, writer.Packer); err != nil {
+		return fmt.Errorf("failed to marshal stats of oracle %s: %w", oracle, err)
+	}
+	if err := writer.Err(); err != nil {
+		return fmt.Errorf("writer error after marshaling stats of oracle %s: %w", oracle, err)
+	}
+	return mu.Insert(ctx, OracleStatsKey(oracle), writer.Bytes())
+}
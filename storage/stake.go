@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/ava-labs/avalanchego/database"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/ava-labs/hypersdk/state"
+)
+
+const (
+	// MaxStakeDataSize defines the maximum size of a marshaled stake.
+	MaxStakeDataSize = 64
+
+	// StakeChunks is the number of 64-byte chunks reserved for a stake.
+	StakeChunks uint16 = 1
+)
+
+// Stake is the PRED an address has locked to vote on appeals. A stake that
+// voted cannot be withdrawn before [LockedUntil], so the same tokens cannot
+// vote twice on an appeal.
+type Stake struct {
+	Amount      uint64 `serialize:"true" json:"amount"`
+	LockedUntil int64  `serialize:"true" json:"lockedUntil"`
+}
+
+// StakeKey generates the state key for an address's stake.
+// Format: StakePrefix | Staker (codec.Address) | Chunks (uint16)
+func StakeKey(staker codec.Address) []byte {
+	key := make([]byte, 1+codec.AddressLen+2)
+	key[0] = StakePrefix
+	copy(key[1:], staker[:])
+	binary.BigEndian.PutUint16(key[1+codec.AddressLen:], StakeChunks)
+	return key
+}
+
+// GetStake retrieves an address's stake. Addresses that never staked have an
+// empty stake.
+func GetStake(ctx context.Context, im state.Immutable, staker codec.Address) (*Stake, error) {
+	valBytes, err := im.GetValue(ctx, StakeKey(staker))
+	if errors.Is(err, database.ErrNotFound) {
+		return &Stake{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	reader := codec.NewReader(valBytes, MaxStakeDataSize)
+	stake := &Stake{}
+	if err := codec.LinearCodec.UnmarshalFrom(reader.Packer, stake); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal stake of %s: %w", staker, err)
+	}
+	return stake, nil
+}
+
+// SetStake stores an address's stake, removing it once fully withdrawn.
+func SetStake(ctx context.Context, mu state.Mutable, staker codec.Address, stake *Stake) error {
+	if stake.Amount == 0 {
+		return mu.Remove(ctx, StakeKey(staker))
+	}
+	writer := codec.NewWriter(0, MaxStakeDataSize)
+	if err := codec.LinearCodec.MarshalInto(stake, writer.Packer); err != nil {
+		return fmt.Errorf("failed to marshal stake of %s: %w", staker, err)
+	}
+	if err := writer.Err(); err != nil {
+		return fmt.Errorf("writer error after marshaling stake of %s: %w", staker, err)
+	}
+	return mu.Insert(ctx, StakeKey(staker), writer.Bytes())
+}
@@ -10,24 +10,73 @@ import (
 	"github.com/ava-labs/hypersdk/state"
 )
 
+// Storage prefixes. Every record type has its own prefix, starting after the
+// prefixes hypersdk reserves for chain metadata (see storage.go). Prefixes are
+// part of the on-disk layout: never reuse or renumber one.
 const (
 	// BalancePrefix is the prefix for storing native token balances for accounts.
-	// Format: BalancePrefix | Address -> uint64
-	BalancePrefix byte = 0x0
+	// Format: BalancePrefix | Address (codec.Address) | Chunks (uint16) -> uint64
+	BalancePrefix byte = 0x3
 
-	// MarketPrefix is the prefix for storing market data.
-	// Format: MarketPrefix | MarketID (uint64) -> Market (struct)
-	MarketPrefix byte = 0x1
+	// MarketPrefix is the prefix for storing market metadata.
+	// Format: MarketPrefix | MarketID (uint64) | Chunks (uint16) -> Market (versioned record)
+	MarketPrefix byte = 0x4
 
 	// ShareBalancePrefix is the prefix for storing user share balances.
-	// Format: ShareBalancePrefix | MarketID (uint64) | UserAddress (codec.Address) | ShareType (uint8) -> uint64 (amount)
-	ShareBalancePrefix byte = 0x2
-)
+	// Format: ShareBalancePrefix | MarketID (uint64) | UserAddress (codec.Address) | ShareType (uint8) | Chunks (uint16) -> uint64 (amount)
+	ShareBalancePrefix byte = 0x5
+
+	// FeedPrefix is the prefix for storing price feeds.
+	// Format: FeedPrefix | Publisher (codec.Address) | FeedID (uint64) | Chunks (uint16) -> Feed (struct)
+	FeedPrefix byte = 0x6
+
+	// IndexPrefix is the prefix for storing the entries of ID indexes.
+	// Format: IndexPrefix | Index (uint8) | Scope | ID (uint64) | Chunks (uint16) -> 1
+	IndexPrefix byte = 0x7
+
+	// OracleStatsPrefix is the prefix for storing per-oracle statistics.
+	// Format: OracleStatsPrefix | Oracle (codec.Address) | Chunks (uint16) -> OracleStats (struct)
+	OracleStatsPrefix byte = 0x8
+
+	// DisputePrefix is the prefix for storing market disputes.
+	// Format: DisputePrefix | MarketID (uint64) | Chunks (uint16) -> Dispute (struct)
+	DisputePrefix byte = 0x9
+
+	// StakePrefix is the prefix for storing PRED stakes used to vote on appeals.
+	// Format: StakePrefix | Staker (codec.Address) | Chunks (uint16) -> Stake (struct)
+	StakePrefix byte = 0xa
+
+	// AppealPrefix is the prefix for storing market appeals.
+	// Format: AppealPrefix | MarketID (uint64) | Chunks (uint16) -> Appeal (struct)
+	AppealPrefix byte = 0xb
+
+	// AppealVotePrefix is the prefix for storing appeal votes.
+	// Format: AppealVotePrefix | MarketID (uint64) | Voter (codec.Address) | Chunks (uint16) -> Overturn (bool)
+	AppealVotePrefix byte = 0xc
+
+	// FeedObservationPrefix is the prefix for storing the observations of
+	// price feeds.
+	// Format: FeedObservationPrefix | Publisher (codec.Address) | FeedID (uint64) | Seq (uint64) | Chunks (uint16) -> PriceObservation (struct)
+	FeedObservationPrefix byte = 0xd
+
+	// PaidCollateralPrefix is the prefix for storing the collateral each
+	// holder paid into a market.
+	// Format: PaidCollateralPrefix | MarketID (uint64) | UserAddress (codec.Address) | Chunks (uint16) -> uint64 (amount)
+	PaidCollateralPrefix byte = 0xe
 
-var (
-	ErrInsufficientBalance = errors.New("insufficient balance")
+	// MarketStatePrefix is the prefix for storing the mutable state of markets.
+	// Format: MarketStatePrefix | MarketID (uint64) | Chunks (uint16) -> MarketState (struct)
+	MarketStatePrefix byte = 0xf
+
+	// MarketSummaryPrefix is the prefix for storing the summaries of swept
+	// markets.
+	// Format: MarketSummaryPrefix | MarketID (uint64) | Chunks (uint16) -> MarketSummary (struct)
+	MarketSummaryPrefix byte = 0x10
 )
 
+// BalanceChunks is the number of 64-byte chunks reserved for a balance.
+const BalanceChunks uint16 = 1
+
 // GetBalance retrieves the native token balance for a given address.
 func GetBalance(ctx context.Context, im state.Immutable, addr codec.Address) (uint64, error) {
 	key := BalanceKey(addr) // Use canonical BalanceKey function
@@ -42,16 +91,20 @@ func GetBalance(ctx context.Context, im state.Immutable, addr codec.Address) (ui
 		return 0, nil
 	}
 	reader := codec.NewReader(valBytes, len(valBytes))
-	balance := reader.UnpackUint64(true) // true for required
+	balance := reader.UnpackUint64(false) // Zeroes are no longer stored, but older records may hold one
 	if errs := reader.Err(); errs != nil {
 		return 0, errs
 	}
 	return balance, nil
 }
 
-// SetBalance sets the native token balance for a given address.
+// SetBalance sets the native token balance for a given address, removing it
+// once it reaches zero.
 func SetBalance(ctx context.Context, mu state.Mutable, addr codec.Address, amount uint64) error {
 	key := BalanceKey(addr) // Use canonical BalanceKey function
+	if amount == 0 {
+		return mu.Remove(ctx, key)
+	}
 	writer := codec.NewWriter(8, 8) // Use literal 8 for uint64 length (8 bytes)
 	writer.PackUint64(amount)
 	if errs := writer.Err(); errs != nil {
@@ -61,14 +114,14 @@ func SetBalance(ctx context.Context, mu state.Mutable, addr codec.Address, amoun
 }
 
 // DeductBalance subtracts an amount from an address's native token balance.
-// It returns ErrInsufficientBalance if the deduction is not possible.
+// It returns an InsufficientBalanceError if the deduction is not possible.
 func DeductBalance(ctx context.Context, mu state.Mutable, addr codec.Address, amount uint64) error {
 	currentBalance, err := GetBalance(ctx, mu, addr) // Pass ctx
 	if err != nil {
 		return err
 	}
 	if currentBalance < amount {
-		return ErrInsufficientBalance
+		return &InsufficientBalanceError{Address: addr, Balance: currentBalance, Required: amount}
 	}
 	newBalance := currentBalance - amount
 	return SetBalance(ctx, mu, addr, newBalance) // Pass ctx
@@ -85,28 +138,6 @@ func AddBalance(ctx context.Context, mu state.Mutable, addr codec.Address, amoun
 	return SetBalance(ctx, mu, addr, newBalance) // Pass ctx
 }
 
-// StateKeysBalance returns the state key for an address's native token balance.
-func StateKeysBalance(addr codec.Address) []byte {
-	key := make([]byte, 1+codec.AddressLen)
-	key[0] = BalancePrefix
-	copy(key[1:], addr[:])
-	return key
-}
-
-// AddressFromKey extracts an address from a balance state key.
-// Returns an error if the key is not a valid balance key.
-func AddressFromKey(key []byte) (codec.Address, error) {
-	if len(key) != 1+codec.AddressLen {
-		return codec.Address{}, errors.New("invalid key length")
-	}
-	if key[0] != BalancePrefix {
-		return codec.Address{}, errors.New("invalid prefix")
-	}
-	var addr codec.Address
-	copy(addr[:], key[1:])
-	return addr, nil
-}
-
 // EnsureActorHasBalance checks if an actor has at least a certain amount.
 // This is useful for pre-transaction checks if not covered by MaxCost.
 func EnsureActorHasBalance(ctx context.Context, im state.Immutable, actor codec.Address, required uint64) error {
@@ -115,7 +146,7 @@ func EnsureActorHasBalance(ctx context.Context, im state.Immutable, actor codec.
 		return err
 	}
 	if bal < required {
-		return ErrInsufficientBalance
+		return &InsufficientBalanceError{Address: actor, Balance: bal, Required: required}
 	}
 	return nil
 }
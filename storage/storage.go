@@ -5,12 +5,11 @@ package storage
 
 import (
 	"context"
-	"encoding/binary" // Added for BalanceKey
+	"encoding/binary"
 	"errors"
 
 	"github.com/ava-labs/avalanchego/database"
 	"github.com/ava-labs/hypersdk/codec"
-	userConsts "github.com/chokosabe/predictionvm/consts" // Added for BalanceKey
 )
 
 type ReadState func(context.Context, [][]byte) ([][]byte, []error)
@@ -22,13 +21,15 @@ type ReadState func(context.Context, [][]byte) ([][]byte, []error)
 //
 // 0x3/ (balance)
 //   -> [owner] => balance
+//
+// The remaining prefixes are listed in state.go.
 
 // [balancePrefix] + [address] + [chunkInfo]
 func BalanceKey(addr codec.Address) (k []byte) {
-	k = make([]byte, 1+codec.AddressLen+userConsts.Uint16Len)
-	k[0] = userConsts.BalancePrefix
+	k = make([]byte, 1+codec.AddressLen+2)
+	k[0] = BalancePrefix
 	copy(k[1:], addr[:])
-	binary.BigEndian.PutUint16(k[1+codec.AddressLen:], userConsts.BalanceChunks)
+	binary.BigEndian.PutUint16(k[1+codec.AddressLen:], BalanceChunks)
 	return
 }
 
@@ -60,7 +61,7 @@ func GetBalanceFromState(
 	f ReadState,
 	addr codec.Address,
 ) (uint64, error) {
-	k := StateKeysBalance(addr) // Replaced BalanceKey with StateKeysBalance
+	k := BalanceKey(addr)
 	values, errs := f(ctx, [][]byte{k})
 	bal, _, err := innerGetBalance(values[0], errs[0])
 	return bal, err
@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/ava-labs/avalanchego/database"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/ava-labs/hypersdk/state"
+)
+
+const (
+	// MaxMarketSummarySize defines the maximum size of a marshaled market
+	// summary.
+	MaxMarketSummarySize = 127
+
+	// MarketSummaryChunks is the number of 64-byte chunks reserved for a
+	// market summary.
+	MarketSummaryChunks uint16 = MaxMarketSummarySize/64 + 1
+)
+
+// MarketSummary is what remains of a market once it has been swept from
+// state, so that its history can still be shown.
+type MarketSummary struct {
+	ID              uint64        `serialize:"true" json:"id"`
+	Creator         codec.Address `serialize:"true" json:"creator"`
+	Oracle          codec.Address `serialize:"true" json:"oracle"`
+	EndTime         int64         `serialize:"true" json:"endTime"`
+	Status          MarketStatus  `serialize:"true" json:"status"`
+	ResolvedOutcome OutcomeType   `serialize:"true" json:"resolvedOutcome"`
+	ResolvedAt      int64         `serialize:"true" json:"resolvedAt"`
+	Tier            uint8         `serialize:"true" json:"tier"`
+	SweptAt         int64         `serialize:"true" json:"sweptAt"`
+}
+
+// MarketSummaryKey generates the state key for the summary of a swept market.
+// Format: MarketSummaryPrefix | MarketID (uint64) | Chunks (uint16)
+func MarketSummaryKey(marketID uint64) []byte {
+	key := make([]byte, 1+8+2)
+	key[0] = MarketSummaryPrefix
+	binary.BigEndian.PutUint64(key[1:], marketID)
+	binary.BigEndian.PutUint16(key[1+8:], MarketSummaryChunks)
+	return key
+}
+
+// GetMarketSummary retrieves the summary of a swept market.
+func GetMarketSummary(ctx context.Context, im state.Immutable, marketID uint64) (*MarketSummary, error) {
+	valBytes, err := im.GetValue(ctx, MarketSummaryKey(marketID))
+	return innerGetMarketSummary(valBytes, err, marketID)
+}
+
+// GetMarketSummaryFromState retrieves the summary of a swept market through
+// [f]. Used to serve RPC queries.
+func GetMarketSummaryFromState(ctx context.Context, f ReadState, marketID uint64) (*MarketSummary, error) {
+	values, errs := f(ctx, [][]byte{MarketSummaryKey(marketID)})
+	return innerGetMarketSummary(values[0], errs[0], marketID)
+}
+
+func innerGetMarketSummary(valBytes []byte, err error, marketID uint64) (*MarketSummary, error) {
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return nil, fmt.Errorf("summary of market %d not found: %w", marketID, err)
+		}
+		return nil, err
+	}
+	reader := codec.NewReader(valBytes, MaxMarketSummarySize)
+	summary := &MarketSummary{}
+	if err := codec.LinearCodec.UnmarshalFrom(reader.Packer, summary); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal summary of market %d: %w", marketID, err)
+	}
+	return summary, nil
+}
+
+// SetMarketSummary stores the summary of a swept market.
+func SetMarketSummary(ctx context.Context, mu state.Mutable, summary *MarketSummary) error {
+	writer := codec.NewWriter(0, MaxMarketSummarySize)
+	if err := codec.LinearCodec.MarshalInto(summary, writer.Packer); err != nil {
+		return fmt.Errorf("failed to marshal summary of market %d: %w", summary.ID, err)
+	}
+	if err := writer.Err(); err != nil {
+		return fmt.Errorf("writer error after marshaling summary of market %d: %w", summary.ID, err)
+	}
+	return mu.Insert(ctx, MarketSummaryKey(summary.ID), writer.Bytes())
+}
+
+// DeleteMarket removes [market] from state along with its status, creator and
+// end time index entries and its dispute and appeal records. Callers must
+// declare the keys SetMarket needs for the market's current status, plus
+// the dispute and appeal keys.
+func DeleteMarket(ctx context.Context, mu state.Mutable, market *Market) error {
+	for _, key := range MarketIndexKeys(market) {
+		if err := mu.Remove(ctx, key); err != nil {
+			return fmt.Errorf("failed to unindex market %d: %w", market.ID, err)
+		}
+	}
+	for _, key := range [][]byte{
+		MarketKey(market.ID),
+		MarketStateKey(market.ID),
+		DisputeKey(market.ID),
+		AppealKey(market.ID),
+	} {
+		if err := mu.Remove(ctx, key); err != nil {
+			return fmt.Errorf("failed to remove market %d: %w", market.ID, err)
+		}
+	}
+	return nil
+}
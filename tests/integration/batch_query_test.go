@@ -0,0 +1,73 @@
+// Copyright (C) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package integration_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ava-labs/hypersdk/chain/chaintest"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chokosabe/predictionvm/consts"
+	"github.com/chokosabe/predictionvm/storage"
+
+	predictionvm "github.com/chokosabe/predictionvm/vm"
+)
+
+// countingVM counts the ReadState calls made to a layoutVM.
+type countingVM struct {
+	layoutVM
+	reads int
+}
+
+func (v *countingVM) ReadState(ctx context.Context, keys [][]byte) ([][]byte, []error) {
+	v.reads++
+	return v.layoutVM.ReadState(ctx, keys)
+}
+
+func TestBatchQuery(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	mu := chaintest.NewInMemoryStore()
+	alice := codec.Address{0x0a}
+
+	require.NoError(storage.SetMarket(ctx, mu, fixtureMarket))
+	require.NoError(storage.SetBalance(ctx, mu, alice, 7))
+	require.NoError(storage.AddShares(ctx, mu, fixtureMarket.ID, alice, consts.NoShareType, 3))
+	vm := &countingVM{layoutVM: layoutVM{mu: mu}}
+	server := predictionvm.NewJSONRPCServer(vm)
+
+	reply := &predictionvm.BatchQueryReply{}
+	require.NoError(server.BatchQuery(httptest.NewRequest("POST", "/", nil), &predictionvm.BatchQueryArgs{
+		Queries: []predictionvm.Query{
+			{Type: predictionvm.QueryBalance, Address: alice},
+			{Type: predictionvm.QueryMarket, MarketID: 7},
+			{Type: predictionvm.QueryShareBalance, MarketID: fixtureMarket.ID, Address: alice, ShareType: consts.NoShareType},
+			{Type: predictionvm.QueryShareBalance, MarketID: fixtureMarket.ID, Address: alice, ShareType: 2},
+			{Type: predictionvm.QueryMarket, MarketID: fixtureMarket.ID},
+			{Type: "order"},
+			{Type: predictionvm.QueryBalance, Address: codec.Address{0x0b}},
+		},
+	}, reply))
+	require.Equal(1, vm.reads)
+	require.Len(reply.Results, 7)
+
+	require.Equal(&predictionvm.QueryResult{Amount: 7}, reply.Results[0])
+	require.Equal(storage.CodeMarketNotFound, reply.Results[1].Code)
+	require.Equal((&storage.MarketNotFoundError{MarketID: 7}).Error(), reply.Results[1].Error)
+	require.Equal(&predictionvm.QueryResult{Amount: 3}, reply.Results[2])
+	require.Contains(reply.Results[3].Error, predictionvm.ErrInvalidShareType.Error())
+	require.Empty(reply.Results[4].Error)
+	require.Equal(fixtureMarket, reply.Results[4].Market.Market)
+	require.Contains(reply.Results[5].Error, predictionvm.ErrUnknownQueryType.Error())
+	require.Equal(&predictionvm.QueryResult{}, reply.Results[6])
+
+	err := server.BatchQuery(httptest.NewRequest("POST", "/", nil), &predictionvm.BatchQueryArgs{
+		Queries: make([]predictionvm.Query, predictionvm.MaxBatchQueries+1),
+	}, reply)
+	require.ErrorIs(err, predictionvm.ErrTooManyQueries)
+}
@@ -0,0 +1,47 @@
+// Copyright (C) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package integration_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/database"
+	"github.com/ava-labs/hypersdk/chain/chaintest"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chokosabe/predictionvm/consts"
+	"github.com/chokosabe/predictionvm/storage"
+)
+
+func TestStorageErrors(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	mu := chaintest.NewInMemoryStore()
+
+	_, err := storage.GetMarket(ctx, mu, 7)
+	var notFound *storage.MarketNotFoundError
+	require.ErrorAs(err, &notFound)
+	require.Equal(uint64(7), notFound.MarketID)
+	require.ErrorIs(err, database.ErrNotFound, "Callers checking for a missing key still match")
+	require.Equal(storage.CodeMarketNotFound, storage.ErrorCode(fmt.Errorf("wrapped: %w", err)))
+
+	require.NoError(storage.AddShares(ctx, mu, 7, layoutAddr, consts.NoShareType, 5))
+	err = storage.DeductShares(ctx, mu, 7, layoutAddr, consts.NoShareType, 6)
+	var shares *storage.InsufficientSharesError
+	require.ErrorAs(err, &shares)
+	require.Equal(storage.InsufficientSharesError{MarketID: 7, Holder: layoutAddr, ShareType: consts.NoShareType, Balance: 5, Required: 6}, *shares)
+	require.Equal(storage.CodeInsufficientShares, storage.ErrorCode(err))
+
+	err = storage.DeductBalance(ctx, mu, layoutAddr, 1)
+	require.ErrorIs(err, storage.ErrInsufficientBalance)
+	require.Equal(storage.CodeInsufficientBalance, storage.ErrorCode(err))
+
+	err = (&storage.MarketState{Status: storage.MarketStatus_Open, EndTime: 10}).CheckTrading(7, 11)
+	require.ErrorIs(err, storage.ErrMarketClosed)
+	require.Equal(storage.CodeMarketClosed, storage.ErrorCode(err))
+
+	require.Empty(storage.ErrorCode(database.ErrClosed))
+}
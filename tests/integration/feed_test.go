@@ -0,0 +1,109 @@
+// Copyright (C) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package integration_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/database"
+	"github.com/ava-labs/avalanchego/trace"
+	"github.com/ava-labs/hypersdk/api"
+	"github.com/ava-labs/hypersdk/chain"
+	"github.com/ava-labs/hypersdk/chain/chaintest"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/ava-labs/hypersdk/genesis"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chokosabe/predictionvm/consts"
+	"github.com/chokosabe/predictionvm/storage"
+
+	predictionvm "github.com/chokosabe/predictionvm/vm"
+)
+
+// feedVM serves JSONRPCServer feed queries from an in-memory store under the
+// default rules.
+type feedVM struct {
+	api.VM
+	mu *chaintest.InMemoryStore
+}
+
+func (*feedVM) Tracer() trace.Tracer { return trace.Noop }
+
+func (v *feedVM) ReadState(ctx context.Context, keys [][]byte) ([][]byte, []error) {
+	values := make([][]byte, len(keys))
+	errs := make([]error, len(keys))
+	for i, key := range keys {
+		values[i], errs[i] = v.mu.GetValue(ctx, key)
+	}
+	return values, errs
+}
+
+// LastAcceptedBlock returns the genesis block.
+func (*feedVM) LastAcceptedBlock(context.Context) (*chain.StatelessBlock, error) {
+	return &chain.StatelessBlock{}, nil
+}
+
+func (*feedVM) GetRuleFactory() chain.RuleFactory {
+	return &genesis.ImmutableRuleFactory{Rules: genesis.NewDefaultRules()}
+}
+
+func TestFeedObservation(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	mu := chaintest.NewInMemoryStore()
+	server := predictionvm.NewJSONRPCServer(&feedVM{mu: mu})
+	publisher := codec.Address{0x0f}
+
+	feed := &storage.Feed{ID: 4, Publisher: publisher}
+	for _, obs := range []storage.PriceObservation{
+		{Timestamp: 1_000, Price: 10},
+		{Timestamp: 2_000, Price: 20},
+		{Timestamp: 4_000, Price: 40},
+	} {
+		require.NoError(storage.AppendObservation(ctx, mu, feed, obs.Timestamp, obs.Price, consts.DefaultFeedCapacity))
+	}
+
+	// Feed 5 has wrapped around its ring buffer, so its first observations
+	// were overwritten.
+	wrapped := &storage.Feed{ID: 5, Publisher: publisher}
+	for i := int64(0); i < int64(consts.DefaultFeedCapacity)+2; i++ {
+		require.NoError(storage.AppendObservation(ctx, mu, wrapped, (i+1)*1_000, 10, consts.DefaultFeedCapacity))
+	}
+
+	observationAt := func(publisher codec.Address, feedID uint64, t int64) (*predictionvm.FeedObservationReply, error) {
+		reply := &predictionvm.FeedObservationReply{}
+		err := server.FeedObservation(httptest.NewRequest("POST", "/", nil), &predictionvm.FeedObservationArgs{Publisher: publisher, FeedID: feedID, Time: t}, reply)
+		return reply, err
+	}
+	for _, tc := range []struct {
+		time     int64
+		sequence uint64
+		price    uint64
+	}{
+		{time: 1_000, sequence: 0, price: 10},
+		{time: 1_999, sequence: 0, price: 10},
+		{time: 2_000, sequence: 1, price: 20},
+		{time: 3_500, sequence: 1, price: 20},
+		{time: 9_000, sequence: 2, price: 40},
+	} {
+		reply, err := observationAt(publisher, 4, tc.time)
+		require.NoError(err)
+		require.Equal(tc.sequence, reply.Sequence, "time %d", tc.time)
+		require.Equal(tc.price, reply.Observation.Price, "time %d", tc.time)
+		require.Equal(consts.DefaultFeedCapacity, reply.Capacity)
+	}
+
+	_, err := observationAt(publisher, 4, 999)
+	require.ErrorIs(err, storage.ErrWindowNotCovered)
+	_, err = observationAt(codec.Address{0x01}, 4, 2_000)
+	require.ErrorIs(err, database.ErrNotFound, "Feeds are namespaced by publisher")
+
+	_, err = observationAt(publisher, 5, 1_500)
+	require.ErrorIs(err, storage.ErrObservationOverwritten)
+	reply, err := observationAt(publisher, 5, 3_500)
+	require.NoError(err)
+	require.Equal(uint64(2), reply.Sequence)
+}
@@ -0,0 +1,404 @@
+// Copyright (C) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package integration_test
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/trace"
+	"github.com/ava-labs/avalanchego/x/merkledb"
+	"github.com/ava-labs/hypersdk/chain/chaintest"
+	"github.com/ava-labs/hypersdk/codec"
+	hgenesis "github.com/ava-labs/hypersdk/genesis"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chokosabe/predictionvm/actions"
+	"github.com/chokosabe/predictionvm/consts"
+	"github.com/chokosabe/predictionvm/controller"
+	"github.com/chokosabe/predictionvm/genesis"
+	"github.com/chokosabe/predictionvm/storage"
+)
+
+// genesisWithMarkets returns a genesis allocating [balance] to layoutAddr and
+// seeding [markets].
+func genesisWithMarkets(balance uint64, markets ...genesis.GenesisMarket) *genesis.Genesis {
+	gen := genesis.NewGenesis([]genesis.Allocation{{Address: genesis.FormatAddress(layoutAddr), Balance: balance}})
+	gen.Custom.Markets = markets
+	return gen
+}
+
+func TestGenesis_SeedsMarkets(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	resolver := codec.Address{0x05}
+
+	gen := genesisWithMarkets(100,
+		genesis.GenesisMarket{
+			ID:                1,
+			Question:          "Will the genesis market resolve Yes?",
+			ClosingTime:       5_000,
+			ResolutionTime:    10_000,
+			CollateralAssetID: consts.Symbol,
+			Creator:           genesis.FormatAddress(layoutAddr),
+			OracleType:        consts.OracleTypeManual,
+			Resolver:          genesis.FormatAddress(resolver),
+			InitialLiquidity:  40,
+		},
+		genesis.GenesisMarket{
+			ID:             2,
+			Question:       "Will feed 3 trade at or above 7?",
+			ClosingTime:    5_000,
+			ResolutionTime: 10_000,
+			OracleType:     consts.OracleTypePriceFeed,
+			PriceFeed:      &storage.PriceFeedParameters{FeedID: 3, Window: 1_000, Threshold: 7, Publisher: resolver},
+		},
+	)
+	mu := chaintest.NewInMemoryStore()
+	require.NoError(gen.InitializeState(ctx, trace.Noop, mu, controller.New()))
+
+	// The initial liquidity came out of the creator's allocation.
+	balance, err := storage.GetBalance(ctx, mu, layoutAddr)
+	require.NoError(err)
+	require.Equal(uint64(60), balance)
+
+	manual, err := storage.GetMarket(ctx, mu, 1)
+	require.NoError(err)
+	require.Equal(storage.MarketStatus_Open, manual.Status)
+	require.Equal(layoutAddr, manual.Creator)
+	require.Equal(resolver, manual.Oracle)
+	require.Equal(int64(5_000), manual.EndTime)
+	require.Equal(int64(10_000), manual.ResolutionTime)
+	require.Equal(uint64(40), manual.Collateral)
+	require.Equal(uint64(40), manual.TotalYesShares)
+	require.Equal(uint64(40), manual.TotalNoShares)
+	for _, shareType := range []uint8{consts.YesShareType, consts.NoShareType} {
+		shares, err := storage.GetShareBalance(ctx, mu, 1, layoutAddr, shareType)
+		require.NoError(err)
+		require.Equal(uint64(40), shares)
+	}
+	paid, err := storage.GetPaidCollateral(ctx, mu, 1, layoutAddr)
+	require.NoError(err)
+	require.Equal(uint64(40), paid, "The creator is refunded its liquidity if the market is")
+
+	feed, err := storage.GetMarket(ctx, mu, 2)
+	require.NoError(err)
+	require.Equal(consts.OracleTypePriceFeed, feed.OracleType)
+	require.Equal((&storage.PriceFeedParameters{FeedID: 3, Window: 1_000, Threshold: 7, Publisher: resolver}).Bytes(), feed.OracleParameters)
+	require.Zero(feed.Collateral)
+
+	// Both markets are indexed like markets created by CreateMarket.
+	for _, id := range []uint64{1, 2} {
+		open, err := storage.IsIndexed(ctx, mu, storage.IndexKey(storage.StatusIndex(storage.MarketStatus_Open), id))
+		require.NoError(err)
+		require.True(open)
+		pending, err := storage.IsIndexed(ctx, mu, storage.IndexKey(storage.PendingResolutionIndex, id))
+		require.NoError(err)
+		require.True(pending)
+	}
+
+	// Nodes copy the same entries into their market index.
+	indexKeys, err := gen.IndexKeys()
+	require.NoError(err)
+	var stored []string
+	for key := range mu.Storage {
+		if key[0] == storage.IndexPrefix {
+			stored = append(stored, key)
+		}
+	}
+	require.Len(indexKeys, len(stored))
+	for _, key := range indexKeys {
+		require.Contains(stored, string(key))
+	}
+}
+
+func TestGenesis_InvalidMarkets(t *testing.T) {
+	valid := func() genesis.GenesisMarket {
+		return genesis.GenesisMarket{
+			ID:             1,
+			Question:       "Will the genesis market resolve Yes?",
+			ClosingTime:    5_000,
+			ResolutionTime: 10_000,
+			Creator:        genesis.FormatAddress(layoutAddr),
+			OracleType:     consts.OracleTypeManual,
+		}
+	}
+
+	testCases := []struct {
+		name        string
+		markets     func(genesis.GenesisMarket) []genesis.GenesisMarket
+		params      genesis.ProtocolParams
+		timestamp   int64
+		expectedErr error
+		verifyErr   error // Defaults to expectedErr
+	}{
+		{
+			name:        "DuplicateID",
+			markets:     func(m genesis.GenesisMarket) []genesis.GenesisMarket { return []genesis.GenesisMarket{m, m} },
+			expectedErr: genesis.ErrDuplicateGenesisMarket,
+		},
+		{
+			name: "EmptyQuestion",
+			markets: func(m genesis.GenesisMarket) []genesis.GenesisMarket {
+				m.Question = ""
+				return []genesis.GenesisMarket{m}
+			},
+			expectedErr: genesis.ErrInvalidGenesisMarket,
+		},
+		{
+			name: "ResolutionBeforeClose",
+			markets: func(m genesis.GenesisMarket) []genesis.GenesisMarket {
+				m.ResolutionTime = m.ClosingTime
+				return []genesis.GenesisMarket{m}
+			},
+			expectedErr: genesis.ErrInvalidGenesisMarket,
+		},
+		{
+			name: "UnsupportedCollateral",
+			markets: func(m genesis.GenesisMarket) []genesis.GenesisMarket {
+				m.CollateralAssetID = "USDC"
+				return []genesis.GenesisMarket{m}
+			},
+			expectedErr: genesis.ErrInvalidGenesisMarket,
+		},
+		{
+			name: "ManualWithoutResolver",
+			markets: func(m genesis.GenesisMarket) []genesis.GenesisMarket {
+				m.Creator = ""
+				return []genesis.GenesisMarket{m}
+			},
+			expectedErr: genesis.ErrInvalidGenesisMarket,
+		},
+		{
+			name: "PriceFeedWithoutFeed",
+			markets: func(m genesis.GenesisMarket) []genesis.GenesisMarket {
+				m.OracleType = consts.OracleTypePriceFeed
+				return []genesis.GenesisMarket{m}
+			},
+			expectedErr: genesis.ErrInvalidGenesisMarket,
+		},
+		{
+			name: "LiquidityAboveAllocation",
+			markets: func(m genesis.GenesisMarket) []genesis.GenesisMarket {
+				m.InitialLiquidity = 101
+				return []genesis.GenesisMarket{m}
+			},
+			expectedErr: storage.ErrInsufficientBalance,
+			verifyErr:   genesis.ErrInvalidGenesisMarket,
+		},
+		{
+			name:        "QuestionAboveParam",
+			markets:     func(m genesis.GenesisMarket) []genesis.GenesisMarket { return []genesis.GenesisMarket{m} },
+			params:      genesis.ProtocolParams{MaxDescriptionSize: 8},
+			expectedErr: genesis.ErrInvalidGenesisMarket,
+		},
+		{
+			name: "OracleParametersAboveParam",
+			markets: func(m genesis.GenesisMarket) []genesis.GenesisMarket {
+				m.Resolver = genesis.FormatAddress(codec.Address{0x05})
+				return []genesis.GenesisMarket{m}
+			},
+			params:      genesis.ProtocolParams{MaxOracleParametersSize: 8},
+			expectedErr: actions.ErrOracleParametersTooLong,
+		},
+		{
+			name: "ClosesAtGenesis",
+			markets: func(m genesis.GenesisMarket) []genesis.GenesisMarket {
+				m.ClosingTime = 5_000
+				return []genesis.GenesisMarket{m}
+			},
+			timestamp:   5,
+			expectedErr: genesis.ErrInvalidGenesisMarket,
+		},
+		{
+			name:        "DurationBelowMin",
+			markets:     func(m genesis.GenesisMarket) []genesis.GenesisMarket { return []genesis.GenesisMarket{m} },
+			params:      genesis.ProtocolParams{MinMarketDuration: 6_000},
+			expectedErr: actions.ErrMarketDurationTooShort,
+		},
+		{
+			name:        "DurationAboveMax",
+			markets:     func(m genesis.GenesisMarket) []genesis.GenesisMarket { return []genesis.GenesisMarket{m} },
+			params:      genesis.ProtocolParams{MaxMarketDuration: 4_000},
+			expectedErr: actions.ErrMarketDurationTooLong,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gen := genesisWithMarkets(100, tc.markets(valid())...)
+			gen.Params = tc.params
+			gen.Timestamp = tc.timestamp
+			err := gen.InitializeState(context.Background(), trace.Noop, chaintest.NewInMemoryStore(), controller.New())
+			require.ErrorIs(t, err, tc.expectedErr)
+			// `genesis validate` rejects it as well.
+			verifyErr := tc.verifyErr
+			if verifyErr == nil {
+				verifyErr = tc.expectedErr
+			}
+			_, err = gen.Verify()
+			require.ErrorIs(t, err, verifyErr)
+		})
+	}
+}
+
+func TestGenesis_FactoryLoad(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	gen := genesisWithMarkets(100, genesis.GenesisMarket{
+		ID:             1,
+		Question:       "Will the genesis market resolve Yes?",
+		ClosingTime:    5_000,
+		ResolutionTime: 10_000,
+		Creator:        genesis.FormatAddress(layoutAddr),
+		OracleType:     consts.OracleTypeManual,
+	})
+	gen.Magic = 7
+	gen.Rules.MinBlockGap = 250
+	genesisBytes, err := json.Marshal(gen)
+	require.NoError(err)
+
+	chainID := ids.GenerateTestID()
+	loaded, ruleFactory, err := genesis.Factory{}.Load(genesisBytes, nil, 5, chainID)
+	require.NoError(err)
+	require.IsType(&genesis.Genesis{}, loaded)
+	require.Equal(uint64(7), loaded.(*genesis.Genesis).GetMagic())
+	require.Equal(merkledb.BranchFactor16, loaded.GetStateBranchFactor())
+
+	rules := ruleFactory.GetRules(0)
+	require.Equal(uint32(5), rules.GetNetworkID())
+	require.Equal(chainID, rules.GetChainID())
+	require.Equal(int64(250), rules.GetMinBlockGap())
+
+	mu := chaintest.NewInMemoryStore()
+	require.NoError(loaded.InitializeState(ctx, trace.Noop, mu, controller.New()))
+	_, err = storage.GetMarket(ctx, mu, 1)
+	require.NoError(err)
+
+	// Genesis files without rules get the hypersdk defaults.
+	loaded, ruleFactory, err = genesis.Factory{}.Load([]byte(`{"allocations":[]}`), nil, 5, chainID)
+	require.NoError(err)
+	require.Equal(merkledb.BranchFactor16, loaded.GetStateBranchFactor())
+	require.Equal(hgenesis.NewDefaultRules().MinBlockGap, ruleFactory.GetRules(0).GetMinBlockGap())
+}
+
+func TestGenesis_Default(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	gen := genesis.GetDefault(layoutAddr)
+	_, err := gen.Verify()
+	require.NoError(err)
+	mu := chaintest.NewInMemoryStore()
+	require.NoError(gen.InitializeState(ctx, trace.Noop, mu, controller.New()))
+
+	// The default market is resolved by its creator, who holds its key.
+	market, err := storage.GetMarket(ctx, mu, 1)
+	require.NoError(err)
+	require.Equal(consts.OracleTypeManual, market.OracleType)
+	require.Equal(layoutAddr, market.Creator)
+	require.Equal(layoutAddr, market.Oracle)
+}
+
+func TestGenesis_ProtocolParams(t *testing.T) {
+	require := require.New(t)
+
+	gen := genesis.NewGenesis(nil)
+	gen.Params = genesis.ProtocolParams{
+		DisputeBond:        5,
+		MinMarketDuration:  1_000,
+		MaxMarketDuration:  2_000,
+		MaxDescriptionSize: 64,
+		TradingFeeRate:     30,
+		MaxMarketDataSize:  512,
+	}
+	genesisBytes, err := json.Marshal(gen)
+	require.NoError(err)
+	_, ruleFactory, err := genesis.Factory{}.Load(genesisBytes, nil, 5, ids.Empty)
+	require.NoError(err)
+
+	// Actions see the parameters through FetchCustom, and the defaults where
+	// genesis leaves them unset.
+	rules := ruleFactory.GetRules(0)
+	require.Equal(uint64(5), actions.DisputeBond(rules))
+	require.Equal(int64(1_000), actions.MinMarketDuration(rules))
+	require.Equal(int64(2_000), actions.MaxMarketDuration(rules))
+	require.Equal(uint64(64), actions.MaxDescriptionSize(rules))
+	require.Equal(uint64(30), actions.TradingFeeRate(rules))
+	require.Equal(uint64(512), actions.MaxMarketDataSize(rules))
+	require.Equal(consts.DefaultClaimFeeRate, actions.ClaimFeeRate(rules))
+	require.Equal(consts.DefaultAppealBond, actions.AppealBond(rules))
+	require.Equal(consts.DefaultResolutionGracePeriod, actions.ResolutionGracePeriod(rules))
+	require.Equal(uint64(actions.CreateMarketComputeUnits), (&actions.CreateMarket{}).ComputeUnits(rules))
+
+	for name, params := range map[string]genesis.ProtocolParams{
+		"NegativeDisputeWindow":  {DisputeWindow: -1},
+		"MinAboveMaxDuration":    {MinMarketDuration: 2, MaxMarketDuration: 1},
+		"GracePeriodAboveLimit":  {ResolutionGracePeriod: consts.MaxResolutionGracePeriod + 1},
+		"DescriptionAboveLimit":  {MaxDescriptionSize: consts.MaxDescriptionSize + 1},
+		"OracleSourceAboveLimit": {MaxOracleSourceSize: consts.MaxOracleSourceSize + 1},
+		"ParametersAboveLimit":   {MaxOracleParametersSize: storage.MaxOracleParametersSize + 1},
+		"MarketDataAboveLimit":   {MaxMarketDataSize: consts.MaxMarketDataSize + 1},
+		"TradingFeeAboveMax":     {TradingFeeRate: consts.MaxFeeRate + 1},
+		"ClaimFeeAboveMax":       {ClaimFeeRate: consts.MaxFeeRate + 1},
+	} {
+		gen := genesis.NewGenesis(nil)
+		gen.Params = params
+		genesisBytes, err := json.Marshal(gen)
+		require.NoError(err)
+		_, _, err = genesis.Factory{}.Load(genesisBytes, nil, 5, ids.Empty)
+		require.ErrorIs(err, genesis.ErrInvalidProtocolParams, name)
+	}
+}
+
+func TestGenesis_Verify(t *testing.T) {
+	require := require.New(t)
+	other := genesis.FormatAddress(codec.Address{0x05})
+
+	gen := genesisWithMarkets(100, genesis.GenesisMarket{
+		ID:               1,
+		Question:         "Will the genesis market resolve Yes?",
+		ClosingTime:      5_000,
+		ResolutionTime:   10_000,
+		Creator:          genesis.FormatAddress(layoutAddr),
+		OracleType:       consts.OracleTypeManual,
+		InitialLiquidity: 60,
+	})
+	gen.Allocations = append(gen.Allocations,
+		genesis.Allocation{Address: other, Balance: 7},
+		genesis.Allocation{Address: genesis.FormatAddress(layoutAddr), Balance: 1},
+	)
+	summary, err := gen.Verify()
+	require.NoError(err)
+	require.Equal(2, summary.Accounts)
+	require.Equal(uint64(108), summary.Supply)
+	require.Len(summary.Markets, 1)
+	require.Equal(uint64(60), summary.Markets[0].Collateral)
+	require.Equal(hgenesis.NewDefaultRules(), summary.Rules)
+
+	// The liquidity of every market comes out of what is left to its creator.
+	gen.Custom.Markets = append(gen.Custom.Markets, gen.Custom.Markets[0])
+	gen.Custom.Markets[1].ID = 2
+	_, err = gen.Verify()
+	require.ErrorIs(err, genesis.ErrInvalidGenesisMarket)
+
+	gen = genesisWithMarkets(math.MaxUint64)
+	gen.Allocations = append(gen.Allocations, genesis.Allocation{Address: other, Balance: 1})
+	_, err = gen.Verify()
+	require.ErrorIs(err, genesis.ErrSupplyOverflow)
+
+	gen = genesisWithMarkets(1)
+	gen.Allocations[0].Address = "pred1bad"
+	_, err = gen.Verify()
+	require.ErrorIs(err, genesis.ErrInvalidAllocation)
+
+	// The factory refuses to start a chain from a genesis that fails Verify.
+	genesisBytes, err := json.Marshal(gen)
+	require.NoError(err)
+	_, _, err = genesis.Factory{}.Load(genesisBytes, nil, 5, ids.Empty)
+	require.ErrorIs(err, genesis.ErrInvalidAllocation)
+}
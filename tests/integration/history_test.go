@@ -0,0 +1,131 @@
+// Copyright (C) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package integration_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ava-labs/hypersdk/auth"
+	"github.com/ava-labs/hypersdk/chain"
+	"github.com/ava-labs/hypersdk/chain/chaintest"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/ava-labs/hypersdk/crypto/ed25519"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chokosabe/predictionvm/actions"
+	"github.com/chokosabe/predictionvm/consts"
+	"github.com/chokosabe/predictionvm/genesis"
+	"github.com/chokosabe/predictionvm/storage"
+
+	predictionvm "github.com/chokosabe/predictionvm/vm"
+)
+
+func TestStateHistory(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	mu := chaintest.NewInMemoryStore()
+	vm := &layoutVM{mu: mu}
+	history := predictionvm.NewStateHistory(vm, 3)
+
+	bobAuth := &auth.ED25519{Signer: ed25519.PublicKey{0x0b}}
+	carolAuth := &auth.ED25519{Signer: ed25519.PublicKey{0x0c}}
+	bob, carol, alice := bobAuth.Actor(), carolAuth.Actor(), codec.Address{0x0a}
+	require.NoError(storage.SetBalance(ctx, mu, alice, 100))
+	require.NoError(storage.SetBalance(ctx, mu, bob, 100))
+	require.NoError(storage.SetBalance(ctx, mu, carol, 100))
+	require.NoError(storage.SetMarket(ctx, mu, &storage.Market{ID: 1, Status: storage.MarketStatus_Open, EndTime: 10_000}))
+	index := vm.marketIndex(t)
+	server := predictionvm.NewJSONRPCServerWithIndex(vm, history, index)
+
+	// accept executes [action] as [signer] in a block at [height] and
+	// records the block.
+	accept := func(height uint64, signer *auth.ED25519, action chain.Action) {
+		tx, err := chain.NewTransaction(chain.Base{}, []chain.Action{action}, signer)
+		require.NoError(err)
+		_, err = action.Execute(ctx, genesis.NewGenesis(nil).RuleFactory().GetRules(1_000), mu, 1_000, signer.Actor(), tx.GetID())
+		require.NoError(err)
+		blk := &chain.ExecutedBlock{
+			Block:            &chain.StatelessBlock{Block: chain.Block{Hght: height, Txs: []*chain.Transaction{tx}}},
+			ExecutionResults: &chain.ExecutionResults{Results: []*chain.Result{{Success: true}}},
+		}
+		require.NoError(history.Accept(ctx, blk))
+		require.NoError(index.Accept(ctx, blk))
+	}
+	height := func(h uint64) *uint64 { return &h }
+	balanceAt := func(addr codec.Address, h *uint64) (uint64, error) {
+		reply := &predictionvm.BalanceReply{}
+		err := server.Balance(httptest.NewRequest("POST", "/", nil), &predictionvm.BalanceArgs{Address: addr, Height: h}, reply)
+		return reply.Amount, err
+	}
+
+	accept(1, bobAuth, &actions.BuyYes{MarketID: 1, Amount: 5, MaxPrice: 10})
+	accept(2, bobAuth, &actions.BuyYes{MarketID: 1, Amount: 3, MaxPrice: 10})
+
+	for _, tc := range []struct {
+		addr    codec.Address
+		height  *uint64
+		balance uint64
+	}{
+		{addr: bob, height: height(1), balance: 50},
+		{addr: bob, height: height(2), balance: 20},
+		{addr: bob, balance: 20},
+		// Untouched since the history started.
+		{addr: alice, height: height(1), balance: 100},
+	} {
+		balance, err := balanceAt(tc.addr, tc.height)
+		require.NoError(err)
+		require.Equal(tc.balance, balance)
+	}
+
+	market := &predictionvm.MarketReply{}
+	require.NoError(server.Market(httptest.NewRequest("POST", "/", nil), &predictionvm.MarketArgs{MarketID: 1, Height: height(1)}, market))
+	require.Equal(uint64(5), market.Market.TotalYesShares)
+	require.Equal(uint64(50), market.Market.Collateral)
+
+	shares := &predictionvm.ShareBalanceReply{}
+	require.NoError(server.ShareBalance(httptest.NewRequest("POST", "/", nil), &predictionvm.ShareBalanceArgs{MarketID: 1, Address: bob, ShareType: consts.YesShareType, Height: height(1)}, shares))
+	require.Equal(uint64(5), shares.Amount)
+
+	positions := &predictionvm.PositionsReply{}
+	require.NoError(server.Positions(httptest.NewRequest("POST", "/", nil), &predictionvm.PositionsArgs{Address: bob, Height: height(1)}, positions))
+	require.Equal([]*predictionvm.Position{
+		{MarketID: 1, ShareType: consts.YesShareType, Amount: 5, MarketStatus: storage.MarketStatus_Open},
+	}, positions.Positions)
+
+	_, err := balanceAt(bob, height(0))
+	require.ErrorIs(err, predictionvm.ErrHeightPruned)
+	_, err = balanceAt(bob, height(3))
+	require.ErrorIs(err, predictionvm.ErrHeightNotAccepted)
+
+	// Carol's balance before the first recorded block touching it is not
+	// known.
+	accept(3, carolAuth, &actions.BuyYes{MarketID: 1, Amount: 1, MaxPrice: 10})
+	_, err = balanceAt(carol, height(2))
+	require.ErrorIs(err, predictionvm.ErrHeightPruned)
+
+	// Only the last three heights are retained, and bob's balance as of the
+	// oldest of them survives the pruning of the block that set it.
+	accept(4, carolAuth, &actions.BuyYes{MarketID: 1, Amount: 1, MaxPrice: 10})
+	accept(5, carolAuth, &actions.BuyYes{MarketID: 1, Amount: 1, MaxPrice: 10})
+	_, err = balanceAt(bob, height(2))
+	require.ErrorIs(err, predictionvm.ErrHeightPruned)
+	balance, err := balanceAt(bob, height(3))
+	require.NoError(err)
+	require.Equal(uint64(20), balance)
+	balance, err = balanceAt(carol, height(4))
+	require.NoError(err)
+	require.Equal(uint64(80), balance)
+
+	// The index follows the accepted blocks.
+	positions = &predictionvm.PositionsReply{}
+	require.NoError(server.Positions(httptest.NewRequest("POST", "/", nil), &predictionvm.PositionsArgs{Address: carol}, positions))
+	require.Equal([]*predictionvm.Position{
+		{MarketID: 1, ShareType: consts.YesShareType, Amount: 3, MarketStatus: storage.MarketStatus_Open},
+	}, positions.Positions)
+
+	err = predictionvm.NewJSONRPCServer(vm).Balance(httptest.NewRequest("POST", "/", nil), &predictionvm.BalanceArgs{Address: bob, Height: height(5)}, &predictionvm.BalanceReply{})
+	require.ErrorIs(err, predictionvm.ErrHistoryDisabled)
+}
@@ -0,0 +1,199 @@
+// Copyright (C) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package integration_test
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/database/memdb"
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/trace"
+	"github.com/ava-labs/hypersdk/api"
+	"github.com/ava-labs/hypersdk/chain"
+	"github.com/ava-labs/hypersdk/chain/chaintest"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/ava-labs/hypersdk/state"
+	"github.com/ava-labs/hypersdk/state/metadata"
+	"github.com/btcsuite/btcd/btcutil/bech32"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chokosabe/predictionvm/actions"
+	"github.com/chokosabe/predictionvm/controller"
+	"github.com/chokosabe/predictionvm/genesis"
+	"github.com/chokosabe/predictionvm/storage"
+
+	predictionvm "github.com/chokosabe/predictionvm/vm"
+)
+
+// prefixes is the canonical storage prefix table. Changing an entry changes
+// where existing records live on disk, so it requires a state migration.
+var prefixes = map[string]byte{
+	"balance":         storage.BalancePrefix,
+	"market":          storage.MarketPrefix,
+	"shareBalance":    storage.ShareBalancePrefix,
+	"feed":            storage.FeedPrefix,
+	"index":           storage.IndexPrefix,
+	"oracleStats":     storage.OracleStatsPrefix,
+	"dispute":         storage.DisputePrefix,
+	"stake":           storage.StakePrefix,
+	"appeal":          storage.AppealPrefix,
+	"appealVote":      storage.AppealVotePrefix,
+	"feedObservation": storage.FeedObservationPrefix,
+	"paidCollateral":  storage.PaidCollateralPrefix,
+	"marketState":     storage.MarketStatePrefix,
+	"marketSummary":   storage.MarketSummaryPrefix,
+}
+
+var layoutAddr = codec.Address{0x01, 0x02}
+
+// layoutVM serves JSONRPCServer queries from an in-memory store.
+type layoutVM struct {
+	api.VM
+	mu *chaintest.InMemoryStore
+}
+
+func (*layoutVM) Tracer() trace.Tracer { return trace.Noop }
+
+func (v *layoutVM) ImmutableState(context.Context) (state.Immutable, error) {
+	return v.mu, nil
+}
+
+func (v *layoutVM) ReadState(ctx context.Context, keys [][]byte) ([][]byte, []error) {
+	values := make([][]byte, len(keys))
+	errs := make([]error, len(keys))
+	for i, key := range keys {
+		values[i], errs[i] = v.mu.GetValue(ctx, key)
+	}
+	return values, errs
+}
+
+// LastAcceptedBlock returns the genesis block.
+func (*layoutVM) LastAcceptedBlock(context.Context) (*chain.StatelessBlock, error) {
+	return &chain.StatelessBlock{}, nil
+}
+
+// marketIndex returns a market index holding the index entries of the store.
+func (v *layoutVM) marketIndex(t *testing.T) *predictionvm.MarketIndex {
+	index := predictionvm.NewMarketIndex(v, memdb.New(), nil)
+	keys := make([][]byte, 0, len(v.mu.Storage))
+	for key := range v.mu.Storage {
+		keys = append(keys, []byte(key))
+	}
+	require.NoError(t, index.Sync(context.Background(), keys))
+	return index
+}
+
+func TestStorageLayout_Prefixes(t *testing.T) {
+	require := require.New(t)
+	owners := make(map[byte]string, len(prefixes))
+	for name, prefix := range prefixes {
+		require.GreaterOrEqual(prefix, metadata.DefaultMinimumPrefix, "%s overlaps the hypersdk metadata prefixes", name)
+		other, ok := owners[prefix]
+		require.False(ok, "%s and %s share prefix %#x", name, other, prefix)
+		owners[prefix] = name
+	}
+	require.Equal(byte(0x3), storage.BalancePrefix)
+	require.Equal(byte(0xf), storage.MarketStatePrefix)
+}
+
+func TestStorageLayout_BalanceKey(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	// Prefix | Address | Chunks
+	expected := "03" + "0102" + strings.Repeat("00", codec.AddressLen-2) + "0001"
+	require.Equal(expected, hex.EncodeToString(storage.BalanceKey(layoutAddr)))
+
+	// Genesis allocations are credited through the controller.
+	data, err := bech32.ConvertBits(layoutAddr[:], 8, 5, true)
+	require.NoError(err)
+	bech32Addr, err := bech32.Encode("pred", data)
+	require.NoError(err)
+	gen := &genesis.Genesis{}
+	gen.Allocations = append(gen.Allocations, struct {
+		Address string `json:"address"`
+		Balance uint64 `json:"balance"`
+	}{Address: bech32Addr, Balance: 100})
+
+	mu := chaintest.NewInMemoryStore()
+	ctrl := controller.New()
+	require.NoError(gen.InitializeState(ctx, trace.Noop, mu, ctrl))
+	require.Contains(mu.Storage, string(storage.BalanceKey(layoutAddr)))
+
+	// The controller reads and charges fees from the same key.
+	require.Contains(ctrl.SponsorStateKeys(layoutAddr), string(storage.BalanceKey(layoutAddr)))
+	balance, err := ctrl.GetBalance(ctx, layoutAddr, mu)
+	require.NoError(err)
+	require.Equal(uint64(100), balance)
+
+	// As does the Balance RPC.
+	server := predictionvm.NewJSONRPCServer(&layoutVM{mu: mu})
+	reply := &predictionvm.BalanceReply{}
+	require.NoError(server.Balance(httptest.NewRequest("POST", "/", nil), &predictionvm.BalanceArgs{Address: layoutAddr}, reply))
+	require.Equal(uint64(100), reply.Amount)
+}
+
+func TestStorageLayout_ActionKeys(t *testing.T) {
+	require := require.New(t)
+	known := make(map[byte]bool, len(prefixes))
+	for _, prefix := range prefixes {
+		known[prefix] = true
+	}
+	for _, typed := range predictionvm.ActionParser.GetRegisteredTypes() {
+		action := typed.(chain.Action)
+		for key := range action.StateKeys(layoutAddr, ids.Empty) {
+			require.True(known[key[0]], "%T declares key %x outside the prefix table", action, key)
+			if key[0] == storage.BalancePrefix {
+				require.Equal(string(storage.BalanceKey(layoutAddr)), key, "%T declares a non-canonical balance key", action)
+			}
+		}
+	}
+}
+
+func TestStorageLayout_ActionsRoundTrip(t *testing.T) {
+	require := require.New(t)
+	for _, typed := range predictionvm.ActionParser.GetRegisteredTypes() {
+		action := typed.(chain.Action)
+		bytes := action.Bytes()
+		require.Equal(action.GetTypeID(), bytes[0], "%T does not prefix its type ID", action)
+		parsed, err := predictionvm.ActionParser.Unmarshal(bytes)
+		require.NoError(err, "%T", action)
+		require.IsType(action, parsed)
+		require.Equal(bytes, parsed.Bytes())
+	}
+	parsed, err := predictionvm.ActionParser.Unmarshal((&actions.BuyNo{MarketID: 3, Amount: 5, MaxPrice: 2}).Bytes())
+	require.NoError(err)
+	require.Equal(&actions.BuyNo{MarketID: 3, Amount: 5, MaxPrice: 2}, parsed)
+}
+
+func TestStorageLayout_ZeroBalancesAreRemoved(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	mu := chaintest.NewInMemoryStore()
+
+	require.NoError(storage.SetBalance(ctx, mu, layoutAddr, 100))
+	require.NoError(storage.DeductBalance(ctx, mu, layoutAddr, 100))
+	require.NotContains(mu.Storage, string(storage.BalanceKey(layoutAddr)))
+	balance, err := storage.GetBalance(ctx, mu, layoutAddr)
+	require.NoError(err)
+	require.Zero(balance)
+
+	require.NoError(storage.AddShares(ctx, mu, 1, layoutAddr, 0, 10))
+	require.NoError(storage.DeductShares(ctx, mu, 1, layoutAddr, 0, 10))
+	require.NotContains(mu.Storage, string(storage.ShareBalanceKey(1, layoutAddr, 0)))
+	require.NotContains(mu.Storage, string(storage.PositionIndexKey(layoutAddr, 0, 1)))
+	shares, err := storage.GetShareBalance(ctx, mu, 1, layoutAddr, 0)
+	require.NoError(err)
+	require.Zero(shares)
+
+	// Records holding a zero from before keys were removed still read.
+	require.NoError(mu.Insert(ctx, storage.BalanceKey(layoutAddr), make([]byte, 8)))
+	balance, err = storage.GetBalance(ctx, mu, layoutAddr)
+	require.NoError(err)
+	require.Zero(balance)
+}
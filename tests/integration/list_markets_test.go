@@ -0,0 +1,241 @@
+// Copyright (C) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package integration_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/database/memdb"
+	"github.com/ava-labs/hypersdk/chain"
+	"github.com/ava-labs/hypersdk/chain/chaintest"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chokosabe/predictionvm/storage"
+
+	predictionvm "github.com/chokosabe/predictionvm/vm"
+)
+
+func listMarkets(t *testing.T, server *predictionvm.JSONRPCServer, args *predictionvm.ListMarketsArgs) ([]uint64, uint64) {
+	t.Helper()
+	reply := &predictionvm.ListMarketsReply{}
+	require.NoError(t, server.ListMarkets(httptest.NewRequest("POST", "/", nil), args, reply))
+	marketIDs := make([]uint64, len(reply.Markets))
+	for i, market := range reply.Markets {
+		marketIDs[i] = market.ID
+	}
+	return marketIDs, reply.Next
+}
+
+func TestListMarkets(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	mu := chaintest.NewInMemoryStore()
+	alice, bob := codec.Address{0x0a}, codec.Address{0x0b}
+
+	// The markets span several end time buckets.
+	for id := uint64(1); id <= 8; id++ {
+		market := &storage.Market{
+			ID:          id * 33,
+			Description: "Listed market",
+			Status:      storage.MarketStatus_Open,
+			Creator:     alice,
+			EndTime:     int64(id) * storage.EndTimeBucket / 2,
+		}
+		if id%2 == 0 {
+			market.Creator = bob
+		}
+		require.NoError(storage.SetMarket(ctx, mu, market))
+		if id > 6 {
+			market.Status = storage.MarketStatus_ResolvedYes
+			require.NoError(storage.SetMarket(ctx, mu, market))
+		}
+	}
+	vm := &layoutVM{mu: mu}
+	server := predictionvm.NewJSONRPCServerWithIndex(vm, nil, vm.marketIndex(t))
+
+	all, next := listMarkets(t, server, &predictionvm.ListMarketsArgs{})
+	require.Equal([]uint64{33, 66, 99, 132, 165, 198, 231, 264}, all)
+	require.Zero(next)
+
+	open := storage.MarketStatus_Open
+	page, next := listMarkets(t, server, &predictionvm.ListMarketsArgs{Status: &open, Limit: 4})
+	require.Equal([]uint64{33, 66, 99, 132}, page)
+	require.Equal(uint64(132), next)
+	page, next = listMarkets(t, server, &predictionvm.ListMarketsArgs{Status: &open, Limit: 4, After: next})
+	require.Equal([]uint64{165, 198}, page)
+	require.Zero(next)
+
+	byCreator, _ := listMarkets(t, server, &predictionvm.ListMarketsArgs{Creator: &bob})
+	require.Equal([]uint64{66, 132, 198, 264}, byCreator)
+	byCreator, _ = listMarkets(t, server, &predictionvm.ListMarketsArgs{Creator: &bob, Status: &open})
+	require.Equal([]uint64{66, 132, 198}, byCreator)
+
+	// End times are 0.5, 1, 1.5, ... days; [1 day, 2 days) holds IDs 2 and 3.
+	byEndTime, _ := listMarkets(t, server, &predictionvm.ListMarketsArgs{
+		EndTimeFrom: storage.EndTimeBucket,
+		EndTimeTo:   2 * storage.EndTimeBucket,
+	})
+	require.Equal([]uint64{66, 99}, byEndTime)
+
+	reply := &predictionvm.ListMarketsReply{}
+	err := server.ListMarkets(httptest.NewRequest("POST", "/", nil), &predictionvm.ListMarketsArgs{EndTimeFrom: 10, EndTimeTo: 10}, reply)
+	require.ErrorIs(err, predictionvm.ErrInvalidEndTimeRange)
+	err = server.ListMarkets(httptest.NewRequest("POST", "/", nil), &predictionvm.ListMarketsArgs{
+		EndTimeTo: (predictionvm.MaxListMarketsEndTimeBuckets + 1) * storage.EndTimeBucket,
+	}, reply)
+	require.ErrorIs(err, predictionvm.ErrEndTimeRangeTooWide)
+
+	err = predictionvm.NewJSONRPCServer(vm).ListMarkets(httptest.NewRequest("POST", "/", nil), &predictionvm.ListMarketsArgs{}, reply)
+	require.ErrorIs(err, predictionvm.ErrIndexDisabled)
+}
+
+func TestListMarkets_TradingClosed(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	mu := chaintest.NewInMemoryStore()
+
+	// Market 1's trading period ended, market 3 was swept.
+	for _, market := range []*storage.Market{
+		{ID: 1, Status: storage.MarketStatus_Open, EndTime: 1_000},
+		{ID: 2, Status: storage.MarketStatus_Open, EndTime: 3_000},
+		{ID: 3, Status: storage.MarketStatus_Open, EndTime: 3_000},
+	} {
+		require.NoError(storage.SetMarket(ctx, mu, market))
+	}
+	vm := &portfolioVM{layoutVM: layoutVM{mu: mu}, timestamp: 2_000}
+	index := vm.marketIndex(t)
+	delete(mu.Storage, string(storage.MarketKey(3)))
+	delete(mu.Storage, string(storage.MarketStateKey(3)))
+	server := predictionvm.NewJSONRPCServerWithIndex(vm, nil, index)
+
+	open, closed := storage.MarketStatus_Open, storage.MarketStatus_TradingClosed
+	page, _ := listMarkets(t, server, &predictionvm.ListMarketsArgs{Status: &open})
+	require.Equal([]uint64{2}, page)
+	page, _ = listMarkets(t, server, &predictionvm.ListMarketsArgs{Status: &closed})
+	require.Equal([]uint64{1}, page)
+
+	reply := &predictionvm.ListMarketsReply{}
+	require.NoError(server.ListMarkets(httptest.NewRequest("POST", "/", nil), &predictionvm.ListMarketsArgs{}, reply))
+	require.Len(reply.Markets, 2)
+	require.Equal(storage.MarketStatus_TradingClosed, reply.Markets[0].Status)
+	require.Equal(storage.MarketStatus_Open, reply.Markets[1].Status)
+}
+
+// heightVM is a layoutVM whose last accepted block is at [height].
+type heightVM struct {
+	layoutVM
+	height uint64
+}
+
+func (v *heightVM) LastAcceptedBlock(context.Context) (*chain.StatelessBlock, error) {
+	return &chain.StatelessBlock{Block: chain.Block{Hght: v.height}}, nil
+}
+
+func TestMarketIndex_Incomplete(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	mu := chaintest.NewInMemoryStore()
+	block := func(height uint64) *chain.ExecutedBlock {
+		return &chain.ExecutedBlock{Block: &chain.StatelessBlock{Block: chain.Block{Hght: height}}}
+	}
+	prefixes := [][]byte{storage.IndexKeyPrefix(storage.StatusIndex(storage.MarketStatus_Open))}
+
+	// An index that copied every block since genesis is complete.
+	vm := &heightVM{layoutVM: layoutVM{mu: mu}}
+	db := memdb.New()
+	index := predictionvm.NewMarketIndex(vm, db, nil)
+	for height := uint64(1); height <= 2; height++ {
+		vm.height = height
+		require.NoError(index.Accept(ctx, block(height)))
+		_, err := index.List(ctx, prefixes, 0, 0)
+		require.NoError(err)
+	}
+
+	// It lags the last accepted block by one while that block's
+	// subscribers are notified.
+	vm.height = 3
+	_, err := index.List(ctx, prefixes, 0, 0)
+	require.NoError(err)
+
+	// Skipping a block, as when the index is disabled for a while, leaves
+	// it incomplete for good.
+	vm.height = 4
+	require.NoError(index.Accept(ctx, block(4)))
+	_, err = index.List(ctx, prefixes, 0, 0)
+	require.ErrorIs(err, predictionvm.ErrIndexIncomplete)
+	_, err = predictionvm.NewMarketIndex(vm, db, nil).List(ctx, prefixes, 0, 0)
+	require.ErrorIs(err, predictionvm.ErrIndexIncomplete)
+
+	// So is an index enabled after the chain progressed, or on a node that
+	// state synced, before and after it copies a block.
+	late := predictionvm.NewMarketIndex(vm, memdb.New(), nil)
+	_, err = late.List(ctx, prefixes, 0, 0)
+	require.ErrorIs(err, predictionvm.ErrIndexIncomplete)
+	synced := predictionvm.NewMarketIndex(vm, memdb.New(), nil)
+	vm.height = 5
+	require.NoError(synced.Accept(ctx, block(5)))
+	_, err = synced.List(ctx, prefixes, 0, 0)
+	require.ErrorIs(err, predictionvm.ErrIndexIncomplete)
+}
+
+func TestListMarkets_ManyMarkets(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	mu := chaintest.NewInMemoryStore()
+	creator := codec.Address{0x0a}
+
+	// Every entry is a key of its own, so an index holds any number of
+	// markets.
+	const markets = 2_000
+	for id := uint64(1); id <= markets; id++ {
+		require.NoError(storage.SetMarket(ctx, mu, &storage.Market{ID: id * 64, Status: storage.MarketStatus_Open, Creator: creator}))
+	}
+	vm := &layoutVM{mu: mu}
+	server := predictionvm.NewJSONRPCServerWithIndex(vm, nil, vm.marketIndex(t))
+
+	var listed []uint64
+	for after := uint64(0); ; {
+		page, next := listMarkets(t, server, &predictionvm.ListMarketsArgs{Creator: &creator, Limit: predictionvm.MaxListMarketsLimit, After: after})
+		listed = append(listed, page...)
+		if next == 0 {
+			break
+		}
+		after = next
+	}
+	require.Len(listed, markets)
+	require.Equal(uint64(64), listed[0])
+	require.Equal(uint64(markets*64), listed[markets-1])
+}
+
+func TestMarket(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	mu := chaintest.NewInMemoryStore()
+	server := predictionvm.NewJSONRPCServer(&layoutVM{mu: mu})
+	getMarket := func(marketID uint64) (*predictionvm.MarketInfo, error) {
+		reply := &predictionvm.MarketReply{}
+		err := server.Market(httptest.NewRequest("POST", "/", nil), &predictionvm.MarketArgs{MarketID: marketID}, reply)
+		return reply.Market, err
+	}
+
+	// Records stored before the MarketState split are read whole.
+	require.NoError(mu.Insert(ctx, storage.MarketKey(marketV1.ID), loadFixture(t, "market_v1.hex")))
+	info, err := getMarket(marketV1.ID)
+	require.NoError(err)
+	require.Equal(upgradedMarketV1(), info.Market)
+
+	require.NoError(storage.SetMarket(ctx, mu, fixtureMarket))
+	info, err = getMarket(fixtureMarket.ID)
+	require.NoError(err)
+	require.Equal(fixtureMarket, info.Market)
+	require.JSONEq(`{"resolver":"`+codec.Address{0x05}.String()+`"}`, string(info.OracleParameters))
+
+	_, err = getMarket(7)
+	var notFound *storage.MarketNotFoundError
+	require.ErrorAs(err, &notFound)
+	require.Equal(uint64(7), notFound.MarketID)
+}
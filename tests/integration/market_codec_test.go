@@ -0,0 +1,212 @@
+// Copyright (C) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package integration_test
+
+import (
+	"context"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ava-labs/hypersdk/chain/chaintest"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chokosabe/predictionvm/storage"
+)
+
+// fixtureMarket is the market testdata/market_v2.hex and
+// testdata/market_state_v1.hex encode.
+var fixtureMarket = &storage.Market{
+	ID:                    0x1234,
+	Description:           "Will the fixture decode?",
+	Status:                storage.MarketStatus_Disputed,
+	Creator:               codec.Address{0x01, 0x02},
+	EndTime:               5_000,
+	ResolutionTime:        10_000,
+	TotalYesShares:        40,
+	TotalNoShares:         60,
+	OracleSource:          "manual",
+	OracleParameters:      (&storage.ManualParameters{Resolver: codec.Address{0x05}}).Bytes(),
+	ResolvedOutcome:       storage.Outcome_Yes,
+	ResolutionGracePeriod: 7_000,
+	Collateral:            1_000,
+	Oracle:                codec.Address{0x05},
+	ResolvedAt:            12_000,
+	Tier:                  1,
+	SweepDeposit:          500,
+}
+
+// marketV1 is the market testdata/market_v1.hex encodes. The fixture was
+// written by the SetMarket of the first release, whose markets only had
+// these fields.
+var marketV1 = &storage.Market{
+	ID:               0x1234,
+	Description:      "Will the fixture decode?",
+	Status:           storage.MarketStatus_ResolvedYes,
+	Creator:          codec.Address{0x01, 0x02},
+	EndTime:          5_000,
+	ResolutionTime:   10_000,
+	TotalYesShares:   40,
+	TotalNoShares:    60,
+	OracleSource:     "manual",
+	OracleParameters: []byte{0x01},
+	ResolvedOutcome:  storage.Outcome_Yes,
+}
+
+// upgradedMarketV1 returns marketV1 with the defaults of the fields added
+// since: manual markets are resolved by their creator.
+func upgradedMarketV1() *storage.Market {
+	market := *marketV1
+	market.Oracle = market.Creator
+	return &market
+}
+
+func loadFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	require.NoError(t, err)
+	b, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	require.NoError(t, err)
+	return b
+}
+
+func TestMarketCodec_Fixtures(t *testing.T) {
+	require := require.New(t)
+	market, err := storage.DecodeMarketV1(marketV1.ID, loadFixture(t, "market_v1.hex"))
+	require.NoError(err)
+	require.Equal(upgradedMarketV1(), market)
+
+	// From MarketV2 on, the record only holds the metadata.
+	metadata := *fixtureMarket
+	metadata.SetState(&storage.MarketState{})
+	market, version, err := storage.DecodeMarket(fixtureMarket.ID, loadFixture(t, "market_v2.hex"))
+	require.NoError(err)
+	require.Equal(storage.MarketV2, version)
+	require.Equal(&metadata, market)
+
+	marketState, err := storage.DecodeMarketState(fixtureMarket.ID, loadFixture(t, "market_state_v1.hex"))
+	require.NoError(err)
+	require.Equal(fixtureMarket.State(), marketState)
+
+	// Both records together decode to the whole market.
+	mu := chaintest.NewInMemoryStore()
+	ctx := context.Background()
+	require.NoError(mu.Insert(ctx, storage.MarketKey(fixtureMarket.ID), loadFixture(t, "market_v2.hex")))
+	require.NoError(mu.Insert(ctx, storage.MarketStateKey(fixtureMarket.ID), loadFixture(t, "market_state_v1.hex")))
+	market, err = storage.GetMarket(ctx, mu, fixtureMarket.ID)
+	require.NoError(err)
+	require.Equal(fixtureMarket, market)
+}
+
+func TestMarketCodec_CurrentVersionIsFrozen(t *testing.T) {
+	// A change to the current encoding must come with a new version and
+	// fixture rather than silently altering the stored records.
+	b, err := storage.EncodeMarket(fixtureMarket)
+	require.NoError(t, err)
+	require.Equal(t, storage.MarketVersion, b[0])
+	require.Equal(t, loadFixture(t, "market_v2.hex"), b)
+
+	b, err = storage.EncodeMarketState(fixtureMarket.ID, fixtureMarket.State())
+	require.NoError(t, err)
+	require.Equal(t, storage.MarketStateV1, b[0])
+	require.Equal(t, loadFixture(t, "market_state_v1.hex"), b)
+	require.LessOrEqual(t, len(b), storage.MaxMarketStateSize)
+}
+
+func TestMarketCodec_LazyUpgrade(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	mu := chaintest.NewInMemoryStore()
+	key := storage.MarketKey(fixtureMarket.ID)
+	require.NoError(mu.Insert(ctx, key, loadFixture(t, "market_v1.hex")))
+
+	// Reads leave the old record in place.
+	market, err := storage.GetMarket(ctx, mu, fixtureMarket.ID)
+	require.NoError(err)
+	require.Equal(upgradedMarketV1(), market)
+	require.Equal(loadFixture(t, "market_v1.hex"), mu.Storage[string(key)])
+
+	// Trades read the MarketState from the old record.
+	marketState, err := storage.GetMarketState(ctx, mu, fixtureMarket.ID)
+	require.NoError(err)
+	require.Equal(upgradedMarketV1().State(), marketState)
+
+	// The next write stores the current version and splits out the state.
+	market.Collateral = 900
+	require.NoError(storage.SetMarket(ctx, mu, market))
+	_, version, err := storage.DecodeMarket(fixtureMarket.ID, mu.Storage[string(key)])
+	require.NoError(err)
+	require.Equal(storage.MarketVersion, version)
+	upgraded, err := storage.GetMarket(ctx, mu, fixtureMarket.ID)
+	require.NoError(err)
+	require.Equal(market, upgraded)
+	marketState, err = storage.GetMarketState(ctx, mu, fixtureMarket.ID)
+	require.NoError(err)
+	require.Equal(market.State(), marketState)
+}
+
+func TestMarketCodec_SplitWrites(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	mu := chaintest.NewInMemoryStore()
+	market := *fixtureMarket
+	require.NoError(storage.SetMarket(ctx, mu, &market))
+	metadata := mu.Storage[string(storage.MarketKey(market.ID))]
+
+	// Trades rewrite only the MarketState.
+	marketState := market.State()
+	marketState.TotalYesShares += 5
+	marketState.Collateral += 5
+	require.NoError(storage.SetMarketState(ctx, mu, market.ID, marketState))
+	require.Equal(metadata, mu.Storage[string(storage.MarketKey(market.ID))])
+	stored, err := storage.GetMarket(ctx, mu, market.ID)
+	require.NoError(err)
+	require.Equal(uint64(45), stored.TotalYesShares)
+
+	// The status and end time are indexed, so they only change via SetMarket.
+	marketState.Status = storage.MarketStatus_ResolvedNo
+	require.ErrorIs(storage.SetMarketState(ctx, mu, market.ID, marketState), storage.ErrMarketStateImmutable)
+	marketState = stored.State()
+	marketState.EndTime++
+	require.ErrorIs(storage.SetMarketState(ctx, mu, market.ID, marketState), storage.ErrMarketStateImmutable)
+}
+
+func TestMarketCodec_Errors(t *testing.T) {
+	v2 := loadFixture(t, "market_v2.hex")
+
+	_, err := storage.DecodeMarketState(fixtureMarket.ID, []byte{storage.MarketStateV1 + 1})
+	require.ErrorIs(t, err, storage.ErrUnknownMarketVersion)
+
+	future := append([]byte{storage.MarketVersion + 1}, v2[1:]...)
+	_, _, err = storage.DecodeMarket(fixtureMarket.ID, future)
+	require.ErrorIs(t, err, storage.ErrUnknownMarketVersion)
+
+	_, _, err = storage.DecodeMarket(fixtureMarket.ID, v2[:len(v2)-1])
+	require.ErrorIs(t, err, storage.ErrMalformedMarket)
+
+	_, _, err = storage.DecodeMarket(fixtureMarket.ID, append(v2, 0x00))
+	require.ErrorIs(t, err, storage.ErrMalformedMarket)
+
+	_, _, err = storage.DecodeMarket(fixtureMarket.ID+1, v2)
+	require.ErrorIs(t, err, storage.ErrMalformedMarket)
+
+	_, _, err = storage.DecodeMarket(fixtureMarket.ID, nil)
+	require.ErrorIs(t, err, storage.ErrMalformedMarket)
+
+	v1 := loadFixture(t, "market_v1.hex")
+	_, err = storage.DecodeMarketV1(fixtureMarket.ID, v1[:len(v1)-1])
+	require.ErrorIs(t, err, storage.ErrMalformedMarket)
+
+	_, err = storage.DecodeMarketV1(fixtureMarket.ID+1, v1)
+	require.ErrorIs(t, err, storage.ErrMalformedMarket)
+
+	// A MarketV2 record without its MarketState is not read as MarketV1.
+	mu := chaintest.NewInMemoryStore()
+	require.NoError(t, mu.Insert(context.Background(), storage.MarketKey(fixtureMarket.ID), v2))
+	_, err = storage.GetMarket(context.Background(), mu, fixtureMarket.ID)
+	require.ErrorIs(t, err, storage.ErrMalformedMarket)
+}
@@ -0,0 +1,220 @@
+// Copyright (C) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package integration_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ava-labs/avalanchego/utils/logging"
+	"github.com/ava-labs/hypersdk/auth"
+	"github.com/ava-labs/hypersdk/chain"
+	"github.com/ava-labs/hypersdk/chain/chaintest"
+	"github.com/ava-labs/hypersdk/pubsub"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chokosabe/predictionvm/actions"
+	"github.com/chokosabe/predictionvm/consts"
+	"github.com/chokosabe/predictionvm/storage"
+
+	predictionvm "github.com/chokosabe/predictionvm/vm"
+)
+
+func newEventTx(t *testing.T, actions ...chain.Action) *chain.Transaction {
+	tx, err := chain.NewTransaction(chain.Base{}, actions, &auth.ED25519{})
+	require.NoError(t, err)
+	return tx
+}
+
+func newEventBlock(height uint64, txs []*chain.Transaction, results []*chain.Result) *chain.ExecutedBlock {
+	return &chain.ExecutedBlock{
+		Block:            &chain.StatelessBlock{Block: chain.Block{Hght: height, Tmstmp: int64(height) * 1_000, Txs: txs}},
+		ExecutionResults: &chain.ExecutionResults{Results: results},
+	}
+}
+
+func TestMarketEvents(t *testing.T) {
+	require := require.New(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	mu := chaintest.NewInMemoryStore()
+
+	market := *fixtureMarket
+	market.ID = 7
+	market.Status = storage.MarketStatus_Open
+	require.NoError(storage.SetMarket(ctx, mu, &market))
+
+	server := predictionvm.NewMarketEventServer(&layoutVM{mu: mu}, logging.NoLog{}, 16, pubsub.MaxWriteMessageSize)
+	httpServer := httptest.NewServer(server.Handler())
+	defer httpServer.Close()
+	client, err := predictionvm.NewMarketEventsClient(httpServer.URL, predictionvm.DefaultEventsHandshakeTimeout, 16)
+	require.NoError(err)
+	defer client.Close()
+
+	require.NoError(client.Subscribe(7, 8))
+	msg, err := client.Listen(ctx)
+	require.NoError(err)
+	require.Equal(&predictionvm.MarketEventsMessage{Sequence: 1, Subscribed: []uint64{7, 8}}, msg)
+
+	newTx := func(actions ...chain.Action) *chain.Transaction {
+		return newEventTx(t, actions...)
+	}
+	newBlock := newEventBlock
+
+	// A block that trades and resolves market 7. The failed trade and the
+	// trade of an unsubscribed market are not reported.
+	trade := newTx(&actions.BuyYes{MarketID: 7, Amount: 5, MaxPrice: 2})
+	block := newBlock(1, []*chain.Transaction{
+		trade,
+		newTx(&actions.BuyNo{MarketID: 7, Amount: 1, MaxPrice: 1}),
+		newTx(&actions.BuyNo{MarketID: 9, Amount: 1, MaxPrice: 1}),
+		newTx(&actions.ResolveMarket{MarketID: 7, Outcome: 1}),
+	}, []*chain.Result{{Success: true}, {Success: false}, {Success: true}, {Success: true}})
+	market.Status = storage.MarketStatus_ResolvedYes
+	market.Tier = 0
+	require.NoError(storage.SetMarket(ctx, mu, &market))
+	require.NoError(server.AcceptBlock(ctx, block))
+
+	msg, err = client.Listen(ctx)
+	require.NoError(err)
+	require.Equal(&predictionvm.MarketEventsMessage{
+		Sequence:  2,
+		Height:    1,
+		Timestamp: 1_000,
+		Events: []*predictionvm.MarketEvent{
+			{
+				Type:     predictionvm.EventTrade,
+				MarketID: 7,
+				Trade: &predictionvm.TradeEvent{
+					TxID:      trade.GetID(),
+					Trader:    trade.Auth.Actor(),
+					ShareType: consts.YesShareType,
+					Shares:    5,
+					Cost:      10,
+				},
+			},
+			{
+				Type:         predictionvm.EventStatusChange,
+				MarketID:     7,
+				StatusChange: &predictionvm.StatusChangeEvent{From: storage.MarketStatus_Open, To: storage.MarketStatus_ResolvedYes},
+			},
+			{
+				Type:     predictionvm.EventResolution,
+				MarketID: 7,
+				Resolution: &predictionvm.ResolutionEvent{
+					Status:     storage.MarketStatus_ResolvedYes,
+					Outcome:    market.ResolvedOutcome,
+					ResolvedAt: market.ResolvedAt,
+				},
+			},
+		},
+	}, msg)
+
+	// Neither a block without events for the subscribed markets nor one for
+	// an unsubscribed market sends anything; the next message is the reply
+	// to the unsubscription.
+	require.NoError(server.AcceptBlock(ctx, newBlock(2, []*chain.Transaction{
+		newTx(&actions.BuyNo{MarketID: 9, Amount: 1, MaxPrice: 1}),
+	}, []*chain.Result{{Success: true}})))
+	require.NoError(client.Unsubscribe(7))
+	msg, err = client.Listen(ctx)
+	require.NoError(err)
+	require.Equal(&predictionvm.MarketEventsMessage{Sequence: 3, Subscribed: []uint64{8}}, msg)
+	require.NoError(server.AcceptBlock(ctx, newBlock(3, []*chain.Transaction{trade}, []*chain.Result{{Success: true}})))
+
+	marketIDs := make([]uint64, predictionvm.MaxMarketSubscriptions)
+	for i := range marketIDs {
+		marketIDs[i] = uint64(100 + i)
+	}
+	require.NoError(client.Subscribe(marketIDs...))
+	msg, err = client.Listen(ctx)
+	require.NoError(err)
+	require.Equal(uint64(4), msg.Sequence)
+	require.Contains(msg.Error, predictionvm.ErrTooManySubscriptions.Error())
+	require.Len(msg.Subscribed, predictionvm.MaxMarketSubscriptions)
+	require.Equal(uint64(8), msg.Subscribed[0])
+}
+
+func TestMarketEvents_MaxMessageSize(t *testing.T) {
+	testCases := []struct {
+		name           string
+		maxMessageSize int
+	}{
+		// Ten trades do not fit in one message, but each fits in one.
+		{name: "Split", maxMessageSize: 1024},
+		// A single trade does not fit in a message.
+		{name: "TooLarge", maxMessageSize: 256},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require := require.New(t)
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			mu := chaintest.NewInMemoryStore()
+			market := *fixtureMarket
+			market.ID = 7
+			market.Status = storage.MarketStatus_Open
+			require.NoError(storage.SetMarket(ctx, mu, &market))
+
+			server := predictionvm.NewMarketEventServer(&layoutVM{mu: mu}, logging.NoLog{}, 16, tc.maxMessageSize)
+			httpServer := httptest.NewServer(server.Handler())
+			defer httpServer.Close()
+			client, err := predictionvm.NewMarketEventsClient(httpServer.URL, predictionvm.DefaultEventsHandshakeTimeout, 16)
+			require.NoError(err)
+			defer client.Close()
+			require.NoError(client.Subscribe(7))
+			_, err = client.Listen(ctx)
+			require.NoError(err)
+
+			var (
+				txs     []*chain.Transaction
+				results []*chain.Result
+			)
+			for i := range 10 {
+				txs = append(txs, newEventTx(t, &actions.BuyYes{MarketID: 7, Amount: uint64(i + 1), MaxPrice: 1}))
+				results = append(results, &chain.Result{Success: true})
+			}
+			require.NoError(server.AcceptBlock(ctx, newEventBlock(1, txs, results)))
+
+			if tc.maxMessageSize < 1024 {
+				// The subscriber is told what it missed, one error per
+				// trade, and stays subscribed.
+				for sequence := uint64(2); sequence < 12; sequence++ {
+					msg, err := client.Listen(ctx)
+					require.NoError(err)
+					require.Equal(sequence, msg.Sequence)
+					require.Equal(uint64(1), msg.Height)
+					require.Empty(msg.Events)
+					require.NotEmpty(msg.Error)
+				}
+				require.NoError(client.Subscribe(8))
+				msg, err := client.Listen(ctx)
+				require.NoError(err)
+				require.Equal(&predictionvm.MarketEventsMessage{Sequence: 12, Subscribed: []uint64{7, 8}}, msg)
+				return
+			}
+
+			// The trades arrive in order across consecutive messages of
+			// the block.
+			var shares []uint64
+			for sequence := uint64(2); len(shares) < len(txs); sequence++ {
+				msg, err := client.Listen(ctx)
+				require.NoError(err)
+				require.Equal(sequence, msg.Sequence)
+				require.Equal(uint64(1), msg.Height)
+				require.Empty(msg.Error)
+				b, err := json.Marshal(msg)
+				require.NoError(err)
+				require.LessOrEqual(len(b), tc.maxMessageSize)
+				for _, event := range msg.Events {
+					shares = append(shares, event.Trade.Shares)
+				}
+			}
+			require.Equal([]uint64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, shares)
+		})
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright (C) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package integration_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ava-labs/hypersdk/chain"
+	"github.com/ava-labs/hypersdk/chain/chaintest"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chokosabe/predictionvm/consts"
+	"github.com/chokosabe/predictionvm/genesis"
+	"github.com/chokosabe/predictionvm/storage"
+
+	predictionvm "github.com/chokosabe/predictionvm/vm"
+)
+
+func TestPositions(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	mu := chaintest.NewInMemoryStore()
+	alice, bob := codec.Address{0x0a}, codec.Address{0x0b}
+
+	for _, market := range []*storage.Market{
+		{ID: 70, Status: storage.MarketStatus_Open},
+		{ID: 6, Status: storage.MarketStatus_ResolvedYes},
+		{ID: 135, Status: storage.MarketStatus_Open},
+	} {
+		require.NoError(storage.SetMarket(ctx, mu, market))
+	}
+	require.NoError(storage.AddShares(ctx, mu, 70, alice, consts.NoShareType, 5))
+	require.NoError(storage.AddShares(ctx, mu, 6, alice, consts.YesShareType, 3))
+	require.NoError(storage.AddShares(ctx, mu, 6, alice, consts.NoShareType, 4))
+	require.NoError(storage.AddShares(ctx, mu, 135, alice, consts.YesShareType, 2))
+	require.NoError(storage.AddShares(ctx, mu, 135, bob, consts.YesShareType, 9))
+
+	// Positions that go back to zero or are redeemed drop out of the index.
+	require.NoError(storage.DeductShares(ctx, mu, 135, alice, consts.YesShareType, 2))
+	require.NoError(storage.RemoveShareBalance(ctx, mu, 6, alice, consts.YesShareType))
+
+	vm := &layoutVM{mu: mu}
+	server := predictionvm.NewJSONRPCServerWithIndex(vm, nil, vm.marketIndex(t))
+	reply := &predictionvm.PositionsReply{}
+	require.NoError(server.Positions(httptest.NewRequest("POST", "/", nil), &predictionvm.PositionsArgs{Address: alice}, reply))
+	require.Equal([]*predictionvm.Position{
+		{MarketID: 6, ShareType: consts.NoShareType, Amount: 4, MarketStatus: storage.MarketStatus_ResolvedYes},
+		{MarketID: 70, ShareType: consts.NoShareType, Amount: 5, MarketStatus: storage.MarketStatus_Open},
+	}, reply.Positions)
+
+	reply = &predictionvm.PositionsReply{}
+	require.NoError(server.Positions(httptest.NewRequest("POST", "/", nil), &predictionvm.PositionsArgs{Address: codec.Address{0x0c}}, reply))
+	require.Empty(reply.Positions)
+}
+
+// portfolioVM serves JSONRPCServer queries that need the last accepted block
+// and the chain rules.
+type portfolioVM struct {
+	layoutVM
+	timestamp int64
+}
+
+func (v *portfolioVM) LastAcceptedBlock(context.Context) (*chain.StatelessBlock, error) {
+	return &chain.StatelessBlock{Block: chain.Block{Tmstmp: v.timestamp}}, nil
+}
+
+func (*portfolioVM) GetRuleFactory() chain.RuleFactory {
+	return genesis.NewGenesis(nil).RuleFactory()
+}
+
+func TestPortfolio(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	mu := chaintest.NewInMemoryStore()
+	alice := codec.Address{0x0a}
+	now := consts.DefaultDisputeWindow + 1
+
+	for _, market := range []*storage.Market{
+		{ID: 1, Status: storage.MarketStatus_Open, Collateral: 100, TotalYesShares: 30, TotalNoShares: 20},
+		{ID: 2, Status: storage.MarketStatus_ResolvedYes, Collateral: 60, TotalYesShares: 20, TotalNoShares: 10},
+		{ID: 3, Status: storage.MarketStatus_ResolvedNo, ResolvedAt: now, Collateral: 10, TotalYesShares: 6, TotalNoShares: 4},
+		{ID: 4, Status: storage.MarketStatus_ResolvedInvalid, Collateral: 50, TotalYesShares: 10, TotalNoShares: 10},
+	} {
+		require.NoError(storage.SetMarket(ctx, mu, market))
+	}
+	require.NoError(storage.AddShares(ctx, mu, 1, alice, consts.YesShareType, 10))
+	require.NoError(storage.AddShares(ctx, mu, 1, alice, consts.NoShareType, 5))
+	require.NoError(storage.AddShares(ctx, mu, 2, alice, consts.YesShareType, 5))
+	require.NoError(storage.AddShares(ctx, mu, 2, alice, consts.NoShareType, 4))
+	require.NoError(storage.AddShares(ctx, mu, 3, alice, consts.NoShareType, 2))
+	require.NoError(storage.AddShares(ctx, mu, 4, alice, consts.YesShareType, 2))
+	for marketID, paid := range map[uint64]uint64{1: 40, 2: 36, 3: 3, 4: 9} {
+		require.NoError(storage.AddPaidCollateral(ctx, mu, marketID, alice, paid))
+	}
+	require.NoError(storage.SetBalance(ctx, mu, alice, 7))
+	vm := &portfolioVM{layoutVM: layoutVM{mu: mu}, timestamp: now}
+	server := predictionvm.NewJSONRPCServerWithIndex(vm, nil, vm.marketIndex(t))
+
+	reply := &predictionvm.PortfolioReply{}
+	require.NoError(server.Portfolio(httptest.NewRequest("POST", "/", nil), &predictionvm.PortfolioArgs{Address: alice}, reply))
+	require.Equal(&predictionvm.PortfolioReply{
+		Timestamp: now,
+		Balance:   7,
+		Holdings: []*predictionvm.Holding{
+			// Unresolved: 15 of the 50 outstanding shares of 100 collateral.
+			{MarketID: 1, MarketStatus: storage.MarketStatus_Open, YesShares: 10, NoShares: 5, CostBasis: 40, Value: 30},
+			// Final: 5 of the 20 winning shares, and the losing NO shares are
+			// worthless.
+			{MarketID: 2, MarketStatus: storage.MarketStatus_ResolvedYes, YesShares: 5, NoShares: 4, CostBasis: 36, Value: 15, Claimable: 15},
+			// Still open to dispute, so nothing can be claimed yet.
+			{MarketID: 3, MarketStatus: storage.MarketStatus_ResolvedNo, NoShares: 2, CostBasis: 3, Value: 5},
+			// Refunded: worth what was paid.
+			{MarketID: 4, MarketStatus: storage.MarketStatus_ResolvedInvalid, YesShares: 2, CostBasis: 9, Value: 9, Claimable: 9},
+		},
+		TotalCostBasis: 88,
+		TotalValue:     59,
+		TotalClaimable: 24,
+	}, reply)
+
+	shareBalance := func(marketID uint64, shareType uint8) (uint64, error) {
+		reply := &predictionvm.ShareBalanceReply{}
+		err := server.ShareBalance(httptest.NewRequest("POST", "/", nil), &predictionvm.ShareBalanceArgs{MarketID: marketID, Address: alice, ShareType: shareType}, reply)
+		return reply.Amount, err
+	}
+	amount, err := shareBalance(1, consts.YesShareType)
+	require.NoError(err)
+	require.Equal(uint64(10), amount)
+	amount, err = shareBalance(3, consts.YesShareType)
+	require.NoError(err)
+	require.Zero(amount)
+	_, err = shareBalance(1, 2)
+	require.ErrorIs(err, predictionvm.ErrInvalidShareType)
+}
@@ -0,0 +1,127 @@
+// Copyright (C) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package integration_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ava-labs/hypersdk/chain/chaintest"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/stretchr/testify/require"
+
+	"github.com/chokosabe/predictionvm/consts"
+	"github.com/chokosabe/predictionvm/storage"
+
+	predictionvm "github.com/chokosabe/predictionvm/vm"
+)
+
+func TestREST(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	mu := chaintest.NewInMemoryStore()
+	alice := codec.Address{0x0a}
+
+	open := &storage.Market{ID: 1, Status: storage.MarketStatus_Open, EndTime: 10_000, Collateral: 100, TotalYesShares: 30, TotalNoShares: 20}
+	require.NoError(storage.SetMarket(ctx, mu, open))
+	require.NoError(storage.SetMarket(ctx, mu, fixtureMarket))
+	require.NoError(storage.AddShares(ctx, mu, open.ID, alice, consts.YesShareType, 10))
+
+	// Routes are matched at the end of the path, after the chain's base URL.
+	vm := &portfolioVM{layoutVM: layoutVM{mu: mu}, timestamp: 1_000}
+	server := httptest.NewServer(predictionvm.NewRESTServerWithIndex(vm, vm.marketIndex(t)))
+	defer server.Close()
+	get := func(path string, reply any) int {
+		resp, err := http.Get(server.URL + "/ext/bc/predictionvm" + path)
+		require.NoError(err)
+		defer resp.Body.Close()
+		require.Equal("application/json", resp.Header.Get("Content-Type"))
+		require.NoError(json.NewDecoder(resp.Body).Decode(reply))
+		return resp.StatusCode
+	}
+
+	market := &predictionvm.MarketReply{}
+	require.Equal(http.StatusOK, get("/rest/markets/1", market))
+	require.Equal(open, market.Market.Market)
+
+	markets := &predictionvm.ListMarketsReply{}
+	require.Equal(http.StatusOK, get("/rest/markets?status=0&limit=10", markets))
+	require.Len(markets.Markets, 1)
+	require.Equal(open.ID, markets.Markets[0].ID)
+
+	positions := &predictionvm.PositionsReply{}
+	require.Equal(http.StatusOK, get("/rest/accounts/"+alice.String()+"/positions", positions))
+	require.Equal([]*predictionvm.Position{
+		{MarketID: open.ID, ShareType: consts.YesShareType, Amount: 10, MarketStatus: storage.MarketStatus_Open},
+	}, positions.Positions)
+
+	// 10 of the 60 shares outstanding after the trade, which brings the
+	// collateral to 150.
+	quote := &predictionvm.QuoteReply{}
+	require.Equal(http.StatusOK, get("/rest/quote?marketId=1&shareType=0&amount=10&maxPrice=5", quote))
+	require.Equal(&predictionvm.QuoteReply{Timestamp: 1_000, Cost: 50, Value: 25}, quote)
+
+	for _, tc := range []struct {
+		path   string
+		status int
+		code   string
+	}{
+		{path: "/rest/markets/7", status: http.StatusNotFound, code: storage.CodeMarketNotFound},
+		{path: "/rest/markets/one", status: http.StatusBadRequest},
+		{path: "/rest/accounts/alice/positions", status: http.StatusBadRequest},
+		{path: "/rest/quote?marketId=1&shareType=0&amount=10", status: http.StatusBadRequest},
+		{path: "/rest/quote?marketId=1&shareType=2&amount=10&maxPrice=5", status: http.StatusBadRequest},
+		{path: "/rest/quote?marketId=4660&shareType=0&amount=10&maxPrice=5", status: http.StatusConflict, code: storage.CodeMarketClosed},
+		{path: "/rest/orders", status: http.StatusNotFound},
+	} {
+		restErr := &predictionvm.RESTError{}
+		require.Equal(tc.status, get(tc.path, restErr), tc.path)
+		require.NotEmpty(restErr.Error, tc.path)
+		require.Equal(tc.code, restErr.Code, tc.path)
+	}
+
+	resp, err := http.Post(server.URL+"/rest/markets", "application/json", nil)
+	require.NoError(err)
+	require.NoError(resp.Body.Close())
+	require.Equal(http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func TestREST_OpenAPI(t *testing.T) {
+	require := require.New(t)
+	server := httptest.NewServer(predictionvm.NewRESTServer(&layoutVM{}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + predictionvm.OpenAPIPath)
+	require.NoError(err)
+	defer resp.Body.Close()
+	require.Equal(http.StatusOK, resp.StatusCode)
+
+	var doc struct {
+		OpenAPI    string                    `json:"openapi"`
+		Paths      map[string]map[string]any `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]map[string]any `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	require.NoError(json.NewDecoder(resp.Body).Decode(&doc))
+	require.Equal("3.0.3", doc.OpenAPI)
+	for _, path := range []string{"/rest/markets", "/rest/markets/{id}", "/rest/accounts/{address}/positions", "/rest/quote"} {
+		require.Contains(doc.Paths, path)
+		require.Contains(doc.Paths[path], "get")
+	}
+
+	// The market's fields are promoted into MarketInfo, whose decoded oracle
+	// parameters replace the market's raw ones.
+	marketInfo := doc.Components.Schemas["MarketInfo"].Properties
+	require.Equal(map[string]any{"type": "integer", "minimum": float64(0)}, marketInfo["id"])
+	require.Equal(map[string]any{"type": "string"}, marketInfo["creator"])
+	require.Equal(map[string]any{}, marketInfo["oracleParameters"])
+	require.Equal(map[string]any{"type": "string", "format": "byte"}, marketInfo["rawOracleParameters"])
+	require.Contains(doc.Components.Schemas, "RESTError")
+}
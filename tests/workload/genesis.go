@@ -15,15 +15,21 @@ import (
 	"github.com/ava-labs/hypersdk/codec"
 	"github.com/ava-labs/hypersdk/crypto/ed25519"
 	"github.com/chokosabe/predictionvm/consts" // Refactored import
+	"github.com/chokosabe/predictionvm/genesis"
 	"github.com/chokosabe/predictionvm/vm"     // Refactored import
 	"github.com/ava-labs/hypersdk/fees"
-	"github.com/ava-labs/hypersdk/genesis"
 	"github.com/ava-labs/hypersdk/tests/workload"
 )
 
 const (
 	// default initial balance for each address
 	InitialBalance uint64 = 3_000_000_000_000_000_000
+
+	// MarketID is the manual market seeded at genesis that the workload
+	// trades in. It is created by the integration test key.
+	MarketID uint64 = 1
+	// MarketLifetime is how long after genesis MarketID stays open.
+	MarketLifetime = 365 * 24 * time.Hour
 )
 
 // hardcoded initial set of ed25519 keys. Each will be initialized with InitialBalance
@@ -36,31 +42,40 @@ var ed25519HexKeys = []string{
 	"5c94b89bd0cccc0b5cef5b79ae2a0d2949c8b5596ec9869ddd4eab4e982b2b6a44bc6edd1009fce73b608cc51ab694dff11b27f8647350c0d43eb138c261796d", //nolint:lll
 }
 
-func newGenesis(authFactories []chain.AuthFactory, minBlockGap time.Duration) *genesis.DefaultGenesis {
+func newGenesis(authFactories []chain.AuthFactory, minBlockGap time.Duration) *genesis.Genesis {
 	// allocate the initial balance to the addresses
-	customAllocs := make([]*genesis.CustomAllocation, 0, len(authFactories))
+	allocs := make([]genesis.Allocation, 0, len(authFactories))
 	for _, authFactory := range authFactories {
-		customAllocs = append(customAllocs, &genesis.CustomAllocation{
-			Address: authFactory.Address(),
+		allocs = append(allocs, genesis.Allocation{
+			Address: genesis.FormatAddress(authFactory.Address()),
 			Balance: InitialBalance,
 		})
 	}
 
-	genesis := genesis.NewDefaultGenesis(customAllocs)
+	gen := genesis.NewGenesis(allocs)
+	closingTime := time.Now().Add(MarketLifetime)
+	gen.Custom.Markets = []genesis.GenesisMarket{{
+		ID:             MarketID,
+		Question:       "Will the workload market resolve Yes?",
+		ClosingTime:    closingTime.UnixMilli(),
+		ResolutionTime: closingTime.Add(time.Hour).UnixMilli(),
+		Creator:        allocs[0].Address,
+		OracleType:     consts.OracleTypeManual,
+	}}
 
 	// Set WindowTargetUnits to MaxUint64 for all dimensions to iterate full mempool during block building.
-	genesis.Rules.WindowTargetUnits = fees.Dimensions{math.MaxUint64, math.MaxUint64, math.MaxUint64, math.MaxUint64, math.MaxUint64}
+	gen.Rules.WindowTargetUnits = fees.Dimensions{math.MaxUint64, math.MaxUint64, math.MaxUint64, math.MaxUint64, math.MaxUint64}
 
 	// Set all limits to MaxUint64 to avoid limiting block size for all dimensions except bandwidth. Must limit bandwidth to avoid building
 	// a block that exceeds the maximum size allowed by AvalancheGo.
-	genesis.Rules.MaxBlockUnits = fees.Dimensions{1800000, math.MaxUint64, math.MaxUint64, math.MaxUint64, math.MaxUint64}
-	genesis.Rules.MinBlockGap = minBlockGap.Milliseconds()
+	gen.Rules.MaxBlockUnits = fees.Dimensions{1800000, math.MaxUint64, math.MaxUint64, math.MaxUint64, math.MaxUint64}
+	gen.Rules.MinBlockGap = minBlockGap.Milliseconds()
 
 	// The NetworkID and ChainID must be populated when the VM is instantiated.
-	genesis.Rules.NetworkID = uint32(0)
-	genesis.Rules.ChainID = ids.Empty
+	gen.Rules.NetworkID = uint32(0)
+	gen.Rules.ChainID = ids.Empty
 
-	return genesis
+	return gen
 }
 
 func newDefaultAuthFactories() []chain.AuthFactory {
@@ -84,7 +99,7 @@ func NewTestNetworkConfig(minBlockGap time.Duration) (workload.DefaultTestNetwor
 	}
 	return workload.NewDefaultTestNetworkConfiguration(
 		consts.Name,
-		genesis.DefaultGenesisFactory{},
+		genesis.Factory{},
 		genesisBytes,
 		vm.Parser,
 		keys,
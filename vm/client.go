@@ -12,7 +12,8 @@ import (
 	"github.com/ava-labs/hypersdk/chain"
 	"github.com/ava-labs/hypersdk/codec"
 	"github.com/chokosabe/predictionvm/consts"
-	"github.com/ava-labs/hypersdk/genesis"
+	"github.com/chokosabe/predictionvm/genesis"
+	"github.com/chokosabe/predictionvm/storage"
 	"github.com/ava-labs/hypersdk/requester"
 	"github.com/ava-labs/hypersdk/utils"
 )
@@ -24,7 +25,7 @@ type JSONRPCClient struct {
 
 	// genesis and the rule factory rely on data fetched via the client
 	// and are cached on the client
-	g           *genesis.DefaultGenesis
+	g           *genesis.Genesis
 	ruleFactory chain.RuleFactory
 }
 
@@ -38,7 +39,7 @@ func NewJSONRPCClient(uri string) *JSONRPCClient {
 	}
 }
 
-func (cli *JSONRPCClient) Genesis(ctx context.Context) (*genesis.DefaultGenesis, error) {
+func (cli *JSONRPCClient) Genesis(ctx context.Context) (*genesis.Genesis, error) {
 	if cli.g != nil {
 		return cli.g, nil
 	}
@@ -70,6 +71,232 @@ func (cli *JSONRPCClient) Balance(ctx context.Context, addr codec.Address) (uint
 	return resp.Amount, err
 }
 
+// FeedObservation returns the observation of feed [feedID] of [publisher] in
+// effect at [t] and its sequence number.
+func (cli *JSONRPCClient) FeedObservation(ctx context.Context, publisher codec.Address, feedID uint64, t int64) (uint64, *storage.PriceObservation, error) {
+	resp := new(FeedObservationReply)
+	err := cli.requester.SendRequest(
+		ctx,
+		"feedObservation",
+		&FeedObservationArgs{
+			Publisher: publisher,
+			FeedID:    feedID,
+			Time:      t,
+		},
+		resp,
+	)
+	return resp.Sequence, resp.Observation, err
+}
+
+// BalanceAt returns the balance of [addr] as of [height].
+func (cli *JSONRPCClient) BalanceAt(ctx context.Context, addr codec.Address, height uint64) (uint64, error) {
+	resp := new(BalanceReply)
+	err := cli.requester.SendRequest(
+		ctx,
+		"balance",
+		&BalanceArgs{
+			Address: addr,
+			Height:  &height,
+		},
+		resp,
+	)
+	return resp.Amount, err
+}
+
+// Market returns market [marketID].
+func (cli *JSONRPCClient) Market(ctx context.Context, marketID uint64) (*MarketInfo, error) {
+	resp := new(MarketReply)
+	err := cli.requester.SendRequest(
+		ctx,
+		"market",
+		&MarketArgs{
+			MarketID: marketID,
+		},
+		resp,
+	)
+	return resp.Market, err
+}
+
+// MarketAt returns market [marketID] as of [height].
+func (cli *JSONRPCClient) MarketAt(ctx context.Context, marketID uint64, height uint64) (*MarketInfo, error) {
+	resp := new(MarketReply)
+	err := cli.requester.SendRequest(
+		ctx,
+		"market",
+		&MarketArgs{
+			MarketID: marketID,
+			Height:   &height,
+		},
+		resp,
+	)
+	return resp.Market, err
+}
+
+// ExpiredMarkets returns the unresolved markets past their resolution deadline.
+func (cli *JSONRPCClient) ExpiredMarkets(ctx context.Context) ([]*MarketInfo, error) {
+	resp := new(ExpiredMarketsReply)
+	err := cli.requester.SendRequest(
+		ctx,
+		"expiredMarkets",
+		nil,
+		resp,
+	)
+	return resp.Markets, err
+}
+
+// ListMarkets returns a page of the markets matching [args] and the After of
+// the next page, which is zero on the last page.
+func (cli *JSONRPCClient) ListMarkets(ctx context.Context, args *ListMarketsArgs) ([]*MarketInfo, uint64, error) {
+	resp := new(ListMarketsReply)
+	err := cli.requester.SendRequest(
+		ctx,
+		"listMarkets",
+		args,
+		resp,
+	)
+	return resp.Markets, resp.Next, err
+}
+
+// Positions returns every share balance held by [addr].
+func (cli *JSONRPCClient) Positions(ctx context.Context, addr codec.Address) ([]*Position, error) {
+	resp := new(PositionsReply)
+	err := cli.requester.SendRequest(
+		ctx,
+		"positions",
+		&PositionsArgs{
+			Address: addr,
+		},
+		resp,
+	)
+	return resp.Positions, err
+}
+
+// PositionsAt returns every share balance held by [addr] as of [height].
+func (cli *JSONRPCClient) PositionsAt(ctx context.Context, addr codec.Address, height uint64) ([]*Position, error) {
+	resp := new(PositionsReply)
+	err := cli.requester.SendRequest(
+		ctx,
+		"positions",
+		&PositionsArgs{
+			Address: addr,
+			Height:  &height,
+		},
+		resp,
+	)
+	return resp.Positions, err
+}
+
+// ShareBalance returns the shares of [shareType] [addr] holds in market
+// [marketID].
+func (cli *JSONRPCClient) ShareBalance(ctx context.Context, marketID uint64, addr codec.Address, shareType uint8) (uint64, error) {
+	resp := new(ShareBalanceReply)
+	err := cli.requester.SendRequest(
+		ctx,
+		"shareBalance",
+		&ShareBalanceArgs{
+			MarketID:  marketID,
+			Address:   addr,
+			ShareType: shareType,
+		},
+		resp,
+	)
+	return resp.Amount, err
+}
+
+// ShareBalanceAt returns the shares of [shareType] [addr] held in market
+// [marketID] as of [height].
+func (cli *JSONRPCClient) ShareBalanceAt(ctx context.Context, marketID uint64, addr codec.Address, shareType uint8, height uint64) (uint64, error) {
+	resp := new(ShareBalanceReply)
+	err := cli.requester.SendRequest(
+		ctx,
+		"shareBalance",
+		&ShareBalanceArgs{
+			MarketID:  marketID,
+			Address:   addr,
+			ShareType: shareType,
+			Height:    &height,
+		},
+		resp,
+	)
+	return resp.Amount, err
+}
+
+// Portfolio returns the balance and holdings of [addr].
+func (cli *JSONRPCClient) Portfolio(ctx context.Context, addr codec.Address) (*PortfolioReply, error) {
+	resp := new(PortfolioReply)
+	err := cli.requester.SendRequest(
+		ctx,
+		"portfolio",
+		&PortfolioArgs{
+			Address: addr,
+		},
+		resp,
+	)
+	return resp, err
+}
+
+// Quote returns what buying [amount] shares of [shareType] in market
+// [marketID] at [maxPrice] would cost.
+func (cli *JSONRPCClient) Quote(ctx context.Context, marketID uint64, shareType uint8, amount uint64, maxPrice uint64) (*QuoteReply, error) {
+	resp := new(QuoteReply)
+	err := cli.requester.SendRequest(
+		ctx,
+		"quote",
+		&QuoteArgs{
+			MarketID:  marketID,
+			ShareType: shareType,
+			Amount:    amount,
+			MaxPrice:  maxPrice,
+		},
+		resp,
+	)
+	return resp, err
+}
+
+// BatchQuery answers [queries] in one request. The results are in the order
+// of the queries and report per-query errors.
+func (cli *JSONRPCClient) BatchQuery(ctx context.Context, queries []Query) ([]*QueryResult, error) {
+	resp := new(BatchQueryReply)
+	err := cli.requester.SendRequest(
+		ctx,
+		"batchQuery",
+		&BatchQueryArgs{
+			Queries: queries,
+		},
+		resp,
+	)
+	return resp.Results, err
+}
+
+// OracleStats returns the track record of an oracle address.
+func (cli *JSONRPCClient) OracleStats(ctx context.Context, oracle codec.Address) (*OracleStatsReply, error) {
+	resp := new(OracleStatsReply)
+	err := cli.requester.SendRequest(
+		ctx,
+		"oracleStats",
+		&OracleStatsArgs{
+			Oracle: oracle,
+		},
+		resp,
+	)
+	return resp, err
+}
+
+// MarketSummary returns the summary of market [marketID], which has been
+// swept from state.
+func (cli *JSONRPCClient) MarketSummary(ctx context.Context, marketID uint64) (*storage.MarketSummary, error) {
+	resp := new(MarketSummaryReply)
+	err := cli.requester.SendRequest(
+		ctx,
+		"marketSummary",
+		&MarketSummaryArgs{
+			MarketID: marketID,
+		},
+		resp,
+	)
+	return resp.Summary, err
+}
+
 func (cli *JSONRPCClient) WaitForBalance(
 	ctx context.Context,
 	addr codec.Address,
@@ -104,6 +331,6 @@ func (cli *JSONRPCClient) GetRuleFactory(ctx context.Context) (chain.RuleFactory
 	if err != nil {
 		return nil, err
 	}
-	cli.ruleFactory = &genesis.ImmutableRuleFactory{Rules: networkGenesis.Rules}
+	cli.ruleFactory = networkGenesis.RuleFactory()
 	return cli.ruleFactory, nil
 }
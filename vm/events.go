@@ -0,0 +1,497 @@
+// Copyright (C) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package vm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/logging"
+	safemath "github.com/ava-labs/avalanchego/utils/math"
+	"github.com/ava-labs/avalanchego/utils/set"
+	"github.com/ava-labs/hypersdk/api"
+	"github.com/ava-labs/hypersdk/chain"
+	"github.com/ava-labs/hypersdk/codec"
+	"github.com/ava-labs/hypersdk/event"
+	"github.com/ava-labs/hypersdk/pubsub"
+	"github.com/ava-labs/hypersdk/vm"
+	"go.uber.org/zap"
+
+	"github.com/chokosabe/predictionvm/actions"
+	"github.com/chokosabe/predictionvm/consts"
+	"github.com/chokosabe/predictionvm/storage"
+)
+
+const (
+	EventsEndpoint  = "/marketws"
+	EventsNamespace = "marketEvents"
+
+	// MaxMarketSubscriptions caps the number of markets a single connection
+	// subscribes to.
+	MaxMarketSubscriptions = 1024
+
+	// messageOverhead bounds the size of a MarketEventsMessage without its
+	// events.
+	messageOverhead = 128
+)
+
+// Types of MarketEvent.
+const (
+	EventTrade        = "trade"
+	EventStatusChange = "statusChange"
+	EventResolution   = "resolution"
+)
+
+var ErrTooManySubscriptions = errors.New("too many market subscriptions")
+
+var _ api.HandlerFactory[api.VM] = (*marketEventServerFactory)(nil)
+
+type EventsConfig struct {
+	Enabled            bool `json:"enabled"`
+	MaxPendingMessages int  `json:"maxPendingMessages"`
+	// MaxMessageSize caps the size of a message; the events of a block that
+	// do not fit in one are split across several.
+	MaxMessageSize int `json:"maxMessageSize"`
+}
+
+func NewDefaultEventsConfig() EventsConfig {
+	return EventsConfig{
+		Enabled:            true,
+		MaxPendingMessages: pubsub.MaxPendingMessages,
+		MaxMessageSize:     pubsub.MaxWriteMessageSize,
+	}
+}
+
+// WithMarketEvents serves market events over a websocket at EventsEndpoint.
+func WithMarketEvents() vm.Option {
+	return vm.NewOption(EventsNamespace, NewDefaultEventsConfig(), func(v api.VM, config EventsConfig) (vm.Opt, error) {
+		if !config.Enabled {
+			return vm.NewOpt(), nil
+		}
+		server := NewMarketEventServer(v, v.Logger(), config.MaxPendingMessages, config.MaxMessageSize)
+		blockSubscription := event.SubscriptionFuncFactory[*chain.ExecutedBlock]{
+			NotifyF: server.AcceptBlock,
+		}
+		return vm.NewOpt(
+			vm.WithBlockSubscriptions(blockSubscription),
+			vm.WithVMAPIs(marketEventServerFactory{server: server}),
+		), nil
+	})
+}
+
+type marketEventServerFactory struct {
+	server *MarketEventServer
+}
+
+func (m marketEventServerFactory) New(api.VM) (api.Handler, error) {
+	return api.Handler{
+		Path:    EventsEndpoint,
+		Handler: m.server.Handler(),
+	}, nil
+}
+
+// SubscribeRequest changes the markets a connection receives events for.
+type SubscribeRequest struct {
+	Subscribe   []uint64 `json:"subscribe,omitempty"`
+	Unsubscribe []uint64 `json:"unsubscribe,omitempty"`
+}
+
+// MarketEventsMessage is sent in reply to every SubscribeRequest, listing the
+// markets the connection is subscribed to, and for every accepted block with
+// events for those markets.
+//
+// The events of a block too large for one message are split across
+// consecutive messages with the same Height.
+//
+// Sequence numbers the messages sent on a connection. Messages are dropped
+// when a subscriber falls behind by more than the server's pending message
+// limit, so a gap in Sequence means events were missed and the markets should
+// be re-read through the Market RPC. So does a message with Events replaced by
+// an Error, sent when the events could not be sent.
+type MarketEventsMessage struct {
+	Sequence   uint64         `json:"sequence"`
+	Height     uint64         `json:"height,omitempty"`
+	Timestamp  int64          `json:"timestamp,omitempty"`
+	Events     []*MarketEvent `json:"events,omitempty"`
+	Subscribed []uint64       `json:"subscribed,omitempty"`
+	Error      string         `json:"error,omitempty"`
+}
+
+// MarketEvent is something that happened to a market in an accepted block.
+// The field named by Type is set.
+type MarketEvent struct {
+	Type         string             `json:"type"`
+	MarketID     uint64             `json:"marketId"`
+	Trade        *TradeEvent        `json:"trade,omitempty"`
+	StatusChange *StatusChangeEvent `json:"statusChange,omitempty"`
+	Resolution   *ResolutionEvent   `json:"resolution,omitempty"`
+}
+
+// TradeEvent is a successful BuyYes or BuyNo.
+type TradeEvent struct {
+	TxID      ids.ID        `json:"txId"`
+	Trader    codec.Address `json:"trader"`
+	ShareType uint8         `json:"shareType"`
+	Shares    uint64        `json:"shares"`
+	Cost      uint64        `json:"cost"`
+}
+
+// StatusChangeEvent reports a market's status at the end of a block that
+// changed it. Changes undone within the same block are not reported.
+type StatusChangeEvent struct {
+	From storage.MarketStatus `json:"from"`
+	To   storage.MarketStatus `json:"to"`
+}
+
+// ResolutionEvent follows the StatusChangeEvent of a market that became
+// resolved, either by its first ruling or by a later one overturning a
+// dispute or appeal.
+type ResolutionEvent struct {
+	Status     storage.MarketStatus `json:"status"`
+	Outcome    storage.OutcomeType  `json:"outcome"`
+	Tier       uint8                `json:"tier"`
+	ResolvedAt int64                `json:"resolvedAt"`
+}
+
+// subscriber is a connection and the markets it is subscribed to.
+type subscriber struct {
+	markets  set.Set[uint64]
+	sequence uint64
+}
+
+// MarketEventServer publishes the events of accepted blocks to the
+// connections subscribed to their markets.
+type MarketEventServer struct {
+	vm             api.VM
+	log            logging.Logger
+	s              *pubsub.Server
+	maxMessageSize int
+
+	l           sync.Mutex
+	subscribers map[*pubsub.Connection]*subscriber
+	listeners   map[uint64]set.Set[*pubsub.Connection]
+	// statuses holds the last status seen of each market with listeners, to
+	// detect status changes.
+	statuses map[uint64]storage.MarketStatus
+}
+
+func NewMarketEventServer(vm api.VM, log logging.Logger, maxPendingMessages int, maxMessageSize int) *MarketEventServer {
+	m := &MarketEventServer{
+		vm:             vm,
+		log:            log,
+		maxMessageSize: maxMessageSize,
+		subscribers:    map[*pubsub.Connection]*subscriber{},
+		listeners:      map[uint64]set.Set[*pubsub.Connection]{},
+		statuses:       map[uint64]storage.MarketStatus{},
+	}
+	cfg := pubsub.NewDefaultServerConfig()
+	cfg.MaxPendingMessages = maxPendingMessages
+	cfg.MaxWriteMessageSize = maxMessageSize
+	m.s = pubsub.New(log, cfg, m.callback)
+	return m
+}
+
+// Handler returns the websocket handler of [m].
+func (m *MarketEventServer) Handler() *pubsub.Server {
+	return m.s
+}
+
+// callback applies a SubscribeRequest received on [c].
+func (m *MarketEventServer) callback(msg []byte, c *pubsub.Connection) {
+	ctx := context.TODO()
+	m.l.Lock()
+	defer m.l.Unlock()
+
+	sub, ok := m.subscribers[c]
+	if !ok {
+		sub = &subscriber{markets: set.Set[uint64]{}}
+		m.subscribers[c] = sub
+	}
+	reply := &MarketEventsMessage{}
+	var req SubscribeRequest
+	if err := json.Unmarshal(msg, &req); err != nil {
+		m.log.Debug("unable to parse market subscription", zap.Error(err))
+		reply.Error = err.Error()
+		m.send(c, reply)
+		return
+	}
+	for _, marketID := range req.Unsubscribe {
+		m.unsubscribe(c, sub, marketID)
+	}
+	for _, marketID := range req.Subscribe {
+		if sub.markets.Contains(marketID) {
+			continue
+		}
+		if sub.markets.Len() >= MaxMarketSubscriptions {
+			reply.Error = fmt.Errorf("%w: max %d", ErrTooManySubscriptions, MaxMarketSubscriptions).Error()
+			break
+		}
+		sub.markets.Add(marketID)
+		listeners, ok := m.listeners[marketID]
+		if !ok {
+			listeners = set.Set[*pubsub.Connection]{}
+			m.listeners[marketID] = listeners
+			// Markets subscribed to before they are created have no status
+			// yet; their first is recorded when a block touches them.
+			if market, err := storage.GetMarketFromState(ctx, m.vm.ReadState, marketID); err == nil {
+				m.statuses[marketID] = market.Status
+			}
+		}
+		listeners.Add(c)
+	}
+	reply.Subscribed = sub.markets.List()
+	slices.Sort(reply.Subscribed)
+	m.send(c, reply)
+}
+
+// unsubscribe removes [marketID] from the subscriptions of [c], forgetting
+// the market once it has no listeners left. Assumes [m.l] is held.
+func (m *MarketEventServer) unsubscribe(c *pubsub.Connection, sub *subscriber, marketID uint64) {
+	if !sub.markets.Contains(marketID) {
+		return
+	}
+	sub.markets.Remove(marketID)
+	listeners := m.listeners[marketID]
+	listeners.Remove(c)
+	if listeners.Len() == 0 {
+		delete(m.listeners, marketID)
+		delete(m.statuses, marketID)
+	}
+}
+
+// removeSubscriber drops every subscription of [c]. Assumes [m.l] is held.
+func (m *MarketEventServer) removeSubscriber(c *pubsub.Connection) {
+	sub := m.subscribers[c]
+	for marketID := range sub.markets {
+		m.unsubscribe(c, sub, marketID)
+	}
+	delete(m.subscribers, c)
+}
+
+// send numbers [msg] and sends it to [c]. A closed connection is dropped. An
+// open connection that [msg] could not be queued on is sent an error with the
+// same Sequence in its place, and keeps its subscriptions. Assumes [m.l] is
+// held.
+func (m *MarketEventServer) send(c *pubsub.Connection, msg *MarketEventsMessage) {
+	sub, ok := m.subscribers[c]
+	if ok {
+		sub.sequence++
+		msg.Sequence = sub.sequence
+	}
+	b, err := json.Marshal(msg)
+	if err != nil {
+		m.log.Error("unable to marshal market events", zap.Error(err))
+		return
+	}
+	if c.Send(b) {
+		return
+	}
+	// The server forgets a connection before it stops accepting messages,
+	// so a connection it still has refused [msg] itself.
+	if m.s.Connections().Has(c) {
+		m.log.Debug("unable to send market events", zap.Int("size", len(b)))
+		b, err = json.Marshal(&MarketEventsMessage{
+			Sequence:  msg.Sequence,
+			Height:    msg.Height,
+			Timestamp: msg.Timestamp,
+			Error:     fmt.Sprintf("unable to send %d bytes of market events", len(b)),
+		})
+		if err == nil && c.Send(b) {
+			return
+		}
+	}
+	m.log.Debug("dropping market event subscriber")
+	if ok {
+		m.removeSubscriber(c)
+	}
+}
+
+// AcceptBlock publishes the events of [blk] to the subscribers of their
+// markets. Market statuses are read from the state [blk] committed.
+func (m *MarketEventServer) AcceptBlock(ctx context.Context, blk *chain.ExecutedBlock) error {
+	m.l.Lock()
+	defer m.l.Unlock()
+
+	// Forget the connections the server closed since the last block.
+	for c := range m.subscribers {
+		if !m.s.Connections().Has(c) {
+			m.removeSubscriber(c)
+		}
+	}
+	if len(m.listeners) == 0 {
+		return nil
+	}
+
+	var (
+		events  = map[uint64][]*MarketEvent{}
+		touched = []uint64{}
+	)
+	for i, tx := range blk.Block.Txs {
+		if !blk.ExecutionResults.Results[i].Success {
+			continue
+		}
+		for _, action := range tx.Actions {
+			marketID, ok := marketOf(action)
+			if !ok {
+				continue
+			}
+			if _, ok := m.listeners[marketID]; !ok {
+				continue
+			}
+			if trade := tradeOf(action); trade != nil {
+				trade.TxID = tx.GetID()
+				trade.Trader = tx.Auth.Actor()
+				events[marketID] = append(events[marketID], &MarketEvent{
+					Type:     EventTrade,
+					MarketID: marketID,
+					Trade:    trade,
+				})
+			}
+			if !slices.Contains(touched, marketID) {
+				touched = append(touched, marketID)
+			}
+		}
+	}
+	for _, marketID := range touched {
+		market, err := storage.GetMarketFromState(ctx, m.vm.ReadState, marketID)
+		if err != nil {
+			// Swept markets are gone from state and have nothing left to
+			// report.
+			continue
+		}
+		from, known := m.statuses[marketID]
+		m.statuses[marketID] = market.Status
+		if !known || from == market.Status {
+			continue
+		}
+		events[marketID] = append(events[marketID], &MarketEvent{
+			Type:         EventStatusChange,
+			MarketID:     marketID,
+			StatusChange: &StatusChangeEvent{From: from, To: market.Status},
+		})
+		if market.Status.IsResolved() {
+			events[marketID] = append(events[marketID], &MarketEvent{
+				Type:     EventResolution,
+				MarketID: marketID,
+				Resolution: &ResolutionEvent{
+					Status:     market.Status,
+					Outcome:    market.ResolvedOutcome,
+					Tier:       market.Tier,
+					ResolvedAt: market.ResolvedAt,
+				},
+			})
+		}
+	}
+	if len(events) == 0 {
+		return nil
+	}
+
+	// Send each subscriber the events of all its markets, in the order they
+	// happened, in as few messages as fit them.
+	sizes := map[*MarketEvent]int{}
+	for _, marketEvents := range events {
+		for _, event := range marketEvents {
+			b, err := json.Marshal(event)
+			if err != nil {
+				m.log.Error("unable to marshal market event", zap.Error(err))
+				return nil
+			}
+			sizes[event] = len(b) + 1 // Separating comma
+		}
+	}
+	for c, sub := range m.subscribers {
+		var subEvents []*MarketEvent
+		for _, marketID := range touched {
+			if sub.markets.Contains(marketID) {
+				subEvents = append(subEvents, events[marketID]...)
+			}
+		}
+		for _, batch := range splitEvents(subEvents, sizes, m.maxMessageSize-messageOverhead) {
+			m.send(c, &MarketEventsMessage{
+				Height:    blk.Block.Hght,
+				Timestamp: blk.Block.Tmstmp,
+				Events:    batch,
+			})
+		}
+	}
+	return nil
+}
+
+// splitEvents splits [events] into consecutive batches of at most [maxSize]
+// bytes of events, as measured by [sizes]. An event larger than [maxSize] is
+// batched alone.
+func splitEvents(events []*MarketEvent, sizes map[*MarketEvent]int, maxSize int) [][]*MarketEvent {
+	var (
+		batches [][]*MarketEvent
+		start   int
+		size    int
+	)
+	for i, event := range events {
+		if i > start && size+sizes[event] > maxSize {
+			batches = append(batches, events[start:i])
+			start, size = i, 0
+		}
+		size += sizes[event]
+	}
+	if start < len(events) {
+		batches = append(batches, events[start:])
+	}
+	return batches
+}
+
+// marketOf returns the market [action] acts on.
+func marketOf(action chain.Action) (uint64, bool) {
+	switch a := action.(type) {
+	case *actions.BuyYes:
+		return a.MarketID, true
+	case *actions.BuyNo:
+		return a.MarketID, true
+	case *actions.ResolveMarket:
+		return a.MarketID, true
+	case *actions.ExpireMarket:
+		return a.MarketID, true
+	case *actions.DisputeMarket:
+		return a.MarketID, true
+	case *actions.AppealMarket:
+		return a.MarketID, true
+	case *actions.VoteAppeal:
+		return a.MarketID, true
+	case *actions.FinalizeAppeal:
+		return a.MarketID, true
+	case *actions.Claim:
+		return a.MarketID, true
+	case *actions.WithdrawBond:
+		return a.MarketID, true
+	case *actions.SweepMarket:
+		return a.MarketID, true
+	default:
+		return 0, false
+	}
+}
+
+// tradeOf returns the trade [action] makes, or nil if it is not a trade.
+func tradeOf(action chain.Action) *TradeEvent {
+	var trade *TradeEvent
+	var maxPrice uint64
+	switch a := action.(type) {
+	case *actions.BuyYes:
+		trade, maxPrice = &TradeEvent{ShareType: consts.YesShareType, Shares: a.Amount}, a.MaxPrice
+	case *actions.BuyNo:
+		trade, maxPrice = &TradeEvent{ShareType: consts.NoShareType, Shares: a.Amount}, a.MaxPrice
+	default:
+		return nil
+	}
+	cost, err := safemath.Mul(trade.Shares, maxPrice)
+	if err != nil {
+		// The purchase failed, so there was no trade.
+		return nil
+	}
+	trade.Cost = cost
+	return trade
+}
@@ -0,0 +1,127 @@
+// Copyright (C) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package vm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ava-labs/hypersdk/pubsub"
+	"github.com/gorilla/websocket"
+)
+
+const DefaultEventsHandshakeTimeout = 10 * time.Second
+
+// MarketEventsClient receives market events from a MarketEventServer.
+type MarketEventsClient struct {
+	conn *websocket.Conn
+
+	writeL sync.Mutex
+
+	pending     chan *MarketEventsMessage
+	readStopped chan struct{}
+	err         error
+}
+
+// NewMarketEventsClient dials the market events endpoint of the node at
+// [uri]. Up to [pending] received messages are buffered until Listen is
+// called; beyond that the client stops reading and the server drops
+// messages, which shows as a gap in Sequence.
+func NewMarketEventsClient(uri string, handshakeTimeout time.Duration, pending int) (*MarketEventsClient, error) {
+	uri = strings.ReplaceAll(uri, "http://", "ws://")
+	uri = strings.ReplaceAll(uri, "https://", "wss://")
+	if !strings.HasPrefix(uri, "ws") {
+		uri = "ws://" + uri
+	}
+	uri = strings.TrimSuffix(uri, "/")
+	uri += EventsEndpoint
+	dialer := &websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: handshakeTimeout,
+	}
+	conn, resp, err := dialer.Dial(uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	c := &MarketEventsClient{
+		conn:        conn,
+		pending:     make(chan *MarketEventsMessage, pending),
+		readStopped: make(chan struct{}),
+	}
+	go c.read()
+	return c, nil
+}
+
+func (c *MarketEventsClient) read() {
+	defer close(c.readStopped)
+	for {
+		_, batch, err := c.conn.ReadMessage()
+		if err != nil {
+			c.err = err
+			return
+		}
+		msgs, err := pubsub.ParseBatchMessage(batch)
+		if err != nil {
+			c.err = err
+			return
+		}
+		for _, msg := range msgs {
+			m := new(MarketEventsMessage)
+			if err := json.Unmarshal(msg, m); err != nil {
+				c.err = err
+				return
+			}
+			c.pending <- m
+		}
+	}
+}
+
+// Subscribe starts the events of [marketIDs]. The server confirms with a
+// message listing every market the client is subscribed to.
+func (c *MarketEventsClient) Subscribe(marketIDs ...uint64) error {
+	return c.send(&SubscribeRequest{Subscribe: marketIDs})
+}
+
+// Unsubscribe stops the events of [marketIDs].
+func (c *MarketEventsClient) Unsubscribe(marketIDs ...uint64) error {
+	return c.send(&SubscribeRequest{Unsubscribe: marketIDs})
+}
+
+func (c *MarketEventsClient) send(req *SubscribeRequest) error {
+	b, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	c.writeL.Lock()
+	defer c.writeL.Unlock()
+	return c.conn.WriteMessage(websocket.BinaryMessage, pubsub.CreateBatchMessage([][]byte{b}))
+}
+
+// Listen returns the next message from the server.
+func (c *MarketEventsClient) Listen(ctx context.Context) (*MarketEventsMessage, error) {
+	select {
+	case msg := <-c.pending:
+		return msg, nil
+	case <-c.readStopped:
+		// Hand out what was read before the connection closed.
+		select {
+		case msg := <-c.pending:
+			return msg, nil
+		default:
+			return nil, c.err
+		}
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Close closes the connection to the server.
+func (c *MarketEventsClient) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,226 @@
+// Copyright (C) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package vm
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ava-labs/avalanchego/database"
+	"github.com/ava-labs/hypersdk/api"
+	"github.com/ava-labs/hypersdk/chain"
+	"github.com/ava-labs/hypersdk/state"
+)
+
+// DefaultHistoryBlocks is the number of accepted blocks whose state the
+// history retains by default.
+const DefaultHistoryBlocks = 1024
+
+var (
+	ErrHistoryDisabled   = errors.New("state history is disabled on this node")
+	ErrHeightPruned      = errors.New("height pruned from state history")
+	ErrHeightNotAccepted = errors.New("height not accepted")
+)
+
+var _ state.Immutable = (*HistoricalState)(nil)
+
+// StateHistory records the values of the state keys each accepted block
+// touches, so that queries can be answered as of one of the last [blocks]
+// heights.
+//
+// The history is kept in memory and starts with the first block accepted
+// after the node starts. A key that no recorded block touched is read from the
+// current state. The value a key had before the first recorded block touching
+// it is not known, and is reported like a pruned height.
+type StateHistory struct {
+	vm     api.VM
+	blocks uint64
+
+	l       sync.RWMutex
+	started bool
+	first   uint64 // First height recorded
+	last    uint64 // Last height recorded
+	values  map[string][]historyValue
+	changed map[uint64][]string // Keys whose value changed at each height
+}
+
+// historyValue is the value of a key after the block at [height].
+type historyValue struct {
+	height uint64
+	value  []byte
+	exists bool
+}
+
+func NewStateHistory(vm api.VM, blocks uint64) *StateHistory {
+	return &StateHistory{
+		vm:      vm,
+		blocks:  blocks,
+		values:  map[string][]historyValue{},
+		changed: map[uint64][]string{},
+	}
+}
+
+// Accept records the values of the keys [blk] touched, as committed by [blk],
+// and prunes the heights that fall out of the history.
+func (s *StateHistory) Accept(ctx context.Context, blk *chain.ExecutedBlock) error {
+	keys := touchedKeys(blk)
+	values, errs := s.vm.ReadState(ctx, keys)
+
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	height := blk.Block.Hght
+	if !s.started {
+		s.started = true
+		s.first = height
+	}
+	s.last = height
+	for i, key := range keys {
+		v := historyValue{height: height}
+		switch {
+		case errs[i] == nil:
+			v.value = slices.Clone(values[i])
+			v.exists = true
+		case !errors.Is(errs[i], database.ErrNotFound):
+			return errs[i]
+		}
+		k := string(key)
+		entries := s.values[k]
+		if n := len(entries); n > 0 && entries[n-1].exists == v.exists && bytes.Equal(entries[n-1].value, v.value) {
+			continue
+		}
+		s.values[k] = append(entries, v)
+		s.changed[height] = append(s.changed[height], k)
+	}
+	s.prune()
+	return nil
+}
+
+// oldest returns the oldest height the history answers for. Assumes [s.l] is
+// held.
+func (s *StateHistory) oldest() uint64 {
+	if s.last+1 <= s.blocks {
+		return s.first
+	}
+	return max(s.first, s.last+1-s.blocks)
+}
+
+// prune drops the values no longer needed to answer for the retained
+// heights, keeping the value each key had at the oldest of them. Assumes
+// [s.l] is held.
+func (s *StateHistory) prune() {
+	oldest := s.oldest()
+	for height, keys := range s.changed {
+		if height >= oldest {
+			continue
+		}
+		for _, k := range keys {
+			entries := s.values[k]
+			i := sort.Search(len(entries), func(i int) bool { return entries[i].height > oldest })
+			if i > 1 {
+				s.values[k] = slices.Clone(entries[i-1:])
+			}
+		}
+		delete(s.changed, height)
+	}
+}
+
+// checkHeight returns an error unless the history answers for [height].
+// Assumes [s.l] is held.
+func (s *StateHistory) checkHeight(height uint64) error {
+	if !s.started || height > s.last {
+		return fmt.Errorf("%w: %d is past the last recorded height", ErrHeightNotAccepted, height)
+	}
+	if oldest := s.oldest(); height < oldest {
+		return fmt.Errorf("%w: %d, this node retains heights %d to %d", ErrHeightPruned, height, oldest, s.last)
+	}
+	return nil
+}
+
+// At returns the state as of [height].
+func (s *StateHistory) At(height uint64) (*HistoricalState, error) {
+	s.l.RLock()
+	defer s.l.RUnlock()
+
+	if err := s.checkHeight(height); err != nil {
+		return nil, err
+	}
+	return &HistoricalState{history: s, height: height}, nil
+}
+
+// recorded returns the value [key] had at [height], and false if no recorded
+// block touched it.
+func (s *StateHistory) recorded(key []byte, height uint64) ([]byte, bool, error) {
+	s.l.RLock()
+	defer s.l.RUnlock()
+
+	// The height may have been pruned since the HistoricalState was made.
+	if err := s.checkHeight(height); err != nil {
+		return nil, true, err
+	}
+	entries := s.values[string(key)]
+	if len(entries) == 0 {
+		return nil, false, nil
+	}
+	i := sort.Search(len(entries), func(i int) bool { return entries[i].height > height })
+	if i == 0 {
+		return nil, true, fmt.Errorf("%w: %d, the key's history on this node starts at height %d", ErrHeightPruned, height, entries[0].height)
+	}
+	if !entries[i-1].exists {
+		return nil, true, database.ErrNotFound
+	}
+	return entries[i-1].value, true, nil
+}
+
+// Keys returns the keys starting with [prefix] that a recorded block touched.
+// A key no recorded block touched has had the same value at every height the
+// history answers for.
+func (s *StateHistory) Keys(prefix []byte) [][]byte {
+	s.l.RLock()
+	defer s.l.RUnlock()
+
+	var keys [][]byte
+	for k := range s.values {
+		if strings.HasPrefix(k, string(prefix)) {
+			keys = append(keys, []byte(k))
+		}
+	}
+	return keys
+}
+
+// HistoricalState reads the state as of a past height.
+type HistoricalState struct {
+	history *StateHistory
+	height  uint64
+}
+
+func (h *HistoricalState) GetValue(ctx context.Context, key []byte) ([]byte, error) {
+	for {
+		if value, ok, err := h.history.recorded(key, h.height); ok {
+			return value, err
+		}
+		// No recorded block touched the key, so its value has not changed
+		// since. Read it, unless a block touching it was recorded meanwhile.
+		values, errs := h.history.vm.ReadState(ctx, [][]byte{key})
+		if _, ok, _ := h.history.recorded(key, h.height); !ok {
+			return values[0], errs[0]
+		}
+	}
+}
+
+// ReadState reads [keys] as of the height of [h].
+func (h *HistoricalState) ReadState(ctx context.Context, keys [][]byte) ([][]byte, []error) {
+	values := make([][]byte, len(keys))
+	errs := make([]error, len(keys))
+	for i, key := range keys {
+		values[i], errs[i] = h.GetValue(ctx, key)
+	}
+	return values, errs
+}
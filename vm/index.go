@@ -0,0 +1,278 @@
+// Copyright (C) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package vm
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+
+	"github.com/ava-labs/avalanchego/database"
+	"github.com/ava-labs/avalanchego/utils/set"
+	"github.com/ava-labs/hypersdk/api"
+	"github.com/ava-labs/hypersdk/chain"
+
+	"github.com/chokosabe/predictionvm/storage"
+)
+
+var (
+	ErrIndexDisabled   = errors.New("market index is disabled on this node")
+	ErrIndexIncomplete = errors.New("market index missed accepted blocks; rebuild it by resyncing the node from genesis")
+)
+
+// The keys below hold the metadata of a MarketIndex database. They cannot
+// collide with an index entry, which starts with storage.IndexPrefix.
+var (
+	// seededKey marks a database that holds the genesis entries.
+	seededKey = []byte("seeded")
+	// heightKey holds the height of the last block whose entries were copied.
+	heightKey = []byte("height")
+	// incompleteKey marks a database that missed the entries of a block.
+	incompleteKey = []byte("incomplete")
+)
+
+// MarketIndex lists the entries of the market indexes (see storage.IndexKey)
+// by prefix. The state cannot be iterated, so the index keeps a copy of every
+// entry in [db], updated with the entries each accepted block touches. The
+// entries of the genesis markets are copied the first time the index is used.
+//
+// The copy only follows the blocks accepted while it is enabled, so it must
+// be enabled from genesis. An index that missed blocks, because the node
+// state synced or enabled it later, is marked incomplete and List fails with
+// ErrIndexIncomplete rather than serve partial listings.
+type MarketIndex struct {
+	vm          api.VM
+	db          database.Database
+	genesisKeys [][]byte
+
+	l          sync.Mutex
+	seeded     bool
+	incomplete bool
+}
+
+// NewMarketIndex returns an index kept in [db]. [genesisKeys] are the entries
+// the genesis stores.
+func NewMarketIndex(vm api.VM, db database.Database, genesisKeys [][]byte) *MarketIndex {
+	return &MarketIndex{
+		vm:          vm,
+		db:          db,
+		genesisKeys: genesisKeys,
+	}
+}
+
+// Accept copies the index entries [blk] touched, as committed by [blk]. The
+// index is marked incomplete if a block was accepted since the last one it
+// copied, or since genesis for a new index, without being copied.
+func (m *MarketIndex) Accept(ctx context.Context, blk *chain.ExecutedBlock) error {
+	m.l.Lock()
+	defer m.l.Unlock()
+
+	if err := m.seed(ctx); err != nil {
+		return err
+	}
+	last, err := m.height()
+	if err != nil {
+		return err
+	}
+	height := blk.Block.Hght
+	if height > last+1 {
+		if err := m.markIncomplete(); err != nil {
+			return err
+		}
+	}
+	if err := m.sync(ctx, touchedKeys(blk)); err != nil {
+		return err
+	}
+	if height <= last {
+		return nil // Already copied
+	}
+	return m.db.Put(heightKey, binary.BigEndian.AppendUint64(nil, height))
+}
+
+// Sync copies the current value of the index entries among [keys]. Other
+// keys are ignored.
+func (m *MarketIndex) Sync(ctx context.Context, keys [][]byte) error {
+	m.l.Lock()
+	defer m.l.Unlock()
+
+	if err := m.seed(ctx); err != nil {
+		return err
+	}
+	return m.sync(ctx, keys)
+}
+
+// seed copies the genesis entries unless [m.db] already holds them. Assumes
+// [m.l] is held.
+func (m *MarketIndex) seed(ctx context.Context) error {
+	if m.seeded {
+		return nil
+	}
+	seeded, err := m.db.Has(seededKey)
+	if err != nil {
+		return err
+	}
+	if !seeded {
+		if err := m.sync(ctx, m.genesisKeys); err != nil {
+			return err
+		}
+		if err := m.db.Put(seededKey, nil); err != nil {
+			return err
+		}
+	}
+	m.seeded = true
+	return nil
+}
+
+// height returns the height of the last block whose entries were copied, or
+// zero if none was. Assumes [m.l] is held.
+func (m *MarketIndex) height() (uint64, error) {
+	value, err := m.db.Get(heightKey)
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+		return 0, nil
+	case err != nil:
+		return 0, err
+	case len(value) != 8:
+		return 0, fmt.Errorf("invalid market index height of %d bytes", len(value))
+	default:
+		return binary.BigEndian.Uint64(value), nil
+	}
+}
+
+// markIncomplete records that the index missed the entries of a block.
+// Assumes [m.l] is held.
+func (m *MarketIndex) markIncomplete() error {
+	m.incomplete = true
+	return m.db.Put(incompleteKey, nil)
+}
+
+// checkComplete returns ErrIndexIncomplete unless the index holds the entries
+// of every accepted block. Assumes [m.l] is held.
+func (m *MarketIndex) checkComplete(ctx context.Context) error {
+	if m.incomplete {
+		return ErrIndexIncomplete
+	}
+	incomplete, err := m.db.Has(incompleteKey)
+	if err != nil {
+		return err
+	}
+	if incomplete {
+		m.incomplete = true
+		return ErrIndexIncomplete
+	}
+	// Blocks accepted before the index was enabled are not caught by Accept
+	// until the next one is. The last accepted block is updated before its
+	// subscribers are notified, so the index may lag it by one block.
+	last, err := m.height()
+	if err != nil {
+		return err
+	}
+	blk, err := m.vm.LastAcceptedBlock(ctx)
+	if err != nil {
+		return err
+	}
+	if blk.Hght > last+1 {
+		if err := m.markIncomplete(); err != nil {
+			return err
+		}
+		return ErrIndexIncomplete
+	}
+	return nil
+}
+
+// sync copies the current value of the index entries among [keys]. Assumes
+// [m.l] is held.
+func (m *MarketIndex) sync(ctx context.Context, keys [][]byte) error {
+	var entries [][]byte
+	for _, key := range keys {
+		if len(key) > 0 && key[0] == storage.IndexPrefix {
+			entries = append(entries, key)
+		}
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	values, errs := m.vm.ReadState(ctx, entries)
+	batch := m.db.NewBatch()
+	for i, key := range entries {
+		var err error
+		switch {
+		case errs[i] == nil:
+			err = batch.Put(key, values[i])
+		case errors.Is(errs[i], database.ErrNotFound):
+			err = batch.Delete(key)
+		default:
+			err = errs[i]
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return batch.Write()
+}
+
+// List returns, in ascending order, the first [limit] distinct IDs greater
+// than [after] indexed under any of [prefixes]. A [limit] of zero lists them
+// all.
+func (m *MarketIndex) List(ctx context.Context, prefixes [][]byte, after uint64, limit int) ([]uint64, error) {
+	m.l.Lock()
+	err := m.seed(ctx)
+	if err == nil {
+		err = m.checkComplete(ctx)
+	}
+	m.l.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if after == math.MaxUint64 {
+		return nil, nil
+	}
+
+	var ids []uint64
+	for _, prefix := range prefixes {
+		it := m.db.NewIteratorWithStartAndPrefix(storage.IndexEntryKey(prefix, after+1), prefix)
+		for n := 0; (limit == 0 || n < limit) && it.Next(); {
+			if id, ok := storage.ParseIndexKey(prefix, it.Key()); ok {
+				ids = append(ids, id)
+				n++
+			}
+		}
+		err := it.Error()
+		it.Release()
+		if err != nil {
+			return nil, err
+		}
+	}
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+	if limit > 0 && len(ids) > limit {
+		ids = ids[:limit]
+	}
+	return ids, nil
+}
+
+// touchedKeys returns the keys the transactions of [blk] may have changed:
+// the state keys of their actions and the balances of their sponsors.
+func touchedKeys(blk *chain.ExecutedBlock) [][]byte {
+	keys := set.Set[string]{}
+	for _, tx := range blk.Block.Txs {
+		keys.Add(string(storage.BalanceKey(tx.Auth.Sponsor())))
+		for i, action := range tx.Actions {
+			for k := range action.StateKeys(tx.Auth.Actor(), chain.CreateActionID(tx.GetID(), uint8(i))) {
+				keys.Add(k)
+			}
+		}
+	}
+	sorted := keys.List()
+	slices.Sort(sorted)
+	touched := make([][]byte, len(sorted))
+	for i, k := range sorted {
+		touched[i] = []byte(k)
+	}
+	return touched
+}
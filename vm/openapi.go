@@ -0,0 +1,156 @@
+// Copyright (C) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package vm
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/chokosabe/predictionvm/consts"
+)
+
+// openAPIDocument returns the OpenAPI document of the REST gateway,
+// generated from restRoutes and the types of their replies.
+var openAPIDocument = sync.OnceValues(func() ([]byte, error) {
+	schemas := map[string]any{}
+	g := &schemaGenerator{schemas: schemas}
+	errorSchema := g.schema(reflect.TypeOf(RESTError{}))
+
+	paths := map[string]any{}
+	for _, route := range restRoutes {
+		params := make([]any, 0, len(route.params))
+		for _, param := range route.params {
+			params = append(params, map[string]any{
+				"name":        param.name,
+				"in":          param.in,
+				"required":    param.required,
+				"description": param.description,
+				"schema":      map[string]any{"type": param.kind},
+			})
+		}
+		paths[RESTEndpoint+route.path] = map[string]any{
+			"get": map[string]any{
+				"summary":    route.summary,
+				"parameters": params,
+				"responses": map[string]any{
+					"200":     jsonResponse("OK", g.schema(reflect.TypeOf(route.reply))),
+					"default": jsonResponse("Error", errorSchema),
+				},
+			},
+		}
+	}
+	return json.MarshalIndent(map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   consts.Name + " REST API",
+			"version": consts.Version.String(),
+		},
+		"paths":      paths,
+		"components": map[string]any{"schemas": schemas},
+	}, "", "  ")
+})
+
+func jsonResponse(description string, schema any) map[string]any {
+	return map[string]any{
+		"description": description,
+		"content": map[string]any{
+			"application/json": map[string]any{"schema": schema},
+		},
+	}
+}
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// schemaGenerator describes Go types as OpenAPI schemas the way
+// encoding/json encodes them. Named structs are added to schemas and
+// referenced.
+type schemaGenerator struct {
+	schemas map[string]any
+}
+
+func (g *schemaGenerator) schema(t reflect.Type) any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == reflect.TypeOf(json.RawMessage{}):
+		return map[string]any{}
+	case t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType):
+		return map[string]any{"type": "string"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint, reflect.Uint64:
+		return map[string]any{"type": "integer", "minimum": 0}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		ref := map[string]any{"$ref": "#/components/schemas/" + t.Name()}
+		if _, ok := g.schemas[t.Name()]; ok {
+			return ref
+		}
+		// Reserve the name before describing the fields in case they refer
+		// back to the struct.
+		g.schemas[t.Name()] = map[string]any{}
+		properties := map[string]any{}
+		g.properties(t, properties)
+		g.schemas[t.Name()] = map[string]any{"type": "object", "properties": properties}
+		return ref
+	default:
+		return map[string]any{}
+	}
+}
+
+// properties adds the JSON fields of struct [t] to [properties]. Embedded
+// structs are flattened as encoding/json does, their fields giving way to
+// those of [t].
+func (g *schemaGenerator) properties(t reflect.Type, properties map[string]any) {
+	var embedded []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			fieldType := field.Type
+			if fieldType.Kind() == reflect.Pointer {
+				fieldType = fieldType.Elem()
+			}
+			if fieldType.Kind() == reflect.Struct {
+				embedded = append(embedded, fieldType)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = g.schema(field.Type)
+	}
+	for _, fieldType := range embedded {
+		promoted := map[string]any{}
+		g.properties(fieldType, promoted)
+		for name, schema := range promoted {
+			if _, ok := properties[name]; !ok {
+				properties[name] = schema
+			}
+		}
+	}
+}
@@ -4,27 +4,80 @@
 package vm
 
 import (
+	"path/filepath"
+
+	"github.com/ava-labs/avalanchego/database/pebbledb"
 	"github.com/ava-labs/hypersdk/api"
+	"github.com/ava-labs/hypersdk/chain"
+	"github.com/ava-labs/hypersdk/event"
 	"github.com/ava-labs/hypersdk/vm"
+
+	"github.com/chokosabe/predictionvm/genesis"
 )
 
 const Namespace = "controller"
 
 type Config struct {
 	Enabled bool `json:"enabled"`
+	// REST serves the REST gateway at RESTEndpoint alongside the JSON-RPC
+	// API.
+	REST bool `json:"rest"`
+	// HistoryBlocks is the number of accepted blocks whose state is kept to
+	// answer queries at past heights. Zero disables the history.
+	HistoryBlocks uint64 `json:"historyBlocks"`
+	// Index keeps the market index that lists markets and positions. It must
+	// be enabled from genesis: an index that missed accepted blocks fails
+	// with ErrIndexIncomplete.
+	Index bool `json:"index"`
 }
 
 func NewDefaultConfig() Config {
 	return Config{
-		Enabled: true,
+		Enabled:       true,
+		HistoryBlocks: DefaultHistoryBlocks,
+		Index:         true,
 	}
 }
 
 func With() vm.Option {
-	return vm.NewOption(Namespace, NewDefaultConfig(), func(_ api.VM, config Config) (vm.Opt, error) {
+	return vm.NewOption(Namespace, NewDefaultConfig(), func(v api.VM, config Config) (vm.Opt, error) {
 		if !config.Enabled {
 			return vm.NewOpt(), nil
 		}
-		return vm.WithVMAPIs(jsonRPCServerFactory{}), nil
+		var (
+			opts    []vm.Opt
+			history *StateHistory
+			index   *MarketIndex
+		)
+		if config.HistoryBlocks > 0 {
+			history = NewStateHistory(v, config.HistoryBlocks)
+			opts = append(opts, vm.WithBlockSubscriptions(event.SubscriptionFuncFactory[*chain.ExecutedBlock]{
+				NotifyF: history.Accept,
+			}))
+		}
+		if config.Index {
+			var genesisKeys [][]byte
+			if g, ok := v.Genesis().(*genesis.Genesis); ok {
+				keys, err := g.IndexKeys()
+				if err != nil {
+					return nil, err
+				}
+				genesisKeys = keys
+			}
+			db, err := pebbledb.New(filepath.Join(v.GetDataDir(), Namespace, "index"), nil, v.Logger(), nil)
+			if err != nil {
+				return nil, err
+			}
+			index = NewMarketIndex(v, db, genesisKeys)
+			opts = append(opts, vm.WithBlockSubscriptions(event.SubscriptionFuncFactory[*chain.ExecutedBlock]{
+				NotifyF: index.Accept,
+				Closer:  db.Close,
+			}))
+		}
+		opts = append(opts, vm.WithVMAPIs(jsonRPCServerFactory{history: history, index: index}))
+		if config.REST {
+			opts = append(opts, vm.WithVMAPIs(restServerFactories(index)...))
+		}
+		return vm.NewOpt(opts...), nil
 	})
 }
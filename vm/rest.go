@@ -0,0 +1,361 @@
+// Copyright (C) 2024, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package vm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	safemath "github.com/ava-labs/avalanchego/utils/math"
+	"github.com/ava-labs/hypersdk/api"
+	"github.com/ava-labs/hypersdk/codec"
+
+	"github.com/chokosabe/predictionvm/actions"
+	"github.com/chokosabe/predictionvm/storage"
+)
+
+const (
+	// RESTEndpoint prefixes the routes of the REST gateway.
+	RESTEndpoint = "/rest"
+	// OpenAPIPath serves the OpenAPI document of the REST gateway.
+	OpenAPIPath = RESTEndpoint + "/openapi.json"
+)
+
+var ErrInvalidParameter = errors.New("invalid parameter")
+
+// restParam is a path or query parameter of a REST route.
+type restParam struct {
+	name        string
+	in          string // "path" or "query"
+	kind        string // OpenAPI type: "integer" or "string"
+	required    bool
+	description string
+}
+
+// restRoute serves a GET route of the REST gateway from the JSONRPCServer
+// method of the same query.
+type restRoute struct {
+	path    string // Relative to RESTEndpoint; {name} segments are path parameters
+	summary string
+	params  []restParam
+	reply   any // Zero value of the reply, describing it in the OpenAPI document
+	serve   func(j *JSONRPCServer, req *http.Request, p restValues) (any, error)
+}
+
+var restRoutes = []*restRoute{
+	{
+		path:    "/markets",
+		summary: "List markets, filtered and paged like the listMarkets RPC.",
+		params: []restParam{
+			{name: "status", in: "query", kind: "integer", description: "Only markets with this status."},
+			{name: "creator", in: "query", kind: "string", description: "Only markets created by this address."},
+			{name: "endTimeFrom", in: "query", kind: "integer", description: "Only markets ending at or after this time; requires endTimeTo."},
+			{name: "endTimeTo", in: "query", kind: "integer", description: "Only markets ending before this time; requires endTimeFrom."},
+			{name: "after", in: "query", kind: "integer", description: "Only markets with a greater ID; the next of the previous page."},
+			{name: "limit", in: "query", kind: "integer", description: "Page size."},
+		},
+		reply: ListMarketsReply{},
+		serve: func(j *JSONRPCServer, req *http.Request, p restValues) (any, error) {
+			args := &ListMarketsArgs{}
+			if p.has("status") {
+				status, err := p.uint("status", 8)
+				if err != nil {
+					return nil, err
+				}
+				s := storage.MarketStatus(status)
+				args.Status = &s
+			}
+			if p.has("creator") {
+				creator, err := p.address("creator")
+				if err != nil {
+					return nil, err
+				}
+				args.Creator = &creator
+			}
+			var err error
+			if args.EndTimeFrom, err = p.int("endTimeFrom"); err != nil {
+				return nil, err
+			}
+			if args.EndTimeTo, err = p.int("endTimeTo"); err != nil {
+				return nil, err
+			}
+			if args.After, err = p.uint("after", 64); err != nil {
+				return nil, err
+			}
+			limit, err := p.int("limit")
+			if err != nil {
+				return nil, err
+			}
+			args.Limit = int(limit)
+			reply := &ListMarketsReply{}
+			return reply, j.ListMarkets(req, args, reply)
+		},
+	},
+	{
+		path:    "/markets/{id}",
+		summary: "Get a market, like the market RPC.",
+		params: []restParam{
+			{name: "id", in: "path", kind: "integer", required: true, description: "Market ID."},
+		},
+		reply: MarketReply{},
+		serve: func(j *JSONRPCServer, req *http.Request, p restValues) (any, error) {
+			marketID, err := p.uint("id", 64)
+			if err != nil {
+				return nil, err
+			}
+			reply := &MarketReply{}
+			return reply, j.Market(req, &MarketArgs{MarketID: marketID}, reply)
+		},
+	},
+	{
+		path:    "/accounts/{address}/positions",
+		summary: "List the share balances of an address, like the positions RPC.",
+		params: []restParam{
+			{name: "address", in: "path", kind: "string", required: true, description: "Account address."},
+		},
+		reply: PositionsReply{},
+		serve: func(j *JSONRPCServer, req *http.Request, p restValues) (any, error) {
+			addr, err := p.address("address")
+			if err != nil {
+				return nil, err
+			}
+			reply := &PositionsReply{}
+			return reply, j.Positions(req, &PositionsArgs{Address: addr}, reply)
+		},
+	},
+	{
+		path:    "/quote",
+		summary: "Price a purchase of shares, like the quote RPC.",
+		params: []restParam{
+			{name: "marketId", in: "query", kind: "integer", required: true, description: "Market ID."},
+			{name: "shareType", in: "query", kind: "integer", required: true, description: "Share type: 0 for YES, 1 for NO."},
+			{name: "amount", in: "query", kind: "integer", required: true, description: "Shares to buy."},
+			{name: "maxPrice", in: "query", kind: "integer", required: true, description: "Price paid per share."},
+		},
+		reply: QuoteReply{},
+		serve: func(j *JSONRPCServer, req *http.Request, p restValues) (any, error) {
+			args := &QuoteArgs{}
+			shareType, err := p.uint("shareType", 8)
+			if err != nil {
+				return nil, err
+			}
+			args.ShareType = uint8(shareType)
+			if args.MarketID, err = p.uint("marketId", 64); err != nil {
+				return nil, err
+			}
+			if args.Amount, err = p.uint("amount", 64); err != nil {
+				return nil, err
+			}
+			if args.MaxPrice, err = p.uint("maxPrice", 64); err != nil {
+				return nil, err
+			}
+			reply := &QuoteReply{}
+			return reply, j.Quote(req, args, reply)
+		},
+	},
+}
+
+// RESTError is the body of a failed REST request. Code is set when the error
+// has a stable code (see storage.ErrorCode).
+type RESTError struct {
+	Error string `json:"error"`
+	Code  string `json:"code,omitempty"`
+}
+
+// RESTServer serves the REST gateway.
+type RESTServer struct {
+	rpc *JSONRPCServer
+}
+
+func NewRESTServer(vm api.VM) *RESTServer {
+	return NewRESTServerWithIndex(vm, nil)
+}
+
+// NewRESTServerWithIndex returns a gateway that lists markets and positions
+// from [index].
+func NewRESTServerWithIndex(vm api.VM, index *MarketIndex) *RESTServer {
+	return &RESTServer{rpc: NewJSONRPCServerWithIndex(vm, nil, index)}
+}
+
+// ServeHTTP routes [req] by the end of its path, which the node prefixes
+// with the chain's base URL.
+func (s *RESTServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeREST(w, http.StatusMethodNotAllowed, &RESTError{Error: "method not allowed"})
+		return
+	}
+	segments := strings.Split(strings.TrimSuffix(req.URL.Path, "/"), "/")
+	if matchPath(segments, OpenAPIPath) != nil {
+		doc, err := openAPIDocument()
+		if err != nil {
+			writeREST(w, http.StatusInternalServerError, &RESTError{Error: err.Error()})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(doc)
+		return
+	}
+	for _, route := range restRoutes {
+		pathValues := matchPath(segments, RESTEndpoint+route.path)
+		if pathValues == nil {
+			continue
+		}
+		values := restValues{path: pathValues, query: req.URL.Query()}
+		reply, err := serveRoute(s.rpc, req, route, values)
+		if err != nil {
+			writeREST(w, restStatus(err), &RESTError{Error: err.Error(), Code: storage.ErrorCode(err)})
+			return
+		}
+		writeREST(w, http.StatusOK, reply)
+		return
+	}
+	writeREST(w, http.StatusNotFound, &RESTError{Error: "not found"})
+}
+
+func serveRoute(j *JSONRPCServer, req *http.Request, route *restRoute, values restValues) (any, error) {
+	for _, param := range route.params {
+		if param.required && !values.has(param.name) {
+			return nil, fmt.Errorf("%w %s: required", ErrInvalidParameter, param.name)
+		}
+	}
+	return route.serve(j, req, values)
+}
+
+// matchPath matches the last segments of a request path against [template],
+// returning its path parameters, or nil when they do not match.
+func matchPath(segments []string, template string) map[string]string {
+	parts := strings.Split(strings.TrimPrefix(template, "/"), "/")
+	if len(segments) < len(parts) {
+		return nil
+	}
+	segments = segments[len(segments)-len(parts):]
+	values := map[string]string{}
+	for i, part := range parts {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			if segments[i] == "" {
+				return nil
+			}
+			values[strings.Trim(part, "{}")] = segments[i]
+			continue
+		}
+		if segments[i] != part {
+			return nil
+		}
+	}
+	return values
+}
+
+// restStatus returns the HTTP status of a failed request.
+func restStatus(err error) int {
+	switch storage.ErrorCode(err) {
+	case storage.CodeMarketNotFound:
+		return http.StatusNotFound
+	case storage.CodeMarketClosed:
+		return http.StatusConflict
+	}
+	for _, invalid := range []error{
+		ErrInvalidParameter,
+		ErrInvalidShareType,
+		ErrInvalidEndTimeRange,
+		ErrEndTimeRangeTooWide,
+		actions.ErrAmountCannotBeZero,
+		actions.ErrMaxPriceCannotBeZero,
+		safemath.ErrOverflow,
+	} {
+		if errors.Is(err, invalid) {
+			return http.StatusBadRequest
+		}
+	}
+	return http.StatusInternalServerError
+}
+
+func writeREST(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// restValues are the path and query parameters of a request. Absent query
+// parameters read as zero.
+type restValues struct {
+	path  map[string]string
+	query map[string][]string
+}
+
+func (v restValues) get(name string) string {
+	if value, ok := v.path[name]; ok {
+		return value
+	}
+	if values := v.query[name]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func (v restValues) has(name string) bool {
+	return v.get(name) != ""
+}
+
+func (v restValues) uint(name string, bitSize int) (uint64, error) {
+	value := v.get(name)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseUint(value, 10, bitSize)
+	if err != nil {
+		return 0, fmt.Errorf("%w %s: %w", ErrInvalidParameter, name, err)
+	}
+	return n, nil
+}
+
+func (v restValues) int(name string) (int64, error) {
+	value := v.get(name)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w %s: %w", ErrInvalidParameter, name, err)
+	}
+	return n, nil
+}
+
+func (v restValues) address(name string) (codec.Address, error) {
+	addr, err := codec.StringToAddress(v.get(name))
+	if err != nil {
+		return codec.EmptyAddress, fmt.Errorf("%w %s: %w", ErrInvalidParameter, name, err)
+	}
+	return addr, nil
+}
+
+var _ api.HandlerFactory[api.VM] = (*restServerFactory)(nil)
+
+// restServerFactory registers the REST gateway at one of its paths. The node
+// matches handler paths exactly, so every route is registered on its own.
+type restServerFactory struct {
+	path  string
+	index *MarketIndex
+}
+
+func (r restServerFactory) New(vm api.VM) (api.Handler, error) {
+	return api.Handler{
+		Path:    r.path,
+		Handler: NewRESTServerWithIndex(vm, r.index),
+	}, nil
+}
+
+// restServerFactories returns the factories registering every path of the
+// REST gateway.
+func restServerFactories(index *MarketIndex) []api.HandlerFactory[api.VM] {
+	factories := []api.HandlerFactory[api.VM]{restServerFactory{path: OpenAPIPath, index: index}}
+	for _, route := range restRoutes {
+		factories = append(factories, restServerFactory{path: RESTEndpoint + route.path, index: index})
+	}
+	return factories
+}
@@ -4,23 +4,64 @@
 package vm
 
 import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
 	"net/http"
+	"slices"
 
+	"github.com/ava-labs/avalanchego/database"
+	safemath "github.com/ava-labs/avalanchego/utils/math"
 	"github.com/ava-labs/hypersdk/api"
 	"github.com/ava-labs/hypersdk/codec"
+	"github.com/chokosabe/predictionvm/actions"
 	"github.com/chokosabe/predictionvm/consts"
+	"github.com/chokosabe/predictionvm/genesis"
 	"github.com/chokosabe/predictionvm/storage"
-	"github.com/ava-labs/hypersdk/genesis"
 )
 
 const JSONRPCEndpoint = "/predictionapi"
 
+const (
+	// DefaultListMarketsLimit is the page size of ListMarkets when none is
+	// requested.
+	DefaultListMarketsLimit = 100
+	// MaxListMarketsLimit is the largest page ListMarkets returns.
+	MaxListMarketsLimit = 1000
+	// MaxListMarketsEndTimeBuckets caps the number of end time buckets a
+	// single ListMarkets query scans.
+	MaxListMarketsEndTimeBuckets = 31
+	// MaxBatchQueries caps the number of queries in one BatchQuery.
+	MaxBatchQueries = 1000
+)
+
+// Query types of BatchQuery.
+const (
+	QueryMarket       = "market"
+	QueryBalance      = "balance"
+	QueryShareBalance = "shareBalance"
+)
+
+var (
+	ErrInvalidEndTimeRange = errors.New("invalid end time range")
+	ErrEndTimeRangeTooWide = errors.New("end time range too wide")
+	ErrInvalidShareType    = errors.New("invalid share type")
+	ErrTooManyQueries      = errors.New("too many queries")
+	ErrUnknownQueryType    = errors.New("unknown query type")
+)
+
 var _ api.HandlerFactory[api.VM] = (*jsonRPCServerFactory)(nil)
 
-type jsonRPCServerFactory struct{}
+type jsonRPCServerFactory struct {
+	history *StateHistory
+	index   *MarketIndex
+}
 
-func (jsonRPCServerFactory) New(vm api.VM) (api.Handler, error) {
-	handler, err := api.NewJSONRPCHandler(consts.Name, NewJSONRPCServer(vm))
+func (f jsonRPCServerFactory) New(vm api.VM) (api.Handler, error) {
+	handler, err := api.NewJSONRPCHandler(consts.Name, NewJSONRPCServerWithIndex(vm, f.history, f.index))
 	return api.Handler{
 		Path:    JSONRPCEndpoint,
 		Handler: handler,
@@ -28,24 +69,62 @@ func (jsonRPCServerFactory) New(vm api.VM) (api.Handler, error) {
 }
 
 type JSONRPCServer struct {
-	vm api.VM
+	vm      api.VM
+	history *StateHistory
+	index   *MarketIndex
 }
 
 func NewJSONRPCServer(vm api.VM) *JSONRPCServer {
-	return &JSONRPCServer{vm: vm}
+	return NewJSONRPCServerWithHistory(vm, nil)
+}
+
+// NewJSONRPCServerWithHistory returns a server answering queries at past
+// heights from [history]. Without a history, such queries fail with
+// ErrHistoryDisabled.
+func NewJSONRPCServerWithHistory(vm api.VM, history *StateHistory) *JSONRPCServer {
+	return NewJSONRPCServerWithIndex(vm, history, nil)
+}
+
+// NewJSONRPCServerWithIndex returns a server that also lists markets and
+// positions from [index]. Without an index, such queries fail with
+// ErrIndexDisabled.
+func NewJSONRPCServerWithIndex(vm api.VM, history *StateHistory, index *MarketIndex) *JSONRPCServer {
+	return &JSONRPCServer{vm: vm, history: history, index: index}
+}
+
+// readState returns how to read the state as of [height], or as of the last
+// accepted block when [height] is nil.
+func (j *JSONRPCServer) readState(height *uint64) (storage.ReadState, error) {
+	if height == nil {
+		return j.vm.ReadState, nil
+	}
+	past, err := j.pastState(*height)
+	if err != nil {
+		return nil, err
+	}
+	return past.ReadState, nil
+}
+
+func (j *JSONRPCServer) pastState(height uint64) (*HistoricalState, error) {
+	if j.history == nil {
+		return nil, ErrHistoryDisabled
+	}
+	return j.history.At(height)
 }
 
 type GenesisReply struct {
-	Genesis *genesis.DefaultGenesis `json:"genesis"`
+	Genesis *genesis.Genesis `json:"genesis"`
 }
 
 func (j *JSONRPCServer) Genesis(_ *http.Request, _ *struct{}, reply *GenesisReply) (err error) {
-	reply.Genesis = j.vm.Genesis().(*genesis.DefaultGenesis)
+	reply.Genesis = j.vm.Genesis().(*genesis.Genesis)
 	return nil
 }
 
 type BalanceArgs struct {
 	Address codec.Address `json:"address"`
+	// Height, when set, reads the balance as of that height.
+	Height *uint64 `json:"height,omitempty"`
 }
 
 type BalanceReply struct {
@@ -56,10 +135,724 @@ func (j *JSONRPCServer) Balance(req *http.Request, args *BalanceArgs, reply *Bal
 	ctx, span := j.vm.Tracer().Start(req.Context(), "Server.Balance")
 	defer span.End()
 
-	balance, err := storage.GetBalanceFromState(ctx, j.vm.ReadState, args.Address)
+	read, err := j.readState(args.Height)
+	if err != nil {
+		return err
+	}
+	balance, err := storage.GetBalanceFromState(ctx, read, args.Address)
 	if err != nil {
 		return err
 	}
 	reply.Amount = balance
 	return err
 }
+
+type FeedObservationArgs struct {
+	Publisher codec.Address `json:"publisher"`
+	FeedID    uint64        `json:"feedId"`
+	Time      int64         `json:"time"`
+	// Height, when set, reads the feed as of that height.
+	Height *uint64 `json:"height,omitempty"`
+}
+
+type FeedObservationReply struct {
+	Sequence    uint64                    `json:"sequence"`
+	Capacity    uint64                    `json:"capacity"`
+	Observation *storage.PriceObservation `json:"observation"`
+}
+
+// FeedObservation returns the observation of a feed in effect at a time, its
+// sequence number and the chain's feed capacity, which ResolveMarket takes as
+// hints.
+func (j *JSONRPCServer) FeedObservation(req *http.Request, args *FeedObservationArgs, reply *FeedObservationReply) error {
+	ctx, span := j.vm.Tracer().Start(req.Context(), "Server.FeedObservation")
+	defer span.End()
+
+	read, err := j.readState(args.Height)
+	if err != nil {
+		return err
+	}
+	blk, err := j.vm.LastAcceptedBlock(ctx)
+	if err != nil {
+		return err
+	}
+	capacity := actions.FeedCapacity(j.vm.GetRuleFactory().GetRules(blk.GetTimestamp()))
+	seq, err := storage.FindObservationAt(ctx, read, args.Publisher, args.FeedID, capacity, args.Time)
+	if err != nil {
+		return err
+	}
+	obs, err := storage.GetFeedObservationFromState(ctx, read, args.Publisher, args.FeedID, seq, capacity)
+	if err != nil {
+		return err
+	}
+	reply.Sequence = seq
+	reply.Capacity = capacity
+	reply.Observation = obs
+	return nil
+}
+
+// ListMarketsArgs filters and pages ListMarkets. All filters are optional and
+// combine with AND. Markets are returned in ascending ID order, starting after
+// the ID in After.
+
+// MarketInfo is a market as returned by the API. Its OracleParameters are
+// decoded into the typed parameters of the market's oracle; when they cannot
+// be decoded, OracleParametersError explains why and the raw bytes are
+// returned in RawOracleParameters.
+type MarketInfo struct {
+	*storage.Market
+	OracleParameters      json.RawMessage `json:"oracleParameters"`
+	RawOracleParameters   []byte          `json:"rawOracleParameters,omitempty"`
+	OracleParametersError string          `json:"oracleParametersError,omitempty"`
+}
+
+// NewMarketInfo decodes the oracle parameters of [market] for the API.
+func NewMarketInfo(market *storage.Market) (*MarketInfo, error) {
+	info := &MarketInfo{Market: market}
+	params, err := storage.DecodeOracleParameters(market.OracleType, market.OracleParameters)
+	if err != nil {
+		info.RawOracleParameters = market.OracleParameters
+		info.OracleParametersError = err.Error()
+		return info, nil
+	}
+	info.OracleParameters, err = json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+type MarketArgs struct {
+	MarketID uint64 `json:"marketId"`
+	// Height, when set, reads the market as of that height.
+	Height *uint64 `json:"height,omitempty"`
+}
+
+type MarketReply struct {
+	Market *MarketInfo `json:"market"`
+}
+
+// Market returns a market by its ID. Markets swept from state are reported
+// as not found; MarketSummary returns what remains of them.
+func (j *JSONRPCServer) Market(req *http.Request, args *MarketArgs, reply *MarketReply) error {
+	ctx, span := j.vm.Tracer().Start(req.Context(), "Server.Market")
+	defer span.End()
+
+	read, err := j.readState(args.Height)
+	if err != nil {
+		return err
+	}
+	market, err := storage.GetMarketFromState(ctx, read, args.MarketID)
+	if err != nil {
+		return err
+	}
+	reply.Market, err = NewMarketInfo(market)
+	return err
+}
+
+type ExpiredMarketsReply struct {
+	Timestamp int64         `json:"timestamp"`
+	Markets   []*MarketInfo `json:"markets"`
+}
+
+// ExpiredMarkets lists the unresolved markets whose resolution deadline had
+// passed as of the last accepted block. Each of them can be expired as Invalid.
+func (j *JSONRPCServer) ExpiredMarkets(req *http.Request, _ *struct{}, reply *ExpiredMarketsReply) error {
+	ctx, span := j.vm.Tracer().Start(req.Context(), "Server.ExpiredMarkets")
+	defer span.End()
+
+	blk, err := j.vm.LastAcceptedBlock(ctx)
+	if err != nil {
+		return err
+	}
+	now := blk.GetTimestamp()
+	gracePeriod := actions.ResolutionGracePeriod(j.vm.GetRuleFactory().GetRules(now))
+
+	if j.index == nil {
+		return ErrIndexDisabled
+	}
+	marketIDs, err := j.index.List(ctx, [][]byte{storage.IndexKeyPrefix(storage.PendingResolutionIndex)}, 0, 0)
+	if err != nil {
+		return err
+	}
+	reply.Timestamp = now
+	reply.Markets = []*MarketInfo{}
+	for _, marketID := range marketIDs {
+		market, err := storage.GetMarketFromState(ctx, j.vm.ReadState, marketID)
+		if err != nil {
+			return err
+		}
+		if now <= market.ResolutionDeadline(gracePeriod) {
+			continue
+		}
+		info, err := NewMarketInfo(market)
+		if err != nil {
+			return err
+		}
+		reply.Markets = append(reply.Markets, info)
+	}
+	return nil
+}
+
+type OracleStatsArgs struct {
+	Oracle codec.Address `json:"oracle"`
+}
+
+type OracleStatsReply struct {
+	Stats          *storage.OracleStats `json:"stats"`
+	AverageLatency uint64               `json:"averageLatency"`
+	Reputation     uint16               `json:"reputation"`
+}
+
+// OracleStats returns the track record of an oracle address.
+func (j *JSONRPCServer) OracleStats(req *http.Request, args *OracleStatsArgs, reply *OracleStatsReply) error {
+	ctx, span := j.vm.Tracer().Start(req.Context(), "Server.OracleStats")
+	defer span.End()
+
+	stats, err := storage.GetOracleStatsFromState(ctx, j.vm.ReadState, args.Oracle)
+	if err != nil {
+		return err
+	}
+	reply.Stats = stats
+	reply.AverageLatency = stats.AverageLatency()
+	reply.Reputation = stats.Reputation()
+	return nil
+}
+
+type MarketSummaryArgs struct {
+	MarketID uint64 `json:"marketId"`
+}
+
+type MarketSummaryReply struct {
+	Summary *storage.MarketSummary `json:"summary"`
+}
+
+// MarketSummary returns what remains of a market swept by SweepMarket.
+func (j *JSONRPCServer) MarketSummary(req *http.Request, args *MarketSummaryArgs, reply *MarketSummaryReply) error {
+	ctx, span := j.vm.Tracer().Start(req.Context(), "Server.MarketSummary")
+	defer span.End()
+
+	summary, err := storage.GetMarketSummaryFromState(ctx, j.vm.ReadState, args.MarketID)
+	if err != nil {
+		return err
+	}
+	reply.Summary = summary
+	return nil
+}
+
+type ListMarketsArgs struct {
+	// Status selects markets by their status as of the last accepted block,
+	// where Open markets whose trading period has ended are TradingClosed
+	// (see storage.Market.StatusAt).
+	Status  *storage.MarketStatus `json:"status,omitempty"`
+	Creator *codec.Address        `json:"creator,omitempty"`
+	// EndTimeFrom and EndTimeTo select markets with
+	// EndTimeFrom <= EndTime < EndTimeTo. Both must be set to filter by end
+	// time, and the range may span at most MaxListMarketsEndTimeBuckets days.
+	EndTimeFrom int64  `json:"endTimeFrom,omitempty"`
+	EndTimeTo   int64  `json:"endTimeTo,omitempty"`
+	After       uint64 `json:"after,omitempty"`
+	Limit       int    `json:"limit,omitempty"`
+}
+
+type ListMarketsReply struct {
+	Markets []*MarketInfo `json:"markets"`
+	// Next is the After of the following page, or zero on the last page.
+	Next uint64 `json:"next"`
+}
+
+// ListMarkets pages through the markets matching the filters in [args] using
+// the status, creator and end time indexes. Markets are reported with their
+// status as of the last accepted block. Indexed markets that cannot be read,
+// such as swept markets the index has yet to drop, are skipped.
+func (j *JSONRPCServer) ListMarkets(req *http.Request, args *ListMarketsArgs, reply *ListMarketsReply) error {
+	ctx, span := j.vm.Tracer().Start(req.Context(), "Server.ListMarkets")
+	defer span.End()
+
+	limit := args.Limit
+	if limit <= 0 {
+		limit = DefaultListMarketsLimit
+	}
+	limit = min(limit, MaxListMarketsLimit)
+	byEndTime := args.EndTimeFrom != 0 || args.EndTimeTo != 0
+	if byEndTime && args.EndTimeTo <= args.EndTimeFrom {
+		return fmt.Errorf("%w: [%d, %d)", ErrInvalidEndTimeRange, args.EndTimeFrom, args.EndTimeTo)
+	}
+
+	if j.index == nil {
+		return ErrIndexDisabled
+	}
+	blk, err := j.vm.LastAcceptedBlock(ctx)
+	if err != nil {
+		return err
+	}
+	now := blk.GetTimestamp()
+	// Scan the narrowest index that covers the query and check the remaining
+	// filters against the markets themselves.
+	var prefixes [][]byte
+	switch {
+	case args.Creator != nil:
+		prefixes = append(prefixes, storage.CreatorIndexPrefix(*args.Creator))
+	case byEndTime:
+		first, last := storage.EndTimeBucketOf(args.EndTimeFrom), storage.EndTimeBucketOf(args.EndTimeTo-1)
+		if last-first >= MaxListMarketsEndTimeBuckets {
+			return fmt.Errorf("%w: [%d, %d) spans %d buckets", ErrEndTimeRangeTooWide, args.EndTimeFrom, args.EndTimeTo, last-first+1)
+		}
+		for bucket := first; bucket <= last; bucket++ {
+			prefixes = append(prefixes, storage.EndTimeIndexPrefix(bucket))
+		}
+	default:
+		statuses := storage.MarketStatuses
+		if args.Status != nil {
+			statuses = []storage.MarketStatus{*args.Status}
+			if *args.Status == storage.MarketStatus_TradingClosed {
+				// Markets stay indexed as Open once trading ends.
+				statuses = append(statuses, storage.MarketStatus_Open)
+			}
+		}
+		for _, status := range statuses {
+			prefixes = append(prefixes, storage.IndexKeyPrefix(storage.StatusIndex(status)))
+		}
+	}
+
+	reply.Markets = []*MarketInfo{}
+	for after := args.After; ; {
+		marketIDs, err := j.index.List(ctx, prefixes, after, limit)
+		if err != nil {
+			return err
+		}
+		if len(marketIDs) == 0 {
+			return nil
+		}
+		for _, marketID := range marketIDs {
+			if len(reply.Markets) == limit {
+				reply.Next = reply.Markets[limit-1].ID
+				return nil
+			}
+			after = marketID
+			market, err := storage.GetMarketFromState(ctx, j.vm.ReadState, marketID)
+			if err != nil {
+				continue
+			}
+			market.Status = market.StatusAt(now)
+			if args.Status != nil && market.Status != *args.Status {
+				continue
+			}
+			if byEndTime && (market.EndTime < args.EndTimeFrom || market.EndTime >= args.EndTimeTo) {
+				continue
+			}
+			info, err := NewMarketInfo(market)
+			if err != nil {
+				return err
+			}
+			reply.Markets = append(reply.Markets, info)
+		}
+	}
+}
+
+type PositionsArgs struct {
+	Address codec.Address `json:"address"`
+	// Height, when set, lists the positions as of that height.
+	Height *uint64 `json:"height,omitempty"`
+}
+
+// Position is a non-zero share balance of an address.
+type Position struct {
+	MarketID     uint64               `json:"marketId"`
+	ShareType    uint8                `json:"shareType"`
+	Amount       uint64               `json:"amount"`
+	MarketStatus storage.MarketStatus `json:"marketStatus"`
+}
+
+type PositionsReply struct {
+	Positions []*Position `json:"positions"`
+}
+
+// Positions lists every share balance held by an address, ordered by market
+// and share type, using the address's position index.
+func (j *JSONRPCServer) Positions(req *http.Request, args *PositionsArgs, reply *PositionsReply) error {
+	ctx, span := j.vm.Tracer().Start(req.Context(), "Server.Positions")
+	defer span.End()
+
+	read, err := j.readState(args.Height)
+	if err != nil {
+		return err
+	}
+	reply.Positions = []*Position{}
+	for _, shareType := range []uint8{consts.YesShareType, consts.NoShareType} {
+		marketIDs, err := j.indexedIDs(ctx, storage.PositionIndexPrefix(args.Address, shareType), args.Height)
+		if err != nil {
+			return err
+		}
+		for _, marketID := range marketIDs {
+			amount, err := storage.GetShareBalanceFromState(ctx, read, marketID, args.Address, shareType)
+			if err != nil {
+				return err
+			}
+			market, err := storage.GetMarketFromState(ctx, read, marketID)
+			if err != nil {
+				return err
+			}
+			reply.Positions = append(reply.Positions, &Position{
+				MarketID:     marketID,
+				ShareType:    shareType,
+				Amount:       amount,
+				MarketStatus: market.Status,
+			})
+		}
+	}
+	slices.SortFunc(reply.Positions, func(a, b *Position) int {
+		if a.MarketID != b.MarketID {
+			return cmp.Compare(a.MarketID, b.MarketID)
+		}
+		return cmp.Compare(a.ShareType, b.ShareType)
+	})
+	return nil
+}
+
+type ShareBalanceArgs struct {
+	MarketID  uint64        `json:"marketId"`
+	Address   codec.Address `json:"address"`
+	ShareType uint8         `json:"shareType"`
+	// Height, when set, reads the shares as of that height.
+	Height *uint64 `json:"height,omitempty"`
+}
+
+type ShareBalanceReply struct {
+	Amount uint64 `json:"amount"`
+}
+
+// ShareBalance returns the shares of one type an address holds in a market.
+func (j *JSONRPCServer) ShareBalance(req *http.Request, args *ShareBalanceArgs, reply *ShareBalanceReply) error {
+	ctx, span := j.vm.Tracer().Start(req.Context(), "Server.ShareBalance")
+	defer span.End()
+
+	if args.ShareType != consts.YesShareType && args.ShareType != consts.NoShareType {
+		return fmt.Errorf("%w: %d", ErrInvalidShareType, args.ShareType)
+	}
+	read, err := j.readState(args.Height)
+	if err != nil {
+		return err
+	}
+	amount, err := storage.GetShareBalanceFromState(ctx, read, args.MarketID, args.Address, args.ShareType)
+	if err != nil {
+		return err
+	}
+	reply.Amount = amount
+	return nil
+}
+
+type PortfolioArgs struct {
+	Address codec.Address `json:"address"`
+}
+
+// Holding is the shares an address holds in one market and what they are
+// worth.
+//
+// Value marks the shares to market. Shares of a resolved market are worth
+// what Claim pays for them. Until then each side's implied probability is its
+// part of the outstanding shares, so every share is worth the market's
+// collateral per outstanding share.
+//
+// Claimable is what Claim would pay as of the portfolio's timestamp: Value,
+// less the claim fee, once the market's outcome is final, zero before.
+//
+// CostBasis is the collateral the address paid for its shares of the market.
+type Holding struct {
+	MarketID     uint64               `json:"marketId"`
+	MarketStatus storage.MarketStatus `json:"marketStatus"`
+	YesShares    uint64               `json:"yesShares"`
+	NoShares     uint64               `json:"noShares"`
+	CostBasis    uint64               `json:"costBasis"`
+	Value        uint64               `json:"value"`
+	Claimable    uint64               `json:"claimable"`
+}
+
+// PortfolioReply is an address's balance and holdings as of the last accepted
+// block.
+type PortfolioReply struct {
+	Timestamp      int64      `json:"timestamp"`
+	Balance        uint64     `json:"balance"`
+	Holdings       []*Holding `json:"holdings"`
+	TotalCostBasis uint64     `json:"totalCostBasis"`
+	TotalValue     uint64     `json:"totalValue"`
+	TotalClaimable uint64     `json:"totalClaimable"`
+}
+
+// Portfolio returns an address's balance and its holdings in every market it
+// has a position in, ordered by market.
+func (j *JSONRPCServer) Portfolio(req *http.Request, args *PortfolioArgs, reply *PortfolioReply) error {
+	ctx, span := j.vm.Tracer().Start(req.Context(), "Server.Portfolio")
+	defer span.End()
+
+	blk, err := j.vm.LastAcceptedBlock(ctx)
+	if err != nil {
+		return err
+	}
+	now := blk.GetTimestamp()
+	rules := j.vm.GetRuleFactory().GetRules(now)
+
+	balance, err := storage.GetBalanceFromState(ctx, j.vm.ReadState, args.Address)
+	if err != nil {
+		return err
+	}
+	var marketIDs []uint64
+	for _, shareType := range []uint8{consts.YesShareType, consts.NoShareType} {
+		ids, err := j.indexedIDs(ctx, storage.PositionIndexPrefix(args.Address, shareType), nil)
+		if err != nil {
+			return err
+		}
+		marketIDs = append(marketIDs, ids...)
+	}
+	slices.Sort(marketIDs)
+
+	reply.Timestamp = now
+	reply.Balance = balance
+	reply.Holdings = []*Holding{}
+	for _, marketID := range slices.Compact(marketIDs) {
+		market, err := storage.GetMarketFromState(ctx, j.vm.ReadState, marketID)
+		if err != nil {
+			return err
+		}
+		holding := &Holding{MarketID: marketID, MarketStatus: market.Status}
+		if holding.YesShares, err = storage.GetShareBalanceFromState(ctx, j.vm.ReadState, marketID, args.Address, consts.YesShareType); err != nil {
+			return err
+		}
+		if holding.NoShares, err = storage.GetShareBalanceFromState(ctx, j.vm.ReadState, marketID, args.Address, consts.NoShareType); err != nil {
+			return err
+		}
+		if holding.CostBasis, err = storage.GetPaidCollateralFromState(ctx, j.vm.ReadState, marketID, args.Address); err != nil {
+			return err
+		}
+		if market.Status.IsResolved() {
+			holding.Value = actions.ClaimPayout(market, holding.YesShares, holding.NoShares, holding.CostBasis)
+			if actions.IsFinal(rules, market, now) {
+				holding.Claimable = holding.Value - actions.Fee(holding.Value, actions.ClaimFeeRate(rules))
+			}
+		} else {
+			holding.Value = impliedValue(market, holding.YesShares+holding.NoShares)
+		}
+		reply.Holdings = append(reply.Holdings, holding)
+		reply.TotalCostBasis += holding.CostBasis
+		reply.TotalValue += holding.Value
+		reply.TotalClaimable += holding.Claimable
+	}
+	return nil
+}
+
+type QuoteArgs struct {
+	MarketID  uint64 `json:"marketId"`
+	ShareType uint8  `json:"shareType"`
+	Amount    uint64 `json:"amount"`
+	MaxPrice  uint64 `json:"maxPrice"`
+}
+
+// QuoteReply is what buying shares would cost as of the last accepted block.
+// Fee is the trading fee charged on top of Cost. Value marks the bought shares
+// to market right after the trade, the way Portfolio values holdings.
+type QuoteReply struct {
+	Timestamp int64  `json:"timestamp"`
+	Cost      uint64 `json:"cost"`
+	Fee       uint64 `json:"fee"`
+	Value     uint64 `json:"value"`
+}
+
+// Quote prices a BuyYes or BuyNo of [args.Amount] shares at [args.MaxPrice].
+// It fails with a MarketClosedError when the market does not accept trades.
+func (j *JSONRPCServer) Quote(req *http.Request, args *QuoteArgs, reply *QuoteReply) error {
+	ctx, span := j.vm.Tracer().Start(req.Context(), "Server.Quote")
+	defer span.End()
+
+	if args.ShareType != consts.YesShareType && args.ShareType != consts.NoShareType {
+		return fmt.Errorf("%w: %d", ErrInvalidShareType, args.ShareType)
+	}
+	if args.Amount == 0 {
+		return actions.ErrAmountCannotBeZero
+	}
+	if args.MaxPrice == 0 {
+		return actions.ErrMaxPriceCannotBeZero
+	}
+	cost, err := safemath.Mul(args.Amount, args.MaxPrice)
+	if err != nil {
+		return err
+	}
+	blk, err := j.vm.LastAcceptedBlock(ctx)
+	if err != nil {
+		return err
+	}
+	now := blk.GetTimestamp()
+	market, err := storage.GetMarketFromState(ctx, j.vm.ReadState, args.MarketID)
+	if err != nil {
+		return err
+	}
+	if err := market.State().CheckTrading(args.MarketID, now); err != nil {
+		return err
+	}
+
+	// Apply the trade to a copy of the market to value the bought shares.
+	market.Collateral += cost
+	if args.ShareType == consts.YesShareType {
+		market.TotalYesShares += args.Amount
+	} else {
+		market.TotalNoShares += args.Amount
+	}
+	reply.Timestamp = now
+	reply.Cost = cost
+	reply.Fee = actions.Fee(cost, actions.TradingFeeRate(j.vm.GetRuleFactory().GetRules(now)))
+	reply.Value = impliedValue(market, args.Amount)
+	return nil
+}
+
+// impliedValue returns the value of [shares] of unresolved [market]: its
+// collateral per outstanding share.
+func impliedValue(market *storage.Market, shares uint64) uint64 {
+	outstanding := new(big.Int).SetUint64(market.TotalYesShares)
+	outstanding.Add(outstanding, new(big.Int).SetUint64(market.TotalNoShares))
+	if outstanding.Sign() == 0 {
+		return 0
+	}
+	value := new(big.Int).SetUint64(market.Collateral)
+	value.Mul(value, new(big.Int).SetUint64(shares))
+	return value.Div(value, outstanding).Uint64()
+}
+
+// indexedIDs returns the sorted IDs indexed under [prefix] as of [height], or
+// as of the last accepted block when [height] is nil.
+func (j *JSONRPCServer) indexedIDs(ctx context.Context, prefix []byte, height *uint64) ([]uint64, error) {
+	if j.index == nil {
+		return nil, ErrIndexDisabled
+	}
+	ids, err := j.index.List(ctx, [][]byte{prefix}, 0, 0)
+	if err != nil || height == nil {
+		return ids, err
+	}
+
+	// The entries added or removed since [height] were touched by a block the
+	// history recorded. Keep the candidates that were indexed at [height].
+	past, err := j.pastState(*height)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range j.history.Keys(prefix) {
+		if id, ok := storage.ParseIndexKey(prefix, key); ok {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+	keys := make([][]byte, len(ids))
+	for i, id := range ids {
+		keys[i] = storage.IndexEntryKey(prefix, id)
+	}
+	_, errs := past.ReadState(ctx, keys)
+	indexed := ids[:0]
+	for i, id := range ids {
+		switch {
+		case errs[i] == nil:
+			indexed = append(indexed, id)
+		case !errors.Is(errs[i], database.ErrNotFound):
+			return nil, errs[i]
+		}
+	}
+	return indexed, nil
+}
+
+// Query is one item of a BatchQuery. Type selects the fields it reads:
+// QueryMarket reads MarketID, QueryBalance reads Address and
+// QueryShareBalance reads all three.
+type Query struct {
+	Type      string        `json:"type"`
+	MarketID  uint64        `json:"marketId,omitempty"`
+	Address   codec.Address `json:"address"`
+	ShareType uint8         `json:"shareType,omitempty"`
+}
+
+// QueryResult answers the Query at the same position. Market is set for
+// market queries and Amount for balance queries. A query that failed sets
+// Error instead, and Code when the error has a stable code (see
+// storage.ErrorCode).
+type QueryResult struct {
+	Market *MarketInfo `json:"market,omitempty"`
+	Amount uint64      `json:"amount"`
+	Error  string      `json:"error,omitempty"`
+	Code   string      `json:"code,omitempty"`
+}
+
+type BatchQueryArgs struct {
+	Queries []Query `json:"queries"`
+}
+
+type BatchQueryReply struct {
+	Results []*QueryResult `json:"results"`
+}
+
+// BatchQuery answers every query in [args] with a single ReadState call.
+// Results are in the order of the queries; a failing query reports its error
+// in its result without failing the others.
+func (j *JSONRPCServer) BatchQuery(req *http.Request, args *BatchQueryArgs, reply *BatchQueryReply) error {
+	ctx, span := j.vm.Tracer().Start(req.Context(), "Server.BatchQuery")
+	defer span.End()
+
+	if len(args.Queries) > MaxBatchQueries {
+		return fmt.Errorf("%w: %d > %d", ErrTooManyQueries, len(args.Queries), MaxBatchQueries)
+	}
+
+	// Gather the keys of every query, remembering where each query's keys
+	// start, and read them at once.
+	var (
+		keys    [][]byte
+		offsets = make([]int, len(args.Queries))
+		errs    = make([]error, len(args.Queries))
+	)
+	for i, q := range args.Queries {
+		offsets[i] = len(keys)
+		switch q.Type {
+		case QueryMarket:
+			keys = append(keys, storage.MarketKey(q.MarketID), storage.MarketStateKey(q.MarketID))
+		case QueryBalance:
+			keys = append(keys, storage.BalanceKey(q.Address))
+		case QueryShareBalance:
+			if q.ShareType != consts.YesShareType && q.ShareType != consts.NoShareType {
+				errs[i] = fmt.Errorf("%w: %d", ErrInvalidShareType, q.ShareType)
+				continue
+			}
+			keys = append(keys, storage.ShareBalanceKey(q.MarketID, q.Address, q.ShareType))
+		default:
+			errs[i] = fmt.Errorf("%w: %q", ErrUnknownQueryType, q.Type)
+		}
+	}
+	values, readErrs := j.vm.ReadState(ctx, keys)
+
+	reply.Results = make([]*QueryResult, len(args.Queries))
+	for i, q := range args.Queries {
+		result := &QueryResult{}
+		reply.Results[i] = result
+		if errs[i] != nil {
+			result.Error = errs[i].Error()
+			continue
+		}
+		// Serve the query's keys from the batch through the same helpers
+		// the single-item methods use.
+		offset := offsets[i]
+		read := func(_ context.Context, queryKeys [][]byte) ([][]byte, []error) {
+			return values[offset : offset+len(queryKeys)], readErrs[offset : offset+len(queryKeys)]
+		}
+		var err error
+		switch q.Type {
+		case QueryMarket:
+			var market *storage.Market
+			if market, err = storage.GetMarketFromState(ctx, read, q.MarketID); err == nil {
+				result.Market, err = NewMarketInfo(market)
+			}
+		case QueryBalance:
+			result.Amount, err = storage.GetBalanceFromState(ctx, read, q.Address)
+		case QueryShareBalance:
+			result.Amount, err = storage.GetShareBalanceFromState(ctx, read, q.MarketID, q.Address, q.ShareType)
+		}
+		if err != nil {
+			result.Error = err.Error()
+			result.Code = storage.ErrorCode(err)
+		}
+	}
+	return nil
+}
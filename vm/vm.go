@@ -9,13 +9,13 @@ import (
 	"github.com/ava-labs/hypersdk/auth"
 	"github.com/ava-labs/hypersdk/chain"
 	"github.com/ava-labs/hypersdk/codec"
-	"github.com/ava-labs/hypersdk/genesis" // Added hypersdk/genesis
 	"github.com/ava-labs/hypersdk/state/metadata"
 	"github.com/ava-labs/hypersdk/vm"
 	"github.com/ava-labs/hypersdk/vm/defaultvm"
 
 	"github.com/chokosabe/predictionvm/actions"    // Local actions
 	"github.com/chokosabe/predictionvm/controller" // Local controller
+	"github.com/chokosabe/predictionvm/genesis"
 )
 
 var (
@@ -41,17 +41,29 @@ func init() {
 
 	if err := errors.Join(
 		// PredictionVM Actions
+		ActionParser.Register(&actions.CreateMarket{}, actions.UnmarshalCreateMarket),
 		ActionParser.Register(&actions.BuyYes{}, actions.UnmarshalBuyYes),
-		// ActionParser.Register(&actions.BuyNo{}, nil),    // TODO: Implement BuyNo action and unmarshaler
-		// ActionParser.Register(&actions.Claim{}, nil),     // TODO: Implement Claim action and unmarshaler
-		// ActionParser.Register(&actions.Resolve{}, nil),   // TODO: Implement Resolve action and unmarshaler
+		ActionParser.Register(&actions.BuyNo{}, actions.UnmarshalBuyNo),
+		ActionParser.Register(&actions.ResolveMarket{}, actions.UnmarshalResolveMarket),
+		ActionParser.Register(&actions.PublishPrice{}, actions.UnmarshalPublishPrice),
+		ActionParser.Register(&actions.ExpireMarket{}, actions.UnmarshalExpireMarket),
+		ActionParser.Register(&actions.Claim{}, actions.UnmarshalClaim),
+		ActionParser.Register(&actions.DisputeMarket{}, actions.UnmarshalDisputeMarket),
+		ActionParser.Register(&actions.WithdrawBond{}, actions.UnmarshalWithdrawBond),
+		ActionParser.Register(&actions.Stake{}, actions.UnmarshalStake),
+		ActionParser.Register(&actions.Unstake{}, actions.UnmarshalUnstake),
+		ActionParser.Register(&actions.AppealMarket{}, actions.UnmarshalAppealMarket),
+		ActionParser.Register(&actions.VoteAppeal{}, actions.UnmarshalVoteAppeal),
+		ActionParser.Register(&actions.FinalizeAppeal{}, actions.UnmarshalFinalizeAppeal),
+		ActionParser.Register(&actions.SweepMarket{}, actions.UnmarshalSweepMarket),
 
 		// Standard Auth Types
 		AuthParser.Register(&auth.ED25519{}, auth.UnmarshalED25519),
 		AuthParser.Register(&auth.SECP256R1{}, auth.UnmarshalSECP256R1),
 		AuthParser.Register(&auth.BLS{}, auth.UnmarshalBLS),
 
-		// OutputParser.Register(...) // TODO: Register any custom outputs for predictionvm if needed
+		// PredictionVM Outputs
+		OutputParser.Register(&actions.CreateMarketResult{}, actions.UnmarshalCreateMarketResult),
 	); err != nil {
 		panic(err)
 	}
@@ -66,9 +78,9 @@ func New(options ...vm.Option) (*vm.VM, error) {
 }
 
 func NewFactory() *vm.Factory {
-	options := defaultvm.NewDefaultOptions() // Start with default options
+	options := append(defaultvm.NewDefaultOptions(), With(), WithMarketEvents()) // Start with default options
 	return vm.NewFactory(
-		&genesis.DefaultGenesisFactory{},
+		&genesis.Factory{},
 		controller.New(),
 		metadata.NewDefaultManager(),
 		ActionParser,